@@ -24,7 +24,7 @@ func main() {
 	}
 
 	// Initialize auth service
-	authService := auth.NewService(cfg.JWT.Secret, cfg.JWT.ExpiresIn)
+	authService := auth.NewService(auth.Config{Secret: cfg.JWT.Secret, ExpiresIn: cfg.JWT.ExpiresIn})
 
 	// Seed users
 	if err := seedUsers(db, authService); err != nil {
@@ -75,9 +75,6 @@ func seedUsers(db *gorm.DB, authService *auth.Service) error {
 			return fmt.Errorf("failed to hash password for %s: %w", u.Email, err)
 		}
 
-		// Generate API key
-		apiKey := generateAPIKey(u.Email)
-
 		// Create user
 		user := models.User{
 			Email:        u.Email,
@@ -87,7 +84,6 @@ func seedUsers(db *gorm.DB, authService *auth.Service) error {
 			Role:         u.Role,
 			Plan:         u.Plan,
 			IsActive:     true,
-			APIKey:       apiKey,
 			UsageResetAt: time.Now().AddDate(0, 1, 0),
 		}
 
@@ -100,7 +96,3 @@ func seedUsers(db *gorm.DB, authService *auth.Service) error {
 
 	return nil
 }
-
-func generateAPIKey(email string) string {
-	return fmt.Sprintf("sk_test_%s_%d", email[:5], time.Now().Unix())
-}
\ No newline at end of file
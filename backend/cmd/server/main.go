@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"web-openssl-backend/internal/config"
 	"web-openssl-backend/internal/handlers"
 	"web-openssl-backend/internal/middleware"
 	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/scheduler"
+	"web-openssl-backend/internal/services"
 	"web-openssl-backend/pkg/auth"
 	"web-openssl-backend/pkg/billing"
+	"web-openssl-backend/pkg/ct"
+	"web-openssl-backend/pkg/mail"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -44,6 +51,12 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Refuse to sign tokens with the published default secret; the example
+	// environment file's secret contains it too
+	if cfg.Server.Env == "production" && strings.Contains(cfg.JWT.Secret, config.DefaultJWTSecret) {
+		log.Fatal("JWT_SECRET must be changed from its default in production")
+	}
+
 	// Initialize database
 	db, err := initDB(cfg.Database.URL)
 	if err != nil {
@@ -56,15 +69,140 @@ func main() {
 	}
 
 	// Initialize services
-	authService := auth.NewService(cfg.JWT.Secret, cfg.JWT.ExpiresIn)
+	authService := auth.NewService(auth.Config{
+		Secret:               cfg.JWT.Secret,
+		ExpiresIn:            cfg.JWT.ExpiresIn,
+		Algorithm:            cfg.JWT.Algorithm,
+		SigningKeyFile:       cfg.JWT.SigningKeyFile,
+		VerificationKeyFiles: cfg.JWT.VerificationKeyFiles,
+	})
+	if err := authService.LoadKeys(); err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
 	billingService := billing.NewService(cfg.Stripe.SecretKey)
 
 	// Initialize handlers
 	h := handlers.NewHandler(db, cfg, authService, billingService)
 
+	// Open the master key that protects stored private keys
+	if err := h.VaultService.Load(); err != nil {
+		log.Fatalf("Invalid vault configuration: %v", err)
+	}
+
+	// Refuse to start without a way to send account email
+	if err := h.Mailer.Check(); err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+	// The log provider writes password reset links to the server log
+	if cfg.Server.Env == "production" && h.Mailer.Provider() == mail.ProviderLog {
+		log.Fatal("MAIL_PROVIDER must not be log in production")
+	}
+	if err := h.AccountService.Check(); err != nil {
+		log.Fatalf("Invalid account configuration: %v", err)
+	}
+	if err := h.WebAuthnService.Check(); err != nil {
+		log.Fatalf("Invalid WebAuthn configuration: %v", err)
+	}
+	if err := h.SecurityService.Check(); err != nil {
+		log.Fatalf("Invalid login configuration: %v", err)
+	}
+
+	// Refuse to start with a TSA certificate that cannot issue time stamps
+	if h.TSAService.Enabled() {
+		if err := h.TSAService.CheckSigner(); err != nil {
+			log.Fatalf("Invalid TSA configuration: %v", err)
+		}
+	}
+
+	// Load the CA that ACME orders are issued from
+	if h.ACMEService.Enabled() {
+		if err := h.ACMEService.LoadCA(); err != nil {
+			log.Fatalf("Invalid ACME configuration: %v", err)
+		}
+	}
+
+	// Create the built-in certificate profiles
+	if err := h.ProfileService.SeedDefaults(context.Background()); err != nil {
+		log.Fatalf("Failed to create default certificate profiles: %v", err)
+	}
+
+	// Jobs that were running when the server last stopped will not finish
+	if err := h.BatchService.FailInterrupted(context.Background()); err != nil {
+		log.Printf("Failed to mark interrupted batch jobs: %v", err)
+	}
+
+	// Load the CT logs embedded SCTs are verified against
+	if cfg.CT.LogListFile != "" {
+		logs, err := ct.LoadLogList(cfg.CT.LogListFile)
+		if err == nil {
+			err = h.CTService.AddLogs(logs)
+		}
+		if err != nil {
+			log.Fatalf("Failed to load CT log list: %v", err)
+		}
+		log.Printf("Loaded %d CT logs", h.CTService.LogCount())
+	}
+
+	// Start background jobs
+	sched := scheduler.New()
+	sched.Every(cfg.Expiry.CheckInterval, "certificate-expiry", func(ctx context.Context) error {
+		result, err := h.ExpiryService.CheckExpiring(ctx)
+		if err != nil {
+			return err
+		}
+		if result.Sent > 0 || result.Failed > 0 {
+			log.Printf("Expiry check: %d checked, %d notices sent, %d failed", result.Checked, result.Sent, result.Failed)
+		}
+		return nil
+	})
+	sched.Every(cfg.Monitoring.CheckInterval, "endpoint-scans", func(ctx context.Context) error {
+		result, err := h.MonitoringService.ScanDue(ctx)
+		if err != nil {
+			return err
+		}
+		if result.Scanned > 0 {
+			log.Printf("Endpoint scans: %d scanned, %d failed, %d alerts", result.Scanned, result.Failed, result.Alerts)
+		}
+		return nil
+	})
+	sched.Every(cfg.Renewal.CheckInterval, "certificate-renewal", func(ctx context.Context) error {
+		result, err := h.RenewalService.RenewDue(ctx)
+		if err != nil {
+			return err
+		}
+		if result.Renewed > 0 || result.Failed > 0 {
+			log.Printf("Certificate renewal: %d renewed, %d failed", result.Renewed, result.Failed)
+		}
+		return nil
+	})
+	sched.Every(time.Hour, "security-cleanup", func(ctx context.Context) error {
+		purged, err := h.SecurityService.Purge(ctx)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Security cleanup: %d stale login counters and events removed", purged)
+		}
+		return nil
+	})
+	sched.Start(context.Background())
+
 	// Setup router
 	router := setupRouter(cfg, h)
 
+	// CAs validate http-01 challenges on port 80, which the API may not use
+	if cfg.ACMEClient.HTTP01Address != "" {
+		solver := gin.New()
+		solver.Use(gin.Recovery())
+		solver.GET("/.well-known/acme-challenge/:token", h.ServeACMEChallenge)
+		go func() {
+			log.Printf("ACME http-01 responder listening on %s", cfg.ACMEClient.HTTP01Address)
+			if err := http.ListenAndServe(cfg.ACMEClient.HTTP01Address, solver); err != nil {
+				log.Fatalf("ACME http-01 responder failed to start: %v", err)
+			}
+		}()
+	}
+
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Server.Port)
 	log.Printf("Environment: %s", cfg.Server.Env)
@@ -85,12 +223,50 @@ func initDB(databaseURL string) (*gorm.DB, error) {
 }
 
 func runMigrations(db *gorm.DB) error {
+	// Users once had a single plaintext API key; scoped keys replaced it
+	if db.Migrator().HasColumn(&models.User{}, "api_key") {
+		if err := db.Migrator().DropColumn(&models.User{}, "api_key"); err != nil {
+			return fmt.Errorf("failed to drop users.api_key: %w", err)
+		}
+	}
+
 	return db.AutoMigrate(
 		&models.User{},
 		&models.Organization{},
 		&models.OrganizationMember{},
 		&models.Operation{},
 		&models.Subscription{},
+		&models.ACMEAccount{},
+		&models.ACMEServerAccount{},
+		&models.ACMEServerOrder{},
+		&models.ACMEServerAuthorization{},
+		&models.ACMEServerChallenge{},
+		&models.ACMEServerCertificate{},
+		&models.KeyPair{},
+		&models.Certificate{},
+		&models.ExpiryNotification{},
+		&models.MonitoredEndpoint{},
+		&models.EndpointScan{},
+		&models.RenewalPolicy{},
+		&models.CertificateRenewal{},
+		&models.DeploymentTarget{},
+		&models.Deployment{},
+		&models.CertificateProfile{},
+		&models.BatchJob{},
+		&models.PasswordResetToken{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.APIKey{},
+		&models.TOTPFactor{},
+		&models.RecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.SSOConnection{},
+		&models.SSOIdentity{},
+		&models.SSODomain{},
+		&models.SSOLoginState{},
+		&models.LoginThrottle{},
+		&models.SecurityEvent{},
 	)
 }
 
@@ -133,15 +309,63 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 			auth.POST("/register", h.Register)
 			auth.POST("/login", h.Login)
 			auth.POST("/refresh", h.RefreshToken)
+			auth.POST("/logout", h.Logout)
 			auth.POST("/forgot-password", h.ForgotPassword)
 			auth.POST("/reset-password", h.ResetPassword)
 			auth.POST("/verify-email", h.VerifyEmail)
+			auth.POST("/resend-verification", h.ResendVerification)
+			auth.POST("/unlock", h.UnlockAccount)
+			auth.POST("/mfa/verify", h.VerifyMFA)
+			auth.POST("/mfa/webauthn/options", h.BeginPasskeySecondFactor)
+			auth.POST("/mfa/webauthn/verify", h.FinishPasskeySecondFactor)
+			auth.POST("/webauthn/login/options", h.BeginPasskeyLogin)
+			auth.POST("/webauthn/login", h.FinishPasskeyLogin)
+			auth.GET("/sso/login", h.BeginSSOLogin)
+			auth.GET("/sso/oidc/callback", h.OIDCCallback)
+			auth.GET("/sso/saml/:id/metadata", h.SAMLMetadata)
+			auth.POST("/sso/saml/:id/acs", h.SAMLAssertionConsumer)
+			auth.POST("/sso/token", h.ExchangeSSOCode)
 		}
 
 		// Protected routes
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware(h.AuthService))
+		protected.Use(middleware.AuthMiddleware(h.AuthService), h.RequireVerifiedEmail(services.RestrictWrite), h.RequireMFA())
 		{
+			// Signing out of every session needs an access token
+			protected.POST("/auth/logout-all", h.LogoutAll)
+
+			// Two-factor authentication
+			mfa := protected.Group("/auth/mfa")
+			{
+				mfa.GET("", h.GetMFAStatus)
+				mfa.POST("/totp", h.BeginTOTPEnrollment)
+				mfa.POST("/totp/confirm", h.ConfirmTOTPEnrollment)
+				mfa.DELETE("/totp", h.DisableTOTP)
+				mfa.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+			}
+
+			// Passkeys
+			passkeys := protected.Group("/auth/webauthn")
+			{
+				passkeys.POST("/register/options", h.BeginPasskeyRegistration)
+				passkeys.POST("/register", h.FinishPasskeyRegistration)
+				passkeys.GET("/credentials", h.GetPasskeys)
+				passkeys.DELETE("/credentials/:id", h.DeletePasskey)
+			}
+
+			// Single sign-on
+			protected.POST("/auth/sso/link", h.LinkSSO)
+
+			// Organization policies
+			protected.PUT("/organizations/:id/mfa-policy", h.SetOrganizationMFAPolicy)
+			protected.GET("/organizations/:id/sso", h.GetSSOConnection)
+			protected.PUT("/organizations/:id/sso", h.SaveSSOConnection)
+			protected.DELETE("/organizations/:id/sso", h.DeleteSSOConnection)
+			protected.GET("/organizations/:id/sso/domains", h.ListSSODomains)
+			protected.POST("/organizations/:id/sso/domains", h.AddSSODomain)
+			protected.POST("/organizations/:id/sso/domains/:domainId/verify", h.VerifySSODomain)
+			protected.DELETE("/organizations/:id/sso/domains/:domainId", h.DeleteSSODomain)
+
 			// User routes
 			users := protected.Group("/users")
 			{
@@ -149,23 +373,41 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 				users.PUT("/me", h.UpdateCurrentUser)
 				users.DELETE("/me", h.DeleteCurrentUser)
 				users.POST("/api-key", h.GenerateAPIKey)
+				users.GET("/me/security-events", h.GetSecurityEvents)
+				users.GET("/me/sessions", h.GetSessions)
+				users.DELETE("/me/sessions", h.RevokeAllSessions)
+				users.DELETE("/me/sessions/:id", h.RevokeSession)
 			}
 
-			// OpenSSL routes
-			openssl := protected.Group("/openssl")
+			// API keys for the OpenSSL routes
+			apiKeys := protected.Group("/api-keys")
+			{
+				apiKeys.GET("/", h.GetAPIKeys)
+				apiKeys.POST("/", h.CreateAPIKey)
+				apiKeys.GET("/scopes", h.GetAPIKeyScopes)
+				apiKeys.DELETE("/:id", h.RevokeAPIKey)
+			}
+
+			// OpenSSL routes, which also take API keys
+			openssl := v1.Group("/openssl")
+			openssl.Use(middleware.TokenOrAPIKeyMiddleware(h.AuthService, h.APIKeyService), h.RequireVerifiedEmail(services.RestrictWrite), h.RequireMFA())
 			{
 				// Certificate operations
 				certs := openssl.Group("/certificates")
+				certs.Use(middleware.RequireScope(models.APIKeyScopeCertificates))
 				{
 					certs.POST("/generate", h.GenerateCertificate)
 					certs.POST("/csr", h.GenerateCSR)
+					certs.POST("/sign", h.SignCSR)
 					certs.POST("/parse", h.ParseCertificate)
+					certs.POST("/lint", h.LintCertificate)
 					certs.POST("/verify", h.VerifyCertificate)
 					certs.POST("/convert", h.ConvertCertificate)
 				}
 
 				// Key operations
 				keys := openssl.Group("/keys")
+				keys.Use(middleware.RequireScope(models.APIKeyScopeKeys))
 				{
 					keys.POST("/generate", h.GenerateKey)
 					keys.POST("/parse", h.ParseKey)
@@ -174,6 +416,7 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 
 				// Encryption operations
 				encrypt := openssl.Group("/encrypt")
+				encrypt.Use(middleware.RequireScope(models.APIKeyScopeEncrypt))
 				{
 					encrypt.POST("/symmetric", h.SymmetricEncrypt)
 					encrypt.POST("/asymmetric", h.AsymmetricEncrypt)
@@ -182,6 +425,7 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 
 				// Hash operations
 				hash := openssl.Group("/hash")
+				hash.Use(middleware.RequireScope(models.APIKeyScopeHash))
 				{
 					hash.POST("/generate", h.GenerateHash)
 					hash.POST("/verify", h.VerifyHash)
@@ -190,14 +434,79 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 
 				// SSL/TLS testing
 				ssl := openssl.Group("/ssl")
+				ssl.Use(middleware.RequireScope(models.APIKeyScopeSSL))
 				{
 					ssl.POST("/test-connection", h.TestSSLConnection)
 					ssl.POST("/analyze-certificate", h.AnalyzeSSLCertificate)
 				}
+
+				// OpenPGP operations
+				pgp := openssl.Group("/pgp")
+				pgp.Use(middleware.RequireScope(models.APIKeyScopePGP))
+				{
+					pgp.POST("/keys/generate", h.GeneratePGPKey)
+					pgp.POST("/sign", h.PGPSign)
+					pgp.POST("/verify", h.PGPVerify)
+					pgp.POST("/encrypt", h.PGPEncrypt)
+					pgp.POST("/decrypt", h.PGPDecrypt)
+				}
+
+				// CMS / S/MIME operations
+				cms := openssl.Group("/cms")
+				cms.Use(middleware.RequireScope(models.APIKeyScopeCMS))
+				{
+					cms.POST("/sign", h.CMSSign)
+					cms.POST("/verify", h.CMSVerify)
+					cms.POST("/encrypt", h.CMSEncrypt)
+					cms.POST("/decrypt", h.CMSDecrypt)
+					cms.POST("/smime/parse", h.ParseSMIME)
+				}
+
+				// RFC 3161 time stamping
+				timestamp := openssl.Group("/timestamp")
+				timestamp.Use(middleware.RequireScope(models.APIKeyScopeTimestamp))
+				{
+					timestamp.POST("/query", h.CreateTimestampQuery)
+					timestamp.POST("/verify", h.VerifyTimestamp)
+				}
+
+				// Certificate Transparency
+				transparency := openssl.Group("/ct")
+				transparency.Use(middleware.RequireScope(models.APIKeyScopeCT))
+				{
+					transparency.POST("/check", h.CheckCertificateTransparency)
+					transparency.POST("/link", h.LinkPrecertificate)
+				}
+
+				// ACME client for external CAs
+				acmeClient := openssl.Group("/acme")
+				acmeClient.Use(middleware.RequireScope(models.APIKeyScopeACME))
+				{
+					acmeClient.POST("/accounts", h.RegisterACMEAccount)
+					acmeClient.GET("/accounts", h.GetACMEAccounts)
+					acmeClient.POST("/certificates", h.ObtainACMECertificate)
+					acmeClient.GET("/certificates", h.GetACMECertificates)
+				}
+			}
+
+			// Certificate and key inventory
+			inventory := protected.Group("/inventory")
+			{
+				inventory.GET("/certificates", h.SearchCertificates)
+				inventory.POST("/certificates", h.ImportCertificate)
+				inventory.GET("/certificates/:id", h.GetInventoryCertificate)
+				inventory.DELETE("/certificates/:id", h.DeleteInventoryCertificate)
+				inventory.GET("/keys", h.GetKeyPairs)
+				inventory.POST("/keys", h.ImportKeyPair)
+				inventory.GET("/keys/:id", h.GetKeyPair)
+				inventory.PATCH("/keys/:id", h.UpdateKeyPair)
+				inventory.POST("/keys/:id/export", h.ExportKeyPair)
+				inventory.DELETE("/keys/:id", h.DeleteKeyPair)
 			}
 
 			// Billing routes
 			billing := protected.Group("/billing")
+			billing.Use(h.RequireVerifiedEmail(services.RestrictBilling))
 			{
 				billing.GET("/plans", h.GetPlans)
 				billing.POST("/subscribe", h.CreateSubscription)
@@ -207,6 +516,66 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 				billing.GET("/usage", h.GetUsage)
 			}
 
+			// Monitored TLS endpoints
+			monitoring := protected.Group("/monitoring")
+			{
+				monitoring.GET("/endpoints", h.GetMonitoredEndpoints)
+				monitoring.POST("/endpoints", h.CreateMonitoredEndpoint)
+				monitoring.GET("/endpoints/:id", h.GetMonitoredEndpoint)
+				monitoring.PATCH("/endpoints/:id", h.UpdateMonitoredEndpoint)
+				monitoring.DELETE("/endpoints/:id", h.DeleteMonitoredEndpoint)
+				monitoring.POST("/endpoints/:id/scan", h.ScanMonitoredEndpoint)
+				monitoring.GET("/endpoints/:id/scans", h.GetEndpointScans)
+				monitoring.GET("/endpoints/:id/scans/:scanId", h.GetEndpointScan)
+				monitoring.GET("/endpoints/:id/trend", h.GetEndpointGradeTrend)
+			}
+
+			// Automatic certificate renewal
+			renewal := protected.Group("/renewal")
+			{
+				renewal.GET("/policies", h.GetRenewalPolicies)
+				renewal.POST("/policies", h.CreateRenewalPolicy)
+				renewal.GET("/policies/:id", h.GetRenewalPolicy)
+				renewal.PATCH("/policies/:id", h.UpdateRenewalPolicy)
+				renewal.DELETE("/policies/:id", h.DeleteRenewalPolicy)
+				renewal.POST("/policies/:id/renew", h.RenewCertificateNow)
+				renewal.GET("/policies/:id/renewals", h.GetCertificateRenewals)
+			}
+
+			// Certificate deployment targets
+			deployments := protected.Group("/deployments")
+			{
+				deployments.GET("/types", h.GetDeploymentTargetTypes)
+				deployments.GET("/targets", h.GetDeploymentTargets)
+				deployments.POST("/targets", h.CreateDeploymentTarget)
+				deployments.GET("/targets/:id", h.GetDeploymentTarget)
+				deployments.PATCH("/targets/:id", h.UpdateDeploymentTarget)
+				deployments.DELETE("/targets/:id", h.DeleteDeploymentTarget)
+				deployments.POST("/targets/:id/deploy", h.DeployCertificate)
+				deployments.GET("/targets/:id/deployments", h.GetDeployments)
+			}
+
+			// Certificate profiles
+			profiles := protected.Group("/profiles")
+			{
+				profiles.GET("/", h.GetCertificateProfiles)
+				profiles.POST("/", h.CreateCertificateProfile)
+				profiles.GET("/:id", h.GetCertificateProfile)
+				profiles.PATCH("/:id", h.UpdateCertificateProfile)
+				profiles.DELETE("/:id", h.DeleteCertificateProfile)
+			}
+
+			// Batch operations
+			batch := protected.Group("/batch")
+			{
+				batch.POST("/", h.RunBatch)
+				batch.GET("/jobs", h.GetBatchJobs)
+				batch.GET("/jobs/:id", h.GetBatchJob)
+			}
+
+			// Expiry notices sent for the user's certificates
+			protected.GET("/expiry/notifications", h.GetExpiryNotifications)
+
 			// Operations history
 			operations := protected.Group("/operations")
 			{
@@ -218,10 +587,22 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 			// Admin routes
 			admin := protected.Group("/admin")
 			admin.Use(middleware.AdminMiddleware())
+			if cfg.Account.AdminRequirePasskey {
+				admin.Use(middleware.RequirePasskeyLogin())
+			}
 			{
 				admin.GET("/users", h.GetAllUsers)
 				admin.GET("/stats", h.GetStats)
 				admin.POST("/users/:id/plan", h.UpdateUserPlan)
+				admin.GET("/users/:id/sessions", h.GetUserSessions)
+				admin.DELETE("/users/:id/sessions", h.RevokeAllUserSessions)
+				admin.DELETE("/users/:id/sessions/:sessionId", h.RevokeUserSession)
+				admin.GET("/vault", h.GetVaultStatus)
+				admin.POST("/vault/rotate", h.RotateVaultMasterKey)
+				admin.POST("/vault/rewrap", h.RewrapVaultKeys)
+				admin.POST("/expiry/check", h.RunExpiryCheck)
+				admin.POST("/monitoring/scan", h.RunEndpointScans)
+				admin.POST("/renewal/run", h.RunCertificateRenewals)
 			}
 		}
 	}
@@ -229,6 +610,33 @@ func setupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 	// Stripe webhook (unprotected)
 	router.POST("/webhooks/stripe", h.HandleStripeWebhook)
 
+	// RFC 3161 time stamping authority (unprotected, TSA clients do not carry JWTs)
+	router.POST("/tsa", h.HandleTimestampQuery)
+
+	// Keys other services verify access tokens with
+	router.GET("/.well-known/jwks.json", h.JWKS)
+
+	// HTTP-01 responses for certificates ordered by the ACME client
+	router.GET("/.well-known/acme-challenge/:token", h.ServeACMEChallenge)
+
+	// ACME server (unprotected, clients authenticate with their account keys)
+	acmeServer := router.Group("/acme")
+	acmeServer.Use(h.ACMEHeaders)
+	{
+		acmeServer.GET("/directory", h.ACMEDirectory)
+		acmeServer.HEAD("/new-nonce", h.ACMENewNonce)
+		acmeServer.GET("/new-nonce", h.ACMENewNonce)
+		acmeServer.POST("/new-account", h.ACMENewAccount)
+		acmeServer.POST("/account/:id", h.ACMEAccount)
+		acmeServer.POST("/account/:id/orders", h.ACMEAccountOrders)
+		acmeServer.POST("/new-order", h.ACMENewOrder)
+		acmeServer.POST("/order/:id", h.ACMEOrder)
+		acmeServer.POST("/order/:id/finalize", h.ACMEFinalize)
+		acmeServer.POST("/authz/:id", h.ACMEAuthorization)
+		acmeServer.POST("/chall/:id", h.ACMEChallenge)
+		acmeServer.POST("/cert/:id", h.ACMECertificate)
+	}
+
 	// Swagger documentation
 	if cfg.Server.Env != "production" {
 		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -20,6 +20,22 @@ type Config struct {
 	RateLimit    RateLimitConfig
 	CORS         CORSConfig
 	FileUpload   FileUploadConfig
+	TSA          TSAConfig
+	CT           CTConfig
+	ACME         ACMEConfig
+	ACMEClient   ACMEClientConfig
+	Vault        VaultConfig
+	Notify       NotifyConfig
+	Expiry       ExpiryConfig
+	Monitoring   MonitoringConfig
+	Renewal      RenewalConfig
+	Deploy       DeployConfig
+	Batch        BatchConfig
+	Mail         MailConfig
+	Account      AccountConfig
+	Login        LoginConfig
+	WebAuthn     WebAuthnConfig
+	SSO          SSOConfig
 }
 
 type DatabaseConfig struct {
@@ -30,9 +46,22 @@ type RedisConfig struct {
 	URL string
 }
 
+// DefaultJWTSecret is the JWT secret used when none is configured. It is
+// public, so the server refuses to start with it in production.
+const DefaultJWTSecret = "change-this-in-production"
+
+// JWTConfig covers access tokens. Algorithm is HS256, which signs with
+// Secret, or RS256, ES256 or EdDSA, which sign with the private key in
+// SigningKeyFile. VerificationKeyFiles are further keys that are published
+// and accepted but not signed with, for rotating keys without rejecting
+// tokens in flight.
 type JWTConfig struct {
-	Secret    string
-	ExpiresIn time.Duration
+	Secret               string
+	ExpiresIn            time.Duration
+	RefreshExpiresIn     time.Duration
+	Algorithm            string
+	SigningKeyFile       string
+	VerificationKeyFiles []string
 }
 
 type StripeConfig struct {
@@ -63,6 +92,165 @@ type FileUploadConfig struct {
 	UploadDir   string
 }
 
+type CTConfig struct {
+	LogListFile string
+}
+
+type ACMEConfig struct {
+	BaseURL        string
+	CACertFile     string
+	CAKeyFile      string
+	ValidityDays   int
+	AllowedDomains []string
+}
+
+type ACMEClientConfig struct {
+	AllowedDirectories []string
+	// HTTP01Address is where http-01 challenge responses are served besides
+	// the API port, e.g. ":80" when the API does not answer on port 80
+	HTTP01Address       string
+	DNSWebhookURL       string
+	DNSWebhookSecret    string
+	DNSPropagationDelay time.Duration
+}
+
+// VaultConfig selects where the master key that wraps stored private keys
+// comes from: "config" (MasterKey and PreviousMasterKeys), "file" (a local
+// KMS stand-in in KeyDir) or "pkcs11".
+type VaultConfig struct {
+	Provider           string
+	MasterKey          string
+	MasterKeyID        string
+	PreviousMasterKeys []string // id:base64 pairs still needed to unwrap
+	KeyDir             string
+	PKCS11Module       string
+	PKCS11Slot         int
+	PKCS11PIN          string
+	PKCS11KeyLabel     string
+}
+
+// NotifyConfig enables a notification channel for each destination that is
+// set; with none set, notifications are only written to the log.
+type NotifyConfig struct {
+	SMTPHost        string
+	SMTPPort        int
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPFrom        string
+	SMTPTo          []string
+	WebhookURL      string
+	WebhookSecret   string
+	SlackWebhookURL string
+}
+
+type ExpiryConfig struct {
+	CheckInterval time.Duration // 0 disables the scheduled check
+	Thresholds    []int         // days before expiry
+}
+
+// MonitoringConfig controls scheduled scans of monitored TLS endpoints.
+// CheckInterval is how often due endpoints are looked for; each endpoint is
+// scanned at its own interval.
+type MonitoringConfig struct {
+	CheckInterval       time.Duration // 0 disables scheduled scans
+	DefaultScanInterval time.Duration
+	MinScanInterval     time.Duration
+	Concurrency         int
+}
+
+// RenewalConfig controls automatic certificate renewal. DefaultDays applies
+// to policies created without their own renewal window.
+type RenewalConfig struct {
+	CheckInterval time.Duration // 0 disables scheduled renewals
+	DefaultDays   int
+}
+
+// DeployConfig restricts deployment targets running on this server.
+// Directory targets write below DirectoryRoot and are disabled without it.
+type DeployConfig struct {
+	DirectoryRoot   string
+	AllowPostHooks  bool
+	PostHookTimeout time.Duration
+}
+
+// BatchConfig limits batch requests. Batches of more than MaxSyncItems
+// operations have to run as a job. Concurrency bounds the operations running
+// at once across all batches; JobsPerUser bounds each user's unfinished jobs.
+type BatchConfig struct {
+	MaxItems     int
+	MaxSyncItems int
+	Concurrency  int
+	JobsPerUser  int
+}
+
+// MailConfig selects how account email is sent: "smtp", "log" (the
+// default, for development and refused in production) or "file", which
+// writes .eml files to FileDir.
+type MailConfig struct {
+	Provider     string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+// AccountConfig covers account recovery and email verification. AppURL is
+// the address of the web application that links in account email point to.
+// UnverifiedRestrictions lists what users who have not verified their email
+// address may not do: write (read-only access) and billing. MFAIssuer names
+// the service in authenticator apps. AdminRequirePasskey keeps admins out of
+// the admin routes unless they signed in with a passkey.
+type AccountConfig struct {
+	AppURL                 string
+	PasswordResetTTL       time.Duration
+	EmailVerificationTTL   time.Duration
+	UnverifiedRestrictions []string
+	MFAIssuer              string
+	AdminRequirePasskey    bool
+}
+
+// LoginConfig covers brute-force protection of logins. After BackoffAfter
+// failed attempts for an account, or IPBackoffAfter from an IP address, each
+// attempt waits BackoffBase, doubling up to BackoffMax. LockoutThreshold
+// failures lock the account for LockoutDuration and email its owner an
+// unlock link. Failures are forgotten after FailureWindow without one, and
+// security events are kept for EventRetention.
+type LoginConfig struct {
+	BackoffAfter     int
+	IPBackoffAfter   int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	FailureWindow    time.Duration
+	EventRetention   time.Duration
+}
+
+// WebAuthnConfig identifies the site to passkeys. RPID is the domain they
+// are bound to and Origins the web application origins allowed to use them.
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// SSOConfig covers enterprise single sign-on. BaseURL is the public address
+// of this API, which identity providers send users back to; it is part of
+// the redirect URI and SAML URLs configured at the identity provider.
+type SSOConfig struct {
+	BaseURL string
+}
+
+type TSAConfig struct {
+	CertFile   string
+	KeyFile    string
+	ChainFile  string
+	PolicyOID  string
+	SerialFile string
+}
+
 func Load() *Config {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
@@ -77,8 +265,12 @@ func Load() *Config {
 			URL: getEnv("REDIS_URL", "redis://localhost:6379"),
 		},
 		JWT: JWTConfig{
-			Secret:    getEnv("JWT_SECRET", "change-this-in-production"),
-			ExpiresIn: parseDuration(getEnv("JWT_EXPIRES_IN", "24h")),
+			Secret:               getEnv("JWT_SECRET", DefaultJWTSecret),
+			ExpiresIn:            parseDuration(getEnv("JWT_EXPIRES_IN", "24h")),
+			RefreshExpiresIn:     parseDuration(getEnv("JWT_REFRESH_EXPIRES_IN", "168h")),
+			Algorithm:            getEnv("JWT_ALGORITHM", "HS256"),
+			SigningKeyFile:       getEnv("JWT_SIGNING_KEY_FILE", ""),
+			VerificationKeyFiles: parseList(getEnv("JWT_VERIFICATION_KEY_FILES", "")),
 		},
 		Stripe: StripeConfig{
 			SecretKey:     getEnv("STRIPE_SECRET_KEY", ""),
@@ -102,6 +294,112 @@ func Load() *Config {
 			MaxFileSize: parseSize(getEnv("MAX_FILE_SIZE", "10MB")),
 			UploadDir:   getEnv("UPLOAD_DIR", "./uploads"),
 		},
+		TSA: TSAConfig{
+			CertFile:   getEnv("TSA_CERT_FILE", ""),
+			KeyFile:    getEnv("TSA_KEY_FILE", ""),
+			ChainFile:  getEnv("TSA_CHAIN_FILE", ""),
+			PolicyOID:  getEnv("TSA_POLICY_OID", ""),
+			SerialFile: getEnv("TSA_SERIAL_FILE", "./data/tsa_serial"),
+		},
+		CT: CTConfig{
+			LogListFile: getEnv("CT_LOG_LIST_FILE", ""),
+		},
+		ACME: ACMEConfig{
+			BaseURL:        getEnv("ACME_BASE_URL", ""),
+			CACertFile:     getEnv("ACME_CA_CERT_FILE", ""),
+			CAKeyFile:      getEnv("ACME_CA_KEY_FILE", ""),
+			ValidityDays:   parseInt(getEnv("ACME_CERT_VALIDITY_DAYS", "90")),
+			AllowedDomains: parseList(getEnv("ACME_ALLOWED_DOMAINS", "")),
+		},
+		ACMEClient: ACMEClientConfig{
+			AllowedDirectories:  parseList(getEnv("ACME_CLIENT_ALLOWED_DIRECTORIES", "")),
+			HTTP01Address:       getEnv("ACME_CLIENT_HTTP01_ADDR", ""),
+			DNSWebhookURL:       getEnv("ACME_CLIENT_DNS_WEBHOOK_URL", ""),
+			DNSWebhookSecret:    getEnv("ACME_CLIENT_DNS_WEBHOOK_SECRET", ""),
+			DNSPropagationDelay: parseDuration(getEnv("ACME_CLIENT_DNS_PROPAGATION_DELAY", "30s")),
+		},
+		Vault: VaultConfig{
+			Provider:           getEnv("VAULT_PROVIDER", "file"),
+			MasterKey:          getEnv("VAULT_MASTER_KEY", ""),
+			MasterKeyID:        getEnv("VAULT_MASTER_KEY_ID", "primary"),
+			PreviousMasterKeys: parseList(getEnv("VAULT_PREVIOUS_MASTER_KEYS", "")),
+			KeyDir:             getEnv("VAULT_KEY_DIR", "./data/vault"),
+			PKCS11Module:       getEnv("VAULT_PKCS11_MODULE", ""),
+			PKCS11Slot:         parseInt(getEnv("VAULT_PKCS11_SLOT", "0")),
+			PKCS11PIN:          getEnv("VAULT_PKCS11_PIN", ""),
+			PKCS11KeyLabel:     getEnv("VAULT_PKCS11_KEY_LABEL", "web-openssl-vault"),
+		},
+		Notify: NotifyConfig{
+			SMTPHost:        getEnv("NOTIFY_SMTP_HOST", ""),
+			SMTPPort:        parseInt(getEnv("NOTIFY_SMTP_PORT", "587")),
+			SMTPUsername:    getEnv("NOTIFY_SMTP_USERNAME", ""),
+			SMTPPassword:    getEnv("NOTIFY_SMTP_PASSWORD", ""),
+			SMTPFrom:        getEnv("NOTIFY_SMTP_FROM", ""),
+			SMTPTo:          parseList(getEnv("NOTIFY_SMTP_TO", "")),
+			WebhookURL:      getEnv("NOTIFY_WEBHOOK_URL", ""),
+			WebhookSecret:   getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+			SlackWebhookURL: getEnv("NOTIFY_SLACK_WEBHOOK_URL", ""),
+		},
+		Expiry: ExpiryConfig{
+			CheckInterval: parseDuration(getEnv("EXPIRY_CHECK_INTERVAL", "1h")),
+			Thresholds:    parseIntList(getEnv("EXPIRY_THRESHOLDS", "30,14,7,1")),
+		},
+		Monitoring: MonitoringConfig{
+			CheckInterval:       parseDuration(getEnv("MONITOR_CHECK_INTERVAL", "5m")),
+			DefaultScanInterval: parseDuration(getEnv("MONITOR_DEFAULT_SCAN_INTERVAL", "24h")),
+			MinScanInterval:     parseDuration(getEnv("MONITOR_MIN_SCAN_INTERVAL", "1h")),
+			Concurrency:         parseInt(getEnv("MONITOR_SCAN_CONCURRENCY", "4")),
+		},
+		Renewal: RenewalConfig{
+			CheckInterval: parseDuration(getEnv("RENEWAL_CHECK_INTERVAL", "1h")),
+			DefaultDays:   parseInt(getEnv("RENEWAL_DEFAULT_DAYS", "30")),
+		},
+		Deploy: DeployConfig{
+			DirectoryRoot:   getEnv("DEPLOY_DIRECTORY_ROOT", ""),
+			AllowPostHooks:  parseBool(getEnv("DEPLOY_ALLOW_POST_HOOKS", "false")),
+			PostHookTimeout: parseDuration(getEnv("DEPLOY_POST_HOOK_TIMEOUT", "1m")),
+		},
+		Batch: BatchConfig{
+			MaxItems:     parseInt(getEnv("BATCH_MAX_ITEMS", "1000")),
+			MaxSyncItems: parseInt(getEnv("BATCH_MAX_SYNC_ITEMS", "50")),
+			Concurrency:  parseInt(getEnv("BATCH_CONCURRENCY", "4")),
+			JobsPerUser:  parseInt(getEnv("BATCH_JOBS_PER_USER", "2")),
+		},
+		Mail: MailConfig{
+			Provider:     getEnv("MAIL_PROVIDER", "log"),
+			From:         getEnv("MAIL_FROM", "OpenSSL UI <no-reply@localhost>"),
+			SMTPHost:     getEnv("MAIL_SMTP_HOST", ""),
+			SMTPPort:     parseInt(getEnv("MAIL_SMTP_PORT", "587")),
+			SMTPUsername: getEnv("MAIL_SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("MAIL_SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", ""),
+		},
+		Account: AccountConfig{
+			AppURL:                 getEnv("APP_URL", "http://localhost:3000"),
+			PasswordResetTTL:       parseDuration(getEnv("PASSWORD_RESET_TTL", "1h")),
+			EmailVerificationTTL:   parseDuration(getEnv("EMAIL_VERIFICATION_TTL", "48h")),
+			UnverifiedRestrictions: parseList(getEnv("UNVERIFIED_EMAIL_RESTRICTIONS", "")),
+			MFAIssuer:              getEnv("MFA_ISSUER", "OpenSSL UI"),
+			AdminRequirePasskey:    parseBool(getEnv("ADMIN_REQUIRE_PASSKEY", "false")),
+		},
+		Login: LoginConfig{
+			BackoffAfter:     parseInt(getEnv("LOGIN_BACKOFF_AFTER", "3")),
+			IPBackoffAfter:   parseInt(getEnv("LOGIN_IP_BACKOFF_AFTER", "20")),
+			BackoffBase:      parseDuration(getEnv("LOGIN_BACKOFF_BASE", "1s")),
+			BackoffMax:       parseDuration(getEnv("LOGIN_BACKOFF_MAX", "15m")),
+			LockoutThreshold: parseInt(getEnv("LOGIN_LOCKOUT_THRESHOLD", "10")),
+			LockoutDuration:  parseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "30m")),
+			FailureWindow:    parseDuration(getEnv("LOGIN_FAILURE_WINDOW", "24h")),
+			EventRetention:   parseDuration(getEnv("SECURITY_EVENT_RETENTION", "2160h")),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:  getEnv("WEBAUTHN_RP_NAME", "OpenSSL UI"),
+			Origins: parseList(getEnv("WEBAUTHN_ORIGINS", "http://localhost:3000")),
+		},
+		SSO: SSOConfig{
+			BaseURL: getEnv("SSO_BASE_URL", "http://localhost:8080"),
+		},
 	}
 
 	return config
@@ -123,6 +421,15 @@ func parseInt(s string) int {
 	return i
 }
 
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		log.Printf("Error parsing boolean %s: %v", s, err)
+		return false
+	}
+	return b
+}
+
 func parseDuration(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
@@ -148,4 +455,21 @@ func parseSize(s string) int64 {
 
 	// Default to bytes
 	return int64(parseInt(s))
-}
\ No newline at end of file
+}
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseIntList(s string) []int {
+	var values []int
+	for _, item := range parseList(s) {
+		values = append(values, parseInt(item))
+	}
+	return values
+}
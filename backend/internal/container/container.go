@@ -39,7 +39,13 @@ func NewContainer(cfg *config.Config, db *gorm.DB) *Container {
 	}
 
 	// Initialize infrastructure services
-	container.AuthService = auth.NewService(cfg.JWT.Secret, cfg.JWT.ExpiresIn)
+	container.AuthService = auth.NewService(auth.Config{
+		Secret:               cfg.JWT.Secret,
+		ExpiresIn:            cfg.JWT.ExpiresIn,
+		Algorithm:            cfg.JWT.Algorithm,
+		SigningKeyFile:       cfg.JWT.SigningKeyFile,
+		VerificationKeyFiles: cfg.JWT.VerificationKeyFiles,
+	})
 	container.BillingService = billing.NewService(cfg.Stripe.SecretKey)
 
 	// Initialize repositories
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"web-openssl-backend/pkg/acme"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxACMERequestSize bounds JWS bodies; the largest, a finalize request
+// carrying a CSR, is a few kilobytes.
+const maxACMERequestSize = 64 * 1024
+
+// ACMEHeaders adds the headers RFC 8555 requires on every response and
+// rejects requests while no CA is configured.
+func (h *Handler) ACMEHeaders(c *gin.Context) {
+	if !h.ACMEService.Enabled() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": acme.ErrNotConfigured.Error()})
+		return
+	}
+
+	c.Header("Replay-Nonce", h.ACMEService.NewNonce())
+	c.Header("Cache-Control", "no-store")
+	c.Header("Link", fmt.Sprintf("<%s>;rel=\"index\"", h.ACMEService.DirectoryURL()))
+	c.Next()
+}
+
+// @Summary ACME directory
+// @Description RFC 8555 directory listing the ACME endpoints
+// @Tags acme
+// @Produce json
+// @Success 200 {object} acme.Directory
+// @Router /acme/directory [get]
+func (h *Handler) ACMEDirectory(c *gin.Context) {
+	c.JSON(http.StatusOK, h.ACMEService.Directory())
+}
+
+// @Summary ACME new nonce
+// @Description Return a fresh anti-replay nonce in the Replay-Nonce header
+// @Tags acme
+// @Success 200
+// @Success 204
+// @Router /acme/new-nonce [head]
+func (h *Handler) ACMENewNonce(c *gin.Context) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary ACME new account
+// @Description Create an ACME account or look up the account for a key
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Success 200 {object} acme.Account
+// @Success 201 {object} acme.Account
+// @Failure 400 {object} acme.Problem
+// @Router /acme/new-account [post]
+func (h *Handler) ACMENewAccount(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	account, created, err := h.ACMEService.NewAccount(req)
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.Header("Location", h.ACMEService.AccountURL(account.ID))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, account)
+}
+
+// @Summary ACME account
+// @Description Read, update or deactivate an ACME account
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Param id path string true "Account ID"
+// @Success 200 {object} acme.Account
+// @Failure 403 {object} acme.Problem
+// @Router /acme/account/{id} [post]
+func (h *Handler) ACMEAccount(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	account, err := h.ACMEService.UpdateAccount(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// @Summary ACME account orders
+// @Description List the URLs of an account's unfinished orders
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Param id path string true "Account ID"
+// @Success 200 {object} acme.OrderList
+// @Failure 403 {object} acme.Problem
+// @Router /acme/account/{id}/orders [post]
+func (h *Handler) ACMEAccountOrders(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	orders, err := h.ACMEService.ListOrders(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+// @Summary ACME new order
+// @Description Request a certificate for a set of DNS identifiers
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Success 201 {object} acme.Order
+// @Failure 400 {object} acme.Problem
+// @Router /acme/new-order [post]
+func (h *Handler) ACMENewOrder(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.ACMEService.NewOrder(req)
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.Header("Location", h.ACMEService.OrderURL(order.ID))
+	c.JSON(http.StatusCreated, order)
+}
+
+// @Summary ACME order
+// @Description Fetch the current state of an order
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Param id path string true "Order ID"
+// @Success 200 {object} acme.Order
+// @Failure 404 {object} acme.Problem
+// @Router /acme/order/{id} [post]
+func (h *Handler) ACMEOrder(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.ACMEService.GetOrder(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// @Summary ACME finalize order
+// @Description Submit the CSR for a ready order and issue the certificate
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Param id path string true "Order ID"
+// @Success 200 {object} acme.Order
+// @Failure 400 {object} acme.Problem
+// @Failure 403 {object} acme.Problem
+// @Router /acme/order/{id}/finalize [post]
+func (h *Handler) ACMEFinalize(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.ACMEService.Finalize(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.Header("Location", h.ACMEService.OrderURL(order.ID))
+	c.JSON(http.StatusOK, order)
+}
+
+// @Summary ACME authorization
+// @Description Fetch or deactivate an authorization
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Param id path string true "Authorization ID"
+// @Success 200 {object} acme.Authorization
+// @Failure 404 {object} acme.Problem
+// @Router /acme/authz/{id} [post]
+func (h *Handler) ACMEAuthorization(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	authz, err := h.ACMEService.GetAuthorization(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, authz)
+}
+
+// @Summary ACME challenge
+// @Description Fetch a challenge or tell the server it is ready to be validated
+// @Tags acme
+// @Accept application/jose+json
+// @Produce json
+// @Param id path string true "Challenge ID"
+// @Success 200 {object} acme.Challenge
+// @Failure 404 {object} acme.Problem
+// @Router /acme/chall/{id} [post]
+func (h *Handler) ACMEChallenge(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	challenge, authzURL, err := h.ACMEService.RespondChallenge(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.Header("Link", fmt.Sprintf("<%s>;rel=\"up\"", authzURL))
+	c.JSON(http.StatusOK, challenge)
+}
+
+// @Summary ACME certificate
+// @Description Download an issued certificate chain
+// @Tags acme
+// @Accept application/jose+json
+// @Produce application/pem-certificate-chain
+// @Param id path string true "Certificate ID"
+// @Success 200 {string} string "PEM certificate chain"
+// @Failure 404 {object} acme.Problem
+// @Router /acme/cert/{id} [post]
+func (h *Handler) ACMECertificate(c *gin.Context) {
+	req, ok := h.acmeRequest(c)
+	if !ok {
+		return
+	}
+
+	chain, err := h.ACMEService.GetCertificate(req, c.Param("id"))
+	if err != nil {
+		h.acmeError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/pem-certificate-chain", []byte(chain))
+}
+
+// Helper functions
+
+// acmeRequest reads and authenticates the JWS body of an ACME POST,
+// writing the problem response itself when it cannot.
+func (h *Handler) acmeRequest(c *gin.Context) (*acme.Request, bool) {
+	if c.ContentType() != "application/jose+json" {
+		h.acmeError(c, acme.UnsupportedMediaType())
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxACMERequestSize))
+	if err != nil {
+		h.acmeError(c, err)
+		return nil, false
+	}
+
+	req, err := h.ACMEService.ParseRequest(body, h.ACMEService.URL(c.Request.URL.Path))
+	if err != nil {
+		h.acmeError(c, err)
+		return nil, false
+	}
+
+	return req, true
+}
+
+func (h *Handler) acmeError(c *gin.Context, err error) {
+	problem := acme.AsProblem(err)
+	if problem.Status == http.StatusInternalServerError {
+		log.Printf("ACME request %s failed: %v", c.Request.URL.Path, err)
+	}
+
+	body, _ := json.Marshal(problem)
+	c.Data(problem.Status, "application/problem+json", body)
+}
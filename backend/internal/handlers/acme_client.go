@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/internal/services"
+	"web-openssl-backend/pkg/acmeclient"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// acmeObtainTimeout bounds a complete order, including waiting for the CA
+// to validate every challenge.
+const acmeObtainTimeout = 5 * time.Minute
+
+// @Summary Register ACME account
+// @Description Register a new account with an external ACME directory
+// @Tags acme-client
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body acmeclient.RegisterAccountRequest true "ACME account registration request"
+// @Success 201 {object} models.ACMEAccount
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/acme/accounts [post]
+func (h *Handler) RegisterACMEAccount(c *gin.Context) {
+	var req acmeclient.RegisterAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "acme_register", "ACME account registration")
+
+	account, err := h.ACMEClient.Register(c.Request.Context(), &req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		status := http.StatusBadGateway
+		if errors.Is(err, acmeclient.ErrDirectoryNotAllowed) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	record := &models.ACMEAccount{
+		UserID:       userID.(uint),
+		DirectoryURL: account.DirectoryURL,
+		Email:        req.Email,
+		AccountURI:   account.URI,
+		Status:       account.Status,
+	}
+	record.ID, err = h.KeyVault.ReserveID(c.Request.Context(), models.TableACMEAccounts)
+	if err == nil {
+		record.PrivateKey, err = h.KeyVault.Seal(models.TableACMEAccounts, record.ID, account.PrivateKey)
+	}
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to protect account key"})
+		return
+	}
+	if err := h.DB.Create(record).Error; err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save ACME account"})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "ACME account registered successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusCreated, record)
+}
+
+// @Summary List ACME accounts
+// @Description List the user's accounts with external ACME CAs
+// @Tags acme-client
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.ACMEAccount
+// @Router /api/v1/openssl/acme/accounts [get]
+func (h *Handler) GetACMEAccounts(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var accounts []models.ACMEAccount
+	if err := h.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ACME accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// @Summary Obtain certificate via ACME
+// @Description Order a certificate for the given domains from the account's CA and store it
+// @Tags acme-client
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body acmeclient.ObtainCertificateRequest true "ACME certificate request"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/openssl/acme/certificates [post]
+func (h *Handler) ObtainACMECertificate(c *gin.Context) {
+	var req acmeclient.ObtainCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var account models.ACMEAccount
+	if err := h.DB.Where("id = ? AND user_id = ?", req.AccountID, userID).First(&account).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ACME account not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ACME account"})
+		}
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "acme_obtain", "ACME certificate order for "+strings.Join(req.Domains, ", "))
+
+	accountKey, err := h.KeyVault.Open(models.TableACMEAccounts, account.ID, account.PrivateKey)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account key"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), acmeObtainTimeout)
+	defer cancel()
+
+	cert, err := h.ACMEClient.Obtain(ctx, &acmeclient.Order{
+		DirectoryURL:  account.DirectoryURL,
+		AccountKey:    accountKey,
+		Domains:       req.Domains,
+		ChallengeType: req.ChallengeType,
+		KeyType:       req.KeyType,
+	})
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := h.InventoryService.ImportCertificate(c.Request.Context(), repository.Scope{UserID: account.UserID}, services.ImportCertificateRequest{
+		Source:        models.SourceACME,
+		Certificate:   cert.Certificate + cert.Chain,
+		PrivateKey:    cert.PrivateKey,
+		ACMEAccountID: &account.ID,
+	})
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save certificate"})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "ACME certificate obtained successfully")
+	h.incrementUsage(c)
+
+	// The private key is only returned here, when the certificate is issued.
+	c.JSON(http.StatusCreated, gin.H{
+		"id":          record.ID,
+		"certificate": cert,
+	})
+}
+
+// @Summary List ACME certificates
+// @Description List inventory certificates obtained from external ACME CAs
+// @Tags acme-client
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Certificate
+// @Router /api/v1/openssl/acme/certificates [get]
+func (h *Handler) GetACMECertificates(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	certs, _, err := h.InventoryService.SearchCertificates(c.Request.Context(), repository.CertificateFilter{
+		Scope:  repository.Scope{UserID: userID.(uint)},
+		Source: models.SourceACME,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch certificates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, certs)
+}
+
+// @Summary ACME HTTP-01 challenge response
+// @Description Serve key authorizations for pending http-01 challenges
+// @Tags acme-client
+// @Produce plain
+// @Param token path string true "Challenge token"
+// @Success 200 {string} string "Key authorization"
+// @Failure 404 {string} string
+// @Router /.well-known/acme-challenge/{token} [get]
+func (h *Handler) ServeACMEChallenge(c *gin.Context) {
+	keyAuthorization, ok := h.ACMEClient.ChallengeResponse(c.Param("token"))
+	if !ok {
+		c.String(http.StatusNotFound, "")
+		return
+	}
+
+	c.String(http.StatusOK, keyAuthorization)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+	// Scopes are the operation groups of the OpenSSL routes the key may call
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// @Summary Get API keys
+// @Description Get the current user's API keys. Only their prefixes are shown
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/api-keys [get]
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	keys, err := h.APIKeyService.ListKeys(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// @Summary Create API key
+// @Description Create a key for the OpenSSL routes, sent in the X-API-Key header. The key is shown only in this response. Requires a plan with API access
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAPIKeyRequest true "API key"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/api-keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	key, apiKey, err := h.APIKeyService.CreateKey(c.Request.Context(), userID.(uint), services.CreateAPIKeyRequest{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		h.apiKeyError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"key": key, "apiKey": apiKey})
+}
+
+// @Summary Revoke API key
+// @Description Revoke an API key; requests made with it fail at once
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.APIKeyService.RevokeKey(c.Request.Context(), userID.(uint), uint(id)); err != nil {
+		h.apiKeyError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// @Summary Get API key scopes
+// @Description Get the operation groups API keys can be scoped to
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/api-keys/scopes [get]
+func (h *Handler) GetAPIKeyScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"scopes": h.APIKeyService.Scopes()})
+}
+
+// Helper functions
+
+func (h *Handler) apiKeyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+	case errors.Is(err, services.ErrInvalidAPIKeyRequest), errors.Is(err, services.ErrTooManyAPIKeys):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAPIAccessNotInPlan):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
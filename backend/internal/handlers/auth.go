@@ -1,14 +1,18 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+	"web-openssl-backend/pkg/auth"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type RegisterRequest struct {
@@ -23,6 +27,23 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
 type AuthResponse struct {
 	AccessToken  string      `json:"accessToken"`
 	RefreshToken string      `json:"refreshToken"`
@@ -30,7 +51,7 @@ type AuthResponse struct {
 }
 
 // @Summary Register a new user
-// @Description Register a new user account
+// @Description Register a new user account and email a link to verify its address
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -60,13 +81,6 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate API key
-	apiKey, err := generateAPIKey()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
-		return
-	}
-
 	// Create user
 	user := models.User{
 		Email:        req.Email,
@@ -76,7 +90,6 @@ func (h *Handler) Register(c *gin.Context) {
 		Role:         models.RoleUser,
 		Plan:         models.PlanFree,
 		IsActive:     true,
-		APIKey:       apiKey,
 		UsageResetAt: time.Now().AddDate(0, 1, 0), // Reset usage monthly
 	}
 
@@ -85,16 +98,15 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := h.AuthService.GenerateToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
-		return
+	// The account works without it; the user can ask for another link
+	if err := h.AccountService.SendVerification(c.Request.Context(), &user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
 	}
 
-	refreshToken, err := h.AuthService.GenerateRefreshToken(&user)
+	// Generate tokens
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), &user, []string{auth.AuthMethodPassword}, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
 
@@ -102,14 +114,14 @@ func (h *Handler) Register(c *gin.Context) {
 	user.Password = ""
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 		User:         user,
 	})
 }
 
 // @Summary Login user
-// @Description Authenticate user and return tokens
+// @Description Authenticate user and return tokens. Users with two-factor authentication get {mfaRequired, mfaToken, mfaMethods} instead, to complete at /auth/mfa/verify (totp) or /auth/mfa/webauthn/verify (webauthn)
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -117,6 +129,7 @@ func (h *Handler) Register(c *gin.Context) {
 // @Success 200 {object} AuthResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
 // @Router /api/v1/auth/login [post]
 func (h *Handler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -125,50 +138,77 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse guesses while the account or IP address is backing off
+	attempt := loginAttempt(c, req.Email)
+	if !h.checkLogin(c, attempt) {
+		return
+	}
+	defer h.loginAborted(c, attempt)
+
 	// Find user
 	var user models.User
-	if err := h.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := h.DB.Where("email = ?", req.Email).First(&user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		h.loginFailed(c, attempt, services.LoginFailureUnknownAccount)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find user"})
+		return
 	}
 
 	// Check password
 	if !h.AuthService.CheckPasswordHash(req.Password, user.Password) {
+		h.loginFailed(c, attempt, services.LoginFailureInvalidPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check if user is active
 	if !user.IsActive {
+		h.loginFailed(c, attempt, services.LoginFailureAccountDisabled)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := h.AuthService.GenerateToken(&user)
+	// Users with two-factor authentication get a challenge in place of the
+	// tokens, to be answered with a TOTP code at /auth/mfa/verify or a
+	// passkey at /auth/mfa/webauthn/verify
+	mfaMethods, err := h.mfaMethods(c, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication"})
+		return
+	}
+	if len(mfaMethods) > 0 {
+		challenge, err := h.MFAService.Challenge(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+			return
+		}
+		h.loginPassed(c, attempt, &user, nil)
+		c.JSON(http.StatusOK, gin.H{"mfaRequired": true, "mfaToken": challenge, "mfaMethods": mfaMethods})
 		return
 	}
 
-	refreshToken, err := h.AuthService.GenerateRefreshToken(&user)
+	// Generate tokens
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), &user, []string{auth.AuthMethodPassword}, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
+	h.loginPassed(c, attempt, &user, []string{auth.AuthMethodPassword})
 
 	// Remove password from response
 	user.Password = ""
 
 	c.JSON(http.StatusOK, AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 		User:         user,
 	})
 }
 
 // @Summary Refresh access token
-// @Description Refresh access token using refresh token
+// @Description Swap a refresh token for a new access token and a new refresh token. Each refresh token works once; presenting one again signs its session out
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -177,109 +217,249 @@ func (h *Handler) Login(c *gin.Context) {
 // @Failure 401 {object} map[string]string
 // @Router /api/v1/auth/refresh [post]
 func (h *Handler) RefreshToken(c *gin.Context) {
-	refreshToken := c.GetHeader("Authorization")
+	refreshToken := bearerToken(c)
 	if refreshToken == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token required"})
 		return
 	}
 
-	// Remove "Bearer " prefix
-	if len(refreshToken) > 7 && refreshToken[:7] == "Bearer " {
-		refreshToken = refreshToken[7:]
+	tokens, err := h.SessionService.Refresh(c.Request.Context(), refreshToken, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens"})
+		}
+		return
 	}
 
-	// Validate refresh token
-	claims, err := h.AuthService.ValidateToken(refreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+	c.JSON(http.StatusOK, gin.H{
+		"accessToken":  tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+	})
+}
+
+// @Summary Log out
+// @Description End the session of a refresh token; its access tokens stop working too
+// @Tags auth
+// @Produce json
+// @Param Authorization header string true "Bearer refresh_token"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/logout [post]
+func (h *Handler) Logout(c *gin.Context) {
+	refreshToken := bearerToken(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token required"})
 		return
 	}
 
-	// Get user
-	var user models.User
-	if err := h.DB.First(&user, claims.UserID).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if err := h.SessionService.Logout(c.Request.Context(), refreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
 		return
 	}
 
-	// Generate new access token
-	accessToken, err := h.AuthService.GenerateToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// @Summary Log out of all sessions
+// @Description End every session of the current user, on all devices
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Router /api/v1/auth/logout-all [post]
+func (h *Handler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.SessionService.LogoutAll(c.Request.Context(), userID.(uint)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"accessToken": accessToken})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// @Summary Access token signing keys
+// @Description The public keys access tokens are signed with, as a JWK set, for other services to verify tokens without the shared secret. Empty when tokens are signed with HS256
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) JWKS(c *gin.Context) {
+	// Short enough that verifiers pick up a key published ahead of a rotation
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.AuthService.JWKS())
 }
 
 // @Summary Forgot password
-// @Description Request password reset
+// @Description Email a single-use password reset link. The response is the same whether or not the address has an account
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param request body map[string]string true "Email request"
+// @Param request body ForgotPasswordRequest true "Email request"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/auth/forgot-password [post]
 func (h *Handler) ForgotPassword(c *gin.Context) {
-	var req map[string]string
+	var req ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	email := req["email"]
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required"})
+	if err := h.AccountService.RequestPasswordReset(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+		log.Printf("Failed to start password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
 		return
 	}
 
-	// TODO: Implement password reset logic
-	// For now, just return success
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent"})
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
 }
 
 // @Summary Reset password
-// @Description Reset password with token
+// @Description Set a new password with the token from a reset email. The token works once, and every existing session is signed out
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param request body map[string]string true "Reset request"
+// @Param request body ResetPasswordRequest true "Reset request"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/auth/reset-password [post]
 func (h *Handler) ResetPassword(c *gin.Context) {
-	var req map[string]string
+	var req ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// TODO: Implement password reset logic
+	if err := h.AccountService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) || errors.Is(err, services.ErrWeakPassword) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		}
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 }
 
 // @Summary Verify email
-// @Description Verify email address
+// @Description Verify an email address with the signed token from a verification email. Tokens expire, and only verify the address they were sent to
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param request body map[string]string true "Verification request"
-// @Success 200 {object} map[string]string
+// @Param request body VerifyEmailRequest true "Verification request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/auth/verify-email [post]
 func (h *Handler) VerifyEmail(c *gin.Context) {
-	var req map[string]string
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.AccountService.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		}
+		return
+	}
+
+	// Remove password from response
+	user.Password = ""
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully", "user": user})
+}
+
+// @Summary Resend verification email
+// @Description Email a new verification link to an unverified address. The response is the same whether or not the address has an account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Email request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/resend-verification [post]
+func (h *Handler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// TODO: Implement email verification logic
-	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+	if err := h.AccountService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		log.Printf("Failed to resend verification email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an unverified account exists for this email, a verification link has been sent"})
+}
+
+// unverifiedAllowed lists the routes unverified users can always write to,
+// so that they can correct a mistyped address, close their account, sign
+// out everywhere or secure their account with two-factor authentication
+// and passkeys
+var unverifiedAllowed = map[string]bool{
+	"/api/v1/users/me":                       true,
+	"/api/v1/auth/logout-all":                true,
+	"/api/v1/auth/mfa/totp":                  true,
+	"/api/v1/auth/mfa/totp/confirm":          true,
+	"/api/v1/auth/mfa/recovery-codes":        true,
+	"/api/v1/auth/webauthn/register/options": true,
+	"/api/v1/auth/webauthn/register":         true,
 }
 
-func generateAPIKey() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+// RequireVerifiedEmail keeps users who have not verified their email address
+// from what the unverified user policy restricts. RestrictWrite lets safe
+// requests through.
+func (h *Handler) RequireVerifiedEmail(restriction string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.AccountService.Restricts(restriction) {
+			c.Next()
+			return
+		}
+		if restriction == services.RestrictWrite {
+			switch c.Request.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				c.Next()
+				return
+			}
+			if unverifiedAllowed[c.FullPath()] {
+				c.Next()
+				return
+			}
+		}
+
+		userID, _ := c.Get("user_id")
+		verified, err := h.AccountService.EmailVerified(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrEmailNotVerified.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Next()
 	}
-	return hex.EncodeToString(bytes), nil
-}
\ No newline at end of file
+}
+
+// bearerToken returns the token of an Authorization header using the Bearer
+// scheme, whose name is case-insensitive, or "" for any other header
+func bearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
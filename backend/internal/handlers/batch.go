@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+	"web-openssl-backend/pkg/openssl"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// Operation types a batch may contain; they match the types single
+// requests record their operations with
+const (
+	batchGenerateKey       = "generate_key"
+	batchGenerateCSR       = "generate_csr"
+	batchParseCertificate  = "parse_certificate"
+	batchVerifyCertificate = "verify_certificate"
+	batchGenerateHash      = "generate_hash"
+)
+
+type BatchOperationRequest struct {
+	Type string `json:"type" binding:"required" enums:"generate_key,generate_csr,parse_certificate,verify_certificate,generate_hash"`
+	// Request is the body the single endpoint for Type takes
+	Request json.RawMessage `json:"request" binding:"required" swaggertype:"object"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperationRequest `json:"operations" binding:"required,min=1,dive"`
+	// Async runs the batch as a job to poll instead of waiting for it
+	Async bool `json:"async,omitempty"`
+}
+
+// @Summary Run batch
+// @Description Run many key, CSR, parse, verify and hash operations in one request. Each item is recorded as an operation of its own, and fails on its own. The whole batch is counted against the monthly usage before it starts and failed items are refunded. Large batches have to run as a job (async) that is polled for its results; a user may only have a few jobs unfinished at a time
+// @Tags batch
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BatchRequest true "Batch of operations"
+// @Success 200 {object} map[string]interface{}
+// @Success 202 {object} models.BatchJob
+// @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /api/v1/batch [post]
+func (h *Handler) RunBatch(c *gin.Context) {
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Operations) > h.Config.Batch.MaxItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch may contain at most %d operations", h.Config.Batch.MaxItems)})
+		return
+	}
+	if !req.Async && len(req.Operations) > h.Config.Batch.MaxSyncItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Batches of more than %d operations must run as a job (async)", h.Config.Batch.MaxSyncItems)})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	operations := make([]services.BatchOperation, len(req.Operations))
+	for i, item := range req.Operations {
+		operation, err := h.batchOperation(userID.(uint), item)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("operation %d: %v", i, err), "index": i})
+			return
+		}
+		operations[i] = operation
+	}
+
+	batch := services.BatchRequest{
+		UserID:     userID.(uint),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+		Operations: operations,
+	}
+
+	if req.Async {
+		job, err := h.BatchService.Submit(c.Request.Context(), batch)
+		if err != nil {
+			h.batchError(c, err, "Failed to start batch job")
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	results, err := h.BatchService.Run(c.Request.Context(), batch)
+	if err != nil {
+		h.batchError(c, err, "Failed to run batch")
+		return
+	}
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"total":     len(results),
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// @Summary Get batch jobs
+// @Description Get the user's batch jobs with their progress, newest first. Results are only included when fetching a single job
+// @Tags batch
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/batch/jobs [get]
+func (h *Handler) GetBatchJobs(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	page, limit := inventoryPagination(c)
+	jobs, total, err := h.BatchService.ListJobs(c.Request.Context(), userID.(uint), (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch batch jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":       jobs,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Get batch job
+// @Description Poll a batch job. Results are filled in once its status is completed
+// @Tags batch
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.BatchJob
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/batch/jobs/{id} [get]
+func (h *Handler) GetBatchJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	job, err := h.BatchService.GetJob(c.Request.Context(), userID.(uint), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Batch job not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch batch job"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Helper functions
+
+// batchOperation decodes and validates one item the way its single endpoint
+// would, and binds it to the OpenSSL call that runs it.
+func (h *Handler) batchOperation(userID uint, item BatchOperationRequest) (services.BatchOperation, error) {
+	switch item.Type {
+	case batchGenerateKey:
+		var req openssl.GenerateKeyRequest
+		if err := decodeBatchRequest(item.Request, &req); err != nil {
+			return services.BatchOperation{}, err
+		}
+		return services.BatchOperation{
+			Type:    item.Type,
+			Command: string(req.KeyType),
+			Message: "Key generated successfully",
+			Run: func(ctx context.Context) (interface{}, error) {
+				response, err := h.OpenSSLService.GenerateKey(&req)
+				if err != nil {
+					return nil, err
+				}
+				h.storeInUserInventory(ctx, userID, models.SourceGenerated, "", response.PrivateKey, response.PublicKey)
+				return response, nil
+			},
+		}, nil
+
+	case batchGenerateCSR:
+		var req openssl.GenerateCSRRequest
+		if err := decodeBatchRequest(item.Request, &req); err != nil {
+			return services.BatchOperation{}, err
+		}
+		return services.BatchOperation{
+			Type:    item.Type,
+			Command: req.Subject.CommonName,
+			Message: "CSR generated successfully",
+			Run: func(ctx context.Context) (interface{}, error) {
+				return h.OpenSSLService.GenerateCSR(&req)
+			},
+		}, nil
+
+	case batchParseCertificate:
+		var req openssl.ParseCertificateRequest
+		if err := decodeBatchRequest(item.Request, &req); err != nil {
+			return services.BatchOperation{}, err
+		}
+		return services.BatchOperation{
+			Type:    item.Type,
+			Command: "Certificate analysis",
+			Message: "Certificate parsed successfully",
+			Run: func(ctx context.Context) (interface{}, error) {
+				return h.OpenSSLService.ParseCertificate(&req)
+			},
+		}, nil
+
+	case batchVerifyCertificate:
+		var req openssl.VerifyCertificateRequest
+		if err := decodeBatchRequest(item.Request, &req); err != nil {
+			return services.BatchOperation{}, err
+		}
+		return services.BatchOperation{
+			Type:    item.Type,
+			Command: "Certificate verification",
+			Message: "Certificate verified",
+			Run: func(ctx context.Context) (interface{}, error) {
+				return h.OpenSSLService.VerifyCertificate(&req)
+			},
+		}, nil
+
+	case batchGenerateHash:
+		var req openssl.HashRequest
+		if err := decodeBatchRequest(item.Request, &req); err != nil {
+			return services.BatchOperation{}, err
+		}
+		return services.BatchOperation{
+			Type:    item.Type,
+			Command: string(req.Algorithm),
+			Message: "Hash generated successfully",
+			Run: func(ctx context.Context) (interface{}, error) {
+				return h.OpenSSLService.GenerateHash(&req)
+			},
+		}, nil
+	}
+
+	return services.BatchOperation{}, fmt.Errorf("unsupported operation type %q", item.Type)
+}
+
+// batchError maps the errors of starting a batch. A batch over the usage
+// limit gets the same response as a single request.
+func (h *Handler) batchError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUsageLimitExceeded):
+		h.usageLimitExceeded(c)
+	case errors.Is(err, services.ErrTooManyBatchJobs):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("At most %d batch jobs may be unfinished at a time", h.Config.Batch.JobsPerUser)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func decodeBatchRequest(data json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(v)
+}
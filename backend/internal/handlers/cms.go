@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/openssl"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create CMS SignedData
+// @Description Sign data with a certificate and key, producing attached or detached CMS SignedData
+// @Tags cms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body openssl.CMSSignRequest true "CMS signing request"
+// @Success 200 {object} openssl.CMSSignResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/cms/sign [post]
+func (h *Handler) CMSSign(c *gin.Context) {
+	var req openssl.CMSSignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "cms_sign", "CMS SignedData")
+
+	// Sign data
+	response, err := h.OpenSSLService.CMSSign(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		status := http.StatusInternalServerError
+		if errors.Is(err, openssl.ErrUnsupportedAlgorithm) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Data signed successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Verify CMS SignedData
+// @Description Verify CMS SignedData against a trust store
+// @Tags cms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body openssl.CMSVerifyRequest true "CMS verification request"
+// @Success 200 {object} openssl.CMSVerifyResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/cms/verify [post]
+func (h *Handler) CMSVerify(c *gin.Context) {
+	var req openssl.CMSVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "cms_verify", "CMS verification")
+
+	// Verify signature
+	response, err := h.OpenSSLService.CMSVerify(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Signature verified")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Create CMS EnvelopedData
+// @Description Encrypt data for one or more recipient certificates
+// @Tags cms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body openssl.CMSEncryptRequest true "CMS encryption request"
+// @Success 200 {object} openssl.CMSEncryptResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/cms/encrypt [post]
+func (h *Handler) CMSEncrypt(c *gin.Context) {
+	var req openssl.CMSEncryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "cms_encrypt", "CMS EnvelopedData")
+
+	// Encrypt data
+	response, err := h.OpenSSLService.CMSEncrypt(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		status := http.StatusInternalServerError
+		if errors.Is(err, openssl.ErrUnsupportedAlgorithm) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Data encrypted successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Decrypt CMS EnvelopedData
+// @Description Decrypt CMS EnvelopedData with a recipient key
+// @Tags cms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body openssl.CMSDecryptRequest true "CMS decryption request"
+// @Success 200 {object} openssl.CMSDecryptResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/cms/decrypt [post]
+func (h *Handler) CMSDecrypt(c *gin.Context) {
+	var req openssl.CMSDecryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "cms_decrypt", "CMS decryption")
+
+	// Decrypt data
+	response, err := h.OpenSSLService.CMSDecrypt(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Data decrypted successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Parse S/MIME email
+// @Description Parse a signed S/MIME email and verify its signature
+// @Tags cms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body openssl.SMIMEParseRequest true "S/MIME parsing request"
+// @Success 200 {object} openssl.SMIMEParseResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/cms/smime/parse [post]
+func (h *Handler) ParseSMIME(c *gin.Context) {
+	var req openssl.SMIMEParseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "smime_parse", "S/MIME analysis")
+
+	// Parse message
+	response, err := h.OpenSSLService.ParseSMIME(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "S/MIME message parsed successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
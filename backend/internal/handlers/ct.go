@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/ct"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Check Certificate Transparency
+// @Description Decode embedded SCTs, verify them against known CT logs and check the SCT policy
+// @Tags ct
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ct.CheckRequest true "CT check request"
+// @Success 200 {object} ct.CheckResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/ct/check [post]
+func (h *Handler) CheckCertificateTransparency(c *gin.Context) {
+	var req ct.CheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "ct_check", "SCT verification")
+
+	// Check SCTs
+	response, err := h.CTService.Check(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Certificate Transparency checked")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Link precertificate
+// @Description Check that a final certificate was issued from a precertificate
+// @Tags ct
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ct.LinkRequest true "Precertificate link request"
+// @Success 200 {object} ct.LinkResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/ct/link [post]
+func (h *Handler) LinkPrecertificate(c *gin.Context) {
+	var req ct.LinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "ct_link", "Precertificate link")
+
+	// Compare certificates
+	response, err := h.CTService.Link(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Precertificate compared")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
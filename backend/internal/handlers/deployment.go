@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateDeploymentTargetRequest struct {
+	Name           string          `json:"name" binding:"required"`
+	Type           string          `json:"type" binding:"required"`
+	Config         json.RawMessage `json:"config" swaggertype:"object"`
+	Secret         string          `json:"secret,omitempty"`
+	CertificateID  *uint           `json:"certificateId,omitempty"`
+	OrganizationID *uint           `json:"organizationId,omitempty"`
+}
+
+type UpdateDeploymentTargetRequest struct {
+	Name   *string         `json:"name,omitempty"`
+	Config json.RawMessage `json:"config,omitempty" swaggertype:"object"`
+	Secret *string         `json:"secret,omitempty"`
+	// CertificateID binds the target to another certificate; 0 unbinds it
+	CertificateID *uint `json:"certificateId,omitempty"`
+}
+
+type DeployCertificateRequest struct {
+	// CertificateID deploys another certificate than the one bound to the target
+	CertificateID *uint `json:"certificateId,omitempty"`
+}
+
+// @Summary Get deployment target types
+// @Description Get the kinds of deployment target this server supports
+// @Tags deployment
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/deployments/types [get]
+func (h *Handler) GetDeploymentTargetTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"types": h.DeploymentService.TargetTypes()})
+}
+
+// @Summary Get deployment targets
+// @Description Get the places certificates are deployed to, with the outcome of their latest deployment
+// @Tags deployment
+// @Produce json
+// @Security BearerAuth
+// @Param organizationId query int false "List the organization's targets instead of the user's"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/deployments/targets [get]
+func (h *Handler) GetDeploymentTargets(c *gin.Context) {
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	targets, total, err := h.DeploymentService.ListTargets(c.Request.Context(), scope, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deployment targets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"targets":    targets,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Create deployment target
+// @Description Add a directory, Kubernetes Secret or webhook target. A target bound to a certificate is deployed again whenever the certificate is renewed
+// @Tags deployment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateDeploymentTargetRequest true "Deployment target"
+// @Success 201 {object} models.DeploymentTarget
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/deployments/targets [post]
+func (h *Handler) CreateDeploymentTarget(c *gin.Context) {
+	var req CreateDeploymentTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.resolveInventoryScope(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	target, err := h.DeploymentService.CreateTarget(c.Request.Context(), scope, services.CreateDeploymentTargetRequest{
+		Name:          req.Name,
+		Type:          req.Type,
+		Config:        req.Config,
+		Secret:        req.Secret,
+		CertificateID: req.CertificateID,
+		PlatformAdmin: platformAdmin(c),
+	})
+	if err != nil {
+		h.deploymentError(c, err, "Failed to create deployment target")
+		return
+	}
+
+	c.JSON(http.StatusCreated, target)
+}
+
+// @Summary Get deployment target
+// @Description Get a deployment target with the certificate bound to it
+// @Tags deployment
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Target ID"
+// @Param organizationId query int false "Organization owning the target"
+// @Success 200 {object} models.DeploymentTarget
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/deployments/targets/{id} [get]
+func (h *Handler) GetDeploymentTarget(c *gin.Context) {
+	id, ok := deploymentTargetID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	target, err := h.DeploymentService.GetTarget(c.Request.Context(), scope, id)
+	if err != nil {
+		h.deploymentError(c, err, "Failed to fetch deployment target")
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// @Summary Update deployment target
+// @Description Rename a target, change its configuration or secret, or bind it to another certificate
+// @Tags deployment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Target ID"
+// @Param organizationId query int false "Organization owning the target"
+// @Param request body UpdateDeploymentTargetRequest true "Target update"
+// @Success 200 {object} models.DeploymentTarget
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/deployments/targets/{id} [patch]
+func (h *Handler) UpdateDeploymentTarget(c *gin.Context) {
+	id, ok := deploymentTargetID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateDeploymentTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	target, err := h.DeploymentService.UpdateTarget(c.Request.Context(), scope, id, services.UpdateDeploymentTargetRequest{
+		Name:          req.Name,
+		Config:        req.Config,
+		Secret:        req.Secret,
+		CertificateID: req.CertificateID,
+		PlatformAdmin: platformAdmin(c),
+	})
+	if err != nil {
+		h.deploymentError(c, err, "Failed to update deployment target")
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// @Summary Delete deployment target
+// @Description Remove a deployment target and its deployment history; deployed files are left in place
+// @Tags deployment
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Target ID"
+// @Param organizationId query int false "Organization owning the target"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/deployments/targets/{id} [delete]
+func (h *Handler) DeleteDeploymentTarget(c *gin.Context) {
+	id, ok := deploymentTargetID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.DeploymentService.DeleteTarget(c.Request.Context(), scope, id); err != nil {
+		h.deploymentError(c, err, "Failed to delete deployment target")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deployment target deleted successfully"})
+}
+
+// @Summary Deploy certificate
+// @Description Deploy the bound certificate, or another one, to a target now. Kubernetes targets return the rendered manifest as the artifact, which is not stored
+// @Tags deployment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Target ID"
+// @Param organizationId query int false "Organization owning the target"
+// @Param request body DeployCertificateRequest false "Certificate to deploy"
+// @Success 200 {object} models.Deployment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/deployments/targets/{id}/deploy [post]
+func (h *Handler) DeployCertificate(c *gin.Context) {
+	id, ok := deploymentTargetID(c)
+	if !ok {
+		return
+	}
+
+	var req DeployCertificateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "certificate_deploy", "Deployment to target "+c.Param("id"))
+
+	deployment, err := h.DeploymentService.Deploy(c.Request.Context(), scope, id, req.CertificateID)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		h.deploymentError(c, err, "Failed to deploy certificate")
+		return
+	}
+
+	if deployment.Success {
+		h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Certificate %d deployed", deployment.CertificateID))
+	} else {
+		h.finishOperation(operation, models.OpStatusFailed, deployment.Error, "")
+	}
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, deployment)
+}
+
+// @Summary Get deployments
+// @Description Get the deployment attempts of a target, newest first
+// @Tags deployment
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Target ID"
+// @Param organizationId query int false "Organization owning the target"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/deployments/targets/{id}/deployments [get]
+func (h *Handler) GetDeployments(c *gin.Context) {
+	id, ok := deploymentTargetID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	deployments, total, err := h.DeploymentService.ListDeployments(c.Request.Context(), scope, id, (page-1)*limit, limit)
+	if err != nil {
+		h.deploymentError(c, err, "Failed to fetch deployments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deployments": deployments,
+		"pagination":  paginationInfo(page, limit, total),
+	})
+}
+
+// Helper functions
+
+func deploymentTargetID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *Handler) deploymentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment target not found"})
+	case errors.Is(err, services.ErrInvalidDeploymentTarget), errors.Is(err, services.ErrNoCertificateToDeploy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlatformAdminOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get expiry notifications
+// @Description Get the expiry notices sent for the user's certificates
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/expiry/notifications [get]
+func (h *Handler) GetExpiryNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	notifications, err := h.ExpiryService.GetNotifications(c.Request.Context(), userID.(uint), (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expiry notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"page":          page,
+		"limit":         limit,
+	})
+}
+
+// @Summary Run expiry check (Admin)
+// @Description Scan for expiring certificates now and send any notices that are due
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.ExpiryCheckResult
+// @Router /api/v1/admin/expiry/check [post]
+func (h *Handler) RunExpiryCheck(c *gin.Context) {
+	operation := h.startOperation(c, "expiry_check", "Certificate expiry check")
+
+	result, err := h.ExpiryService.CheckExpiring(c.Request.Context())
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Checked %d certificates, sent %d notices, %d failed", result.Checked, result.Sent, result.Failed))
+
+	c.JSON(http.StatusOK, result)
+}
@@ -2,9 +2,22 @@ package handlers
 
 import (
 	"web-openssl-backend/internal/config"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/internal/services"
+	"web-openssl-backend/pkg/acme"
+	"web-openssl-backend/pkg/acmeclient"
 	"web-openssl-backend/pkg/auth"
 	"web-openssl-backend/pkg/billing"
+	"web-openssl-backend/pkg/ct"
+	"web-openssl-backend/pkg/deploy"
+	"web-openssl-backend/pkg/mail"
+	"web-openssl-backend/pkg/notify"
+	"web-openssl-backend/pkg/oidc"
 	"web-openssl-backend/pkg/openssl"
+	"web-openssl-backend/pkg/pgp"
+	"web-openssl-backend/pkg/tsa"
+	"web-openssl-backend/pkg/vault"
+	"web-openssl-backend/pkg/webauthn"
 
 	"gorm.io/gorm"
 )
@@ -15,10 +28,148 @@ type Handler struct {
 	AuthService    *auth.Service
 	BillingService *billing.Service
 	OpenSSLService *openssl.Service
+	PGPService     *pgp.Service
+	TSAService     *tsa.Service
+	CTService      *ct.Service
+	ACMEService    *acme.Service
+	ACMEClient     *acmeclient.Service
+	VaultService   *vault.Service
+	Notifier       *notify.Service
+	Mailer         *mail.Service
+
+	KeyVault          services.KeyVaultService
+	InventoryService  services.InventoryService
+	ExpiryService     services.ExpiryService
+	MonitoringService services.MonitoringService
+	RenewalService    services.RenewalService
+	DeploymentService services.DeploymentService
+	ProfileService    services.ProfileService
+	BatchService      services.BatchService
+	AccountService    services.AccountService
+	SessionService    services.SessionService
+	APIKeyService     services.APIKeyService
+	MFAService        services.MFAService
+	WebAuthnService   services.WebAuthnService
+	SSOService        services.SSOService
+	SecurityService   services.SecurityService
 }
 
 func NewHandler(db *gorm.DB, cfg *config.Config, authService *auth.Service, billingService *billing.Service) *Handler {
 	opensslService := openssl.NewService(cfg.OpenSSL.BinaryPath)
+	vaultService := vault.NewService(vault.Config{
+		Provider:           cfg.Vault.Provider,
+		MasterKey:          cfg.Vault.MasterKey,
+		MasterKeyID:        cfg.Vault.MasterKeyID,
+		PreviousMasterKeys: cfg.Vault.PreviousMasterKeys,
+		KeyDir:             cfg.Vault.KeyDir,
+		PKCS11Module:       cfg.Vault.PKCS11Module,
+		PKCS11Slot:         cfg.Vault.PKCS11Slot,
+		PKCS11PIN:          cfg.Vault.PKCS11PIN,
+		PKCS11KeyLabel:     cfg.Vault.PKCS11KeyLabel,
+	})
+	keyVault := services.NewKeyVaultService(vaultService, repository.NewSealedKeyRepository(db))
+	notifier := notify.NewService(notify.Config{
+		SMTPHost:        cfg.Notify.SMTPHost,
+		SMTPPort:        cfg.Notify.SMTPPort,
+		SMTPUsername:    cfg.Notify.SMTPUsername,
+		SMTPPassword:    cfg.Notify.SMTPPassword,
+		SMTPFrom:        cfg.Notify.SMTPFrom,
+		SMTPTo:          cfg.Notify.SMTPTo,
+		WebhookURL:      cfg.Notify.WebhookURL,
+		WebhookSecret:   cfg.Notify.WebhookSecret,
+		SlackWebhookURL: cfg.Notify.SlackWebhookURL,
+	})
+	mailer := mail.NewService(mail.Config{
+		Provider:     cfg.Mail.Provider,
+		From:         cfg.Mail.From,
+		SMTPHost:     cfg.Mail.SMTPHost,
+		SMTPPort:     cfg.Mail.SMTPPort,
+		SMTPUsername: cfg.Mail.SMTPUsername,
+		SMTPPassword: cfg.Mail.SMTPPassword,
+		Dir:          cfg.Mail.FileDir,
+	})
+	certificateRepo := repository.NewCertificateRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	monitoringService := services.NewMonitoringService(
+		repository.NewMonitoredEndpointRepository(db),
+		repository.NewEndpointScanRepository(db),
+		userRepo,
+		opensslService,
+		notifier,
+		cfg.Monitoring.DefaultScanInterval,
+		cfg.Monitoring.MinScanInterval,
+		cfg.Monitoring.Concurrency,
+	)
+	expiryService := services.NewExpiryService(
+		certificateRepo,
+		repository.NewExpiryNotificationRepository(db),
+		userRepo,
+		notifier,
+		cfg.Expiry.Thresholds,
+	)
+	expiryService.AddSource(monitoringService.ExpiringEndpoints)
+	acmeService := acme.NewService(acme.Config{
+		BaseURL:        cfg.ACME.BaseURL,
+		CACertFile:     cfg.ACME.CACertFile,
+		CAKeyFile:      cfg.ACME.CAKeyFile,
+		ValidityDays:   cfg.ACME.ValidityDays,
+		AllowedDomains: cfg.ACME.AllowedDomains,
+	}, opensslService, repository.NewACMEStore(db))
+	acmeClient := acmeclient.NewService(acmeclient.Config{
+		AllowedDirectories:  cfg.ACMEClient.AllowedDirectories,
+		DNSWebhookURL:       cfg.ACMEClient.DNSWebhookURL,
+		DNSWebhookSecret:    cfg.ACMEClient.DNSWebhookSecret,
+		DNSPropagationDelay: cfg.ACMEClient.DNSPropagationDelay,
+	})
+	inventoryService := services.NewInventoryService(
+		certificateRepo,
+		repository.NewKeyPairRepository(db),
+		repository.NewOrganizationRepository(db),
+		keyVault,
+	)
+	renewalService := services.NewRenewalService(
+		certificateRepo,
+		repository.NewRenewalPolicyRepository(db),
+		repository.NewCertificateRenewalRepository(db),
+		userRepo,
+		inventoryService,
+		keyVault,
+		acmeService,
+		acmeClient,
+		notifier,
+		cfg.Renewal.DefaultDays,
+	)
+	deploymentService := services.NewDeploymentService(
+		repository.NewDeploymentTargetRepository(db),
+		repository.NewDeploymentRepository(db),
+		certificateRepo,
+		keyVault,
+		deploy.NewService(deploy.Config{
+			DirectoryRoot:   cfg.Deploy.DirectoryRoot,
+			AllowPostHooks:  cfg.Deploy.AllowPostHooks,
+			PostHookTimeout: cfg.Deploy.PostHookTimeout,
+		}),
+	)
+	renewalService.OnRenewed(deploymentService.CertificateRenewed)
+	accountService := services.NewAccountService(
+		userRepo,
+		repository.NewPasswordResetTokenRepository(db),
+		authService,
+		mailer,
+		cfg.Account.AppURL,
+		cfg.Account.PasswordResetTTL,
+		cfg.Account.EmailVerificationTTL,
+		cfg.Account.UnverifiedRestrictions,
+	)
+	sessionService := services.NewSessionService(
+		userRepo,
+		repository.NewRefreshTokenRepository(db),
+		repository.NewSessionRepository(db),
+		authService,
+		cfg.JWT.RefreshExpiresIn,
+	)
+	authService.AddTokenCheck(accountService.CheckToken)
+	authService.AddTokenCheck(sessionService.CheckToken)
 
 	return &Handler{
 		DB:             db,
@@ -26,5 +177,82 @@ func NewHandler(db *gorm.DB, cfg *config.Config, authService *auth.Service, bill
 		AuthService:    authService,
 		BillingService: billingService,
 		OpenSSLService: opensslService,
+		PGPService:     pgp.NewService(),
+		TSAService: tsa.NewService(cfg.OpenSSL.BinaryPath, tsa.Config{
+			CertFile:   cfg.TSA.CertFile,
+			KeyFile:    cfg.TSA.KeyFile,
+			ChainFile:  cfg.TSA.ChainFile,
+			PolicyOID:  cfg.TSA.PolicyOID,
+			SerialFile: cfg.TSA.SerialFile,
+		}),
+		CTService:         ct.NewService(),
+		ACMEService:       acmeService,
+		ACMEClient:        acmeClient,
+		VaultService:      vaultService,
+		Notifier:          notifier,
+		Mailer:            mailer,
+		KeyVault:          keyVault,
+		InventoryService:  inventoryService,
+		ExpiryService:     expiryService,
+		MonitoringService: monitoringService,
+		RenewalService:    renewalService,
+		DeploymentService: deploymentService,
+		ProfileService: services.NewProfileService(
+			repository.NewCertificateProfileRepository(db),
+			repository.NewOrganizationRepository(db),
+		),
+		BatchService: services.NewBatchService(
+			repository.NewBatchJobRepository(db),
+			repository.NewOperationRepository(db),
+			userRepo,
+			cfg.Batch.Concurrency,
+			cfg.Batch.JobsPerUser,
+		),
+		AccountService: accountService,
+		SessionService: sessionService,
+		APIKeyService:  services.NewAPIKeyService(repository.NewAPIKeyRepository(db), userRepo),
+		MFAService: services.NewMFAService(
+			repository.NewMFARepository(db),
+			userRepo,
+			repository.NewOrganizationRepository(db),
+			keyVault,
+			authService,
+			cfg.Account.MFAIssuer,
+		),
+		WebAuthnService: services.NewWebAuthnService(
+			repository.NewWebAuthnRepository(db),
+			userRepo,
+			webauthn.NewService(webauthn.Config{
+				RPID:    cfg.WebAuthn.RPID,
+				RPName:  cfg.WebAuthn.RPName,
+				Origins: cfg.WebAuthn.Origins,
+			}),
+		),
+		SSOService: services.NewSSOService(
+			repository.NewSSORepository(db),
+			userRepo,
+			repository.NewOrganizationRepository(db),
+			keyVault,
+			authService,
+			oidc.NewService(),
+			cfg.SSO.BaseURL,
+		),
+		SecurityService: services.NewSecurityService(
+			repository.NewSecurityRepository(db),
+			userRepo,
+			authService,
+			mailer,
+			cfg.Account.AppURL,
+			services.LoginPolicy{
+				BackoffAfter:     cfg.Login.BackoffAfter,
+				IPBackoffAfter:   cfg.Login.IPBackoffAfter,
+				BackoffBase:      cfg.Login.BackoffBase,
+				BackoffMax:       cfg.Login.BackoffMax,
+				LockoutThreshold: cfg.Login.LockoutThreshold,
+				LockoutDuration:  cfg.Login.LockoutDuration,
+				FailureWindow:    cfg.Login.FailureWindow,
+				EventRetention:   cfg.Login.EventRetention,
+			},
+		),
 	}
 }
\ No newline at end of file
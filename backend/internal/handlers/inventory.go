@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ImportCertificateRequest struct {
+	Name           string `json:"name,omitempty"`
+	Certificate    string `json:"certificate" binding:"required"`
+	PrivateKey     string `json:"privateKey,omitempty"`
+	Exportable     bool   `json:"exportable,omitempty"`
+	OrganizationID *uint  `json:"organizationId,omitempty"`
+}
+
+type ImportKeyPairRequest struct {
+	Name           string `json:"name,omitempty"`
+	PrivateKey     string `json:"privateKey" binding:"required"`
+	PublicKey      string `json:"publicKey,omitempty"`
+	Exportable     bool   `json:"exportable,omitempty"`
+	OrganizationID *uint  `json:"organizationId,omitempty"`
+}
+
+type UpdateKeyPairRequest struct {
+	Exportable *bool `json:"exportable" binding:"required"`
+}
+
+// @Summary Search certificate inventory
+// @Description Search stored certificates by name, issuer, serial number and expiry
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param organizationId query int false "Search the organization's inventory instead of the user's"
+// @Param commonName query string false "Common name contains"
+// @Param san query string false "Subject alternative name contains"
+// @Param issuer query string false "Issuer contains"
+// @Param serial query string false "Serial number (hex)"
+// @Param source query string false "Source (generated, imported, signed, acme)"
+// @Param expiresAfter query string false "Not after at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param expiresBefore query string false "Not after before (RFC 3339 or YYYY-MM-DD)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/inventory/certificates [get]
+func (h *Handler) SearchCertificates(c *gin.Context) {
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	filter := repository.CertificateFilter{
+		Scope:        scope,
+		CommonName:   c.Query("commonName"),
+		SAN:          c.Query("san"),
+		Issuer:       c.Query("issuer"),
+		SerialNumber: c.Query("serial"),
+		Source:       models.CertificateSource(c.Query("source")),
+		Offset:       (page - 1) * limit,
+		Limit:        limit,
+	}
+
+	var err error
+	if filter.ExpiresAfter, err = parseQueryTime(c, "expiresAfter"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.ExpiresBefore, err = parseQueryTime(c, "expiresBefore"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	certs, total, err := h.InventoryService.SearchCertificates(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search certificates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"certificates": certs,
+		"pagination":   paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Import certificate
+// @Description Store a PEM certificate, with optional chain and private key, in the inventory
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ImportCertificateRequest true "Certificate import request"
+// @Success 201 {object} models.Certificate
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/inventory/certificates [post]
+func (h *Handler) ImportCertificate(c *gin.Context) {
+	var req ImportCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.resolveInventoryScope(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	cert, err := h.InventoryService.ImportCertificate(c.Request.Context(), scope, services.ImportCertificateRequest{
+		Name:        req.Name,
+		Source:      models.SourceImported,
+		Certificate: req.Certificate,
+		PrivateKey:  req.PrivateKey,
+		Exportable:  req.Exportable,
+	})
+	if errors.Is(err, services.ErrCertificateExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "id": cert.ID})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cert)
+}
+
+// @Summary Get certificate
+// @Description Get a stored certificate with its key pair, issuer and issued certificates
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Certificate ID"
+// @Param organizationId query int false "Organization owning the certificate"
+// @Success 200 {object} models.Certificate
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/inventory/certificates/{id} [get]
+func (h *Handler) GetInventoryCertificate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	cert, err := h.InventoryService.GetCertificate(c.Request.Context(), scope, uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch certificate"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, cert)
+}
+
+// @Summary Delete certificate
+// @Description Remove a certificate from the inventory
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Certificate ID"
+// @Param organizationId query int false "Organization owning the certificate"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/inventory/certificates/{id} [delete]
+func (h *Handler) DeleteInventoryCertificate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.InventoryService.DeleteCertificate(c.Request.Context(), scope, uint(id)); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete certificate"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Certificate deleted successfully"})
+}
+
+// @Summary List key pairs
+// @Description List stored key pairs
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param organizationId query int false "List the organization's keys instead of the user's"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/inventory/keys [get]
+func (h *Handler) GetKeyPairs(c *gin.Context) {
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	keys, total, err := h.InventoryService.ListKeyPairs(c.Request.Context(), scope, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch key pairs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keyPairs":   keys,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Import key pair
+// @Description Store a PEM private key in the inventory and link it to matching certificates
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ImportKeyPairRequest true "Key import request"
+// @Success 201 {object} models.KeyPair
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/inventory/keys [post]
+func (h *Handler) ImportKeyPair(c *gin.Context) {
+	var req ImportKeyPairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.resolveInventoryScope(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	key, err := h.InventoryService.ImportKeyPair(c.Request.Context(), scope, services.ImportKeyPairRequest{
+		Name:       req.Name,
+		Source:     models.SourceImported,
+		PrivateKey: req.PrivateKey,
+		PublicKey:  req.PublicKey,
+		Exportable: req.Exportable,
+	})
+	if errors.Is(err, services.ErrKeyPairExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "id": key.ID})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// @Summary Get key pair
+// @Description Get a stored key pair with the certificates issued for it
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Key pair ID"
+// @Param organizationId query int false "Organization owning the key"
+// @Success 200 {object} models.KeyPair
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/inventory/keys/{id} [get]
+func (h *Handler) GetKeyPair(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key pair ID"})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.InventoryService.GetKeyPair(c.Request.Context(), scope, uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Key pair not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch key pair"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// @Summary Update key pair
+// @Description Grant or revoke export of a stored private key (owner or organization admin)
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Key pair ID"
+// @Param organizationId query int false "Organization owning the key"
+// @Param request body UpdateKeyPairRequest true "Key pair update request"
+// @Success 200 {object} models.KeyPair
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/inventory/keys/{id} [patch]
+func (h *Handler) UpdateKeyPair(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key pair ID"})
+		return
+	}
+
+	var req UpdateKeyPairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.InventoryService.SetKeyPairExportable(c.Request.Context(), scope, uint(id), *req.Exportable)
+	if err != nil {
+		h.keyPairError(c, err, "Failed to update key pair")
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// @Summary Export private key
+// @Description Decrypt a stored private key from the vault; requires export to be granted on the key
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Key pair ID"
+// @Param organizationId query int false "Organization owning the key"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/inventory/keys/{id}/export [post]
+func (h *Handler) ExportKeyPair(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key pair ID"})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	// Every export attempt is recorded
+	operation := h.startOperation(c, "export_key", "Private key export for key pair "+c.Param("id"))
+
+	privateKey, err := h.InventoryService.ExportKeyPair(c.Request.Context(), scope, uint(id))
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		h.keyPairError(c, err, "Failed to export key pair")
+		return
+	}
+
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Private key exported")
+
+	c.JSON(http.StatusOK, gin.H{"privateKey": privateKey})
+}
+
+// @Summary Delete key pair
+// @Description Remove a key pair from the inventory; its certificates are kept
+// @Tags inventory
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Key pair ID"
+// @Param organizationId query int false "Organization owning the key"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/inventory/keys/{id} [delete]
+func (h *Handler) DeleteKeyPair(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key pair ID"})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.InventoryService.DeleteKeyPair(c.Request.Context(), scope, uint(id)); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Key pair not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete key pair"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Key pair deleted successfully"})
+}
+
+// Helper functions
+
+// inventoryScope resolves the scope named by the organizationId query
+// parameter, defaulting to the user's own inventory.
+func (h *Handler) inventoryScope(c *gin.Context) (repository.Scope, bool) {
+	var organizationID *uint
+	if value := c.Query("organizationId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+			return repository.Scope{}, false
+		}
+		orgID := uint(id)
+		organizationID = &orgID
+	}
+
+	return h.resolveInventoryScope(c, organizationID)
+}
+
+func (h *Handler) resolveInventoryScope(c *gin.Context, organizationID *uint) (repository.Scope, bool) {
+	userID, _ := c.Get("user_id")
+
+	scope, err := h.InventoryService.ResolveScope(c.Request.Context(), userID.(uint), organizationID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotOrganizationMember) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve organization"})
+		}
+		return scope, false
+	}
+	return scope, true
+}
+
+// storeInInventory keeps the result of a generating operation in the user's
+// inventory. The operation has already succeeded, so a failure here is
+// logged rather than returned.
+func (h *Handler) storeInInventory(c *gin.Context, source models.CertificateSource, certificate, privateKey, publicKey string) {
+	userID, _ := c.Get("user_id")
+	h.storeInUserInventory(c.Request.Context(), userID.(uint), source, certificate, privateKey, publicKey)
+}
+
+// storeInUserInventory is storeInInventory for results produced outside a
+// request, such as by batch jobs.
+func (h *Handler) storeInUserInventory(ctx context.Context, userID uint, source models.CertificateSource, certificate, privateKey, publicKey string) {
+	scope := repository.Scope{UserID: userID}
+
+	var err error
+	if certificate != "" {
+		_, err = h.InventoryService.ImportCertificate(ctx, scope, services.ImportCertificateRequest{
+			Source:      source,
+			Certificate: certificate,
+			PrivateKey:  privateKey,
+		})
+	} else {
+		_, err = h.InventoryService.ImportKeyPair(ctx, scope, services.ImportKeyPairRequest{
+			Source:     source,
+			PrivateKey: privateKey,
+			PublicKey:  publicKey,
+		})
+	}
+	if err != nil && !errors.Is(err, services.ErrCertificateExists) && !errors.Is(err, services.ErrKeyPairExists) {
+		log.Printf("Failed to store %s result in inventory: %v", source, err)
+	}
+}
+
+func (h *Handler) keyPairError(c *gin.Context, err error, message string) {
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key pair not found"})
+	case errors.Is(err, services.ErrExportNotPermitted),
+		errors.Is(err, services.ErrOrganizationAdminOnly),
+		errors.Is(err, services.ErrNotOrganizationMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func inventoryPagination(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
+
+func paginationInfo(page, limit int, total int64) map[string]interface{} {
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	return map[string]interface{}{
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": totalPages,
+		"hasNext":    page < totalPages,
+		"hasPrev":    page > 1,
+	}
+}
+
+func parseQueryTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("invalid " + name + ": use RFC 3339 or YYYY-MM-DD")
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+	"web-openssl-backend/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFACodeRequest struct {
+	// Code is a code from the authenticator app, or an unused recovery code
+	Code string `json:"code" binding:"required"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFAPolicyRequest struct {
+	Required bool `json:"required"`
+}
+
+// @Summary Get two-factor authentication status
+// @Description Get whether the current user has two-factor authentication enabled, whether an organization requires it and how many recovery codes are left
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.MFAStatus
+// @Router /api/v1/auth/mfa [get]
+func (h *Handler) GetMFAStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	status, err := h.MFAService.Status(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor authentication status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary Start TOTP enrolment
+// @Description Create an authenticator secret and its otpauth:// URI to show as a QR code. It takes effect once confirmed with a code
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.TOTPEnrollment
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/auth/mfa/totp [post]
+func (h *Handler) BeginTOTPEnrollment(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	enrollment, err := h.MFAService.BeginEnrollment(c.Request.Context(), userID.(uint))
+	if err != nil {
+		h.mfaError(c, err, "Failed to start two-factor enrolment")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Confirm TOTP enrolment
+// @Description Enable two-factor authentication with a code from the authenticator app. Returns the recovery codes, shown only once, and tokens for a session that passed two-factor authentication
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authenticator code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/mfa/totp/confirm [post]
+func (h *Handler) ConfirmTOTPEnrollment(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	codes, err := h.MFAService.ConfirmEnrollment(c.Request.Context(), userID.(uint), req.Code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	// The current session did not pass two-factor authentication, so the
+	// user gets one that did
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), &user, []string{auth.AuthMethodPassword, auth.AuthMethodOTP}, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": codes,
+		"accessToken":   tokens.AccessToken,
+		"refreshToken":  tokens.RefreshToken,
+	})
+}
+
+// @Summary Disable TOTP
+// @Description Disable two-factor authentication, confirmed with a code. Not possible while an organization requires it
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/auth/mfa/totp [delete]
+func (h *Handler) DisableTOTP(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.MFAService.Disable(c.Request.Context(), userID.(uint), req.Code); err != nil {
+		h.mfaError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace the recovery codes, confirmed with a code. The old codes stop working
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/mfa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	codes, err := h.MFAService.RegenerateRecoveryCodes(c.Request.Context(), userID.(uint), req.Code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// @Summary Complete login with two-factor authentication
+// @Description Swap the mfaToken returned by login and a code from the authenticator app, or a recovery code, for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyMFARequest true "Login challenge"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /api/v1/auth/mfa/verify [post]
+func (h *Handler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.MFAService.ChallengeUser(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		h.mfaError(c, err, "Failed to verify login challenge")
+		return
+	}
+	// Second factor guesses count against the account like password ones
+	attempt := loginAttempt(c, user.Email)
+	if !h.checkLogin(c, attempt) {
+		return
+	}
+	defer h.loginAborted(c, attempt)
+
+	user, err = h.MFAService.VerifyChallenge(c.Request.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFALocked) {
+			h.loginFailed(c, attempt, services.LoginFailureInvalidSecondFactor)
+		}
+		h.mfaError(c, err, "Failed to verify authentication code")
+		return
+	}
+
+	methods := []string{auth.AuthMethodPassword, auth.AuthMethodOTP}
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), user, methods, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+	h.loginPassed(c, attempt, user, methods)
+
+	// Remove password from response
+	user.Password = ""
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         *user,
+	})
+}
+
+// @Summary Set organization two-factor policy
+// @Description Require two-factor authentication of all members of an organization. Organization admins only; they need it enabled themselves to require it
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param request body MFAPolicyRequest true "Policy"
+// @Success 200 {object} models.Organization
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/organizations/{id}/mfa-policy [put]
+func (h *Handler) SetOrganizationMFAPolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req MFAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	org, err := h.MFAService.SetOrganizationPolicy(c.Request.Context(), userID.(uint), uint(id), req.Required)
+	if err != nil {
+		h.mfaError(c, err, "Failed to update organization policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// RequireMFA refuses members of organizations that require two-factor
+// authentication until their session has passed it. The auth and profile
+// routes stay open, so that they can enrol. API keys are not affected.
+func (h *Handler) RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key"); ok || c.GetBool("mfa") {
+			c.Next()
+			return
+		}
+		path := c.FullPath()
+		if strings.HasPrefix(path, "/api/v1/auth/") || strings.HasPrefix(path, "/api/v1/users/me") {
+			c.Next()
+			return
+		}
+
+		userID, _ := c.Get("user_id")
+		required, err := h.MFAService.Required(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication"})
+			c.Abort()
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                 services.ErrMFARequiredByOrganization.Error(),
+				"mfaEnrollmentRequired": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Helper functions
+
+// mfaMethods lists the second factors a user can complete a password login
+// with: "totp" and "webauthn".
+func (h *Handler) mfaMethods(c *gin.Context, userID uint) ([]string, error) {
+	var methods []string
+
+	totpEnabled, err := h.MFAService.Enabled(c.Request.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if totpEnabled {
+		methods = append(methods, "totp")
+	}
+
+	hasPasskeys, err := h.WebAuthnService.HasCredentials(c.Request.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if hasPasskeys {
+		methods = append(methods, "webauthn")
+	}
+	return methods, nil
+}
+
+func (h *Handler) mfaError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFAChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFALocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMFACode),
+		errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrMFANotEnrolling):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFARequiredByOrganization),
+		errors.Is(err, services.ErrOrganizationAdminOnly),
+		errors.Is(err, services.ErrNotOrganizationMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateEndpointRequest struct {
+	Name            string `json:"name,omitempty"`
+	Hostname        string `json:"hostname" binding:"required"`
+	Port            int    `json:"port,omitempty"`
+	IntervalMinutes int    `json:"intervalMinutes,omitempty"`
+	OrganizationID  *uint  `json:"organizationId,omitempty"`
+}
+
+type UpdateEndpointRequest struct {
+	Name            *string `json:"name,omitempty"`
+	IntervalMinutes *int    `json:"intervalMinutes,omitempty"`
+	Paused          *bool   `json:"paused,omitempty"`
+}
+
+// @Summary Get monitored endpoints
+// @Description Get the TLS endpoints scanned on a schedule, with the result of their latest scan
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param organizationId query int false "List the organization's endpoints instead of the user's"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/monitoring/endpoints [get]
+func (h *Handler) GetMonitoredEndpoints(c *gin.Context) {
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	endpoints, total, err := h.MonitoringService.ListEndpoints(c.Request.Context(), scope, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch endpoints"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoints":  endpoints,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Add monitored endpoint
+// @Description Register a host:port to be scanned on a schedule; the first scan runs shortly after
+// @Tags monitoring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateEndpointRequest true "Endpoint"
+// @Success 201 {object} models.MonitoredEndpoint
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/monitoring/endpoints [post]
+func (h *Handler) CreateMonitoredEndpoint(c *gin.Context) {
+	var req CreateEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.resolveInventoryScope(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.MonitoringService.CreateEndpoint(c.Request.Context(), scope, services.CreateEndpointRequest{
+		Name:            req.Name,
+		Hostname:        req.Hostname,
+		Port:            req.Port,
+		IntervalMinutes: req.IntervalMinutes,
+	})
+	if errors.Is(err, services.ErrEndpointExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "id": endpoint.ID})
+		return
+	}
+	if err != nil {
+		h.endpointError(c, err, "Failed to add endpoint")
+		return
+	}
+
+	c.JSON(http.StatusCreated, endpoint)
+}
+
+// @Summary Get monitored endpoint
+// @Description Get a monitored endpoint with the result of its latest scan
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Success 200 {object} models.MonitoredEndpoint
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id} [get]
+func (h *Handler) GetMonitoredEndpoint(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.MonitoringService.GetEndpoint(c.Request.Context(), scope, id)
+	if err != nil {
+		h.endpointError(c, err, "Failed to fetch endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// @Summary Update monitored endpoint
+// @Description Rename an endpoint, change its scan interval or pause its scans
+// @Tags monitoring
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Param request body UpdateEndpointRequest true "Endpoint update"
+// @Success 200 {object} models.MonitoredEndpoint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id} [patch]
+func (h *Handler) UpdateMonitoredEndpoint(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.MonitoringService.UpdateEndpoint(c.Request.Context(), scope, id, services.UpdateEndpointRequest{
+		Name:            req.Name,
+		IntervalMinutes: req.IntervalMinutes,
+		Paused:          req.Paused,
+	})
+	if err != nil {
+		h.endpointError(c, err, "Failed to update endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// @Summary Delete monitored endpoint
+// @Description Stop monitoring an endpoint and remove its scan history
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id} [delete]
+func (h *Handler) DeleteMonitoredEndpoint(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.MonitoringService.DeleteEndpoint(c.Request.Context(), scope, id); err != nil {
+		h.endpointError(c, err, "Failed to delete endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Endpoint deleted successfully"})
+}
+
+// @Summary Scan monitored endpoint
+// @Description Scan an endpoint now; the result is stored and compared with the previous scan
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Success 200 {object} models.EndpointScan
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id}/scan [post]
+func (h *Handler) ScanMonitoredEndpoint(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "ssl_test", "Scan of monitored endpoint "+c.Param("id"))
+
+	scan, err := h.MonitoringService.ScanEndpoint(c.Request.Context(), scope, id)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		h.endpointError(c, err, "Failed to scan endpoint")
+		return
+	}
+
+	if scan.Success {
+		h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Endpoint scanned, grade %s", scan.Grade))
+	} else {
+		h.finishOperation(operation, models.OpStatusFailed, scan.Error, "")
+	}
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// @Summary Get endpoint scans
+// @Description Get the scan history of a monitored endpoint, newest first
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id}/scans [get]
+func (h *Handler) GetEndpointScans(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	scans, total, err := h.MonitoringService.ListScans(c.Request.Context(), scope, id, (page-1)*limit, limit)
+	if err != nil {
+		h.endpointError(c, err, "Failed to fetch scans")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scans":      scans,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Get endpoint scan
+// @Description Get one stored scan with the full scanner report
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param scanId path int true "Scan ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Success 200 {object} models.EndpointScan
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id}/scans/{scanId} [get]
+func (h *Handler) GetEndpointScan(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+	scanID, err := strconv.ParseUint(c.Param("scanId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan ID"})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	scan, err := h.MonitoringService.GetScan(c.Request.Context(), scope, id, uint(scanID))
+	if err != nil {
+		h.endpointError(c, err, "Failed to fetch scan")
+		return
+	}
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// @Summary Get endpoint grade trend
+// @Description Get the grade of every successful scan in a period, oldest first
+// @Tags monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param organizationId query int false "Organization owning the endpoint"
+// @Param days query int false "Number of days to include" default(90)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/monitoring/endpoints/{id}/trend [get]
+func (h *Handler) GetEndpointGradeTrend(c *gin.Context) {
+	id, ok := endpointID(c)
+	if !ok {
+		return
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if days < 1 || days > 730 {
+		days = 90
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	points, err := h.MonitoringService.GetGradeTrend(c.Request.Context(), scope, id, since)
+	if err != nil {
+		h.endpointError(c, err, "Failed to fetch grade trend")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpointId": id,
+		"since":      since,
+		"points":     points,
+	})
+}
+
+// @Summary Scan due endpoints (Admin)
+// @Description Scan every monitored endpoint whose next scan is due, without waiting for the scheduler
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.ScanDueResult
+// @Router /api/v1/admin/monitoring/scan [post]
+func (h *Handler) RunEndpointScans(c *gin.Context) {
+	operation := h.startOperation(c, "endpoint_scans", "Scheduled endpoint scans")
+
+	result, err := h.MonitoringService.ScanDue(c.Request.Context())
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Scanned %d endpoints, %d failed, %d alerts", result.Scanned, result.Failed, result.Alerts))
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Helper functions
+
+func endpointID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid endpoint ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *Handler) endpointError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Endpoint not found"})
+	case errors.Is(err, services.ErrInvalidEndpoint), errors.Is(err, services.ErrScanIntervalTooShort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
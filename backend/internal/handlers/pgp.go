@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/pgp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Generate OpenPGP key
+// @Description Generate a new OpenPGP key pair with one or more user IDs
+// @Tags pgp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pgp.GenerateKeyRequest true "OpenPGP key generation request"
+// @Success 200 {object} pgp.GenerateKeyResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/pgp/keys/generate [post]
+func (h *Handler) GeneratePGPKey(c *gin.Context) {
+	var req pgp.GenerateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "pgp_generate_key", req.UserIDs[0].Name)
+
+	// Generate key
+	response, err := h.PGPService.GenerateKey(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "OpenPGP key generated successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Sign message with OpenPGP
+// @Description Produce a cleartext or detached OpenPGP signature
+// @Tags pgp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pgp.SignRequest true "OpenPGP signing request"
+// @Success 200 {object} pgp.SignResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/pgp/sign [post]
+func (h *Handler) PGPSign(c *gin.Context) {
+	var req pgp.SignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	command := "Cleartext signature"
+	if req.Detached {
+		command = "Detached signature"
+	}
+	operation := h.startOperation(c, "pgp_sign", command)
+
+	// Sign message
+	response, err := h.PGPService.Sign(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Message signed successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Verify OpenPGP signature
+// @Description Verify a cleartext-signed message or a detached OpenPGP signature
+// @Tags pgp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pgp.VerifyRequest true "OpenPGP verification request"
+// @Success 200 {object} pgp.VerifyResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/pgp/verify [post]
+func (h *Handler) PGPVerify(c *gin.Context) {
+	var req pgp.VerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "pgp_verify", "Signature verification")
+
+	// Verify signature
+	response, err := h.PGPService.Verify(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Signature verified")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Encrypt message with OpenPGP
+// @Description Encrypt a message to one or more OpenPGP recipients, optionally signing it
+// @Tags pgp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pgp.EncryptRequest true "OpenPGP encryption request"
+// @Success 200 {object} pgp.EncryptResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/pgp/encrypt [post]
+func (h *Handler) PGPEncrypt(c *gin.Context) {
+	var req pgp.EncryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "pgp_encrypt", "OpenPGP encryption")
+
+	// Encrypt message
+	response, err := h.PGPService.Encrypt(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Message encrypted successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Decrypt OpenPGP message
+// @Description Decrypt an OpenPGP message and check its signature if present
+// @Tags pgp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pgp.DecryptRequest true "OpenPGP decryption request"
+// @Success 200 {object} pgp.DecryptResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/pgp/decrypt [post]
+func (h *Handler) PGPDecrypt(c *gin.Context) {
+	var req pgp.DecryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "pgp_decrypt", "OpenPGP decryption")
+
+	// Decrypt message
+	response, err := h.PGPService.Decrypt(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Message decrypted successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
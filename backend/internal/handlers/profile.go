@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateCertificateProfileRequest struct {
+	// OrganizationID is left out for a global profile, which only platform administrators may create
+	OrganizationID *uint    `json:"organizationId,omitempty"`
+	Name           string   `json:"name" binding:"required"`
+	Description    string   `json:"description,omitempty"`
+	KeyType        string   `json:"keyType" binding:"required"`
+	KeySize        int      `json:"keySize,omitempty"`
+	KeyUsage       []string `json:"keyUsage,omitempty"`
+	ExtKeyUsage    []string `json:"extKeyUsage,omitempty"`
+	HashAlgorithm  string   `json:"hashAlgorithm,omitempty"`
+	ValidDays      int      `json:"validDays" binding:"required"`
+	MaxValidDays   int      `json:"maxValidDays,omitempty"`
+	IsCA           bool     `json:"isCA,omitempty"`
+	LockedFields   []string `json:"lockedFields,omitempty"`
+}
+
+type UpdateCertificateProfileRequest struct {
+	Name          *string   `json:"name,omitempty"`
+	Description   *string   `json:"description,omitempty"`
+	KeyType       *string   `json:"keyType,omitempty"`
+	KeySize       *int      `json:"keySize,omitempty"`
+	KeyUsage      *[]string `json:"keyUsage,omitempty"`
+	ExtKeyUsage   *[]string `json:"extKeyUsage,omitempty"`
+	HashAlgorithm *string   `json:"hashAlgorithm,omitempty"`
+	ValidDays     *int      `json:"validDays,omitempty"`
+	MaxValidDays  *int      `json:"maxValidDays,omitempty"`
+	IsCA          *bool     `json:"isCA,omitempty"`
+	LockedFields  *[]string `json:"lockedFields,omitempty"`
+}
+
+// @Summary Get certificate profiles
+// @Description Get the global certificate profiles together with the organization's
+// @Tags profiles
+// @Produce json
+// @Security BearerAuth
+// @Param organizationId query int false "Include the organization's profiles"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/profiles [get]
+func (h *Handler) GetCertificateProfiles(c *gin.Context) {
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	profiles, total, err := h.ProfileService.ListProfiles(c.Request.Context(), scope, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch certificate profiles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profiles":   profiles,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Create certificate profile
+// @Description Create a profile for an organization, or a global one as a platform administrator. Locked fields cannot be overridden by requests using the profile
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateCertificateProfileRequest true "Certificate profile"
+// @Success 201 {object} models.CertificateProfile
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/profiles [post]
+func (h *Handler) CreateCertificateProfile(c *gin.Context) {
+	var req CreateCertificateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := h.ProfileService.CreateProfile(c.Request.Context(), profileActor(c), services.CreateCertificateProfileRequest{
+		OrganizationID: req.OrganizationID,
+		Name:           req.Name,
+		Description:    req.Description,
+		KeyType:        req.KeyType,
+		KeySize:        req.KeySize,
+		KeyUsage:       req.KeyUsage,
+		ExtKeyUsage:    req.ExtKeyUsage,
+		HashAlgorithm:  req.HashAlgorithm,
+		ValidDays:      req.ValidDays,
+		MaxValidDays:   req.MaxValidDays,
+		IsCA:           req.IsCA,
+		LockedFields:   req.LockedFields,
+	})
+	if err != nil {
+		h.profileError(c, err, "Failed to create certificate profile")
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+// @Summary Get certificate profile
+// @Description Get a global profile or one of an organization the user belongs to
+// @Tags profiles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Success 200 {object} models.CertificateProfile
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/profiles/{id} [get]
+func (h *Handler) GetCertificateProfile(c *gin.Context) {
+	id, ok := certificateProfileID(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	profile, err := h.ProfileService.GetProfile(c.Request.Context(), userID.(uint), id)
+	if err != nil {
+		h.profileError(c, err, "Failed to fetch certificate profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Update certificate profile
+// @Description Change a profile's defaults and constraints; certificates already issued with it are not affected
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Param request body UpdateCertificateProfileRequest true "Profile update"
+// @Success 200 {object} models.CertificateProfile
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/profiles/{id} [patch]
+func (h *Handler) UpdateCertificateProfile(c *gin.Context) {
+	id, ok := certificateProfileID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateCertificateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := h.ProfileService.UpdateProfile(c.Request.Context(), profileActor(c), id, services.UpdateCertificateProfileRequest{
+		Name:          req.Name,
+		Description:   req.Description,
+		KeyType:       req.KeyType,
+		KeySize:       req.KeySize,
+		KeyUsage:      req.KeyUsage,
+		ExtKeyUsage:   req.ExtKeyUsage,
+		HashAlgorithm: req.HashAlgorithm,
+		ValidDays:     req.ValidDays,
+		MaxValidDays:  req.MaxValidDays,
+		IsCA:          req.IsCA,
+		LockedFields:  req.LockedFields,
+	})
+	if err != nil {
+		h.profileError(c, err, "Failed to update certificate profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Delete certificate profile
+// @Description Remove a certificate profile
+// @Tags profiles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/profiles/{id} [delete]
+func (h *Handler) DeleteCertificateProfile(c *gin.Context) {
+	id, ok := certificateProfileID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ProfileService.DeleteProfile(c.Request.Context(), profileActor(c), id); err != nil {
+		h.profileError(c, err, "Failed to delete certificate profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Certificate profile deleted successfully"})
+}
+
+// Helper functions
+
+func certificateProfileID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func profileActor(c *gin.Context) services.ProfileActor {
+	userID, _ := c.Get("user_id")
+
+	return services.ProfileActor{
+		UserID:        userID.(uint),
+		PlatformAdmin: platformAdmin(c),
+	}
+}
+
+// platformAdmin reports whether the request comes from a platform
+// administrator
+func platformAdmin(c *gin.Context) bool {
+	role, _ := c.Get("user_role")
+	userRole, _ := role.(models.UserRole)
+	return userRole == models.RoleAdmin || userRole == models.RoleSuperAdmin
+}
+
+func (h *Handler) profileError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate profile not found"})
+	case errors.Is(err, services.ErrInvalidCertificateProfile), errors.Is(err, services.ErrProfileConstraint):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCertificateProfileExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlatformAdminOnly),
+		errors.Is(err, services.ErrOrganizationAdminOnly),
+		errors.Is(err, services.ErrNotOrganizationMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateRenewalPolicyRequest struct {
+	CertificateID    uint   `json:"certificateId" binding:"required"`
+	DaysBeforeExpiry int    `json:"daysBeforeExpiry,omitempty"`
+	KeyStrategy      string `json:"keyStrategy,omitempty" binding:"omitempty,oneof=reuse rotate"`
+	ChallengeType    string `json:"challengeType,omitempty" binding:"omitempty,oneof=http-01 dns-01"`
+	WebhookURL       string `json:"webhookUrl,omitempty" binding:"omitempty,url"`
+	WebhookSecret    string `json:"webhookSecret,omitempty"`
+	OrganizationID   *uint  `json:"organizationId,omitempty"`
+}
+
+type UpdateRenewalPolicyRequest struct {
+	DaysBeforeExpiry *int    `json:"daysBeforeExpiry,omitempty"`
+	KeyStrategy      *string `json:"keyStrategy,omitempty"`
+	ChallengeType    *string `json:"challengeType,omitempty"`
+	WebhookURL       *string `json:"webhookUrl,omitempty"`
+	WebhookSecret    *string `json:"webhookSecret,omitempty"`
+	Paused           *bool   `json:"paused,omitempty"`
+}
+
+// @Summary Get renewal policies
+// @Description Get the automatic renewal policies with the certificate each currently covers
+// @Tags renewal
+// @Produce json
+// @Security BearerAuth
+// @Param organizationId query int false "List the organization's policies instead of the user's"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/renewal/policies [get]
+func (h *Handler) GetRenewalPolicies(c *gin.Context) {
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	policies, total, err := h.RenewalService.ListPolicies(c.Request.Context(), scope, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch renewal policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policies":   policies,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Create renewal policy
+// @Description Renew an inventory certificate issued by the platform CA or obtained via ACME a number of days before it expires
+// @Tags renewal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateRenewalPolicyRequest true "Renewal policy"
+// @Success 201 {object} models.RenewalPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 422 {object} map[string]string
+// @Router /api/v1/renewal/policies [post]
+func (h *Handler) CreateRenewalPolicy(c *gin.Context) {
+	var req CreateRenewalPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.resolveInventoryScope(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	policy, err := h.RenewalService.CreatePolicy(c.Request.Context(), scope, services.CreateRenewalPolicyRequest{
+		CertificateID:    req.CertificateID,
+		DaysBeforeExpiry: req.DaysBeforeExpiry,
+		KeyStrategy:      req.KeyStrategy,
+		ChallengeType:    req.ChallengeType,
+		WebhookURL:       req.WebhookURL,
+		WebhookSecret:    req.WebhookSecret,
+	})
+	if errors.Is(err, services.ErrRenewalPolicyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "id": policy.ID})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+	if err != nil {
+		h.renewalError(c, err, "Failed to create renewal policy")
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// @Summary Get renewal policy
+// @Description Get a renewal policy with the certificate it currently covers
+// @Tags renewal
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Param organizationId query int false "Organization owning the policy"
+// @Success 200 {object} models.RenewalPolicy
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/renewal/policies/{id} [get]
+func (h *Handler) GetRenewalPolicy(c *gin.Context) {
+	id, ok := renewalPolicyID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	policy, err := h.RenewalService.GetPolicy(c.Request.Context(), scope, id)
+	if err != nil {
+		h.renewalError(c, err, "Failed to fetch renewal policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// @Summary Update renewal policy
+// @Description Change when and how a certificate is renewed, or pause its renewal
+// @Tags renewal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Param organizationId query int false "Organization owning the policy"
+// @Param request body UpdateRenewalPolicyRequest true "Policy update"
+// @Success 200 {object} models.RenewalPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/renewal/policies/{id} [patch]
+func (h *Handler) UpdateRenewalPolicy(c *gin.Context) {
+	id, ok := renewalPolicyID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateRenewalPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	policy, err := h.RenewalService.UpdatePolicy(c.Request.Context(), scope, id, services.UpdateRenewalPolicyRequest{
+		DaysBeforeExpiry: req.DaysBeforeExpiry,
+		KeyStrategy:      req.KeyStrategy,
+		ChallengeType:    req.ChallengeType,
+		WebhookURL:       req.WebhookURL,
+		WebhookSecret:    req.WebhookSecret,
+		Paused:           req.Paused,
+	})
+	if err != nil {
+		h.renewalError(c, err, "Failed to update renewal policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// @Summary Delete renewal policy
+// @Description Stop renewing a certificate and remove the policy's renewal history
+// @Tags renewal
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Param organizationId query int false "Organization owning the policy"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/renewal/policies/{id} [delete]
+func (h *Handler) DeleteRenewalPolicy(c *gin.Context) {
+	id, ok := renewalPolicyID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.RenewalService.DeletePolicy(c.Request.Context(), scope, id); err != nil {
+		h.renewalError(c, err, "Failed to delete renewal policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Renewal policy deleted successfully"})
+}
+
+// @Summary Renew certificate now
+// @Description Renew the policy's certificate immediately instead of waiting for its renewal date
+// @Tags renewal
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Param organizationId query int false "Organization owning the policy"
+// @Success 200 {object} models.CertificateRenewal
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/renewal/policies/{id}/renew [post]
+func (h *Handler) RenewCertificateNow(c *gin.Context) {
+	id, ok := renewalPolicyID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "certificate_renewal", "Renewal of policy "+c.Param("id"))
+
+	renewal, err := h.RenewalService.RenewNow(c.Request.Context(), scope, id)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		h.renewalError(c, err, "Failed to renew certificate")
+		return
+	}
+
+	if renewal.Success {
+		h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Certificate renewed as %d", *renewal.NewCertificateID))
+	} else {
+		h.finishOperation(operation, models.OpStatusFailed, renewal.Error, "")
+	}
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, renewal)
+}
+
+// @Summary Get certificate renewals
+// @Description Get the renewal attempts of a policy, newest first
+// @Tags renewal
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Param organizationId query int false "Organization owning the policy"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/renewal/policies/{id}/renewals [get]
+func (h *Handler) GetCertificateRenewals(c *gin.Context) {
+	id, ok := renewalPolicyID(c)
+	if !ok {
+		return
+	}
+
+	scope, ok := h.inventoryScope(c)
+	if !ok {
+		return
+	}
+
+	page, limit := inventoryPagination(c)
+	renewals, total, err := h.RenewalService.ListRenewals(c.Request.Context(), scope, id, (page-1)*limit, limit)
+	if err != nil {
+		h.renewalError(c, err, "Failed to fetch renewals")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"renewals":   renewals,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// @Summary Renew due certificates (Admin)
+// @Description Renew every certificate whose policy has reached its renewal date, without waiting for the scheduler
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.RenewDueResult
+// @Router /api/v1/admin/renewal/run [post]
+func (h *Handler) RunCertificateRenewals(c *gin.Context) {
+	operation := h.startOperation(c, "certificate_renewals", "Scheduled certificate renewals")
+
+	result, err := h.RenewalService.RenewDue(c.Request.Context())
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Renewed %d certificates, %d failed", result.Renewed, result.Failed))
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Helper functions
+
+func renewalPolicyID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *Handler) renewalError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Renewal policy not found"})
+	case errors.Is(err, services.ErrInvalidRenewalPolicy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotRenewable), errors.Is(err, services.ErrKeyUnavailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
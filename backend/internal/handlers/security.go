@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// @Summary Unlock account
+// @Description Lift the lockout of an account after too many failed logins, with the token from the email sent when it was locked
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body UnlockAccountRequest true "Unlock token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/unlock [post]
+func (h *Handler) UnlockAccount(c *gin.Context) {
+	var req UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.SecurityService.UnlockAccount(c.Request.Context(), req.Token, c.ClientIP(), c.GetHeader("User-Agent"))
+	if errors.Is(err, services.ErrInvalidUnlockToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked; you can sign in again"})
+}
+
+// @Summary Get security events
+// @Description Get the log of sign-ins to the current user's account, newest first: successful and failed logins, attempts refused while backing off, and lockouts
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/users/me/security-events [get]
+func (h *Handler) GetSecurityEvents(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	page, limit := inventoryPagination(c)
+	events, total, err := h.SecurityService.ListEvents(c.Request.Context(), userID.(uint), (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     events,
+		"pagination": paginationInfo(page, limit, total),
+	})
+}
+
+// Helper functions
+
+func loginAttempt(c *gin.Context, email string) *services.LoginAttempt {
+	return &services.LoginAttempt{
+		Email:     email,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+}
+
+// checkLogin counts a login attempt, answering it itself and returning false
+// while the account or IP address has to wait.
+func (h *Handler) checkLogin(c *gin.Context, attempt *services.LoginAttempt) bool {
+	wait, err := h.SecurityService.CheckLogin(c.Request.Context(), attempt)
+	if errors.Is(err, services.ErrLoginThrottled) || errors.Is(err, services.ErrAccountLocked) {
+		c.Header("Retry-After", strconv.Itoa(int(wait/time.Second)))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":      err.Error(),
+			"locked":     errors.Is(err, services.ErrAccountLocked),
+			"retryAfter": int(wait / time.Second),
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return false
+	}
+	return true
+}
+
+// loginFailed, loginPassed and loginAborted report the outcome of an
+// attempt. They do not change the response, so errors are only logged.
+func (h *Handler) loginFailed(c *gin.Context, attempt *services.LoginAttempt, reason string) {
+	if err := h.SecurityService.LoginFailed(c.Request.Context(), attempt, reason); err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+}
+
+func (h *Handler) loginPassed(c *gin.Context, attempt *services.LoginAttempt, user *models.User, methods []string) {
+	if err := h.SecurityService.LoginPassed(c.Request.Context(), attempt, user, methods); err != nil {
+		log.Printf("Failed to record login of user %d: %v", user.ID, err)
+	}
+}
+
+// loginAborted settles an attempt that ended before loginFailed or
+// loginPassed was called; deferred, it covers every error path
+func (h *Handler) loginAborted(c *gin.Context, attempt *services.LoginAttempt) {
+	if err := h.SecurityService.LoginAborted(c.Request.Context(), attempt); err != nil {
+		log.Printf("Failed to record aborted login: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// @Summary Get sessions
+// @Description Get the devices the current user is signed in on, last seen first. The session of this request has current set
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/users/me/sessions [get]
+func (h *Handler) GetSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	sessions, err := h.SessionService.ListSessions(c.Request.Context(), userID.(uint), c.GetString("session_id"))
+	if err != nil {
+		h.sessionError(c, err, "Failed to fetch sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// @Summary Revoke session
+// @Description Sign out one of the current user's devices; its tokens stop working at once
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/users/me/sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	id, ok := sessionID(c, "id")
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.SessionService.RevokeSession(c.Request.Context(), userID.(uint), id); err != nil {
+		h.sessionError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// @Summary Revoke all sessions
+// @Description Sign out all of the current user's devices, this one included
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Router /api/v1/users/me/sessions [delete]
+func (h *Handler) RevokeAllSessions(c *gin.Context) {
+	h.LogoutAll(c)
+}
+
+// @Summary Get user sessions (Admin)
+// @Description Get the devices a user is signed in on (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/sessions [get]
+func (h *Handler) GetUserSessions(c *gin.Context) {
+	userID, ok := adminUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.SessionService.ListSessions(c.Request.Context(), userID, "")
+	if err != nil {
+		h.sessionError(c, err, "Failed to fetch sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// @Summary Revoke user session (Admin)
+// @Description Sign out one of a user's devices (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param sessionId path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/sessions/{sessionId} [delete]
+func (h *Handler) RevokeUserSession(c *gin.Context) {
+	userID, ok := adminUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := sessionID(c, "sessionId")
+	if !ok {
+		return
+	}
+
+	if err := h.SessionService.RevokeSession(c.Request.Context(), userID, id); err != nil {
+		h.sessionError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// @Summary Revoke all user sessions (Admin)
+// @Description Sign out all of a user's devices (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/sessions [delete]
+func (h *Handler) RevokeAllUserSessions(c *gin.Context) {
+	userID, ok := adminUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.Select("id").First(&models.User{}, userID).Error; err != nil {
+		h.sessionError(c, err, "Failed to revoke sessions")
+		return
+	}
+	if err := h.SessionService.LogoutAll(c.Request.Context(), userID); err != nil {
+		h.sessionError(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked successfully"})
+}
+
+// Helper functions
+
+func sessionID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func adminUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *Handler) sessionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,532 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// ssoBindingCookie ties a single sign-on to the browser that started it
+	ssoBindingCookie = "sso_binding"
+	ssoCookiePath    = "/api/v1/auth/sso/"
+	ssoCookieMaxAge  = 600
+	// ssoCallbackPath is the web application page single sign-on ends on
+	ssoCallbackPath = "/sso/callback"
+)
+
+type SSOConnectionRequest struct {
+	Protocol string `json:"protocol" binding:"required,oneof=oidc saml"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	Scopes       string `json:"scopes"`
+
+	// IdPMetadata is the identity provider's SAML metadata XML, in place of
+	// the three settings after it
+	IdPMetadata    string `json:"idpMetadata"`
+	IdPEntityID    string `json:"idpEntityId"`
+	IdPSSOURL      string `json:"idpSsoUrl"`
+	IdPCertificate string `json:"idpCertificate"`
+
+	EmailClaim     string                       `json:"emailClaim"`
+	FirstNameClaim string                       `json:"firstNameClaim"`
+	LastNameClaim  string                       `json:"lastNameClaim"`
+	GroupsClaim    string                       `json:"groupsClaim"`
+	GroupRoles     map[string]models.MemberRole `json:"groupRoles"`
+	DefaultRole    models.MemberRole            `json:"defaultRole"`
+}
+
+type SSODomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+type SSOLinkRequest struct {
+	OrganizationID uint `json:"organizationId" binding:"required"`
+}
+
+type SSOTokenRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// @Summary Get organization single sign-on
+// @Description Get an organization's identity provider and the addresses to configure at it. Organization admins only
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Success 200 {object} models.SSOConnection
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso [get]
+func (h *Handler) GetSSOConnection(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	connection, err := h.SSOService.GetConnection(c.Request.Context(), userID.(uint), uint(id))
+	if err != nil {
+		h.ssoError(c, err, "Failed to fetch single sign-on settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// @Summary Set up organization single sign-on
+// @Description Configure the OpenID Connect or SAML identity provider the organization's members sign in with. Members at the organization's verified domains who sign in for the first time get an account and a role mapped from their groups. Organization admins only; requires the enterprise plan
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param request body SSOConnectionRequest true "Identity provider"
+// @Success 200 {object} models.SSOConnection
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso [put]
+func (h *Handler) SaveSSOConnection(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req SSOConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	userID, _ := c.Get("user_id")
+	connection, err := h.SSOService.SaveConnection(c.Request.Context(), userID.(uint), uint(id), services.SSOConnectionRequest{
+		Protocol:       req.Protocol,
+		Enabled:        enabled,
+		Issuer:         req.Issuer,
+		ClientID:       req.ClientID,
+		ClientSecret:   req.ClientSecret,
+		Scopes:         req.Scopes,
+		IdPMetadata:    req.IdPMetadata,
+		IdPEntityID:    req.IdPEntityID,
+		IdPSSOURL:      req.IdPSSOURL,
+		IdPCertificate: req.IdPCertificate,
+		EmailClaim:     req.EmailClaim,
+		FirstNameClaim: req.FirstNameClaim,
+		LastNameClaim:  req.LastNameClaim,
+		GroupsClaim:    req.GroupsClaim,
+		GroupRoles:     req.GroupRoles,
+		DefaultRole:    req.DefaultRole,
+	})
+	if err != nil {
+		h.ssoError(c, err, "Failed to save single sign-on settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// @Summary Remove organization single sign-on
+// @Description Remove the organization's identity provider. Accounts created by single sign-on remain. Organization admins only
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso [delete]
+func (h *Handler) DeleteSSOConnection(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.SSOService.DeleteConnection(c.Request.Context(), userID.(uint), uint(id)); err != nil {
+		h.ssoError(c, err, "Failed to remove single sign-on")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on removed successfully"})
+}
+
+// @Summary List single sign-on domains
+// @Description List the email domains single sign-on creates accounts for, with the TXT record that verifies each. Organization admins only
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Success 200 {array} models.SSODomain
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso/domains [get]
+func (h *Handler) ListSSODomains(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	domains, err := h.SSOService.ListDomains(c.Request.Context(), userID.(uint), uint(id))
+	if err != nil {
+		h.ssoError(c, err, "Failed to fetch single sign-on domains")
+		return
+	}
+
+	c.JSON(http.StatusOK, domains)
+}
+
+// @Summary Add single sign-on domain
+// @Description Add an email domain of the organization. Single sign-on creates accounts only for addresses at verified domains; publish the returned TXT record, then verify the domain. Organization admins only
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param request body SSODomainRequest true "Domain"
+// @Success 201 {object} models.SSODomain
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso/domains [post]
+func (h *Handler) AddSSODomain(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req SSODomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	domain, err := h.SSOService.AddDomain(c.Request.Context(), userID.(uint), uint(id), req.Domain)
+	if err != nil {
+		h.ssoError(c, err, "Failed to add single sign-on domain")
+		return
+	}
+
+	c.JSON(http.StatusCreated, domain)
+}
+
+// @Summary Verify single sign-on domain
+// @Description Look up the domain's verification TXT record. Organization admins only
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param domainId path int true "Domain ID"
+// @Success 200 {object} models.SSODomain
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso/domains/{domainId}/verify [post]
+func (h *Handler) VerifySSODomain(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	domainID, err := strconv.ParseUint(c.Param("domainId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	domain, err := h.SSOService.VerifyDomain(c.Request.Context(), userID.(uint), uint(id), uint(domainID))
+	if err != nil {
+		h.ssoError(c, err, "Failed to verify single sign-on domain")
+		return
+	}
+
+	c.JSON(http.StatusOK, domain)
+}
+
+// @Summary Remove single sign-on domain
+// @Description Remove an email domain; single sign-on stops creating accounts for it. Existing accounts remain. Organization admins only
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param domainId path int true "Domain ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/organizations/{id}/sso/domains/{domainId} [delete]
+func (h *Handler) DeleteSSODomain(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	domainID, err := strconv.ParseUint(c.Param("domainId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.SSOService.DeleteDomain(c.Request.Context(), userID.(uint), uint(id), uint(domainID)); err != nil {
+		h.ssoError(c, err, "Failed to remove single sign-on domain")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain removed successfully"})
+}
+
+// @Summary Start single sign-on
+// @Description Navigate the browser here to sign in at the organization's identity provider. It comes back to the web application's /sso/callback page with a one-time code to swap for tokens, or an error
+// @Tags auth
+// @Param organization query int true "Organization ID"
+// @Success 303
+// @Router /api/v1/auth/sso/login [get]
+func (h *Handler) BeginSSOLogin(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Query("organization"), 10, 32)
+	if err != nil {
+		h.ssoFinished(c, nil, services.ErrSSONotConfigured)
+		return
+	}
+
+	redirectURL, binding, err := h.SSOService.BeginLogin(c.Request.Context(), uint(id))
+	if err != nil {
+		h.ssoFinished(c, nil, err)
+		return
+	}
+
+	h.setSSOBinding(c, binding, ssoCookieMaxAge)
+	c.Redirect(http.StatusSeeOther, redirectURL)
+}
+
+// @Summary Link account to single sign-on
+// @Description Get the address to send the browser to in order to link the current account to the user's identity at the organization's identity provider. It comes back to the web application's /sso/callback page with linked=true, or an error
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body SSOLinkRequest true "Organization"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/auth/sso/link [post]
+func (h *Handler) LinkSSO(c *gin.Context) {
+	var req SSOLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	redirectURL, err := h.SSOService.BeginLink(c.Request.Context(), userID.(uint), req.OrganizationID)
+	if err != nil {
+		h.ssoError(c, err, "Failed to start single sign-on")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirectUrl": redirectURL})
+}
+
+// @Summary OpenID Connect callback
+// @Description Where OpenID providers send the browser back to; the redirect URI to register at the provider
+// @Tags auth
+// @Param code query string false "Authorization code"
+// @Param state query string true "State"
+// @Success 303
+// @Router /api/v1/auth/sso/oidc/callback [get]
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("OpenID provider returned %s: %s", providerError, c.Query("error_description"))
+		h.ssoFinished(c, nil, services.ErrSSOLoginFailed)
+		return
+	}
+
+	binding, _ := c.Cookie(ssoBindingCookie)
+	result, err := h.SSOService.FinishOIDC(c.Request.Context(), c.Query("state"), c.Query("code"), binding)
+	h.ssoFinished(c, result, err)
+}
+
+// @Summary SAML service provider metadata
+// @Description The metadata to load into the organization's SAML identity provider
+// @Tags auth
+// @Produce xml
+// @Param id path int true "Connection ID"
+// @Success 200 {string} string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/auth/sso/saml/{id}/metadata [get]
+func (h *Handler) SAMLMetadata(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connection ID"})
+		return
+	}
+
+	metadata, err := h.SSOService.Metadata(c.Request.Context(), uint(id))
+	if err != nil {
+		h.ssoError(c, err, "Failed to generate metadata")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// @Summary SAML assertion consumer service
+// @Description Where SAML identity providers post their responses, by the HTTP-POST binding
+// @Tags auth
+// @Accept x-www-form-urlencoded
+// @Param id path int true "Connection ID"
+// @Param SAMLResponse formData string true "SAML response"
+// @Param RelayState formData string true "Relay state"
+// @Success 303
+// @Router /api/v1/auth/sso/saml/{id}/acs [post]
+func (h *Handler) SAMLAssertionConsumer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.ssoFinished(c, nil, services.ErrSSOLoginExpired)
+		return
+	}
+
+	binding, _ := c.Cookie(ssoBindingCookie)
+	result, err := h.SSOService.FinishSAML(c.Request.Context(), uint(id), c.PostForm("RelayState"), c.PostForm("SAMLResponse"), binding)
+	h.ssoFinished(c, result, err)
+}
+
+// @Summary Complete single sign-on
+// @Description Swap the one-time code single sign-on returned to the web application for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body SSOTokenRequest true "Login code"
+// @Success 200 {object} AuthResponse
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/sso/token [post]
+func (h *Handler) ExchangeSSOCode(c *gin.Context) {
+	var req SSOTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, methods, err := h.SSOService.ExchangeLoginCode(c.Request.Context(), req.Code)
+	if err != nil {
+		h.ssoError(c, err, "Failed to complete single sign-on")
+		return
+	}
+
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), user, methods, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+	h.loginPassed(c, loginAttempt(c, user.Email), user, methods)
+
+	// Remove password from response
+	user.Password = ""
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         *user,
+	})
+}
+
+// Helper functions
+
+// ssoFinished sends the browser back to the web application with the
+// outcome of a single sign-on.
+func (h *Handler) ssoFinished(c *gin.Context, result *services.SSOLoginResult, err error) {
+	h.setSSOBinding(c, "", -1)
+
+	query := url.Values{}
+	switch {
+	case err != nil:
+		query.Set("error", ssoErrorMessage(err))
+	case result.Linked:
+		query.Set("linked", "true")
+	default:
+		query.Set("code", result.LoginCode)
+	}
+	c.Redirect(http.StatusSeeOther, strings.TrimRight(h.Config.Account.AppURL, "/")+ssoCallbackPath+"?"+query.Encode())
+}
+
+// setSSOBinding sets the binding cookie, or clears it for a negative maxAge.
+// SAML responses are posted from the identity provider's site, which only
+// carries SameSite=None cookies; those must be Secure, so plain http
+// development setups fall back to the browser default.
+func (h *Handler) setSSOBinding(c *gin.Context, binding string, maxAge int) {
+	secure := strings.HasPrefix(h.Config.SSO.BaseURL, "https://")
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	}
+	c.SetCookie(ssoBindingCookie, binding, maxAge, ssoCookiePath, "", secure, true)
+}
+
+// ssoErrorMessage returns what the user may be told about a failed single
+// sign-on.
+func ssoErrorMessage(err error) string {
+	for _, known := range []error{
+		services.ErrSSONotInPlan,
+		services.ErrSSONotConfigured,
+		services.ErrInvalidSSOConnection,
+		services.ErrSSOLoginExpired,
+		services.ErrSSOAccountExists,
+		services.ErrSSOIdentityLinked,
+		services.ErrSSODomainNotVerified,
+		services.ErrSSOLoginFailed,
+	} {
+		if errors.Is(err, known) {
+			return err.Error()
+		}
+	}
+	log.Printf("Single sign-on failed: %v", err)
+	return services.ErrSSOLoginFailed.Error()
+}
+
+func (h *Handler) ssoError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not set up"})
+	case errors.Is(err, services.ErrNotOrganizationMember),
+		errors.Is(err, services.ErrOrganizationAdminOnly),
+		errors.Is(err, services.ErrSSONotInPlan):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSSOConnection),
+		errors.Is(err, services.ErrInvalidSSODomain),
+		errors.Is(err, services.ErrSSONotConfigured):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSSOLoginExpired),
+		errors.Is(err, services.ErrSSOLoginFailed):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSSODomainNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSSOAccountExists),
+		errors.Is(err, services.ErrSSOIdentityLinked),
+		errors.Is(err, services.ErrSSODomainTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
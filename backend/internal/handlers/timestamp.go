@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/tsa"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTimestampQuerySize bounds TimeStampReq bodies; real queries are a few
+// hundred bytes.
+const maxTimestampQuerySize = 64 * 1024
+
+// @Summary Time stamping authority
+// @Description Accept an RFC 3161 TimeStampReq and return a signed TimeStampResp
+// @Tags timestamp
+// @Accept application/timestamp-query
+// @Produce application/timestamp-reply
+// @Success 200 {string} string "DER encoded TimeStampResp"
+// @Failure 415 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /tsa [post]
+func (h *Handler) HandleTimestampQuery(c *gin.Context) {
+	if c.ContentType() != "application/timestamp-query" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/timestamp-query"})
+		return
+	}
+
+	query, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTimestampQuerySize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if len(query) > maxTimestampQuerySize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Time stamp query too large"})
+		return
+	}
+
+	response, err := h.TSAService.Reply(query)
+	if err != nil {
+		if errors.Is(err, tsa.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/timestamp-reply", response)
+}
+
+// @Summary Create time stamp query
+// @Description Build an RFC 3161 TimeStampReq for data or a digest
+// @Tags timestamp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body tsa.CreateQueryRequest true "Time stamp query request"
+// @Success 200 {object} tsa.CreateQueryResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/timestamp/query [post]
+func (h *Handler) CreateTimestampQuery(c *gin.Context) {
+	var req tsa.CreateQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "timestamp_query", "Time stamp query")
+
+	// Build query
+	response, err := h.TSAService.CreateQuery(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Time stamp query created successfully")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Verify time stamp token
+// @Description Verify an RFC 3161 TimeStampResp against the original data or its digest
+// @Tags timestamp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body tsa.VerifyRequest true "Time stamp verification request"
+// @Success 200 {object} tsa.VerifyResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/openssl/timestamp/verify [post]
+func (h *Handler) VerifyTimestamp(c *gin.Context) {
+	var req tsa.VerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check usage limits
+	if !h.checkUsageLimits(c) {
+		return
+	}
+
+	// Record operation start
+	operation := h.startOperation(c, "timestamp_verify", "Time stamp verification")
+
+	// Verify token
+	response, err := h.TSAService.Verify(&req)
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record successful operation
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Time stamp verified")
+	h.incrementUsage(c)
+
+	c.JSON(http.StatusOK, response)
+}
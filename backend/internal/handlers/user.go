@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 
 	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,7 +33,7 @@ func (h *Handler) GetCurrentUser(c *gin.Context) {
 }
 
 // @Summary Update current user
-// @Description Update current authenticated user information
+// @Description Update current authenticated user information. A changed email address has to be verified again
 // @Tags users
 // @Accept json
 // @Produce json
@@ -61,8 +63,12 @@ func (h *Handler) UpdateCurrentUser(c *gin.Context) {
 	if lastName, ok := req["lastName"]; ok {
 		user.LastName = lastName
 	}
-	if email, ok := req["email"]; ok {
+	emailChanged := false
+	if email, ok := req["email"]; ok && email != user.Email {
+		// A new address has to be verified again
 		user.Email = email
+		user.EmailVerified = false
+		emailChanged = true
 	}
 
 	if err := h.DB.Save(&user).Error; err != nil {
@@ -70,6 +76,12 @@ func (h *Handler) UpdateCurrentUser(c *gin.Context) {
 		return
 	}
 
+	if emailChanged {
+		if err := h.AccountService.SendVerification(c.Request.Context(), &user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
 	// Remove password from response
 	user.Password = ""
 
@@ -96,31 +108,22 @@ func (h *Handler) DeleteCurrentUser(c *gin.Context) {
 }
 
 // @Summary Generate new API key
-// @Description Generate a new API key for the current user
+// @Description Create an API key for every OpenSSL operation group. Kept for older clients; see /api/v1/api-keys
 // @Tags users
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /api/v1/users/api-key [post]
 func (h *Handler) GenerateAPIKey(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Generate new API key
-	apiKey, err := generateAPIKey()
+	_, apiKey, err := h.APIKeyService.CreateKey(c.Request.Context(), userID.(uint), services.CreateAPIKeyRequest{
+		Name:   "Generated key",
+		Scopes: h.APIKeyService.Scopes(),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
-		return
-	}
-
-	user.APIKey = apiKey
-	if err := h.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update API key"})
+		h.apiKeyError(c, err, "Failed to generate API key")
 		return
 	}
 
@@ -122,23 +122,3 @@ func (h *UserHandler) DeleteAccount(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
 }
-
-// GenerateAPIKey godoc
-// @Summary Generate new API key
-// @Description Generate a new API key for the user
-// @Tags users
-// @Produce json
-// @Security BearerAuth
-// @Success 200 {object} map[string]string
-// @Router /api/v1/users/api-key [post]
-func (h *UserHandler) GenerateAPIKey(c *gin.Context) {
-	userID := c.GetUint("user_id")
-
-	apiKey, err := h.userService.GenerateAPIKey(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"apiKey": apiKey})
-}
\ No newline at end of file
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/vault"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get vault status (Admin)
+// @Description Show the current master key and how many sealed keys each master key protects
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.VaultStatus
+// @Router /api/v1/admin/vault [get]
+func (h *Handler) GetVaultStatus(c *gin.Context) {
+	status, err := h.KeyVault.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary Rotate vault master key (Admin)
+// @Description Create a new master key for wrapping; existing records stay readable until re-wrapped
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/admin/vault/rotate [post]
+func (h *Handler) RotateVaultMasterKey(c *gin.Context) {
+	operation := h.startOperation(c, "vault_rotate", "Vault master key rotation")
+
+	keyID, err := h.KeyVault.RotateMasterKey(c.Request.Context())
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		if errors.Is(err, vault.ErrRotationUnsupported) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The configured master key is managed externally; configure a new key and re-wrap"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.finishOperation(operation, models.OpStatusCompleted, "", "Vault master key rotated to "+keyID)
+
+	c.JSON(http.StatusOK, gin.H{"masterKeyId": keyID})
+}
+
+// @Summary Re-wrap vault keys (Admin)
+// @Description Re-wrap every data key that is not wrapped with the current master key
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.RewrapResult
+// @Router /api/v1/admin/vault/rewrap [post]
+func (h *Handler) RewrapVaultKeys(c *gin.Context) {
+	operation := h.startOperation(c, "vault_rewrap", "Vault key re-wrap")
+
+	result, err := h.KeyVault.RewrapAll(c.Request.Context())
+	if err != nil {
+		h.finishOperation(operation, models.OpStatusFailed, err.Error(), "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	h.finishOperation(operation, models.OpStatusCompleted, "", fmt.Sprintf("Re-wrapped %d keys, %d failed", result.Rewrapped, result.Failed))
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"web-openssl-backend/internal/services"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/webauthn"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RegisterPasskeyRequest struct {
+	Name string `json:"name"`
+	// Credential is the result of navigator.credentials.create(), in its
+	// toJSON() form
+	Credential webauthn.CredentialCreation `json:"credential" binding:"required"`
+}
+
+type PasskeyLoginRequest struct {
+	// Credential is the result of navigator.credentials.get(), in its
+	// toJSON() form
+	Credential webauthn.CredentialAssertion `json:"credential" binding:"required"`
+}
+
+type PasskeyChallengeRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+}
+
+type VerifyPasskeyRequest struct {
+	MFAToken   string                       `json:"mfaToken" binding:"required"`
+	Credential webauthn.CredentialAssertion `json:"credential" binding:"required"`
+}
+
+// @Summary Start passkey registration
+// @Description Get the options to pass to navigator.credentials.create()
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/webauthn/register/options [post]
+func (h *Handler) BeginPasskeyRegistration(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	options, err := h.WebAuthnService.BeginRegistration(c.Request.Context(), userID.(uint))
+	if err != nil {
+		h.webAuthnError(c, err, "Failed to start passkey registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// @Summary Register passkey
+// @Description Store the passkey created with the registration options
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RegisterPasskeyRequest true "Created credential"
+// @Success 201 {object} models.WebAuthnCredential
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/webauthn/register [post]
+func (h *Handler) FinishPasskeyRegistration(c *gin.Context) {
+	var req RegisterPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	credential, err := h.WebAuthnService.FinishRegistration(c.Request.Context(), userID.(uint), req.Name, &req.Credential)
+	if err != nil {
+		h.webAuthnError(c, err, "Failed to register passkey")
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// @Summary Get passkeys
+// @Description Get the current user's passkeys
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/auth/webauthn/credentials [get]
+func (h *Handler) GetPasskeys(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	credentials, err := h.WebAuthnService.ListCredentials(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// @Summary Delete passkey
+// @Description Remove a passkey; it can no longer be used to sign in
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Passkey ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/auth/webauthn/credentials/{id} [delete]
+func (h *Handler) DeletePasskey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.WebAuthnService.DeleteCredential(c.Request.Context(), userID.(uint), uint(id)); err != nil {
+		h.webAuthnError(c, err, "Failed to delete passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+// @Summary Start passkey login
+// @Description Get the options to pass to navigator.credentials.get() to sign in with a passkey instead of a password
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/auth/webauthn/login/options [post]
+func (h *Handler) BeginPasskeyLogin(c *gin.Context) {
+	options, err := h.WebAuthnService.BeginLogin(c.Request.Context())
+	if err != nil {
+		h.webAuthnError(c, err, "Failed to start passkey login")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// @Summary Login with passkey
+// @Description Sign in with a passkey alone. The session counts as having passed two-factor authentication
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyLoginRequest true "Passkey assertion"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/webauthn/login [post]
+func (h *Handler) FinishPasskeyLogin(c *gin.Context) {
+	var req PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The account is only known once the assertion checks out, so only the
+	// IP address is throttled
+	attempt := loginAttempt(c, "")
+	if !h.checkLogin(c, attempt) {
+		return
+	}
+	defer h.loginAborted(c, attempt)
+
+	user, err := h.WebAuthnService.FinishLogin(c.Request.Context(), &req.Credential)
+	if errors.Is(err, services.ErrInvalidPasskey) {
+		h.loginFailed(c, attempt, services.LoginFailureInvalidPasskey)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.webAuthnError(c, err, "Failed to sign in with passkey")
+		return
+	}
+
+	methods := []string{auth.AuthMethodPasskey}
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), user, methods, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+	h.loginPassed(c, attempt, user, methods)
+
+	// Remove password from response
+	user.Password = ""
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         *user,
+	})
+}
+
+// @Summary Start passkey second factor
+// @Description Get the options to pass to navigator.credentials.get() to complete a password login with a passkey
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyChallengeRequest true "Login challenge"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/mfa/webauthn/options [post]
+func (h *Handler) BeginPasskeySecondFactor(c *gin.Context) {
+	var req PasskeyChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.MFAService.ChallengeUser(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		h.mfaError(c, err, "Failed to verify login challenge")
+		return
+	}
+
+	options, err := h.WebAuthnService.BeginSecondFactor(c.Request.Context(), user)
+	if err != nil {
+		h.webAuthnError(c, err, "Failed to start passkey verification")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// @Summary Complete login with a passkey
+// @Description Swap the mfaToken returned by login and a passkey assertion for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyPasskeyRequest true "Login challenge and passkey assertion"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/mfa/webauthn/verify [post]
+func (h *Handler) FinishPasskeySecondFactor(c *gin.Context) {
+	var req VerifyPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.MFAService.ChallengeUser(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		h.mfaError(c, err, "Failed to verify login challenge")
+		return
+	}
+	attempt := loginAttempt(c, user.Email)
+	if !h.checkLogin(c, attempt) {
+		return
+	}
+	defer h.loginAborted(c, attempt)
+
+	err = h.WebAuthnService.FinishSecondFactor(c.Request.Context(), user, &req.Credential)
+	if errors.Is(err, services.ErrInvalidPasskey) {
+		h.loginFailed(c, attempt, services.LoginFailureInvalidSecondFactor)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.webAuthnError(c, err, "Failed to verify passkey")
+		return
+	}
+
+	methods := []string{auth.AuthMethodPassword, auth.AuthMethodPasskey}
+	tokens, err := h.SessionService.StartSession(c.Request.Context(), user, methods, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+	h.loginPassed(c, attempt, user, methods)
+
+	// Remove password from response
+	user.Password = ""
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         *user,
+	})
+}
+
+// Helper functions
+
+func (h *Handler) webAuthnError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+	case errors.Is(err, services.ErrInvalidPasskey),
+		errors.Is(err, services.ErrPasskeyCeremonyExpired),
+		errors.Is(err, services.ErrTooManyPasskeys),
+		errors.Is(err, services.ErrNoPasskeys):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
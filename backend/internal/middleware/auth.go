@@ -1,10 +1,12 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/services"
 	"web-openssl-backend/pkg/auth"
 
 	"github.com/gin-gonic/gin"
@@ -12,33 +14,63 @@ import (
 
 func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
+		if authenticateToken(c, authService) {
+			c.Next()
 		}
+	}
+}
 
-		// Check for Bearer token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+// TokenOrAPIKeyMiddleware authenticates with an access token like
+// AuthMiddleware, or with an API key sent in X-API-Key
+func TokenOrAPIKeyMiddleware(authService *auth.Service, apiKeys services.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			if authenticateToken(c, authService) {
+				c.Next()
+			}
 			return
 		}
 
-		token := parts[1]
-		claims, err := authService.ValidateToken(token)
+		apiKey, user, err := apiKeys.Authenticate(c.Request.Context(), key, c.ClientIP())
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			switch {
+			case errors.Is(err, services.ErrInvalidAPIKey):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrAPIAccessNotInPlan):
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check API key"})
+			}
 			c.Abort()
 			return
 		}
 
 		// Set user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
+		c.Set("user_id", user.ID)
+		c.Set("user_email", user.Email)
+		c.Set("user_role", user.Role)
+		c.Set("api_key", apiKey)
+		c.Next()
+	}
+}
+
+// RequireScope limits requests authenticated with an API key to keys
+// scoped to the operation group; other requests pass
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("api_key")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		if !value.(*models.APIKey).HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is not scoped to " + scope + " operations"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -63,23 +95,61 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
-func APIKeyMiddleware() gin.HandlerFunc {
+// RequirePasskeyLogin refuses sessions that were not signed in with a
+// passkey, which unlike passwords and TOTP codes cannot be phished.
+func RequirePasskeyLogin() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		apiKey := c.GetHeader("X-API-Key")
-		if apiKey == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
-			c.Abort()
-			return
-		}
-
-		// TODO: Validate API key against database
-		// For now, we'll just check if it's not empty
-		if len(apiKey) < 32 {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		methods, _ := c.Get("auth_methods")
+		list, _ := methods.([]string)
+		if !auth.HasAuthMethod(list, auth.AuthMethodPasskey) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Sign in with a passkey to use this route", "passkeyRequired": true})
 			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// authenticateToken checks the Bearer access token of a request and sets
+// the user info in the context, or aborts the request
+func authenticateToken(c *gin.Context, authService *auth.Service) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		c.Abort()
+		return false
+	}
+
+	// Check for Bearer token
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		c.Abort()
+		return false
+	}
+
+	token := parts[1]
+	claims, err := authService.ValidateToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
+	}
+
+	// Only access tokens authorize requests
+	if claims.Type != auth.TokenTypeAccess {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token type"})
+		c.Abort()
+		return false
+	}
+
+	// Set user info in context
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("user_role", claims.Role)
+	c.Set("session_id", claims.SessionID)
+	c.Set("mfa", claims.MFA)
+	c.Set("auth_methods", claims.AuthMethods)
+	return true
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"web-openssl-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminMiddlewareGuardsUserSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		role   models.UserRole
+		status int
+	}{
+		{"user", models.RoleUser, http.StatusForbidden},
+		{"admin", models.RoleAdmin, http.StatusOK},
+		{"super admin", models.RoleSuperAdmin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked := false
+			router := gin.New()
+			admin := router.Group("/admin", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				c.Set("user_role", tt.role)
+			}, AdminMiddleware())
+			admin.DELETE("/users/:id/sessions/:sessionId", func(c *gin.Context) {
+				revoked = true
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/users/1/sessions/1", nil))
+			if w.Code != tt.status || revoked != (tt.status == http.StatusOK) {
+				t.Errorf("status = %d, revoked = %v; want %d", w.Code, revoked, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single-use token emailed to a user who forgot
+// their password. Only the SHA-256 of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	IPAddress string     `json:"ipAddress"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// RefreshToken is an opaque token that obtains new access tokens. Each use
+// rotates it for a new token of the same family; a family is one login
+// session. Only the SHA-256 of the token is stored.
+type RefreshToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"userId" gorm:"not null;index"`
+	FamilyID  string    `json:"familyId" gorm:"not null;index"`
+	TokenHash string    `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// UsedAt is set once the token has been rotated; presenting it again
+	// means it was stolen
+	UsedAt    *time.Time `json:"usedAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	// AuthMethods lists, comma separated, the authentication methods the
+	// session's login used
+	AuthMethods string    `json:"authMethods"`
+	IPAddress   string    `json:"ipAddress"`
+	UserAgent   string    `json:"userAgent"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Session is a login on one device, as users see it: the refresh token
+// family FamilyID with where it was last used from. LastSeenAt moves with
+// every refresh, so it lags actual use by up to an access token lifetime.
+type Session struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	UserID   uint   `json:"userId" gorm:"not null;index"`
+	FamilyID string `json:"-" gorm:"not null;uniqueIndex"`
+	// AuthMethods lists, comma separated, the authentication methods the
+	// login used
+	AuthMethods string     `json:"authMethods"`
+	IPAddress   string     `json:"ipAddress"`
+	UserAgent   string     `json:"userAgent"`
+	LastSeenAt  time.Time  `json:"lastSeenAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	// Current marks the session of the request that lists it
+	Current bool `json:"current" gorm:"-"`
+}
+
+// TOTPFactor is a user's authenticator app. The shared secret is sealed by
+// the key vault like private keys are, under the same columns, so vault
+// maintenance covers it. Enrolment is complete once a first code confirms it.
+type TOTPFactor struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"userId" gorm:"not null;uniqueIndex"`
+	Secret      SealedKey  `json:"-" gorm:"embedded;embeddedPrefix:private_key_"`
+	ConfirmedAt *time.Time `json:"confirmedAt"`
+	// LastUsedStep is the time step of the last accepted code, which cannot
+	// be used again
+	LastUsedStep   int64      `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LastFailedAt   *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// RecoveryCode is a one-time code that stands in for a TOTP code when the
+// authenticator is lost. Only the SHA-256 of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// WebAuthnCredential is a passkey or security key registered by a user. The
+// public key is the COSE key the authenticator returned. UserHandle is the
+// random handle the authenticator stores with the credential; all of a
+// user's credentials share it.
+type WebAuthnCredential struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	UserID       uint   `json:"userId" gorm:"not null;index"`
+	Name         string `json:"name" gorm:"not null"`
+	CredentialID string `json:"credentialId" gorm:"not null;uniqueIndex"`
+	UserHandle   string `json:"-" gorm:"not null;index"`
+	PublicKey    []byte `json:"-" gorm:"not null"`
+	Algorithm    int    `json:"algorithm"`
+	SignCount    uint32 `json:"-"`
+	AAGUID       string `json:"aaguid"`
+	// Transports is comma separated, e.g. "internal,hybrid"
+	Transports string `json:"transports"`
+	// BackupEligible is set for passkeys that sync between devices
+	BackupEligible bool       `json:"backupEligible"`
+	LastUsedAt     *time.Time `json:"lastUsedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// WebAuthnChallenge is an open registration or authentication ceremony. It is
+// found by the challenge the client signed and deleted when used. UserID is
+// zero for passkey logins, where the user is not known until the end.
+type WebAuthnChallenge struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	UserID        uint   `json:"userId" gorm:"index"`
+	Ceremony      string `json:"ceremony" gorm:"not null"`
+	ChallengeHash string `json:"-" gorm:"not null;uniqueIndex"`
+	// UserHandle is the handle offered in a registration ceremony
+	UserHandle string    `json:"-"`
+	ExpiresAt  time.Time `json:"expiresAt" gorm:"index"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ACMEServerAccount, ACMEServerOrder, ACMEServerAuthorization,
+// ACMEServerChallenge and ACMEServerCertificate hold the state of the
+// built-in ACME server. IDs are the random identifiers in its resource
+// URLs; problems and identifier lists are stored as their JSON documents.
+
+type ACMEServerAccount struct {
+	ID                   string          `gorm:"primaryKey;size:64"`
+	Key                  json.RawMessage `gorm:"not null"`
+	Thumbprint           string          `gorm:"not null;uniqueIndex"`
+	Status               string          `gorm:"not null"`
+	Contact              []string        `gorm:"serializer:json;type:text"`
+	TermsOfServiceAgreed bool
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+type ACMEServerOrder struct {
+	ID               string          `gorm:"primaryKey;size:64"`
+	AccountID        string          `gorm:"not null;index;size:64"`
+	AuthorizationIDs []string        `gorm:"serializer:json;type:text"`
+	CertificateID    string          `gorm:"size:64"`
+	Status           string          `gorm:"not null"`
+	Expires          time.Time       `gorm:"index"`
+	Identifiers      json.RawMessage `gorm:"not null"`
+	Error            json.RawMessage
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type ACMEServerAuthorization struct {
+	ID              string `gorm:"primaryKey;size:64"`
+	AccountID       string `gorm:"not null;index;size:64"`
+	Status          string `gorm:"not null"`
+	Expires         time.Time
+	IdentifierType  string `gorm:"not null"`
+	IdentifierValue string `gorm:"not null"`
+	Wildcard        bool
+	Challenges      []ACMEServerChallenge `gorm:"foreignKey:AuthorizationID;constraint:OnDelete:CASCADE"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type ACMEServerChallenge struct {
+	ID              string `gorm:"primaryKey;size:64"`
+	AuthorizationID string `gorm:"not null;index;size:64"`
+	// Position keeps the challenges in the order they were offered
+	Position  int
+	Type      string `gorm:"not null"`
+	Status    string `gorm:"not null"`
+	Token     string `gorm:"not null"`
+	Validated *time.Time
+	Error     json.RawMessage
+}
+
+type ACMEServerCertificate struct {
+	ID           string `gorm:"primaryKey;size:64"`
+	AccountID    string `gorm:"not null;index;size:64"`
+	OrderID      string `gorm:"not null;index;size:64"`
+	SerialNumber string `gorm:"index"`
+	Chain        string `gorm:"type:text;not null"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Operation groups an API key can be scoped to; they name the groups of
+// the OpenSSL routes
+const (
+	APIKeyScopeCertificates = "certificates"
+	APIKeyScopeKeys         = "keys"
+	APIKeyScopeEncrypt      = "encrypt"
+	APIKeyScopeHash         = "hash"
+	APIKeyScopeSSL          = "ssl"
+	APIKeyScopePGP          = "pgp"
+	APIKeyScopeCMS          = "cms"
+	APIKeyScopeTimestamp    = "timestamp"
+	APIKeyScopeCT           = "ct"
+	APIKeyScopeACME         = "acme"
+)
+
+// APIKey authenticates programmatic access to the OpenSSL routes in place of
+// an access token. Only the SHA-256 of the key is stored; Prefix is kept to
+// tell keys apart.
+type APIKey struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     uint           `json:"userId" gorm:"not null;index"`
+	Name       string         `json:"name" gorm:"not null"`
+	Prefix     string         `json:"prefix" gorm:"not null"`
+	KeyHash    string         `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes     string         `json:"scopes"` // comma separated APIKeyScope* names
+	ExpiresAt  *time.Time     `json:"expiresAt"`
+	LastUsedAt *time.Time     `json:"lastUsedAt"`
+	LastUsedIP string         `json:"lastUsedIp"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// HasScope reports whether the key may be used for an operation group.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if strings.TrimSpace(s) == scope {
+			return true
+		}
+	}
+	return false
+}
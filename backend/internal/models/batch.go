@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BatchJob is a batch of operations run in the background. Each item is
+// also recorded as an Operation; Results holds the per-item outcomes once
+// the job has finished.
+type BatchJob struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	UserID     uint            `json:"userId" gorm:"not null;index"`
+	Status     OpStatus        `json:"status" gorm:"default:'pending';index"`
+	Total      int             `json:"total"`
+	Succeeded  int             `json:"succeeded"`
+	Failed     int             `json:"failed"`
+	Error      string          `json:"error,omitempty" gorm:"type:text"`
+	Results    json.RawMessage `json:"results,omitempty"`
+	StartedAt  *time.Time      `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// What started a deployment
+const (
+	DeployTriggerManual  = "manual"
+	DeployTriggerRenewal = "renewal"
+)
+
+// DeploymentTarget is a place certificates are pushed to. Config holds the
+// settings of its Type; Secret signs webhook deliveries, is sealed by the
+// vault and is never returned. A target bound to a certificate is deployed again whenever that
+// certificate is renewed, and follows it to the new certificate.
+type DeploymentTarget struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	UserID         uint            `json:"userId" gorm:"not null;index"`
+	OrganizationID *uint           `json:"organizationId" gorm:"index"`
+	Name           string          `json:"name" gorm:"not null"`
+	Type           string          `json:"type" gorm:"not null"`
+	Config         json.RawMessage `json:"config"`
+	Secret         SealedKey       `json:"-" gorm:"embedded;embeddedPrefix:private_key_"`
+	CertificateID  *uint           `json:"certificateId" gorm:"index"`
+
+	LastDeployedAt *time.Time `json:"lastDeployedAt"`
+	LastSuccess    *bool      `json:"lastSuccess"`
+	LastError      string     `json:"lastError,omitempty" gorm:"type:text"`
+
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Certificate *Certificate `json:"certificate,omitempty"`
+}
+
+// Deployment records one attempt to push a certificate to a target.
+// Artifact carries anything rendered for the caller, such as a Kubernetes
+// manifest, and is not stored since it may contain the private key.
+type Deployment struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	TargetID      uint      `json:"targetId" gorm:"not null;index"`
+	CertificateID uint      `json:"certificateId" gorm:"not null;index"`
+	Trigger       string    `json:"trigger" gorm:"not null"`
+	Success       bool      `json:"success"`
+	Error         string    `json:"error,omitempty" gorm:"type:text"`
+	Output        string    `json:"output,omitempty" gorm:"type:text"`
+	DurationMs    int64     `json:"durationMs"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"index"`
+
+	Artifact string `json:"artifact,omitempty" gorm:"-"`
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CertificateSource records how a certificate or key entered the inventory.
+type CertificateSource string
+
+const (
+	SourceGenerated CertificateSource = "generated"
+	SourceImported  CertificateSource = "imported"
+	SourceSigned    CertificateSource = "signed"
+	SourceACME      CertificateSource = "acme"
+)
+
+// SealedKey is a private key encrypted with its own data key, which is in
+// turn wrapped by the vault master key named by MasterKeyID. The ciphertext
+// is bound to the table and ID of its record, so a value copied to another
+// record does not open there.
+type SealedKey struct {
+	Ciphertext  []byte `json:"-" gorm:"not null"`
+	WrappedKey  []byte `json:"-" gorm:"not null"`
+	MasterKeyID string `json:"-" gorm:"not null;index"`
+}
+
+// Tables of the records that hold a SealedKey
+const (
+	TableKeyPairs          = "key_pairs"
+	TableACMEAccounts      = "acme_accounts"
+	TableTOTPFactors       = "totp_factors"
+	TableSSOConnections    = "sso_connections"
+	TableRenewalPolicies   = "renewal_policies"
+	TableDeploymentTargets = "deployment_targets"
+)
+
+// KeyPair is a private key held in the inventory. Keys are owned by a user
+// or, when OrganizationID is set, shared with the organization's members.
+type KeyPair struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	UserID         uint              `json:"userId" gorm:"not null;index"`
+	OrganizationID *uint             `json:"organizationId" gorm:"index"`
+	Name           string            `json:"name"`
+	Source         CertificateSource `json:"source" gorm:"not null"`
+	Algorithm      string            `json:"algorithm" gorm:"not null"`
+	KeySize        int               `json:"keySize"`
+	PublicKey      string            `json:"publicKey" gorm:"type:text;not null"`
+	PrivateKey     SealedKey         `json:"-" gorm:"embedded;embeddedPrefix:private_key_"`
+	// Exportable must be granted explicitly before the private key can be
+	// read back out of the vault.
+	Exportable bool `json:"exportable" gorm:"default:false"`
+	// Fingerprint is the hex SHA-256 of the DER SubjectPublicKeyInfo, shared
+	// with every certificate issued for this key.
+	Fingerprint string         `json:"fingerprint" gorm:"not null;index"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Organization *Organization `json:"organization,omitempty"`
+	Certificates []Certificate `json:"certificates,omitempty"`
+}
+
+// Certificate is an X.509 certificate held in the inventory together with
+// the metadata parsed from it, so it can be searched without decoding PEM.
+type Certificate struct {
+	ID                    uint              `json:"id" gorm:"primaryKey"`
+	UserID                uint              `json:"userId" gorm:"not null;index"`
+	OrganizationID        *uint             `json:"organizationId" gorm:"index"`
+	KeyPairID             *uint             `json:"keyPairId" gorm:"index"`
+	IssuerCertificateID   *uint             `json:"issuerCertificateId" gorm:"index"`
+	ACMEAccountID         *uint             `json:"acmeAccountId" gorm:"index"`
+	ReplacesCertificateID *uint             `json:"replacesCertificateId" gorm:"index"` // set on renewed certificates
+	Name                  string            `json:"name"`
+	Source                CertificateSource `json:"source" gorm:"not null"`
+	CommonName            string            `json:"commonName" gorm:"index"`
+	SANs                  string            `json:"sans" gorm:"type:text"` // comma separated
+	Subject               string            `json:"subject" gorm:"type:text"`
+	Issuer                string            `json:"issuer" gorm:"type:text;index"`
+	SerialNumber          string            `json:"serialNumber" gorm:"index"`
+	NotBefore             time.Time         `json:"notBefore"`
+	NotAfter              time.Time         `json:"notAfter" gorm:"index"`
+	IsCA                  bool              `json:"isCA"`
+	KeyAlgorithm          string            `json:"keyAlgorithm"`
+	KeySize               int               `json:"keySize"`
+	SignatureAlgorithm    string            `json:"signatureAlgorithm"`
+	Fingerprint           string            `json:"fingerprint" gorm:"not null;index"` // SHA-256 of the DER certificate
+	KeyFingerprint        string            `json:"keyFingerprint" gorm:"index"`
+	PEM                   string            `json:"pem" gorm:"type:text;not null"`
+	Chain                 string            `json:"chain,omitempty" gorm:"type:text"`
+	CreatedAt             time.Time         `json:"createdAt"`
+	UpdatedAt             time.Time         `json:"updatedAt"`
+	DeletedAt             gorm.DeletedAt    `json:"-" gorm:"index"`
+
+	// Relationships
+	Organization       *Organization `json:"organization,omitempty"`
+	KeyPair            *KeyPair      `json:"keyPair,omitempty"`
+	IssuerCertificate  *Certificate  `json:"issuerCertificate,omitempty"`
+	IssuedCertificates []Certificate `json:"issuedCertificates,omitempty" gorm:"foreignKey:IssuerCertificateID"`
+	ACMEAccount        *ACMEAccount  `json:"acmeAccount,omitempty"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Subjects of expiry notifications
+const (
+	ExpirySubjectCertificate = "certificate"
+	ExpirySubjectEndpoint    = "endpoint"
+)
+
+// Kinds of change detected between two scans of an endpoint
+const (
+	EndpointChangeCertificate = "certificate"
+	EndpointChangeChain       = "chain"
+	EndpointChangeProtocols   = "protocols"
+	EndpointChangeCiphers     = "ciphers"
+	EndpointChangeGrade       = "grade"
+)
+
+// MonitoredEndpoint is a TLS server scanned on a schedule. The Last* and
+// Certificate* fields summarize the latest scan so lists and the expiry
+// check do not have to read scan history.
+type MonitoredEndpoint struct {
+	ID              uint   `json:"id" gorm:"primaryKey"`
+	UserID          uint   `json:"userId" gorm:"not null;index"`
+	OrganizationID  *uint  `json:"organizationId" gorm:"index"`
+	Name            string `json:"name"`
+	Hostname        string `json:"hostname" gorm:"not null"`
+	Port            int    `json:"port" gorm:"not null"`
+	IntervalMinutes int    `json:"intervalMinutes" gorm:"not null"`
+	Paused          bool   `json:"paused" gorm:"default:false"`
+
+	NextScanAt             time.Time  `json:"nextScanAt" gorm:"index"`
+	LastScanAt             *time.Time `json:"lastScanAt"`
+	LastScanID             *uint      `json:"lastScanId"`
+	LastGrade              string     `json:"lastGrade"`
+	LastError              string     `json:"lastError,omitempty" gorm:"type:text"`
+	CertificateFingerprint string     `json:"certificateFingerprint"`
+	CertificateNotAfter    *time.Time `json:"certificateNotAfter" gorm:"index"`
+
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Organization *Organization `json:"organization,omitempty"`
+}
+
+// Address returns the endpoint as host:port
+func (e *MonitoredEndpoint) Address() string {
+	if strings.Contains(e.Hostname, ":") {
+		return fmt.Sprintf("[%s]:%d", e.Hostname, e.Port)
+	}
+	return fmt.Sprintf("%s:%d", e.Hostname, e.Port)
+}
+
+// EndpointScan is the stored result of one scan of a monitored endpoint.
+// Sets are stored sorted and comma separated so scans compare as strings;
+// Report holds the full scanner response.
+type EndpointScan struct {
+	ID                     uint            `json:"id" gorm:"primaryKey"`
+	EndpointID             uint            `json:"endpointId" gorm:"not null;index"`
+	Success                bool            `json:"success"`
+	Error                  string          `json:"error,omitempty" gorm:"type:text"`
+	Grade                  string          `json:"grade"`
+	Trusted                bool            `json:"trusted"`
+	Protocol               string          `json:"protocol"`
+	Cipher                 string          `json:"cipher"`
+	Protocols              string          `json:"protocols" gorm:"type:text"`
+	Ciphers                string          `json:"ciphers" gorm:"type:text"`
+	CertificateFingerprint string          `json:"certificateFingerprint"`
+	ChainFingerprints      string          `json:"chainFingerprints" gorm:"type:text"`
+	NotAfter               *time.Time      `json:"notAfter"`
+	Changes                string          `json:"changes"` // comma separated EndpointChange* kinds
+	Alerted                bool            `json:"alerted"`
+	Report                 json.RawMessage `json:"report,omitempty"`
+	DurationMs             int64           `json:"durationMs"`
+	CreatedAt              time.Time       `json:"createdAt" gorm:"index"`
+}
+
+// ExpiryNotification records that an expiry threshold was announced for a
+// certificate, so every threshold is sent once per certificate. NotAfter is
+// part of the key because a subject can be renewed in place.
+type ExpiryNotification struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UserID        uint      `json:"userId" gorm:"not null;index"`
+	SubjectType   string    `json:"subjectType" gorm:"not null;uniqueIndex:idx_expiry_notification"`
+	SubjectID     uint      `json:"subjectId" gorm:"not null;uniqueIndex:idx_expiry_notification"`
+	NotAfter      time.Time `json:"notAfter" gorm:"not null;uniqueIndex:idx_expiry_notification"`
+	ThresholdDays int       `json:"thresholdDays" gorm:"not null;uniqueIndex:idx_expiry_notification"`
+	Channels      string    `json:"channels"` // comma separated channels that accepted it
+	Error         string    `json:"error" gorm:"type:text"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
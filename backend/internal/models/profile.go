@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fields of a certificate profile that can be locked against overrides
+const (
+	ProfileFieldKeyType       = "keyType"
+	ProfileFieldKeySize       = "keySize"
+	ProfileFieldKeyUsage      = "keyUsage"
+	ProfileFieldExtKeyUsage   = "extKeyUsage"
+	ProfileFieldHashAlgorithm = "hashAlgorithm"
+	ProfileFieldValidDays     = "validDays"
+	ProfileFieldIsCA          = "isCA"
+)
+
+// CertificateProfile holds the defaults and constraints of a kind of
+// certificate, so generation requests only need a subject and SANs.
+// Profiles without an organization are global and managed by platform
+// administrators; the others are managed by their organization's admins.
+// A request may override any field that is not locked, but never asks for
+// more than MaxValidDays.
+type CertificateProfile struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	OrganizationID *uint  `json:"organizationId" gorm:"index"`
+	Name           string `json:"name" gorm:"not null"`
+	Description    string `json:"description" gorm:"type:text"`
+
+	KeyType       string `json:"keyType" gorm:"not null"`
+	KeySize       int    `json:"keySize"`
+	KeyUsage      string `json:"keyUsage"`    // comma separated
+	ExtKeyUsage   string `json:"extKeyUsage"` // comma separated
+	HashAlgorithm string `json:"hashAlgorithm"`
+	ValidDays     int    `json:"validDays" gorm:"not null"`
+	MaxValidDays  int    `json:"maxValidDays"` // 0 for no maximum
+	IsCA          bool   `json:"isCA"`
+	LockedFields  string `json:"lockedFields"` // comma separated ProfileField* names
+
+	CreatedBy uint           `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
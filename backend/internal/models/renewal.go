@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// Key handling when a certificate is renewed
+const (
+	RenewalKeyReuse  = "reuse"
+	RenewalKeyRotate = "rotate"
+)
+
+// RenewalPolicy renews a certificate DaysBeforeExpiry days before it
+// expires. After each renewal the policy moves on to the new certificate,
+// so one policy covers the whole lineage.
+type RenewalPolicy struct {
+	ID               uint   `json:"id" gorm:"primaryKey"`
+	UserID           uint   `json:"userId" gorm:"not null;index"`
+	OrganizationID   *uint  `json:"organizationId" gorm:"index"`
+	CertificateID    uint   `json:"certificateId" gorm:"not null;uniqueIndex"`
+	DaysBeforeExpiry int    `json:"daysBeforeExpiry" gorm:"not null"`
+	KeyStrategy      string `json:"keyStrategy" gorm:"not null"`
+	ChallengeType    string `json:"challengeType,omitempty"` // ACME certificates only
+	WebhookURL       string `json:"webhookUrl,omitempty"`
+	// WebhookSecret signs webhook deliveries and is sealed by the vault
+	WebhookSecret SealedKey `json:"-" gorm:"embedded;embeddedPrefix:private_key_"`
+	Paused        bool      `json:"paused" gorm:"default:false"`
+
+	// RenewAt is the current certificate's not-after minus DaysBeforeExpiry
+	RenewAt time.Time `json:"renewAt" gorm:"index"`
+	// NextAttemptAt holds retries back after a failed renewal
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+	FailureCount  int        `json:"failureCount"`
+	LastError     string     `json:"lastError,omitempty" gorm:"type:text"`
+	LastRenewedAt *time.Time `json:"lastRenewedAt"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Relationships
+	Certificate *Certificate `json:"certificate,omitempty"`
+}
+
+// CertificateRenewal records one renewal attempt of a policy
+type CertificateRenewal struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	PolicyID         uint      `json:"policyId" gorm:"not null;index"`
+	OldCertificateID uint      `json:"oldCertificateId" gorm:"not null"`
+	NewCertificateID *uint     `json:"newCertificateId"`
+	Success          bool      `json:"success"`
+	Error            string    `json:"error,omitempty" gorm:"type:text"`
+	KeyRotated       bool      `json:"keyRotated"`
+	WebhookStatus    string    `json:"webhookStatus,omitempty"` // delivered, failed or empty when none is configured
+	WebhookError     string    `json:"webhookError,omitempty" gorm:"type:text"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Security event types
+const (
+	SecurityEventLoginSucceeded = "login_succeeded"
+	// SecurityEventSecondFactorRequired is a correct password of a user who
+	// still has to pass a second factor
+	SecurityEventSecondFactorRequired = "second_factor_required"
+	SecurityEventLoginFailed          = "login_failed"
+	// SecurityEventLoginBlocked is an attempt refused while the account or
+	// IP address was backing off or locked
+	SecurityEventLoginBlocked    = "login_blocked"
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+)
+
+// LoginThrottle counts the failed logins for an email address or from an IP
+// address; Target is "email:" or "ip:" followed by the address. A count is
+// forgotten once no attempt has failed for a while.
+type LoginThrottle struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Target        string    `json:"target" gorm:"not null;uniqueIndex"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt" gorm:"index"`
+	// LockedAt is when the account was last locked and its owner emailed
+	LockedAt  *time.Time `json:"lockedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// SecurityEvent is an entry of the log users see of the logins to their
+// account. UserID is zero for attempts at addresses without an account.
+type SecurityEvent struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"userId" gorm:"index"`
+	Type   string `json:"type" gorm:"not null"`
+	// Reason says why a login failed or was blocked
+	Reason string `json:"reason,omitempty"`
+	// AuthMethods lists, comma separated, the methods a successful login
+	// used
+	AuthMethods string    `json:"authMethods,omitempty"`
+	Email       string    `json:"email"`
+	IPAddress   string    `json:"ipAddress"`
+	UserAgent   string    `json:"userAgent"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"index"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Single sign-on protocols an organization's identity provider can speak
+const (
+	SSOProtocolOIDC = "oidc"
+	SSOProtocolSAML = "saml"
+)
+
+// SSOConnection is the identity provider an organization signs its members
+// in with. The OpenID Connect client secret is sealed by the key vault like
+// private keys are, under the same columns; SAML connections seal an empty
+// one. Claim names left empty fall back to the protocol's usual names.
+type SSOConnection struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	OrganizationID uint   `json:"organizationId" gorm:"not null;uniqueIndex"`
+	Protocol       string `json:"protocol" gorm:"not null"`
+	Enabled        bool   `json:"enabled" gorm:"default:true"`
+
+	// OpenID Connect
+	Issuer       string    `json:"issuer"`
+	ClientID     string    `json:"clientId"`
+	ClientSecret SealedKey `json:"-" gorm:"embedded;embeddedPrefix:private_key_"`
+	// Scopes are requested besides openid, space separated
+	Scopes string `json:"scopes"`
+
+	// SAML
+	IdPEntityID string `json:"idpEntityId"`
+	IdPSSOURL   string `json:"idpSsoUrl"`
+	// IdPCertificate holds the PEM certificates assertions may be signed by
+	IdPCertificate string `json:"idpCertificate" gorm:"type:text"`
+
+	EmailClaim     string `json:"emailClaim"`
+	FirstNameClaim string `json:"firstNameClaim"`
+	LastNameClaim  string `json:"lastNameClaim"`
+	GroupsClaim    string `json:"groupsClaim"`
+	// GroupRoles maps identity provider groups to member roles; members in
+	// none of them get DefaultRole
+	GroupRoles  map[string]MemberRole `json:"groupRoles" gorm:"serializer:json;type:text"`
+	DefaultRole MemberRole            `json:"defaultRole" gorm:"default:'member'"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	UpdatedAt   time.Time             `json:"updatedAt"`
+
+	// The addresses to enter at the identity provider, filled in for display
+	RedirectURI string `json:"redirectUri,omitempty" gorm:"-"`
+	SPEntityID  string `json:"spEntityId,omitempty" gorm:"-"`
+	ACSURL      string `json:"acsUrl,omitempty" gorm:"-"`
+}
+
+// SSODomain is an email domain of an organization. Single sign-on creates
+// accounts only for addresses at domains the organization proved it controls
+// by publishing Token in a TXT record; a domain is verified for one
+// organization at a time.
+type SSODomain struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	ConnectionID uint       `json:"connectionId" gorm:"not null;uniqueIndex:idx_sso_domain_connection"`
+	Domain       string     `json:"domain" gorm:"not null;uniqueIndex:idx_sso_domain_connection;uniqueIndex:idx_sso_domain_verified,where:verified_at IS NOT NULL"`
+	Token        string     `json:"-" gorm:"not null"`
+	VerifiedAt   *time.Time `json:"verifiedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+
+	// The TXT record to publish, filled in for display
+	RecordName  string `json:"recordName,omitempty" gorm:"-"`
+	RecordValue string `json:"recordValue,omitempty" gorm:"-"`
+}
+
+// SSOIdentity links a user to the subject an identity provider knows them
+// by
+type SSOIdentity struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	ConnectionID uint       `json:"connectionId" gorm:"not null;uniqueIndex:idx_sso_identity_subject"`
+	Subject      string     `json:"subject" gorm:"not null;uniqueIndex:idx_sso_identity_subject"`
+	UserID       uint       `json:"userId" gorm:"not null;index"`
+	LastLoginAt  *time.Time `json:"lastLoginAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// SSOLoginState is a single sign-on in progress. It is found by the state
+// sent to the identity provider, or once the user is known, by the one-time
+// code the web application swaps for tokens, and deleted when used. Only
+// SHA-256 hashes of the state, code and browser binding are stored.
+type SSOLoginState struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	ConnectionID uint   `json:"connectionId" gorm:"not null"`
+	Stage        string `json:"stage" gorm:"not null"`
+	StateHash    string `json:"-" gorm:"not null;uniqueIndex"`
+	// BindingHash ties a login to the browser that started it
+	BindingHash string `json:"-"`
+	// LinkUserID is set when a signed-in user links their account
+	LinkUserID uint `json:"linkUserId"`
+	// OpenID Connect nonce and PKCE code verifier, or SAML request ID
+	Nonce        string `json:"-"`
+	CodeVerifier string `json:"-"`
+	RequestID    string `json:"-"`
+	// UserID and AuthMethods are set once the identity provider vouched for
+	// the user
+	UserID      uint      `json:"userId"`
+	AuthMethods string    `json:"authMethods"`
+	ExpiresAt   time.Time `json:"expiresAt" gorm:"index"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
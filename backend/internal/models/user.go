@@ -17,9 +17,12 @@ type User struct {
 	IsActive    bool      `json:"isActive" gorm:"default:true"`
 	EmailVerified bool    `json:"emailVerified" gorm:"default:false"`
 	StripeCustomerID string `json:"stripeCustomerId" gorm:"index"`
-	APIKey      string    `json:"apiKey" gorm:"uniqueIndex"`
 	UsageCount  int       `json:"usageCount" gorm:"default:0"`
 	UsageResetAt time.Time `json:"usageResetAt"`
+	// VerificationSentAt throttles verification emails
+	VerificationSentAt *time.Time `json:"-"`
+	// Tokens issued before SessionsRevokedAt are no longer accepted
+	SessionsRevokedAt *time.Time `json:"-"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -54,6 +57,9 @@ type Organization struct {
 	StripeCustomerID string `json:"stripeCustomerId" gorm:"index"`
 	IsActive    bool      `json:"isActive" gorm:"default:true"`
 	Settings    string    `json:"settings" gorm:"type:jsonb"`
+	// RequireMFA keeps members without two-factor authentication out until
+	// they enrol
+	RequireMFA  bool      `json:"requireMfa" gorm:"default:false"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -128,6 +134,19 @@ type Subscription struct {
 	User User `json:"user"`
 }
 
+// ACMEAccount is an account a user holds with an external ACME CA.
+type ACMEAccount struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"userId" gorm:"not null;index"`
+	DirectoryURL string    `json:"directoryUrl" gorm:"not null"`
+	Email        string    `json:"email"`
+	AccountURI   string    `json:"accountUri" gorm:"not null"`
+	Status       string    `json:"status"`
+	PrivateKey   SealedKey `json:"-" gorm:"embedded;embeddedPrefix:private_key_"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
 func GetPlanLimits(plan PlanType) map[string]interface{} {
 	limits := make(map[string]interface{})
 
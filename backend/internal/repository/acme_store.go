@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/acme"
+
+	"gorm.io/gorm"
+)
+
+// acmeStore implements acme.Store interface
+// Single Responsibility: Only handles ACME server state persistence
+type acmeStore struct {
+	db *gorm.DB
+}
+
+// NewACMEStore creates a new ACME store instance, which keeps accounts,
+// orders and issued certificates across restarts
+func NewACMEStore(db *gorm.DB) acme.Store {
+	return &acmeStore{db: db}
+}
+
+func (r *acmeStore) CreateAccount(account *acme.Account) error {
+	return r.db.Create(toACMEAccount(account)).Error
+}
+
+func (r *acmeStore) GetAccount(id string) (*acme.Account, error) {
+	var record models.ACMEServerAccount
+	if err := r.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, acmeStoreError(err)
+	}
+	return fromACMEAccount(&record), nil
+}
+
+func (r *acmeStore) GetAccountByThumbprint(thumbprint string) (*acme.Account, error) {
+	var record models.ACMEServerAccount
+	if err := r.db.Where("thumbprint = ?", thumbprint).First(&record).Error; err != nil {
+		return nil, acmeStoreError(err)
+	}
+	return fromACMEAccount(&record), nil
+}
+
+func (r *acmeStore) UpdateAccount(account *acme.Account) error {
+	record := toACMEAccount(account)
+	return acmeUpdated(r.db.Model(&models.ACMEServerAccount{}).
+		Where("id = ?", account.ID).
+		Select("key", "thumbprint", "status", "contact", "terms_of_service_agreed").
+		Updates(record))
+}
+
+func (r *acmeStore) CreateOrder(order *acme.Order) error {
+	record, err := toACMEOrder(order)
+	if err != nil {
+		return err
+	}
+	return r.db.Create(record).Error
+}
+
+func (r *acmeStore) GetOrder(id string) (*acme.Order, error) {
+	var record models.ACMEServerOrder
+	if err := r.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, acmeStoreError(err)
+	}
+	return fromACMEOrder(&record)
+}
+
+func (r *acmeStore) ListOrders(accountID string) ([]*acme.Order, error) {
+	var records []models.ACMEServerOrder
+	if err := r.db.Where("account_id = ?", accountID).Order("expires ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	orders := make([]*acme.Order, 0, len(records))
+	for i := range records {
+		order, err := fromACMEOrder(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+func (r *acmeStore) UpdateOrder(order *acme.Order) error {
+	record, err := toACMEOrder(order)
+	if err != nil {
+		return err
+	}
+	return acmeUpdated(r.db.Model(&models.ACMEServerOrder{}).
+		Where("id = ?", order.ID).
+		Select("authorization_ids", "certificate_id", "status", "expires", "identifiers", "error").
+		Updates(record))
+}
+
+func (r *acmeStore) CreateAuthorization(authz *acme.Authorization) error {
+	record, err := toACMEAuthorization(authz)
+	if err != nil {
+		return err
+	}
+	return r.db.Create(record).Error
+}
+
+func (r *acmeStore) GetAuthorization(id string) (*acme.Authorization, error) {
+	var record models.ACMEServerAuthorization
+	err := r.db.
+		Preload("Challenges", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Where("id = ?", id).
+		First(&record).Error
+	if err != nil {
+		return nil, acmeStoreError(err)
+	}
+	return fromACMEAuthorization(&record)
+}
+
+func (r *acmeStore) GetAuthorizationByChallenge(challengeID string) (*acme.Authorization, error) {
+	var challenge models.ACMEServerChallenge
+	if err := r.db.Where("id = ?", challengeID).First(&challenge).Error; err != nil {
+		return nil, acmeStoreError(err)
+	}
+	return r.GetAuthorization(challenge.AuthorizationID)
+}
+
+func (r *acmeStore) UpdateAuthorization(authz *acme.Authorization) error {
+	record, err := toACMEAuthorization(authz)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := acmeUpdated(tx.Model(&models.ACMEServerAuthorization{}).
+			Where("id = ?", authz.ID).
+			Select("status", "expires", "identifier_type", "identifier_value", "wildcard").
+			Updates(record))
+		if err != nil {
+			return err
+		}
+		for i := range record.Challenges {
+			challenge := &record.Challenges[i]
+			err := tx.Model(&models.ACMEServerChallenge{}).
+				Where("id = ? AND authorization_id = ?", challenge.ID, authz.ID).
+				Select("position", "type", "status", "token", "validated", "error").
+				Updates(challenge).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *acmeStore) CreateCertificate(cert *acme.Certificate) error {
+	return r.db.Create(&models.ACMEServerCertificate{
+		ID:           cert.ID,
+		AccountID:    cert.AccountID,
+		OrderID:      cert.OrderID,
+		SerialNumber: cert.SerialNumber,
+		Chain:        cert.Chain,
+		CreatedAt:    cert.CreatedAt,
+	}).Error
+}
+
+func (r *acmeStore) GetCertificate(id string) (*acme.Certificate, error) {
+	var record models.ACMEServerCertificate
+	if err := r.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, acmeStoreError(err)
+	}
+	return &acme.Certificate{
+		ID:           record.ID,
+		AccountID:    record.AccountID,
+		OrderID:      record.OrderID,
+		SerialNumber: record.SerialNumber,
+		Chain:        record.Chain,
+		CreatedAt:    record.CreatedAt,
+	}, nil
+}
+
+// Helper functions
+
+// acmeStoreError turns a missing record into the error the ACME service
+// expects
+func acmeStoreError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return acme.ErrNotFound
+	}
+	return err
+}
+
+func acmeUpdated(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return acme.ErrNotFound
+	}
+	return nil
+}
+
+func toACMEAccount(account *acme.Account) *models.ACMEServerAccount {
+	return &models.ACMEServerAccount{
+		ID:                   account.ID,
+		Key:                  account.Key,
+		Thumbprint:           account.Thumbprint,
+		Status:               account.Status,
+		Contact:              account.Contact,
+		TermsOfServiceAgreed: account.TermsOfServiceAgreed,
+		CreatedAt:            account.CreatedAt,
+	}
+}
+
+func fromACMEAccount(record *models.ACMEServerAccount) *acme.Account {
+	return &acme.Account{
+		ID:                   record.ID,
+		Key:                  record.Key,
+		Thumbprint:           record.Thumbprint,
+		CreatedAt:            record.CreatedAt,
+		Status:               record.Status,
+		Contact:              record.Contact,
+		TermsOfServiceAgreed: record.TermsOfServiceAgreed,
+	}
+}
+
+func toACMEOrder(order *acme.Order) (*models.ACMEServerOrder, error) {
+	identifiers, err := json.Marshal(order.Identifiers)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := marshalProblem(order.Error)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ACMEServerOrder{
+		ID:               order.ID,
+		AccountID:        order.AccountID,
+		AuthorizationIDs: order.AuthorizationIDs,
+		CertificateID:    order.CertificateID,
+		Status:           order.Status,
+		Expires:          order.Expires,
+		Identifiers:      identifiers,
+		Error:            problem,
+	}, nil
+}
+
+func fromACMEOrder(record *models.ACMEServerOrder) (*acme.Order, error) {
+	order := &acme.Order{
+		ID:               record.ID,
+		AccountID:        record.AccountID,
+		AuthorizationIDs: record.AuthorizationIDs,
+		CertificateID:    record.CertificateID,
+		Status:           record.Status,
+		Expires:          record.Expires.UTC(),
+	}
+	if err := json.Unmarshal(record.Identifiers, &order.Identifiers); err != nil {
+		return nil, err
+	}
+	var err error
+	order.Error, err = unmarshalProblem(record.Error)
+	return order, err
+}
+
+func toACMEAuthorization(authz *acme.Authorization) (*models.ACMEServerAuthorization, error) {
+	record := &models.ACMEServerAuthorization{
+		ID:              authz.ID,
+		AccountID:       authz.AccountID,
+		Status:          authz.Status,
+		Expires:         authz.Expires,
+		IdentifierType:  authz.Identifier.Type,
+		IdentifierValue: authz.Identifier.Value,
+		Wildcard:        authz.Wildcard,
+	}
+	for i, challenge := range authz.Challenges {
+		problem, err := marshalProblem(challenge.Error)
+		if err != nil {
+			return nil, err
+		}
+		record.Challenges = append(record.Challenges, models.ACMEServerChallenge{
+			ID:              challenge.ID,
+			AuthorizationID: authz.ID,
+			Position:        i,
+			Type:            challenge.Type,
+			Status:          challenge.Status,
+			Token:           challenge.Token,
+			Validated:       challenge.Validated,
+			Error:           problem,
+		})
+	}
+	return record, nil
+}
+
+func fromACMEAuthorization(record *models.ACMEServerAuthorization) (*acme.Authorization, error) {
+	authz := &acme.Authorization{
+		ID:         record.ID,
+		AccountID:  record.AccountID,
+		Status:     record.Status,
+		Expires:    record.Expires.UTC(),
+		Identifier: acme.Identifier{Type: record.IdentifierType, Value: record.IdentifierValue},
+		Wildcard:   record.Wildcard,
+		Challenges: make([]*acme.Challenge, 0, len(record.Challenges)),
+	}
+	for _, challenge := range record.Challenges {
+		problem, err := unmarshalProblem(challenge.Error)
+		if err != nil {
+			return nil, err
+		}
+		authz.Challenges = append(authz.Challenges, &acme.Challenge{
+			ID:        challenge.ID,
+			Type:      challenge.Type,
+			Status:    challenge.Status,
+			Token:     challenge.Token,
+			Validated: challenge.Validated,
+			Error:     problem,
+		})
+	}
+	return authz, nil
+}
+
+func marshalProblem(problem *acme.Problem) (json.RawMessage, error) {
+	if problem == nil {
+		return nil, nil
+	}
+	return json.Marshal(problem)
+}
+
+func unmarshalProblem(data json.RawMessage) (*acme.Problem, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var problem acme.Problem
+	if err := json.Unmarshal(data, &problem); err != nil {
+		return nil, err
+	}
+	return &problem, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyRepository implements APIKeyRepository interface
+// Single Responsibility: Only handles API key persistence
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository instance
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID uint) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *apiKeyRepository) MarkUsed(ctx context.Context, id uint, at time.Time, ipAddress string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"last_used_at": at, "last_used_ip": ipAddress}).Error
+}
+
+func (r *apiKeyRepository) Delete(ctx context.Context, userID, id uint) error {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.APIKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// batchJobRepository implements BatchJobRepository interface
+// Single Responsibility: Only handles batch job persistence
+type batchJobRepository struct {
+	db *gorm.DB
+}
+
+// NewBatchJobRepository creates a new batch job repository instance
+func NewBatchJobRepository(db *gorm.DB) BatchJobRepository {
+	return &batchJobRepository{db: db}
+}
+
+func (r *batchJobRepository) Create(ctx context.Context, job *models.BatchJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *batchJobRepository) GetByID(ctx context.Context, userID, id uint) (*models.BatchJob, error) {
+	var job models.BatchJob
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		First(&job, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *batchJobRepository) Update(ctx context.Context, job *models.BatchJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+func (r *batchJobRepository) UpdateProgress(ctx context.Context, id uint, succeeded, failed int) error {
+	return r.db.WithContext(ctx).
+		Model(&models.BatchJob{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"succeeded": succeeded, "failed": failed}).Error
+}
+
+func (r *batchJobRepository) List(ctx context.Context, userID uint, offset, limit int) ([]*models.BatchJob, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.BatchJob{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Omit("results").Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var jobs []*models.BatchJob
+	err := query.Find(&jobs).Error
+	return jobs, total, err
+}
+
+func (r *batchJobRepository) FailUnfinished(ctx context.Context, reason string) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.BatchJob{}).
+		Where("status IN ?", []models.OpStatus{models.OpStatusPending, models.OpStatusRunning}).
+		Updates(map[string]interface{}{"status": models.OpStatusFailed, "error": reason})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// certificateProfileRepository implements CertificateProfileRepository interface
+// Single Responsibility: Only handles certificate profile persistence
+type certificateProfileRepository struct {
+	db *gorm.DB
+}
+
+// NewCertificateProfileRepository creates a new certificate profile repository instance
+func NewCertificateProfileRepository(db *gorm.DB) CertificateProfileRepository {
+	return &certificateProfileRepository{db: db}
+}
+
+func (r *certificateProfileRepository) Create(ctx context.Context, profile *models.CertificateProfile) error {
+	return r.db.WithContext(ctx).Create(profile).Error
+}
+
+func (r *certificateProfileRepository) GetByID(ctx context.Context, id uint) (*models.CertificateProfile, error) {
+	var profile models.CertificateProfile
+	if err := r.db.WithContext(ctx).First(&profile, id).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *certificateProfileRepository) GetByName(ctx context.Context, organizationID *uint, name string) (*models.CertificateProfile, error) {
+	query := r.db.WithContext(ctx).Where("LOWER(name) = LOWER(?)", name)
+	if organizationID != nil {
+		query = query.Where("organization_id = ?", *organizationID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+
+	var profile models.CertificateProfile
+	if err := query.First(&profile).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *certificateProfileRepository) Update(ctx context.Context, profile *models.CertificateProfile) error {
+	return r.db.WithContext(ctx).Save(profile).Error
+}
+
+func (r *certificateProfileRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.CertificateProfile{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *certificateProfileRepository) List(ctx context.Context, organizationID *uint, offset, limit int) ([]*models.CertificateProfile, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.CertificateProfile{})
+	if organizationID != nil {
+		query = query.Where("organization_id IS NULL OR organization_id = ?", *organizationID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Global profiles first, then the organization's
+	query = query.Order("organization_id IS NOT NULL, name ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var profiles []*models.CertificateProfile
+	err := query.Find(&profiles).Error
+	return profiles, total, err
+}
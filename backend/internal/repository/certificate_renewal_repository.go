@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// certificateRenewalRepository implements CertificateRenewalRepository interface
+// Single Responsibility: Only handles renewal attempt persistence
+type certificateRenewalRepository struct {
+	db *gorm.DB
+}
+
+// NewCertificateRenewalRepository creates a new certificate renewal repository instance
+func NewCertificateRenewalRepository(db *gorm.DB) CertificateRenewalRepository {
+	return &certificateRenewalRepository{db: db}
+}
+
+func (r *certificateRenewalRepository) Create(ctx context.Context, renewal *models.CertificateRenewal) error {
+	return r.db.WithContext(ctx).Create(renewal).Error
+}
+
+func (r *certificateRenewalRepository) ListByPolicy(ctx context.Context, policyID uint, offset, limit int) ([]*models.CertificateRenewal, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.CertificateRenewal{}).Where("policy_id = ?", policyID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var renewals []*models.CertificateRenewal
+	err := query.Find(&renewals).Error
+	return renewals, total, err
+}
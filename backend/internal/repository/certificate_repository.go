@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// certificateRepository implements CertificateRepository interface
+// Single Responsibility: Only handles certificate inventory persistence
+type certificateRepository struct {
+	db *gorm.DB
+}
+
+// NewCertificateRepository creates a new certificate repository instance
+func NewCertificateRepository(db *gorm.DB) CertificateRepository {
+	return &certificateRepository{db: db}
+}
+
+func (r *certificateRepository) Create(ctx context.Context, cert *models.Certificate) error {
+	return r.db.WithContext(ctx).Create(cert).Error
+}
+
+func (r *certificateRepository) GetByID(ctx context.Context, scope Scope, id uint) (*models.Certificate, error) {
+	var cert models.Certificate
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Preload("KeyPair").
+		Preload("IssuerCertificate").
+		Preload("IssuedCertificates").
+		Preload("ACMEAccount").
+		First(&cert, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (r *certificateRepository) GetByFingerprint(ctx context.Context, scope Scope, fingerprint string) (*models.Certificate, error) {
+	var cert models.Certificate
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Where("fingerprint = ?", fingerprint).
+		First(&cert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (r *certificateRepository) FindBySubject(ctx context.Context, scope Scope, subject string) ([]*models.Certificate, error) {
+	var certs []*models.Certificate
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Where("subject = ?", subject).
+		Order("not_after DESC").
+		Find(&certs).Error
+	return certs, err
+}
+
+func (r *certificateRepository) FindUnlinkedByIssuer(ctx context.Context, scope Scope, issuer string) ([]*models.Certificate, error) {
+	var certs []*models.Certificate
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Where("issuer = ? AND issuer_certificate_id IS NULL", issuer).
+		Find(&certs).Error
+	return certs, err
+}
+
+func (r *certificateRepository) AttachKeyPair(ctx context.Context, scope Scope, keyFingerprint string, keyPairID uint) error {
+	return applyScope(r.db.WithContext(ctx).Model(&models.Certificate{}), scope).
+		Where("key_fingerprint = ? AND key_pair_id IS NULL", keyFingerprint).
+		Update("key_pair_id", keyPairID).Error
+}
+
+func (r *certificateRepository) Update(ctx context.Context, cert *models.Certificate) error {
+	return r.db.WithContext(ctx).Save(cert).Error
+}
+
+func (r *certificateRepository) Delete(ctx context.Context, scope Scope, id uint) error {
+	result := applyScope(r.db.WithContext(ctx), scope).Delete(&models.Certificate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *certificateRepository) Search(ctx context.Context, filter CertificateFilter) ([]*models.Certificate, int64, error) {
+	query := applyScope(r.db.WithContext(ctx).Model(&models.Certificate{}), filter.Scope)
+
+	if filter.CommonName != "" {
+		query = query.Where("LOWER(common_name) LIKE ?", containsPattern(filter.CommonName))
+	}
+	if filter.SAN != "" {
+		query = query.Where("LOWER(sans) LIKE ?", containsPattern(filter.SAN))
+	}
+	if filter.Issuer != "" {
+		query = query.Where("LOWER(issuer) LIKE ?", containsPattern(filter.Issuer))
+	}
+	if filter.SerialNumber != "" {
+		query = query.Where("serial_number = ?", filter.SerialNumber)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.KeyPairID != nil {
+		query = query.Where("key_pair_id = ?", *filter.KeyPairID)
+	}
+	if filter.ExpiresAfter != nil {
+		query = query.Where("not_after >= ?", *filter.ExpiresAfter)
+	}
+	if filter.ExpiresBefore != nil {
+		query = query.Where("not_after < ?", *filter.ExpiresBefore)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("not_after ASC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var certs []*models.Certificate
+	err := query.Find(&certs).Error
+	return certs, total, err
+}
+
+// ListExpiring returns certificates of every owner whose validity ends in
+// [from, to), ordered by ID so callers can page with afterID
+func (r *certificateRepository) ListExpiring(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]*models.Certificate, error) {
+	var certs []*models.Certificate
+	err := r.db.WithContext(ctx).
+		Where("not_after >= ? AND not_after < ? AND id > ?", from, to, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&certs).Error
+	return certs, err
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// deploymentRepository implements DeploymentRepository interface
+// Single Responsibility: Only handles deployment attempt persistence
+type deploymentRepository struct {
+	db *gorm.DB
+}
+
+// NewDeploymentRepository creates a new deployment repository instance
+func NewDeploymentRepository(db *gorm.DB) DeploymentRepository {
+	return &deploymentRepository{db: db}
+}
+
+func (r *deploymentRepository) Create(ctx context.Context, deployment *models.Deployment) error {
+	return r.db.WithContext(ctx).Create(deployment).Error
+}
+
+func (r *deploymentRepository) ListByTarget(ctx context.Context, targetID uint, offset, limit int) ([]*models.Deployment, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Deployment{}).Where("target_id = ?", targetID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var deployments []*models.Deployment
+	err := query.Find(&deployments).Error
+	return deployments, total, err
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// deploymentTargetRepository implements DeploymentTargetRepository interface
+// Single Responsibility: Only handles deployment target persistence
+type deploymentTargetRepository struct {
+	db *gorm.DB
+}
+
+// NewDeploymentTargetRepository creates a new deployment target repository instance
+func NewDeploymentTargetRepository(db *gorm.DB) DeploymentTargetRepository {
+	return &deploymentTargetRepository{db: db}
+}
+
+func (r *deploymentTargetRepository) Create(ctx context.Context, target *models.DeploymentTarget) error {
+	return r.db.WithContext(ctx).Create(target).Error
+}
+
+func (r *deploymentTargetRepository) GetByID(ctx context.Context, scope Scope, id uint) (*models.DeploymentTarget, error) {
+	var target models.DeploymentTarget
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Preload("Certificate").
+		First(&target, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+func (r *deploymentTargetRepository) Update(ctx context.Context, target *models.DeploymentTarget) error {
+	return r.db.WithContext(ctx).Omit("Certificate").Save(target).Error
+}
+
+// Delete removes the target together with its deployment history
+func (r *deploymentTargetRepository) Delete(ctx context.Context, scope Scope, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := applyScope(tx, scope).Delete(&models.DeploymentTarget{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("target_id = ?", id).Delete(&models.Deployment{}).Error
+	})
+}
+
+func (r *deploymentTargetRepository) List(ctx context.Context, scope Scope, offset, limit int) ([]*models.DeploymentTarget, int64, error) {
+	query := applyScope(r.db.WithContext(ctx).Model(&models.DeploymentTarget{}), scope)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("name ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var targets []*models.DeploymentTarget
+	err := query.Find(&targets).Error
+	return targets, total, err
+}
+
+func (r *deploymentTargetRepository) ListByCertificate(ctx context.Context, certificateID uint) ([]*models.DeploymentTarget, error) {
+	var targets []*models.DeploymentTarget
+	err := r.db.WithContext(ctx).
+		Where("certificate_id = ?", certificateID).
+		Order("id ASC").
+		Find(&targets).Error
+	return targets, err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// endpointScanRepository implements EndpointScanRepository interface
+// Single Responsibility: Only handles endpoint scan history persistence
+type endpointScanRepository struct {
+	db *gorm.DB
+}
+
+// NewEndpointScanRepository creates a new endpoint scan repository instance
+func NewEndpointScanRepository(db *gorm.DB) EndpointScanRepository {
+	return &endpointScanRepository{db: db}
+}
+
+func (r *endpointScanRepository) Create(ctx context.Context, scan *models.EndpointScan) error {
+	return r.db.WithContext(ctx).Create(scan).Error
+}
+
+func (r *endpointScanRepository) GetByID(ctx context.Context, endpointID, id uint) (*models.EndpointScan, error) {
+	var scan models.EndpointScan
+	err := r.db.WithContext(ctx).Where("endpoint_id = ?", endpointID).First(&scan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+func (r *endpointScanRepository) GetLatestSuccessful(ctx context.Context, endpointID uint) (*models.EndpointScan, error) {
+	var scan models.EndpointScan
+	err := r.db.WithContext(ctx).
+		Omit("report").
+		Where("endpoint_id = ? AND success = ?", endpointID, true).
+		Order("id DESC").
+		First(&scan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// List returns scans newest first without their full reports
+func (r *endpointScanRepository) List(ctx context.Context, endpointID uint, offset, limit int) ([]*models.EndpointScan, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.EndpointScan{}).Where("endpoint_id = ?", endpointID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Omit("report").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var scans []*models.EndpointScan
+	err := query.Find(&scans).Error
+	return scans, total, err
+}
+
+func (r *endpointScanRepository) ListSince(ctx context.Context, endpointID uint, since time.Time) ([]*models.EndpointScan, error) {
+	var scans []*models.EndpointScan
+	err := r.db.WithContext(ctx).
+		Select("id", "endpoint_id", "success", "grade", "created_at").
+		Where("endpoint_id = ? AND created_at >= ?", endpointID, since).
+		Order("created_at ASC").
+		Find(&scans).Error
+	return scans, err
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// expiryNotificationRepository implements ExpiryNotificationRepository interface
+// Single Responsibility: Only handles expiry notification persistence
+type expiryNotificationRepository struct {
+	db *gorm.DB
+}
+
+// NewExpiryNotificationRepository creates a new expiry notification repository instance
+func NewExpiryNotificationRepository(db *gorm.DB) ExpiryNotificationRepository {
+	return &expiryNotificationRepository{db: db}
+}
+
+func (r *expiryNotificationRepository) Create(ctx context.Context, notification *models.ExpiryNotification) error {
+	return r.db.WithContext(ctx).Create(notification).Error
+}
+
+func (r *expiryNotificationRepository) Exists(ctx context.Context, subjectType string, subjectID uint, notAfter time.Time, thresholdDays int) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.ExpiryNotification{}).
+		Where("subject_type = ? AND subject_id = ? AND not_after = ? AND threshold_days = ?", subjectType, subjectID, notAfter, thresholdDays).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *expiryNotificationRepository) GetByUserID(ctx context.Context, userID uint, offset, limit int) ([]*models.ExpiryNotification, error) {
+	var notifications []*models.ExpiryNotification
+	query := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	err := query.Find(&notifications).Error
+	return notifications, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 	"web-openssl-backend/internal/models"
 )
 
@@ -14,12 +15,26 @@ type UserRepository interface {
 	Update(ctx context.Context, user *models.User) error
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, offset, limit int) ([]*models.User, error)
+	IncrementUsage(ctx context.Context, id uint, count int) error
+	// ReserveUsage counts count operations against the monthly usage unless
+	// that would exceed limit, failing with gorm.ErrRecordNotFound then
+	ReserveUsage(ctx context.Context, id uint, count, limit int, now time.Time) error
+	// UpdatePassword replaces the password hash and revokes every session
+	// issued before revokedAt
+	UpdatePassword(ctx context.Context, id uint, passwordHash string, revokedAt time.Time) error
+	// MarkEmailVerified verifies the user's address if it still is email,
+	// failing with gorm.ErrRecordNotFound otherwise
+	MarkEmailVerified(ctx context.Context, id uint, email string) error
+	MarkVerificationSent(ctx context.Context, id uint, at time.Time) error
+	// RevokeSessions rejects every token issued before at
+	RevokeSessions(ctx context.Context, id uint, at time.Time) error
 }
 
 // OperationRepository defines the interface for operation data operations
 type OperationRepository interface {
 	Create(ctx context.Context, operation *models.Operation) error
 	GetByID(ctx context.Context, id uint) (*models.Operation, error)
+	Update(ctx context.Context, operation *models.Operation) error
 	GetByUserID(ctx context.Context, userID uint, offset, limit int) ([]*models.Operation, error)
 	GetStats(ctx context.Context, userID uint) (map[string]interface{}, error)
 	Delete(ctx context.Context, id uint) error
@@ -33,6 +48,11 @@ type OrganizationRepository interface {
 	Delete(ctx context.Context, id uint) error
 	AddMember(ctx context.Context, member *models.OrganizationMember) error
 	RemoveMember(ctx context.Context, orgID, userID uint) error
+	GetMember(ctx context.Context, orgID, userID uint) (*models.OrganizationMember, error)
+	// RequiresMFA reports whether one of the user's organizations requires
+	// two-factor authentication
+	RequiresMFA(ctx context.Context, userID uint) (bool, error)
+	SetMemberRole(ctx context.Context, orgID, userID uint, role models.MemberRole) error
 }
 
 // SubscriptionRepository defines the interface for subscription data operations
@@ -41,4 +61,296 @@ type SubscriptionRepository interface {
 	GetByUserID(ctx context.Context, userID uint) (*models.Subscription, error)
 	Update(ctx context.Context, sub *models.Subscription) error
 	Cancel(ctx context.Context, id uint) error
-}
\ No newline at end of file
+}
+
+// Scope selects records owned by a user, or by an organization when
+// OrganizationID is set
+type Scope struct {
+	UserID         uint
+	OrganizationID *uint
+}
+
+// CertificateFilter narrows a certificate search. Empty fields are ignored;
+// text fields match case-insensitively on a substring
+type CertificateFilter struct {
+	Scope         Scope
+	CommonName    string
+	SAN           string
+	Issuer        string
+	SerialNumber  string
+	Source        models.CertificateSource
+	KeyPairID     *uint
+	ExpiresAfter  *time.Time
+	ExpiresBefore *time.Time
+	Offset        int
+	Limit         int
+}
+
+// CertificateRepository defines the interface for certificate inventory operations
+type CertificateRepository interface {
+	Create(ctx context.Context, cert *models.Certificate) error
+	GetByID(ctx context.Context, scope Scope, id uint) (*models.Certificate, error)
+	GetByFingerprint(ctx context.Context, scope Scope, fingerprint string) (*models.Certificate, error)
+	FindBySubject(ctx context.Context, scope Scope, subject string) ([]*models.Certificate, error)
+	FindUnlinkedByIssuer(ctx context.Context, scope Scope, issuer string) ([]*models.Certificate, error)
+	AttachKeyPair(ctx context.Context, scope Scope, keyFingerprint string, keyPairID uint) error
+	Update(ctx context.Context, cert *models.Certificate) error
+	Delete(ctx context.Context, scope Scope, id uint) error
+	Search(ctx context.Context, filter CertificateFilter) ([]*models.Certificate, int64, error)
+	ListExpiring(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]*models.Certificate, error)
+}
+
+// KeyPairRepository defines the interface for key inventory operations
+type KeyPairRepository interface {
+	Create(ctx context.Context, key *models.KeyPair) error
+	GetByID(ctx context.Context, scope Scope, id uint) (*models.KeyPair, error)
+	GetByFingerprint(ctx context.Context, scope Scope, fingerprint string) (*models.KeyPair, error)
+	Update(ctx context.Context, key *models.KeyPair) error
+	Delete(ctx context.Context, scope Scope, id uint) error
+	List(ctx context.Context, scope Scope, offset, limit int) ([]*models.KeyPair, int64, error)
+}
+
+// SealedKeyRecord identifies a sealed private key in one of the tables that
+// store them
+type SealedKeyRecord struct {
+	Table string
+	ID    uint
+	Key   models.SealedKey
+}
+
+// SealedKeyRepository defines the interface for maintaining vault-sealed keys
+// across every model that stores one
+type SealedKeyRepository interface {
+	Tables() []string
+	NextID(ctx context.Context, table string) (uint, error)
+	CountByMasterKey(ctx context.Context) (map[string]int64, error)
+	ListNotWrappedWith(ctx context.Context, table, masterKeyID string, afterID uint, limit int) ([]SealedKeyRecord, error)
+	ReplaceKey(ctx context.Context, record SealedKeyRecord, previousMasterKeyID string) error
+}
+
+// ExpiryNotificationRepository defines the interface for expiry notification bookkeeping
+type ExpiryNotificationRepository interface {
+	Create(ctx context.Context, notification *models.ExpiryNotification) error
+	Exists(ctx context.Context, subjectType string, subjectID uint, notAfter time.Time, thresholdDays int) (bool, error)
+	GetByUserID(ctx context.Context, userID uint, offset, limit int) ([]*models.ExpiryNotification, error)
+}
+
+// MonitoredEndpointRepository defines the interface for monitored endpoint data operations
+type MonitoredEndpointRepository interface {
+	Create(ctx context.Context, endpoint *models.MonitoredEndpoint) error
+	GetByID(ctx context.Context, scope Scope, id uint) (*models.MonitoredEndpoint, error)
+	GetByAddress(ctx context.Context, scope Scope, hostname string, port int) (*models.MonitoredEndpoint, error)
+	Update(ctx context.Context, endpoint *models.MonitoredEndpoint) error
+	Delete(ctx context.Context, scope Scope, id uint) error
+	List(ctx context.Context, scope Scope, offset, limit int) ([]*models.MonitoredEndpoint, int64, error)
+	// ListDue and ListExpiring span every owner, for the background jobs
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*models.MonitoredEndpoint, error)
+	ListExpiring(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]*models.MonitoredEndpoint, error)
+}
+
+// EndpointScanRepository defines the interface for endpoint scan history
+type EndpointScanRepository interface {
+	Create(ctx context.Context, scan *models.EndpointScan) error
+	GetByID(ctx context.Context, endpointID, id uint) (*models.EndpointScan, error)
+	GetLatestSuccessful(ctx context.Context, endpointID uint) (*models.EndpointScan, error)
+	List(ctx context.Context, endpointID uint, offset, limit int) ([]*models.EndpointScan, int64, error)
+	ListSince(ctx context.Context, endpointID uint, since time.Time) ([]*models.EndpointScan, error)
+}
+
+// RenewalPolicyRepository defines the interface for renewal policy data operations
+type RenewalPolicyRepository interface {
+	Create(ctx context.Context, policy *models.RenewalPolicy) error
+	GetByID(ctx context.Context, scope Scope, id uint) (*models.RenewalPolicy, error)
+	GetByCertificateID(ctx context.Context, certificateID uint) (*models.RenewalPolicy, error)
+	Update(ctx context.Context, policy *models.RenewalPolicy) error
+	Delete(ctx context.Context, scope Scope, id uint) error
+	List(ctx context.Context, scope Scope, offset, limit int) ([]*models.RenewalPolicy, int64, error)
+	// ListDue spans every owner, for the background job
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*models.RenewalPolicy, error)
+}
+
+// CertificateRenewalRepository defines the interface for renewal attempt history
+type CertificateRenewalRepository interface {
+	Create(ctx context.Context, renewal *models.CertificateRenewal) error
+	ListByPolicy(ctx context.Context, policyID uint, offset, limit int) ([]*models.CertificateRenewal, int64, error)
+}
+
+// DeploymentTargetRepository defines the interface for deployment target data operations
+type DeploymentTargetRepository interface {
+	Create(ctx context.Context, target *models.DeploymentTarget) error
+	GetByID(ctx context.Context, scope Scope, id uint) (*models.DeploymentTarget, error)
+	Update(ctx context.Context, target *models.DeploymentTarget) error
+	Delete(ctx context.Context, scope Scope, id uint) error
+	List(ctx context.Context, scope Scope, offset, limit int) ([]*models.DeploymentTarget, int64, error)
+	ListByCertificate(ctx context.Context, certificateID uint) ([]*models.DeploymentTarget, error)
+}
+
+// DeploymentRepository defines the interface for deployment attempt history
+type DeploymentRepository interface {
+	Create(ctx context.Context, deployment *models.Deployment) error
+	ListByTarget(ctx context.Context, targetID uint, offset, limit int) ([]*models.Deployment, int64, error)
+}
+
+// CertificateProfileRepository defines the interface for certificate profile data operations
+type CertificateProfileRepository interface {
+	Create(ctx context.Context, profile *models.CertificateProfile) error
+	GetByID(ctx context.Context, id uint) (*models.CertificateProfile, error)
+	// GetByName looks a name up among the organization's profiles, or the global ones for nil
+	GetByName(ctx context.Context, organizationID *uint, name string) (*models.CertificateProfile, error)
+	Update(ctx context.Context, profile *models.CertificateProfile) error
+	Delete(ctx context.Context, id uint) error
+	// List returns the global profiles together with the organization's
+	List(ctx context.Context, organizationID *uint, offset, limit int) ([]*models.CertificateProfile, int64, error)
+}
+
+// BatchJobRepository defines the interface for batch job data operations
+type BatchJobRepository interface {
+	Create(ctx context.Context, job *models.BatchJob) error
+	GetByID(ctx context.Context, userID, id uint) (*models.BatchJob, error)
+	Update(ctx context.Context, job *models.BatchJob) error
+	UpdateProgress(ctx context.Context, id uint, succeeded, failed int) error
+	// List leaves out the results, which can be large
+	List(ctx context.Context, userID uint, offset, limit int) ([]*models.BatchJob, int64, error)
+	// FailUnfinished marks the jobs still pending or running as failed
+	FailUnfinished(ctx context.Context, reason string) (int64, error)
+}
+
+// PasswordResetTokenRepository defines the interface for password reset token data operations
+type PasswordResetTokenRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	// Redeem marks the token used, sets the user's new password, revoking
+	// every session issued before at, and revokes the user's other tokens,
+	// all in one transaction. It fails with gorm.ErrRecordNotFound if the
+	// token already was used, so that concurrent resets cannot both succeed.
+	Redeem(ctx context.Context, token *models.PasswordResetToken, passwordHash string, at time.Time) error
+	// Revoke marks the user's unused tokens used
+	Revoke(ctx context.Context, userID uint, at time.Time) error
+	CountSince(ctx context.Context, userID uint, since time.Time) (int64, error)
+}
+
+// RefreshTokenRepository defines the interface for refresh token data operations
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// Use marks the token rotated, failing with gorm.ErrRecordNotFound if it
+	// already was or has been revoked
+	Use(ctx context.Context, id uint, at time.Time) error
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeUser(ctx context.Context, userID uint, at time.Time) error
+	// FamilyRevoked reports whether the session a family makes up has ended
+	FamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+// SessionRepository defines the interface for login session data operations
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	// GetByID fails with gorm.ErrRecordNotFound for sessions of other users
+	GetByID(ctx context.Context, userID, id uint) (*models.Session, error)
+	// Touch records a refresh of the session, failing with
+	// gorm.ErrRecordNotFound if it has no record
+	Touch(ctx context.Context, familyID string, at, expiresAt time.Time, ipAddress, userAgent string) error
+	// ListActive returns the sessions of a user that are neither revoked nor
+	// expired at now, nor started before startedAfter, last seen first
+	ListActive(ctx context.Context, userID uint, startedAfter, now time.Time) ([]*models.Session, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeUser(ctx context.Context, userID uint, at time.Time) error
+}
+
+// APIKeyRepository defines the interface for API key data operations
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListByUser(ctx context.Context, userID uint) ([]*models.APIKey, error)
+	CountByUser(ctx context.Context, userID uint) (int64, error)
+	// MarkUsed records the key's last use without touching UpdatedAt
+	MarkUsed(ctx context.Context, id uint, at time.Time, ipAddress string) error
+	Delete(ctx context.Context, userID, id uint) error
+}
+
+// MFARepository defines the interface for TOTP factor and recovery code data operations
+type MFARepository interface {
+	GetFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error)
+	// ReplaceFactor stores a new, unconfirmed factor in place of the user's
+	ReplaceFactor(ctx context.Context, factor *models.TOTPFactor) error
+	// Confirm completes enrolment with the step of the first code and stores
+	// the recovery codes
+	Confirm(ctx context.Context, id uint, step int64, at time.Time, codeHashes []string) error
+	// UseStep records an accepted code, failing with gorm.ErrRecordNotFound
+	// if its step was not after the last one used
+	UseStep(ctx context.Context, id uint, step int64) error
+	RecordFailure(ctx context.Context, id uint, at time.Time) error
+	// DeleteFactor removes the factor together with the recovery codes
+	DeleteFactor(ctx context.Context, userID uint) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+	// UseRecoveryCode spends an unused code, failing with
+	// gorm.ErrRecordNotFound otherwise
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) error
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+}
+
+// WebAuthnRepository defines the interface for WebAuthn credential data operations
+type WebAuthnRepository interface {
+	CreateChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error
+	// UseChallenge deletes and returns the open ceremony with the challenge,
+	// failing with gorm.ErrRecordNotFound if there is none or it has expired
+	UseChallenge(ctx context.Context, challengeHash, ceremony string, at time.Time) (*models.WebAuthnChallenge, error)
+	CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error
+	GetCredential(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID uint) ([]*models.WebAuthnCredential, error)
+	CountCredentials(ctx context.Context, userID uint) (int64, error)
+	// UserHandle returns the handle of the user's credentials, or "" if the
+	// user has none
+	UserHandle(ctx context.Context, userID uint) (string, error)
+	// MarkUsed stores the new signature counter, failing with
+	// gorm.ErrRecordNotFound if another login changed it first
+	MarkUsed(ctx context.Context, id uint, previousCount, signCount uint32, at time.Time) error
+	DeleteCredential(ctx context.Context, userID, id uint) error
+}
+
+// SSORepository defines the interface for single sign-on data operations
+type SSORepository interface {
+	GetConnection(ctx context.Context, organizationID uint) (*models.SSOConnection, error)
+	GetConnectionByID(ctx context.Context, id uint) (*models.SSOConnection, error)
+	SaveConnection(ctx context.Context, connection *models.SSOConnection) error
+	// DeleteConnection removes an organization's connection together with
+	// the identities linked through it
+	DeleteConnection(ctx context.Context, organizationID uint) error
+	CreateState(ctx context.Context, state *models.SSOLoginState) error
+	// UseState deletes and returns the login state at the stage, failing
+	// with gorm.ErrRecordNotFound if there is none or it has expired
+	UseState(ctx context.Context, stateHash, stage string, at time.Time) (*models.SSOLoginState, error)
+	GetIdentity(ctx context.Context, connectionID uint, subject string) (*models.SSOIdentity, error)
+	CreateIdentity(ctx context.Context, identity *models.SSOIdentity) error
+	MarkIdentityUsed(ctx context.Context, id uint, at time.Time) error
+	// ProvisionUser creates a user signing in for the first time, with
+	// their membership and identity
+	ProvisionUser(ctx context.Context, user *models.User, member *models.OrganizationMember, identity *models.SSOIdentity) error
+	CreateDomain(ctx context.Context, domain *models.SSODomain) error
+	GetDomain(ctx context.Context, connectionID, id uint) (*models.SSODomain, error)
+	ListDomains(ctx context.Context, connectionID uint) ([]*models.SSODomain, error)
+	// GetVerifiedDomain returns the verification of domain by any connection
+	GetVerifiedDomain(ctx context.Context, domain string) (*models.SSODomain, error)
+	MarkDomainVerified(ctx context.Context, id uint, at time.Time) error
+	DeleteDomain(ctx context.Context, connectionID, id uint) error
+}
+
+// SecurityRepository defines the interface for login throttling and security
+// event data operations
+type SecurityRepository interface {
+	// UpdateThrottles locks the counters of the targets, creating missing
+	// ones, for the duration of update, and saves them unless it fails
+	UpdateThrottles(ctx context.Context, targets []string, update func(throttles []*models.LoginThrottle) error) error
+	GetThrottle(ctx context.Context, target string) (*models.LoginThrottle, error)
+	// ForgiveFailure takes one failure off a counter
+	ForgiveFailure(ctx context.Context, target string) error
+	ResetThrottle(ctx context.Context, target string) error
+	// MarkLocked records that an account was locked, failing with
+	// gorm.ErrRecordNotFound if it already was at or after lockedSince
+	MarkLocked(ctx context.Context, target string, at, lockedSince time.Time) error
+	CreateEvent(ctx context.Context, event *models.SecurityEvent) error
+	ListEvents(ctx context.Context, userID uint, offset, limit int) ([]*models.SecurityEvent, int64, error)
+	// Purge deletes counters without a failure since throttlesBefore and
+	// events older than eventsBefore
+	Purge(ctx context.Context, throttlesBefore, eventsBefore time.Time) (int64, error)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// keyPairRepository implements KeyPairRepository interface
+// Single Responsibility: Only handles key inventory persistence
+type keyPairRepository struct {
+	db *gorm.DB
+}
+
+// NewKeyPairRepository creates a new key pair repository instance
+func NewKeyPairRepository(db *gorm.DB) KeyPairRepository {
+	return &keyPairRepository{db: db}
+}
+
+func (r *keyPairRepository) Create(ctx context.Context, key *models.KeyPair) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *keyPairRepository) GetByID(ctx context.Context, scope Scope, id uint) (*models.KeyPair, error) {
+	var key models.KeyPair
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Preload("Certificates", func(db *gorm.DB) *gorm.DB {
+			return db.Order("not_after DESC")
+		}).
+		First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *keyPairRepository) GetByFingerprint(ctx context.Context, scope Scope, fingerprint string) (*models.KeyPair, error) {
+	var key models.KeyPair
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Where("fingerprint = ?", fingerprint).
+		First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *keyPairRepository) Update(ctx context.Context, key *models.KeyPair) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+func (r *keyPairRepository) Delete(ctx context.Context, scope Scope, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := applyScope(tx, scope).Delete(&models.KeyPair{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		// Certificates outlive their key; they just lose the link to it
+		return tx.Model(&models.Certificate{}).
+			Where("key_pair_id = ?", id).
+			Update("key_pair_id", nil).Error
+	})
+}
+
+func (r *keyPairRepository) List(ctx context.Context, scope Scope, offset, limit int) ([]*models.KeyPair, int64, error) {
+	query := applyScope(r.db.WithContext(ctx).Model(&models.KeyPair{}), scope)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var keys []*models.KeyPair
+	err := query.Find(&keys).Error
+	return keys, total, err
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// mfaRepository implements MFARepository interface
+// Single Responsibility: Only handles TOTP factor and recovery code persistence
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new MFA repository instance
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error) {
+	var factor models.TOTPFactor
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&factor).Error; err != nil {
+		return nil, err
+	}
+	return &factor, nil
+}
+
+func (r *mfaRepository) ReplaceFactor(ctx context.Context, factor *models.TOTPFactor) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", factor.UserID).Delete(&models.TOTPFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(factor).Error
+	})
+}
+
+func (r *mfaRepository) Confirm(ctx context.Context, id uint, step int64, at time.Time, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var factor models.TOTPFactor
+		if err := tx.First(&factor, id).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.TOTPFactor{}).
+			Where("id = ? AND confirmed_at IS NULL AND last_used_step < ?", id, step).
+			Updates(map[string]interface{}{"confirmed_at": at, "last_used_step": step, "failed_attempts": 0})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return replaceRecoveryCodes(tx, factor.UserID, codeHashes)
+	})
+}
+
+func (r *mfaRepository) UseStep(ctx context.Context, id uint, step int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.TOTPFactor{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Updates(map[string]interface{}{"last_used_step": step, "failed_attempts": 0})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) RecordFailure(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.TOTPFactor{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"failed_attempts": gorm.Expr("failed_attempts + 1"), "last_failed_at": at}).Error
+}
+
+func (r *mfaRepository) DeleteFactor(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TOTPFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return tx.Create(&codes).Error
+}
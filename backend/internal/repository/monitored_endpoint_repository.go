@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// monitoredEndpointRepository implements MonitoredEndpointRepository interface
+// Single Responsibility: Only handles monitored endpoint persistence
+type monitoredEndpointRepository struct {
+	db *gorm.DB
+}
+
+// NewMonitoredEndpointRepository creates a new monitored endpoint repository instance
+func NewMonitoredEndpointRepository(db *gorm.DB) MonitoredEndpointRepository {
+	return &monitoredEndpointRepository{db: db}
+}
+
+func (r *monitoredEndpointRepository) Create(ctx context.Context, endpoint *models.MonitoredEndpoint) error {
+	return r.db.WithContext(ctx).Create(endpoint).Error
+}
+
+func (r *monitoredEndpointRepository) GetByID(ctx context.Context, scope Scope, id uint) (*models.MonitoredEndpoint, error) {
+	var endpoint models.MonitoredEndpoint
+	err := applyScope(r.db.WithContext(ctx), scope).First(&endpoint, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *monitoredEndpointRepository) GetByAddress(ctx context.Context, scope Scope, hostname string, port int) (*models.MonitoredEndpoint, error) {
+	var endpoint models.MonitoredEndpoint
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Where("hostname = ? AND port = ?", hostname, port).
+		First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *monitoredEndpointRepository) Update(ctx context.Context, endpoint *models.MonitoredEndpoint) error {
+	return r.db.WithContext(ctx).Save(endpoint).Error
+}
+
+// Delete removes the endpoint together with its scan history
+func (r *monitoredEndpointRepository) Delete(ctx context.Context, scope Scope, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := applyScope(tx, scope).Delete(&models.MonitoredEndpoint{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("endpoint_id = ?", id).Delete(&models.EndpointScan{}).Error
+	})
+}
+
+func (r *monitoredEndpointRepository) List(ctx context.Context, scope Scope, offset, limit int) ([]*models.MonitoredEndpoint, int64, error) {
+	query := applyScope(r.db.WithContext(ctx).Model(&models.MonitoredEndpoint{}), scope)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("hostname ASC, port ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var endpoints []*models.MonitoredEndpoint
+	err := query.Find(&endpoints).Error
+	return endpoints, total, err
+}
+
+func (r *monitoredEndpointRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.MonitoredEndpoint, error) {
+	var endpoints []*models.MonitoredEndpoint
+	err := r.db.WithContext(ctx).
+		Where("paused = ? AND next_scan_at <= ?", false, now).
+		Order("next_scan_at ASC").
+		Limit(limit).
+		Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *monitoredEndpointRepository) ListExpiring(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]*models.MonitoredEndpoint, error) {
+	var endpoints []*models.MonitoredEndpoint
+	err := r.db.WithContext(ctx).
+		Where("paused = ? AND certificate_not_after >= ? AND certificate_not_after < ? AND id > ?", false, from, to, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&endpoints).Error
+	return endpoints, err
+}
@@ -31,6 +31,10 @@ func (r *operationRepository) GetByID(ctx context.Context, id uint) (*models.Ope
 	return &operation, nil
 }
 
+func (r *operationRepository) Update(ctx context.Context, operation *models.Operation) error {
+	return r.db.WithContext(ctx).Omit("User").Save(operation).Error
+}
+
 func (r *operationRepository) GetByUserID(ctx context.Context, userID uint, offset, limit int) ([]*models.Operation, error) {
 	var operations []*models.Operation
 	query := r.db.WithContext(ctx).
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// organizationRepository implements OrganizationRepository interface
+// Single Responsibility: Only handles organization data persistence
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizationRepository creates a new organization repository instance
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	return r.db.WithContext(ctx).Create(org).Error
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, id uint) (*models.Organization, error) {
+	var org models.Organization
+	err := r.db.WithContext(ctx).Preload("Members").First(&org, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) Update(ctx context.Context, org *models.Organization) error {
+	return r.db.WithContext(ctx).Save(org).Error
+}
+
+func (r *organizationRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Organization{}, id).Error
+}
+
+func (r *organizationRepository) AddMember(ctx context.Context, member *models.OrganizationMember) error {
+	return r.db.WithContext(ctx).Create(member).Error
+}
+
+func (r *organizationRepository) RemoveMember(ctx context.Context, orgID, userID uint) error {
+	return r.db.WithContext(ctx).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Delete(&models.OrganizationMember{}).Error
+}
+
+func (r *organizationRepository) GetMember(ctx context.Context, orgID, userID uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *organizationRepository) RequiresMFA(ctx context.Context, userID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.OrganizationMember{}).
+		Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.user_id = ? AND organizations.require_mfa = ?", userID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *organizationRepository) SetMemberRole(ctx context.Context, orgID, userID uint, role models.MemberRole) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
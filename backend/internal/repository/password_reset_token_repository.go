@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// passwordResetTokenRepository implements PasswordResetTokenRepository interface
+// Single Responsibility: Only handles password reset token persistence
+type passwordResetTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetTokenRepository creates a new password reset token repository instance
+func NewPasswordResetTokenRepository(db *gorm.DB) PasswordResetTokenRepository {
+	return &passwordResetTokenRepository{db: db}
+}
+
+func (r *passwordResetTokenRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *passwordResetTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetTokenRepository) Redeem(ctx context.Context, token *models.PasswordResetToken, passwordHash string, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Claim the token before changing anything, so it works only once
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		result = tx.Model(&models.User{}).
+			Where("id = ?", token.UserID).
+			Updates(map[string]interface{}{"password": passwordHash, "sessions_revoked_at": at})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", at).Error
+	})
+}
+
+func (r *passwordResetTokenRepository) Revoke(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", at).Error
+}
+
+func (r *passwordResetTokenRepository) CountSince(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// renewalPolicyRepository implements RenewalPolicyRepository interface
+// Single Responsibility: Only handles renewal policy persistence
+type renewalPolicyRepository struct {
+	db *gorm.DB
+}
+
+// NewRenewalPolicyRepository creates a new renewal policy repository instance
+func NewRenewalPolicyRepository(db *gorm.DB) RenewalPolicyRepository {
+	return &renewalPolicyRepository{db: db}
+}
+
+func (r *renewalPolicyRepository) Create(ctx context.Context, policy *models.RenewalPolicy) error {
+	return r.db.WithContext(ctx).Create(policy).Error
+}
+
+func (r *renewalPolicyRepository) GetByID(ctx context.Context, scope Scope, id uint) (*models.RenewalPolicy, error) {
+	var policy models.RenewalPolicy
+	err := applyScope(r.db.WithContext(ctx), scope).
+		Preload("Certificate").
+		First(&policy, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *renewalPolicyRepository) GetByCertificateID(ctx context.Context, certificateID uint) (*models.RenewalPolicy, error) {
+	var policy models.RenewalPolicy
+	err := r.db.WithContext(ctx).Where("certificate_id = ?", certificateID).First(&policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *renewalPolicyRepository) Update(ctx context.Context, policy *models.RenewalPolicy) error {
+	return r.db.WithContext(ctx).Omit("Certificate").Save(policy).Error
+}
+
+// Delete removes the policy together with its renewal history
+func (r *renewalPolicyRepository) Delete(ctx context.Context, scope Scope, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := applyScope(tx, scope).Delete(&models.RenewalPolicy{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("policy_id = ?", id).Delete(&models.CertificateRenewal{}).Error
+	})
+}
+
+func (r *renewalPolicyRepository) List(ctx context.Context, scope Scope, offset, limit int) ([]*models.RenewalPolicy, int64, error) {
+	query := applyScope(r.db.WithContext(ctx).Model(&models.RenewalPolicy{}), scope)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Preload("Certificate").Order("renew_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var policies []*models.RenewalPolicy
+	err := query.Find(&policies).Error
+	return policies, total, err
+}
+
+func (r *renewalPolicyRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.RenewalPolicy, error) {
+	var policies []*models.RenewalPolicy
+	err := r.db.WithContext(ctx).
+		Where("paused = ? AND renew_at <= ?", false, now).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("renew_at ASC").
+		Limit(limit).
+		Find(&policies).Error
+	return policies, err
+}
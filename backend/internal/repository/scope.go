@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// applyScope restricts a query to the records visible in scope. Records
+// owned by an organization are only returned for that organization, so a
+// user's personal inventory never includes them.
+func applyScope(db *gorm.DB, scope Scope) *gorm.DB {
+	if scope.OrganizationID != nil {
+		return db.Where("organization_id = ?", *scope.OrganizationID)
+	}
+	return db.Where("user_id = ? AND organization_id IS NULL", scope.UserID)
+}
+
+// containsPattern builds a LIKE pattern matching value anywhere in a
+// lower-cased column
+func containsPattern(value string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(strings.ToLower(value)) + "%"
+}
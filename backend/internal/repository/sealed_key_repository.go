@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sealedKeyModels lists every model with an embedded models.SealedKey stored
+// under the private_key_ column prefix
+var sealedKeyModels = []struct {
+	table string
+	model interface{}
+}{
+	{models.TableKeyPairs, &models.KeyPair{}},
+	{models.TableACMEAccounts, &models.ACMEAccount{}},
+	{models.TableTOTPFactors, &models.TOTPFactor{}},
+	{models.TableSSOConnections, &models.SSOConnection{}},
+	{models.TableRenewalPolicies, &models.RenewalPolicy{}},
+	{models.TableDeploymentTargets, &models.DeploymentTarget{}},
+}
+
+// sealedKeyRow is the projection of a sealed key column set
+type sealedKeyRow struct {
+	ID                    uint
+	PrivateKeyCiphertext  []byte
+	PrivateKeyWrappedKey  []byte
+	PrivateKeyMasterKeyID string
+}
+
+// sealedKeyRepository implements SealedKeyRepository interface
+// Single Responsibility: Only handles sealed key persistence for vault maintenance
+type sealedKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewSealedKeyRepository creates a new sealed key repository instance
+func NewSealedKeyRepository(db *gorm.DB) SealedKeyRepository {
+	return &sealedKeyRepository{db: db}
+}
+
+// NextID takes the next value of the ID sequence of table, for records whose
+// key is sealed before they are created
+func (r *sealedKeyRepository) NextID(ctx context.Context, table string) (uint, error) {
+	if _, err := sealedKeyModel(table); err != nil {
+		return 0, err
+	}
+	var id uint
+	err := r.db.WithContext(ctx).
+		Raw("SELECT nextval(pg_get_serial_sequence(?, 'id'))", table).
+		Scan(&id).Error
+	return id, err
+}
+
+func (r *sealedKeyRepository) Tables() []string {
+	tables := make([]string, len(sealedKeyModels))
+	for i, m := range sealedKeyModels {
+		tables[i] = m.table
+	}
+	return tables
+}
+
+// CountByMasterKey counts sealed keys per master key, including soft-deleted
+// records, which still have to be readable until they are purged
+func (r *sealedKeyRepository) CountByMasterKey(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, m := range sealedKeyModels {
+		var rows []struct {
+			MasterKeyID string
+			Count       int64
+		}
+		err := r.db.WithContext(ctx).Unscoped().
+			Model(m.model).
+			Select("private_key_master_key_id AS master_key_id, COUNT(*) AS count").
+			Group("private_key_master_key_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[row.MasterKeyID] += row.Count
+		}
+	}
+	return counts, nil
+}
+
+func (r *sealedKeyRepository) ListNotWrappedWith(ctx context.Context, table, masterKeyID string, afterID uint, limit int) ([]SealedKeyRecord, error) {
+	model, err := sealedKeyModel(table)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []sealedKeyRow
+	err = r.db.WithContext(ctx).Unscoped().
+		Model(model).
+		Select("id, private_key_ciphertext, private_key_wrapped_key, private_key_master_key_id").
+		Where("private_key_master_key_id <> ? AND id > ?", masterKeyID, afterID).
+		Order("id").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]SealedKeyRecord, len(rows))
+	for i, row := range rows {
+		records[i] = SealedKeyRecord{
+			Table: table,
+			ID:    row.ID,
+			Key: models.SealedKey{
+				Ciphertext:  row.PrivateKeyCiphertext,
+				WrappedKey:  row.PrivateKeyWrappedKey,
+				MasterKeyID: row.PrivateKeyMasterKeyID,
+			},
+		}
+	}
+	return records, nil
+}
+
+// ReplaceKey stores a re-wrapped key, provided the record is still wrapped
+// with previousMasterKeyID, so a concurrent update is never overwritten
+func (r *sealedKeyRepository) ReplaceKey(ctx context.Context, record SealedKeyRecord, previousMasterKeyID string) error {
+	model, err := sealedKeyModel(record.Table)
+	if err != nil {
+		return err
+	}
+
+	result := r.db.WithContext(ctx).Unscoped().
+		Model(model).
+		Where("id = ? AND private_key_master_key_id = ?", record.ID, previousMasterKeyID).
+		Updates(map[string]interface{}{
+			"private_key_ciphertext":    record.Key.Ciphertext,
+			"private_key_wrapped_key":   record.Key.WrappedKey,
+			"private_key_master_key_id": record.Key.MasterKeyID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func sealedKeyModel(table string) (interface{}, error) {
+	for _, m := range sealedKeyModels {
+		if m.table == table {
+			return m.model, nil
+		}
+	}
+	return nil, fmt.Errorf("table %s does not store sealed keys", table)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// securityRepository implements SecurityRepository interface
+// Single Responsibility: Only handles login throttle and security event persistence
+type securityRepository struct {
+	db *gorm.DB
+}
+
+// NewSecurityRepository creates a new security repository instance
+func NewSecurityRepository(db *gorm.DB) SecurityRepository {
+	return &securityRepository{db: db}
+}
+
+func (r *securityRepository) UpdateThrottles(ctx context.Context, targets []string, update func(throttles []*models.LoginThrottle) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		throttles := make([]*models.LoginThrottle, len(targets))
+		for i, target := range targets {
+			// Create the counter first, so that there is a row to lock
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Target: target}).Error; err != nil {
+				return err
+			}
+			var throttle models.LoginThrottle
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("target = ?", target).First(&throttle).Error; err != nil {
+				return err
+			}
+			throttles[i] = &throttle
+		}
+
+		if err := update(throttles); err != nil {
+			return err
+		}
+		for _, throttle := range throttles {
+			if err := tx.Save(throttle).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *securityRepository) GetThrottle(ctx context.Context, target string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	if err := r.db.WithContext(ctx).Where("target = ?", target).First(&throttle).Error; err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *securityRepository) ForgiveFailure(ctx context.Context, target string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.LoginThrottle{}).
+		Where("target = ? AND failures > 0", target).
+		UpdateColumn("failures", gorm.Expr("failures - 1")).Error
+}
+
+func (r *securityRepository) ResetThrottle(ctx context.Context, target string) error {
+	return r.db.WithContext(ctx).Where("target = ?", target).Delete(&models.LoginThrottle{}).Error
+}
+
+func (r *securityRepository) MarkLocked(ctx context.Context, target string, at, lockedSince time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.LoginThrottle{}).
+		Where("target = ? AND (locked_at IS NULL OR locked_at < ?)", target, lockedSince).
+		UpdateColumn("locked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *securityRepository) CreateEvent(ctx context.Context, event *models.SecurityEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *securityRepository) ListEvents(ctx context.Context, userID uint, offset, limit int) ([]*models.SecurityEvent, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.SecurityEvent{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("created_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var events []*models.SecurityEvent
+	err := query.Find(&events).Error
+	return events, total, err
+}
+
+func (r *securityRepository) Purge(ctx context.Context, throttlesBefore, eventsBefore time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("last_failure_at < ?", throttlesBefore).Delete(&models.LoginThrottle{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+
+		result = tx.Where("created_at < ?", eventsBefore).Delete(&models.SecurityEvent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+		return nil
+	})
+	return deleted, err
+}
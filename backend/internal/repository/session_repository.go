@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sessionRepository implements SessionRepository interface
+// Single Responsibility: Only handles login session persistence
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository instance
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *sessionRepository) GetByID(ctx context.Context, userID, id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) Touch(ctx context.Context, familyID string, at, expiresAt time.Time, ipAddress, userAgent string) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("family_id = ?", familyID).
+		Updates(map[string]interface{}{
+			"last_seen_at": at,
+			"expires_at":   expiresAt,
+			"ip_address":   ipAddress,
+			"user_agent":   userAgent,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) ListActive(ctx context.Context, userID uint, startedAfter, now time.Time) ([]*models.Session, error) {
+	var sessions []*models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ? AND created_at >= ?", userID, now, startedAfter).
+		Order("last_seen_at DESC, id DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *sessionRepository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+func (r *sessionRepository) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ssoRepository implements SSORepository interface
+// Single Responsibility: Only handles single sign-on connection, identity and login state persistence
+type ssoRepository struct {
+	db *gorm.DB
+}
+
+// NewSSORepository creates a new SSO repository instance
+func NewSSORepository(db *gorm.DB) SSORepository {
+	return &ssoRepository{db: db}
+}
+
+func (r *ssoRepository) GetConnection(ctx context.Context, organizationID uint) (*models.SSOConnection, error) {
+	var connection models.SSOConnection
+	if err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).First(&connection).Error; err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r *ssoRepository) GetConnectionByID(ctx context.Context, id uint) (*models.SSOConnection, error) {
+	var connection models.SSOConnection
+	if err := r.db.WithContext(ctx).First(&connection, id).Error; err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r *ssoRepository) SaveConnection(ctx context.Context, connection *models.SSOConnection) error {
+	return r.db.WithContext(ctx).Save(connection).Error
+}
+
+func (r *ssoRepository) DeleteConnection(ctx context.Context, organizationID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var connection models.SSOConnection
+		if err := tx.Where("organization_id = ?", organizationID).First(&connection).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("connection_id = ?", connection.ID).Delete(&models.SSOIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("connection_id = ?", connection.ID).Delete(&models.SSOLoginState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("connection_id = ?", connection.ID).Delete(&models.SSODomain{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&connection).Error
+	})
+}
+
+func (r *ssoRepository) CreateState(ctx context.Context, state *models.SSOLoginState) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Sign-ins that were never completed are removed as new ones start
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.SSOLoginState{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(state).Error
+	})
+}
+
+func (r *ssoRepository) UseState(ctx context.Context, stateHash, stage string, at time.Time) (*models.SSOLoginState, error) {
+	var state models.SSOLoginState
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ? AND stage = ?", stateHash, stage).First(&state).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&models.SSOLoginState{}, state.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || at.After(state.ExpiresAt) {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *ssoRepository) GetIdentity(ctx context.Context, connectionID uint, subject string) (*models.SSOIdentity, error) {
+	var identity models.SSOIdentity
+	err := r.db.WithContext(ctx).
+		Where("connection_id = ? AND subject = ?", connectionID, subject).
+		First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *ssoRepository) CreateIdentity(ctx context.Context, identity *models.SSOIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *ssoRepository) MarkIdentityUsed(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SSOIdentity{}).
+		Where("id = ?", id).
+		UpdateColumn("last_login_at", at).Error
+}
+
+func (r *ssoRepository) ProvisionUser(ctx context.Context, user *models.User, member *models.OrganizationMember, identity *models.SSOIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		member.UserID = user.ID
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (r *ssoRepository) CreateDomain(ctx context.Context, domain *models.SSODomain) error {
+	return r.db.WithContext(ctx).Create(domain).Error
+}
+
+func (r *ssoRepository) GetDomain(ctx context.Context, connectionID, id uint) (*models.SSODomain, error) {
+	var domain models.SSODomain
+	if err := r.db.WithContext(ctx).Where("connection_id = ?", connectionID).First(&domain, id).Error; err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (r *ssoRepository) ListDomains(ctx context.Context, connectionID uint) ([]*models.SSODomain, error) {
+	var domains []*models.SSODomain
+	err := r.db.WithContext(ctx).
+		Where("connection_id = ?", connectionID).
+		Order("domain").
+		Find(&domains).Error
+	return domains, err
+}
+
+func (r *ssoRepository) GetVerifiedDomain(ctx context.Context, domain string) (*models.SSODomain, error) {
+	var verified models.SSODomain
+	err := r.db.WithContext(ctx).
+		Where("domain = ? AND verified_at IS NOT NULL", domain).
+		First(&verified).Error
+	if err != nil {
+		return nil, err
+	}
+	return &verified, nil
+}
+
+func (r *ssoRepository) MarkDomainVerified(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SSODomain{}).
+		Where("id = ?", id).
+		UpdateColumn("verified_at", at).Error
+}
+
+func (r *ssoRepository) DeleteDomain(ctx context.Context, connectionID, id uint) error {
+	result := r.db.WithContext(ctx).Where("connection_id = ?", connectionID).Delete(&models.SSODomain{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
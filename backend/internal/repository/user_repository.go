@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 	"web-openssl-backend/internal/models"
 
 	"gorm.io/gorm"
@@ -44,6 +45,72 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	return r.db.WithContext(ctx).Save(user).Error
 }
 
+func (r *userRepository) IncrementUsage(ctx context.Context, id uint, count int) error {
+	return r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Update("usage_count", gorm.Expr("usage_count + ?", count)).Error
+}
+
+// ReserveUsage counts count operations against the user's monthly usage in
+// one statement, starting a new month first when the current one is over.
+// Nothing is counted, and gorm.ErrRecordNotFound returned, when the user
+// does not exist or usage would go over limit; a negative limit is
+// unlimited.
+func (r *userRepository) ReserveUsage(ctx context.Context, id uint, count, limit int, now time.Time) error {
+	used := gorm.Expr("CASE WHEN usage_reset_at < ? THEN 0 ELSE usage_count END", now)
+	result := r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Where("? < 0 OR ? + ? <= ?", limit, used, count, limit).
+		Updates(map[string]interface{}{
+			"usage_count":    gorm.Expr("? + ?", used, count),
+			"usage_reset_at": gorm.Expr("CASE WHEN usage_reset_at < ? THEN ? ELSE usage_reset_at END", now, now.AddDate(0, 1, 0)),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uint, passwordHash string, revokedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"password": passwordHash, "sessions_revoked_at": revokedAt}).Error
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uint, email string) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND email = ?", id, email).
+		Update("email_verified", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) MarkVerificationSent(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Update("verification_sent_at", at).Error
+}
+
+func (r *userRepository) RevokeSessions(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Update("sessions_revoked_at", at).Error
+}
+
 func (r *userRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"web-openssl-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// webAuthnRepository implements WebAuthnRepository interface
+// Single Responsibility: Only handles WebAuthn credential and ceremony persistence
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnRepository creates a new WebAuthn repository instance
+func NewWebAuthnRepository(db *gorm.DB) WebAuthnRepository {
+	return &webAuthnRepository{db: db}
+}
+
+func (r *webAuthnRepository) CreateChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Ceremonies that were never completed are removed as new ones start
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.WebAuthnChallenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(challenge).Error
+	})
+}
+
+func (r *webAuthnRepository) UseChallenge(ctx context.Context, challengeHash, ceremony string, at time.Time) (*models.WebAuthnChallenge, error) {
+	var challenge models.WebAuthnChallenge
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("challenge_hash = ? AND ceremony = ?", challengeHash, ceremony).First(&challenge).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&models.WebAuthnChallenge{}, challenge.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || at.After(challenge.ExpiresAt) {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *webAuthnRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+func (r *webAuthnRepository) GetCredential(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := r.db.WithContext(ctx).Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnRepository) ListCredentials(ctx context.Context, userID uint) ([]*models.WebAuthnCredential, error) {
+	var credentials []*models.WebAuthnCredential
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *webAuthnRepository) CountCredentials(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *webAuthnRepository) UserHandle(ctx context.Context, userID uint) (string, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").First(&credential).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return credential.UserHandle, nil
+}
+
+func (r *webAuthnRepository) MarkUsed(ctx context.Context, id uint, previousCount, signCount uint32, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previousCount).
+		UpdateColumns(map[string]interface{}{"sign_count": signCount, "last_used_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, userID, id uint) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
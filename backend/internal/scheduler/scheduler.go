@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a task run at a fixed interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs background jobs for the server process. Each job runs in
+// its own goroutine, once shortly after start and then every interval; a
+// run never overlaps the previous run of the same job.
+type Scheduler struct {
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers a job. Jobs with a non-positive interval are disabled.
+func (s *Scheduler) Every(interval time.Duration, name string, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		log.Printf("Scheduler: job %s is disabled", name)
+		return
+	}
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Start launches every job and returns immediately. Jobs stop when ctx is
+// cancelled; Wait blocks until they have.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Wait blocks until every job has stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	// Let the server finish starting before the first run
+	timer := time.NewTimer(startDelay(job.Interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, job)
+		timer.Reset(job.Interval)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Scheduler: job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	started := time.Now()
+	if err := job.Run(ctx); err != nil {
+		log.Printf("Scheduler: job %s failed after %s: %v", job.Name, time.Since(started).Round(time.Millisecond), err)
+		return
+	}
+	log.Printf("Scheduler: job %s finished in %s", job.Name, time.Since(started).Round(time.Millisecond))
+}
+
+func startDelay(interval time.Duration) time.Duration {
+	if interval < time.Minute {
+		return interval
+	}
+	return 30 * time.Second
+}
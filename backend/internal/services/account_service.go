@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/mail"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("password reset link is invalid or has expired")
+	ErrWeakPassword      = errors.New("password does not meet the requirements")
+	ErrSessionRevoked    = errors.New("session has been revoked")
+
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+	ErrEmailNotVerified         = errors.New("verify your email address to use this feature")
+)
+
+// Restrictions the unverified user policy can put on users who have not
+// verified their email address
+const (
+	// RestrictWrite leaves unverified users read-only access
+	RestrictWrite = "write"
+	// RestrictBilling keeps unverified users from subscribing to plans
+	RestrictBilling = "billing"
+)
+
+const (
+	defaultPasswordResetTTL = time.Hour
+	secretTokenBytes        = 32
+	// maxResetRequests caps the reset emails sent to one user per hour
+	maxResetRequests   = 5
+	accountMailTimeout = 30 * time.Second
+
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes
+	maxPasswordBytes = 72
+
+	emailVerificationPurpose  = "email-verification"
+	defaultEmailVerifyTTL     = 48 * time.Hour
+	verificationResendBackoff = time.Minute
+)
+
+// accountService implements AccountService interface
+// Single Responsibility: Handles password resets, email verification and
+// session revocation
+type accountService struct {
+	userRepo        repository.UserRepository
+	resetRepo       repository.PasswordResetTokenRepository
+	authService     *auth.Service
+	mailer          *mail.Service
+	appURL          string
+	resetTTL        time.Duration
+	verificationTTL time.Duration
+	restrictions    []string
+}
+
+// NewAccountService creates a new account service. Reset and verification
+// links point to appURL, the address of the web application. Users who have
+// not verified their email address are kept from the restrictions listed in
+// unverifiedRestrictions.
+func NewAccountService(
+	userRepo repository.UserRepository,
+	resetRepo repository.PasswordResetTokenRepository,
+	authService *auth.Service,
+	mailer *mail.Service,
+	appURL string,
+	resetTTL time.Duration,
+	verificationTTL time.Duration,
+	unverifiedRestrictions []string,
+) AccountService {
+	if resetTTL <= 0 {
+		resetTTL = defaultPasswordResetTTL
+	}
+	if verificationTTL <= 0 {
+		verificationTTL = defaultEmailVerifyTTL
+	}
+
+	return &accountService{
+		userRepo:        userRepo,
+		resetRepo:       resetRepo,
+		authService:     authService,
+		mailer:          mailer,
+		appURL:          strings.TrimRight(appURL, "/"),
+		resetTTL:        resetTTL,
+		verificationTTL: verificationTTL,
+		restrictions:    unverifiedRestrictions,
+	}
+}
+
+func (s *accountService) RequestPasswordReset(ctx context.Context, email, ipAddress string) error {
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	now := time.Now()
+	recent, err := s.resetRepo.CountSince(ctx, user.ID, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent >= maxResetRequests {
+		log.Printf("Password reset for user %d throttled after %d requests in the last hour", user.ID, recent)
+		return nil
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the newest link works
+	if err := s.resetRepo.Revoke(ctx, user.ID, now); err != nil {
+		return err
+	}
+	if err := s.resetRepo.Create(ctx, &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashSecretToken(token),
+		ExpiresAt: now.Add(s.resetTTL),
+		IPAddress: ipAddress,
+	}); err != nil {
+		return err
+	}
+
+	link := s.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	s.sendMail(user, &mail.Message{
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Someone asked to reset the password of your account %s.\n\n"+
+			"Open this link within %s to choose a new password:\n%s\n\n"+
+			"If it was not you, ignore this email; your password stays the same.",
+			user.Email, formatTTL(s.resetTTL), link),
+	})
+	return nil
+}
+
+func (s *accountService) ResetPassword(ctx context.Context, token, password string) error {
+	reset, err := s.resetRepo.GetByHash(ctx, hashSecretToken(strings.TrimSpace(token)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if reset.UsedAt != nil || now.After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, reset.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := validatePassword(password, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := s.authService.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// The token is claimed together with the password change, so it works
+	// only once and is not used up by a reset that fails
+	if err := s.resetRepo.Redeem(ctx, reset, hash, now); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	s.sendMail(user, &mail.Message{
+		Subject: "Your password was changed",
+		Text: fmt.Sprintf("The password of your account %s was reset on %s and you were signed out everywhere.\n\n"+
+			"If you did not do this, reset your password again and contact support.",
+			user.Email, now.UTC().Format("2006-01-02 15:04 MST")),
+	})
+	return nil
+}
+
+func (s *accountService) CheckToken(claims *auth.Claims) error {
+	user, err := s.userRepo.GetByID(context.Background(), claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user.SessionsRevokedAt == nil || claims.IssuedAt == nil {
+		return nil
+	}
+
+	// Issue times have a resolution of a second
+	if claims.IssuedAt.Time.Before(user.SessionsRevokedAt.Truncate(time.Second)) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func (s *accountService) SendVerification(ctx context.Context, user *models.User) error {
+	token, err := s.authService.GeneratePurposeToken(emailVerificationPurpose, user, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkVerificationSent(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+
+	link := s.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	s.sendMail(user, &mail.Message{
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Confirm that %s is your email address by opening this link within %s:\n%s\n\n"+
+			"If you did not create an account, ignore this email.",
+			user.Email, formatTTL(s.verificationTTL), link),
+	})
+	return nil
+}
+
+func (s *accountService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive || user.EmailVerified {
+		return nil
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendBackoff {
+		return nil
+	}
+
+	return s.SendVerification(ctx, user)
+}
+
+func (s *accountService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	claims, err := s.authService.ValidatePurposeToken(emailVerificationPurpose, strings.TrimSpace(token))
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// The token names the address it was sent to, so links sent before an
+	// email change do not verify the new address
+	if err := s.userRepo.MarkEmailVerified(ctx, claims.UserID, claims.Email); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+
+	return s.userRepo.GetByID(ctx, claims.UserID)
+}
+
+func (s *accountService) Restricts(restriction string) bool {
+	for _, r := range s.restrictions {
+		if r == restriction {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *accountService) EmailVerified(ctx context.Context, userID uint) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+func (s *accountService) Check() error {
+	for _, r := range s.restrictions {
+		if r != RestrictWrite && r != RestrictBilling {
+			return fmt.Errorf("unknown unverified email restriction %q, use %s or %s", r, RestrictWrite, RestrictBilling)
+		}
+	}
+	return nil
+}
+
+// Helper functions
+
+func (s *accountService) sendMail(user *models.User, message *mail.Message) {
+	sendUserMail(s.mailer, user, message)
+}
+
+// sendUserMail delivers in the background, so that requests for existing
+// and unknown addresses take the same time.
+func sendUserMail(mailer *mail.Service, user *models.User, message *mail.Message) {
+	message.To = []string{user.Email}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), accountMailTimeout)
+		defer cancel()
+
+		if err := mailer.Send(ctx, message); err != nil {
+			log.Printf("Failed to email user %d: %v", user.ID, err)
+		}
+	}()
+}
+
+// newSecretToken makes the opaque tokens handed out for password resets and
+// refreshes; only their hash is stored.
+func newSecretToken() (string, error) {
+	token := make([]byte, secretTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validatePassword requires at least 8 characters mixing letters with
+// digits or symbols, and rejects the account's own email address.
+func validatePassword(password, email string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrWeakPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: use at most %d bytes", ErrWeakPassword, maxPasswordBytes)
+	}
+
+	var letters, others bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letters = true
+		} else if !unicode.IsSpace(r) {
+			others = true
+		}
+	}
+	if !letters || !others {
+		return fmt.Errorf("%w: mix letters with digits or symbols", ErrWeakPassword)
+	}
+
+	if strings.EqualFold(password, email) {
+		return fmt.Errorf("%w: do not use your email address", ErrWeakPassword)
+	}
+	return nil
+}
+
+func formatTTL(ttl time.Duration) string {
+	if ttl%time.Hour == 0 {
+		if hours := int(ttl / time.Hour); hours != 1 {
+			return fmt.Sprintf("%d hours", hours)
+		}
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d minutes", int(ttl/time.Minute))
+}
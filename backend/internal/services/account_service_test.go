@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/mail"
+
+	"gorm.io/gorm"
+)
+
+var resetLinkPattern = regexp.MustCompile(`reset-password\?token=(\S+)`)
+
+// memoryUserRepo hands out copies, as rows read from a database would be
+type memoryUserRepo struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[uint]*models.User
+}
+
+func (r *memoryUserRepo) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memoryUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// memoryResetRepo keeps the claim-then-update semantics of the real
+// repository's Redeem transaction
+type memoryResetRepo struct {
+	users *memoryUserRepo
+
+	mu     sync.Mutex
+	tokens []*models.PasswordResetToken
+}
+
+func (r *memoryResetRepo) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	token.CreatedAt = time.Now()
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *memoryResetRepo) GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryResetRepo) Redeem(ctx context.Context, token *models.PasswordResetToken, passwordHash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.tokens[token.ID-1]
+	if stored.UsedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+
+	r.users.mu.Lock()
+	user, ok := r.users.users[token.UserID]
+	if ok {
+		user.Password = passwordHash
+		user.SessionsRevokedAt = &at
+	}
+	r.users.mu.Unlock()
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	for _, other := range r.tokens {
+		if other.UserID == token.UserID && other.UsedAt == nil {
+			other.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryResetRepo) Revoke(ctx context.Context, userID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryResetRepo) CountSince(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, token := range r.tokens {
+		if token.UserID == userID && !token.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type accountTest struct {
+	service AccountService
+	users   *memoryUserRepo
+	auth    *auth.Service
+	mail    *mail.FakeSender
+}
+
+func newAccountTest(t *testing.T, resetTTL time.Duration) *accountTest {
+	t.Helper()
+	authService := auth.NewService(auth.Config{Secret: "test-secret", ExpiresIn: time.Hour})
+	password, err := authService.HashPassword("old-passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &memoryUserRepo{users: map[uint]*models.User{
+		1: {ID: 1, Email: "user@example.com", Password: password, IsActive: true},
+	}}
+	sender := mail.NewFakeSender()
+	mailer := mail.NewService(mail.Config{From: "noreply@example.com"})
+	mailer.SetSender(sender)
+
+	return &accountTest{
+		service: NewAccountService(users, &memoryResetRepo{users: users}, authService, mailer, "https://app.example.com/", resetTTL, 0, nil),
+		users:   users,
+		auth:    authService,
+		mail:    sender,
+	}
+}
+
+// waitForMail returns the nth message sent, which goes out in the background
+func (a *accountTest) waitForMail(t *testing.T, n int) mail.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if sent := a.mail.Sent(); len(sent) >= n {
+			return sent[n-1]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message %d was never sent", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// requestReset asks for a reset link and returns the token mailed in it
+func (a *accountTest) requestReset(t *testing.T, n int) string {
+	t.Helper()
+	if err := a.service.RequestPasswordReset(context.Background(), "user@example.com", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	message := a.waitForMail(t, n)
+	if len(message.To) != 1 || message.To[0] != "user@example.com" {
+		t.Fatalf("reset link sent to %v", message.To)
+	}
+	match := resetLinkPattern.FindStringSubmatch(message.Text)
+	if match == nil {
+		t.Fatalf("no reset link in %q", message.Text)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestResetTokenWorksOnce(t *testing.T) {
+	a := newAccountTest(t, 0)
+	ctx := context.Background()
+	token := a.requestReset(t, 1)
+
+	if err := a.service.ResetPassword(ctx, token, "new-passw0rd"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	user, _ := a.users.GetByID(ctx, 1)
+	if !a.auth.CheckPasswordHash("new-passw0rd", user.Password) {
+		t.Error("password was not changed")
+	}
+	if user.SessionsRevokedAt == nil {
+		t.Error("sessions were not revoked")
+	}
+	if confirmation := a.waitForMail(t, 2); confirmation.Subject != "Your password was changed" {
+		t.Errorf("confirmation subject = %q", confirmation.Subject)
+	}
+
+	if err := a.service.ResetPassword(ctx, token, "other-passw0rd"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("second ResetPassword error = %v, want %v", err, ErrInvalidResetToken)
+	}
+	user, _ = a.users.GetByID(ctx, 1)
+	if !a.auth.CheckPasswordHash("new-passw0rd", user.Password) {
+		t.Error("used token changed the password again")
+	}
+}
+
+func TestConcurrentResetsClaimTokenOnce(t *testing.T) {
+	a := newAccountTest(t, 0)
+	token := a.requestReset(t, 1)
+
+	const attempts = 5
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			errs <- a.service.ResetPassword(context.Background(), token, "new-passw0rd")
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < attempts; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrInvalidResetToken):
+			t.Errorf("ResetPassword error = %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d resets succeeded with one token, want 1", succeeded)
+	}
+}
+
+func TestNewResetRequestRevokesEarlierLink(t *testing.T) {
+	a := newAccountTest(t, 0)
+	ctx := context.Background()
+	first := a.requestReset(t, 1)
+	second := a.requestReset(t, 2)
+
+	if err := a.service.ResetPassword(ctx, first, "new-passw0rd"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("earlier link error = %v, want %v", err, ErrInvalidResetToken)
+	}
+	if err := a.service.ResetPassword(ctx, second, "new-passw0rd"); err != nil {
+		t.Fatalf("latest link: %v", err)
+	}
+}
+
+func TestFailedResetKeepsToken(t *testing.T) {
+	a := newAccountTest(t, 0)
+	ctx := context.Background()
+	token := a.requestReset(t, 1)
+
+	if err := a.service.ResetPassword(ctx, token, "short"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("weak password error = %v, want %v", err, ErrWeakPassword)
+	}
+	if err := a.service.ResetPassword(ctx, token, "new-passw0rd"); err != nil {
+		t.Fatalf("token was used up by a failed reset: %v", err)
+	}
+}
+
+func TestExpiredResetTokenIsRejected(t *testing.T) {
+	a := newAccountTest(t, time.Millisecond)
+	token := a.requestReset(t, 1)
+	time.Sleep(10 * time.Millisecond)
+
+	if err := a.service.ResetPassword(context.Background(), token, "new-passw0rd"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expired token error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestResetForUnknownEmailSendsNothing(t *testing.T) {
+	a := newAccountTest(t, 0)
+	if err := a.service.RequestPasswordReset(context.Background(), "nobody@example.com", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if sent := a.mail.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d messages for an unknown address", len(sent))
+	}
+}
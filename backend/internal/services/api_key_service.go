@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key")
+	ErrInvalidAPIKey        = errors.New("API key is invalid, revoked or expired")
+	ErrAPIAccessNotInPlan   = errors.New("API access is not included in your plan")
+	ErrTooManyAPIKeys       = errors.New("too many API keys")
+)
+
+const (
+	// apiKeyPrefix marks the keys, so that secret scanners can find them
+	apiKeyPrefix = "osk_"
+	// apiKeyShownPrefix is how much of a key is kept to tell keys apart
+	apiKeyShownPrefix = len(apiKeyPrefix) + 8
+	maxAPIKeysPerUser = 25
+	// apiKeyUseInterval limits how often the last use of a key is written
+	apiKeyUseInterval = time.Minute
+)
+
+var apiKeyScopes = []string{
+	models.APIKeyScopeCertificates,
+	models.APIKeyScopeKeys,
+	models.APIKeyScopeEncrypt,
+	models.APIKeyScopeHash,
+	models.APIKeyScopeSSL,
+	models.APIKeyScopePGP,
+	models.APIKeyScopeCMS,
+	models.APIKeyScopeTimestamp,
+	models.APIKeyScopeCT,
+	models.APIKeyScopeACME,
+}
+
+// apiKeyService implements APIKeyService interface
+// Single Responsibility: Issues API keys and authenticates requests made with them
+type apiKeyService struct {
+	keyRepo  repository.APIKeyRepository
+	userRepo repository.UserRepository
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(keyRepo repository.APIKeyRepository, userRepo repository.UserRepository) APIKeyService {
+	return &apiKeyService{
+		keyRepo:  keyRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *apiKeyService) CreateKey(ctx context.Context, userID uint, req CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiry must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !planHasAPIAccess(user.Plan) {
+		return nil, "", ErrAPIAccessNotInPlan
+	}
+
+	count, err := s.keyRepo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", fmt.Errorf("%w: revoke one of your %d keys first", ErrTooManyAPIKeys, count)
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := apiKeyPrefix + secret
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plaintext[:apiKeyShownPrefix],
+		KeyHash:   hashSecretToken(plaintext),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+func (s *apiKeyService) ListKeys(ctx context.Context, userID uint) ([]*models.APIKey, error) {
+	return s.keyRepo.ListByUser(ctx, userID)
+}
+
+func (s *apiKeyService) RevokeKey(ctx context.Context, userID, id uint) error {
+	return s.keyRepo.Delete(ctx, userID, id)
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, key, ipAddress string) (*models.APIKey, *models.User, error) {
+	key = strings.TrimSpace(key)
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.keyRepo.GetByHash(ctx, hashSecretToken(key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(ctx, apiKey.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	// Checked on every request, so that a downgrade takes effect at once
+	if !planHasAPIAccess(user.Plan) {
+		return nil, nil, ErrAPIAccessNotInPlan
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyUseInterval || apiKey.LastUsedIP != ipAddress {
+		if err := s.keyRepo.MarkUsed(ctx, apiKey.ID, now, ipAddress); err != nil {
+			return nil, nil, err
+		}
+		apiKey.LastUsedAt = &now
+		apiKey.LastUsedIP = ipAddress
+	}
+
+	return apiKey, user, nil
+}
+
+func (s *apiKeyService) Scopes() []string {
+	return append([]string(nil), apiKeyScopes...)
+}
+
+// Helper functions
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !isAPIKeyScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
+
+func isAPIKeyScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func planHasAPIAccess(plan models.PlanType) bool {
+	access, _ := models.GetPlanLimits(plan)["api_access"].(bool)
+	return access
+}
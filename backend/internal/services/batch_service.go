@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUsageLimitExceeded = errors.New("monthly usage limit exceeded")
+	ErrTooManyBatchJobs   = errors.New("too many batch jobs running")
+)
+
+const (
+	defaultBatchConcurrency = 4
+	defaultBatchJobsPerUser = 2
+	batchJobTimeout         = 30 * time.Minute
+)
+
+// batchService implements BatchService interface
+// Single Responsibility: Runs batches of operations and tracks batch jobs
+type batchService struct {
+	jobRepo       repository.BatchJobRepository
+	operationRepo repository.OperationRepository
+	userRepo      repository.UserRepository
+	jobsPerUser   int
+
+	// slots is the worker pool shared by every batch and job
+	slots chan struct{}
+
+	mu      sync.Mutex
+	running map[uint]int // jobs pending or running, per user
+}
+
+// NewBatchService creates a new batch service running at most concurrency
+// operations at once across all batches, and at most jobsPerUser jobs of
+// one user at a time
+func NewBatchService(
+	jobRepo repository.BatchJobRepository,
+	operationRepo repository.OperationRepository,
+	userRepo repository.UserRepository,
+	concurrency int,
+	jobsPerUser int,
+) BatchService {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if jobsPerUser <= 0 {
+		jobsPerUser = defaultBatchJobsPerUser
+	}
+
+	return &batchService{
+		jobRepo:       jobRepo,
+		operationRepo: operationRepo,
+		userRepo:      userRepo,
+		jobsPerUser:   jobsPerUser,
+		slots:         make(chan struct{}, concurrency),
+		running:       make(map[uint]int),
+	}
+}
+
+func (s *batchService) Run(ctx context.Context, req BatchRequest) ([]*BatchResult, error) {
+	if err := s.reserveUsage(ctx, req); err != nil {
+		return nil, err
+	}
+
+	results := s.run(ctx, req, nil)
+	s.refundUsage(ctx, req, results)
+	return results, nil
+}
+
+func (s *batchService) Submit(ctx context.Context, req BatchRequest) (*models.BatchJob, error) {
+	if !s.startJob(req.UserID) {
+		return nil, ErrTooManyBatchJobs
+	}
+	if err := s.reserveUsage(ctx, req); err != nil {
+		s.endJob(req.UserID)
+		return nil, err
+	}
+
+	job := &models.BatchJob{
+		UserID: req.UserID,
+		Status: models.OpStatusPending,
+		Total:  len(req.Operations),
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		s.refundUsage(ctx, req, nil)
+		s.endJob(req.UserID)
+		return nil, err
+	}
+
+	// The job outlives the request that submitted it
+	go s.runJob(*job, req)
+
+	return job, nil
+}
+
+func (s *batchService) GetJob(ctx context.Context, userID, id uint) (*models.BatchJob, error) {
+	return s.jobRepo.GetByID(ctx, userID, id)
+}
+
+func (s *batchService) ListJobs(ctx context.Context, userID uint, offset, limit int) ([]*models.BatchJob, int64, error) {
+	return s.jobRepo.List(ctx, userID, offset, limit)
+}
+
+func (s *batchService) FailInterrupted(ctx context.Context) error {
+	count, err := s.jobRepo.FailUnfinished(ctx, "interrupted by a server restart")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Printf("Marked %d interrupted batch jobs as failed", count)
+	}
+	return nil
+}
+
+// Helper functions
+
+// reserveUsage counts every operation of the batch against the user's
+// monthly allowance before any of them runs, so concurrent batches cannot
+// together run past it. It stands in for the handlers' checkUsageLimits
+// and incrementUsage: those check and count separately, which lets
+// concurrent requests all pass the check, and a batch would overshoot by
+// its whole size rather than by one operation.
+func (s *batchService) reserveUsage(ctx context.Context, req BatchRequest) error {
+	user, err := s.userRepo.GetByID(ctx, req.UserID)
+	if err != nil {
+		return err
+	}
+
+	limit := models.GetPlanLimits(user.Plan)["operations_per_month"].(int)
+	err = s.userRepo.ReserveUsage(ctx, req.UserID, len(req.Operations), limit, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUsageLimitExceeded
+	}
+	return err
+}
+
+// refundUsage gives back the usage reserved for operations that did not
+// succeed. Without results, nothing ran and the whole batch is refunded.
+func (s *batchService) refundUsage(ctx context.Context, req BatchRequest, results []*BatchResult) {
+	unused := len(req.Operations)
+	for _, result := range results {
+		if result.Success {
+			unused--
+		}
+	}
+	if unused == 0 {
+		return
+	}
+
+	// The request may be gone by now; the refund must still be made
+	if err := s.userRepo.IncrementUsage(context.WithoutCancel(ctx), req.UserID, -unused); err != nil {
+		log.Printf("Failed to refund usage of %d batch operations: %v", unused, err)
+	}
+}
+
+// startJob takes one of the user's job slots, unless all are in use
+func (s *batchService) startJob(userID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[userID] >= s.jobsPerUser {
+		return false
+	}
+	s.running[userID]++
+	return true
+}
+
+func (s *batchService) endJob(userID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[userID]--; s.running[userID] <= 0 {
+		delete(s.running, userID)
+	}
+}
+
+func (s *batchService) runJob(job models.BatchJob, req BatchRequest) {
+	defer s.endJob(job.UserID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchJobTimeout)
+	defer cancel()
+
+	started := time.Now()
+	job.Status = models.OpStatusRunning
+	job.StartedAt = &started
+	if err := s.jobRepo.Update(ctx, &job); err != nil {
+		log.Printf("Failed to start batch job %d: %v", job.ID, err)
+	}
+
+	var mu sync.Mutex
+	results := s.run(ctx, req, func(result *BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if result.Success {
+			job.Succeeded++
+		} else {
+			job.Failed++
+		}
+		if err := s.jobRepo.UpdateProgress(ctx, job.ID, job.Succeeded, job.Failed); err != nil {
+			log.Printf("Failed to record progress of batch job %d: %v", job.ID, err)
+		}
+	})
+
+	finished := time.Now()
+	job.Status = models.OpStatusCompleted
+	job.FinishedAt = &finished
+	if ctx.Err() != nil {
+		job.Status = models.OpStatusFailed
+		job.Error = fmt.Sprintf("job did not finish within %s", batchJobTimeout)
+	}
+
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		job.Status = models.OpStatusFailed
+		job.Error = fmt.Sprintf("failed to encode results: %v", err)
+	} else {
+		job.Results = encoded
+	}
+
+	// Store the outcome even if the job ran out of time
+	if err := s.jobRepo.Update(context.Background(), &job); err != nil {
+		log.Printf("Failed to finish batch job %d: %v", job.ID, err)
+	}
+	s.refundUsage(context.Background(), req, results)
+}
+
+// run executes the operations on the shared worker pool. Results keep the
+// order of the operations; done is called as each one finishes, or is
+// given up on because ctx is done.
+func (s *batchService) run(ctx context.Context, req BatchRequest, done func(*BatchResult)) []*BatchResult {
+	results := make([]*BatchResult, len(req.Operations))
+
+	var wg sync.WaitGroup
+	for i, op := range req.Operations {
+		if !s.acquire(ctx) {
+			results[i] = &BatchResult{Index: i, Type: op.Type, Error: "batch cancelled before this operation started"}
+			if done != nil {
+				done(results[i])
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, op BatchOperation) {
+			defer wg.Done()
+			defer func() { <-s.slots }()
+
+			results[i] = s.runOperation(ctx, req, i, op)
+			if done != nil {
+				done(results[i])
+			}
+		}(i, op)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// acquire waits for a worker of the pool, giving up when ctx is done
+func (s *batchService) acquire(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runOperation records and runs one operation. Its usage was reserved with
+// the batch and is refunded if it fails.
+func (s *batchService) runOperation(ctx context.Context, req BatchRequest, index int, op BatchOperation) *BatchResult {
+	result := &BatchResult{Index: index, Type: op.Type}
+
+	operation := &models.Operation{
+		UserID:    req.UserID,
+		Type:      op.Type,
+		Command:   op.Command,
+		Status:    models.OpStatusRunning,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	}
+	if err := s.operationRepo.Create(ctx, operation); err != nil {
+		log.Printf("Failed to record batch operation: %v", err)
+	}
+	result.OperationID = operation.ID
+
+	output, err := safeRun(ctx, op.Run)
+	if err != nil {
+		result.Error = err.Error()
+		operation.Status = models.OpStatusFailed
+		operation.Error = err.Error()
+	} else {
+		result.Success = true
+		result.Result = output
+		operation.Status = models.OpStatusCompleted
+		operation.Output = op.Message
+	}
+	operation.Duration = int(time.Since(operation.CreatedAt).Milliseconds())
+
+	if operation.ID != 0 {
+		if err := s.operationRepo.Update(ctx, operation); err != nil {
+			log.Printf("Failed to finish batch operation %d: %v", operation.ID, err)
+		}
+	}
+	return result
+}
+
+// safeRun keeps a panicking operation from taking the rest of the batch,
+// or the server, down with it.
+func safeRun(ctx context.Context, run func(context.Context) (interface{}, error)) (output interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("operation failed: %v", r)
+		}
+	}()
+	return run(ctx)
+}
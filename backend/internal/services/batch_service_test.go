@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+)
+
+type memoryOperationRepo struct {
+	repository.OperationRepository
+
+	mu         sync.Mutex
+	operations []*models.Operation
+}
+
+func (r *memoryOperationRepo) Create(ctx context.Context, operation *models.Operation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	operation.ID = uint(len(r.operations) + 1)
+	operation.CreatedAt = time.Now()
+	copied := *operation
+	r.operations = append(r.operations, &copied)
+	return nil
+}
+
+func (r *memoryOperationRepo) Update(ctx context.Context, operation *models.Operation) error {
+	return nil
+}
+
+func TestBatchReportsEveryOperationWhenCancelled(t *testing.T) {
+	s := NewBatchService(nil, &memoryOperationRepo{}, nil, 1, 1).(*batchService)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The first operation holds the only worker until the batch times out,
+	// so the others never start
+	blocking := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	req := BatchRequest{UserID: 1, Operations: []BatchOperation{
+		{Type: "hash", Run: blocking},
+		{Type: "hash", Run: blocking},
+		{Type: "hash", Run: blocking},
+	}}
+
+	var mu sync.Mutex
+	var reported []int
+	results := s.run(ctx, req, func(result *BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, result.Index)
+	})
+
+	if len(reported) != len(req.Operations) {
+		t.Fatalf("done was called for %v, want every one of %d operations", reported, len(req.Operations))
+	}
+	for i, result := range results {
+		if result == nil || result.Success || result.Error == "" {
+			t.Errorf("result %d = %+v, want a failure", i, result)
+		}
+	}
+	if results[1].OperationID != 0 || results[2].OperationID != 0 {
+		t.Error("operations that never started were recorded")
+	}
+}
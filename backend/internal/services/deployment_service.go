@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/deploy"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidDeploymentTarget = errors.New("invalid deployment target")
+	ErrNoCertificateToDeploy   = errors.New("no certificate to deploy")
+)
+
+// deploymentService implements DeploymentService interface
+// Single Responsibility: Handles deployment targets and certificate delivery
+type deploymentService struct {
+	targetRepo     repository.DeploymentTargetRepository
+	deploymentRepo repository.DeploymentRepository
+	certRepo       repository.CertificateRepository
+	vault          KeyVaultService
+	deployer       *deploy.Service
+}
+
+// NewDeploymentService creates a new deployment service
+func NewDeploymentService(
+	targetRepo repository.DeploymentTargetRepository,
+	deploymentRepo repository.DeploymentRepository,
+	certRepo repository.CertificateRepository,
+	vault KeyVaultService,
+	deployer *deploy.Service,
+) DeploymentService {
+	return &deploymentService{
+		targetRepo:     targetRepo,
+		deploymentRepo: deploymentRepo,
+		certRepo:       certRepo,
+		vault:          vault,
+		deployer:       deployer,
+	}
+}
+
+func (s *deploymentService) CreateTarget(ctx context.Context, scope repository.Scope, req CreateDeploymentTargetRequest) (*models.DeploymentTarget, error) {
+	target := &models.DeploymentTarget{
+		UserID:         scope.UserID,
+		OrganizationID: scope.OrganizationID,
+		Name:           strings.TrimSpace(req.Name),
+		Type:           req.Type,
+		Config:         req.Config,
+	}
+	if target.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidDeploymentTarget)
+	}
+	if len(target.Config) == 0 {
+		target.Config = json.RawMessage("{}")
+	}
+	if err := s.deployer.Validate(target.Type, target.Config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDeploymentTarget, err)
+	}
+	if s.deployer.Privileged(target.Type, target.Config) && !req.PlatformAdmin {
+		return nil, fmt.Errorf("setting a post-hook command %w", ErrPlatformAdminOnly)
+	}
+
+	if req.CertificateID != nil {
+		cert, err := s.certificate(ctx, scope, *req.CertificateID)
+		if err != nil {
+			return nil, err
+		}
+		target.CertificateID = &cert.ID
+	}
+
+	var err error
+	if target.ID, err = s.vault.ReserveID(ctx, models.TableDeploymentTargets); err != nil {
+		return nil, err
+	}
+	if target.Secret, err = s.vault.Seal(models.TableDeploymentTargets, target.ID, req.Secret); err != nil {
+		return nil, err
+	}
+
+	if err := s.targetRepo.Create(ctx, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (s *deploymentService) GetTarget(ctx context.Context, scope repository.Scope, id uint) (*models.DeploymentTarget, error) {
+	return s.targetRepo.GetByID(ctx, scope, id)
+}
+
+func (s *deploymentService) ListTargets(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.DeploymentTarget, int64, error) {
+	return s.targetRepo.List(ctx, scope, offset, limit)
+}
+
+func (s *deploymentService) UpdateTarget(ctx context.Context, scope repository.Scope, id uint, req UpdateDeploymentTargetRequest) (*models.DeploymentTarget, error) {
+	target, err := s.targetRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		target.Name = strings.TrimSpace(*req.Name)
+		if target.Name == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidDeploymentTarget)
+		}
+	}
+	if len(req.Config) > 0 {
+		if err := s.deployer.Validate(target.Type, req.Config); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDeploymentTarget, err)
+		}
+		if s.deployer.Privileged(target.Type, req.Config) && !req.PlatformAdmin {
+			return nil, fmt.Errorf("setting a post-hook command %w", ErrPlatformAdminOnly)
+		}
+		target.Config = req.Config
+	}
+	if req.Secret != nil {
+		if target.Secret, err = s.vault.Seal(models.TableDeploymentTargets, target.ID, *req.Secret); err != nil {
+			return nil, err
+		}
+	}
+	if req.CertificateID != nil {
+		target.CertificateID = nil
+		target.Certificate = nil
+		if *req.CertificateID != 0 {
+			cert, err := s.certificate(ctx, scope, *req.CertificateID)
+			if err != nil {
+				return nil, err
+			}
+			target.CertificateID = &cert.ID
+			target.Certificate = cert
+		}
+	}
+
+	if err := s.targetRepo.Update(ctx, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (s *deploymentService) DeleteTarget(ctx context.Context, scope repository.Scope, id uint) error {
+	return s.targetRepo.Delete(ctx, scope, id)
+}
+
+func (s *deploymentService) Deploy(ctx context.Context, scope repository.Scope, targetID uint, certificateID *uint) (*models.Deployment, error) {
+	target, err := s.targetRepo.GetByID(ctx, scope, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if certificateID == nil {
+		certificateID = target.CertificateID
+	}
+	if certificateID == nil {
+		return nil, fmt.Errorf("%w: the target is not bound to a certificate", ErrNoCertificateToDeploy)
+	}
+	cert, err := s.certificate(ctx, scope, *certificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.deploy(ctx, target, cert, models.DeployTriggerManual)
+}
+
+func (s *deploymentService) ListDeployments(ctx context.Context, scope repository.Scope, targetID uint, offset, limit int) ([]*models.Deployment, int64, error) {
+	if _, err := s.targetRepo.GetByID(ctx, scope, targetID); err != nil {
+		return nil, 0, err
+	}
+	return s.deploymentRepo.ListByTarget(ctx, targetID, offset, limit)
+}
+
+func (s *deploymentService) TargetTypes() []string {
+	return s.deployer.Types()
+}
+
+// CertificateRenewed is a RenewalHook moving the targets bound to the
+// previous certificate on to its renewal and deploying it to each of them.
+func (s *deploymentService) CertificateRenewed(ctx context.Context, previous, current *models.Certificate) {
+	targets, err := s.targetRepo.ListByCertificate(ctx, previous.ID)
+	if err != nil {
+		log.Printf("Failed to list deployment targets of certificate %d: %v", previous.ID, err)
+		return
+	}
+
+	for _, target := range targets {
+		target.CertificateID = &current.ID
+		if err := s.targetRepo.Update(ctx, target); err != nil {
+			log.Printf("Failed to rebind deployment target %d: %v", target.ID, err)
+			continue
+		}
+
+		// Reload through the target's scope for the key pair
+		scope := repository.Scope{UserID: target.UserID, OrganizationID: target.OrganizationID}
+		cert, err := s.certRepo.GetByID(ctx, scope, current.ID)
+		if err != nil {
+			log.Printf("Failed to load certificate %d for deployment target %d: %v", current.ID, target.ID, err)
+			continue
+		}
+		if _, err := s.deploy(ctx, target, cert, models.DeployTriggerRenewal); err != nil {
+			log.Printf("Failed to record deployment to target %d: %v", target.ID, err)
+		}
+	}
+}
+
+// Helper functions
+
+// deploy pushes cert to target and records the attempt. A failed delivery
+// is recorded on the deployment rather than returned as an error.
+func (s *deploymentService) deploy(ctx context.Context, target *models.DeploymentTarget, cert *models.Certificate, trigger string) (*models.Deployment, error) {
+	started := time.Now()
+	var result *deploy.Result
+	bundle, err := s.bundle(cert)
+	var secret string
+	if err == nil {
+		secret, err = s.vault.Open(models.TableDeploymentTargets, target.ID, target.Secret)
+	}
+	if err == nil {
+		bundle.Tenant = deploymentTenant(target)
+		result, err = s.deployer.Deploy(ctx, target.Type, target.Config, secret, bundle)
+	}
+	now := time.Now()
+
+	deployment := &models.Deployment{
+		TargetID:      target.ID,
+		CertificateID: cert.ID,
+		Trigger:       trigger,
+		Success:       err == nil,
+		DurationMs:    now.Sub(started).Milliseconds(),
+	}
+	if result != nil {
+		deployment.Output = result.Output
+		deployment.Artifact = result.Artifact
+	}
+	if err != nil {
+		deployment.Error = err.Error()
+	}
+
+	if err := s.deploymentRepo.Create(ctx, deployment); err != nil {
+		return nil, err
+	}
+
+	target.LastDeployedAt = &now
+	target.LastSuccess = &deployment.Success
+	target.LastError = deployment.Error
+	if err := s.targetRepo.Update(ctx, target); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// bundle collects the material to deploy. The private key is only
+// included when its key pair has been marked exportable.
+func (s *deploymentService) bundle(cert *models.Certificate) (*deploy.Bundle, error) {
+	leaf, _, err := parseCertificateBundle(cert.PEM)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &deploy.Bundle{
+		CertificateID: cert.ID,
+		Name:          cert.Name,
+		CommonName:    cert.CommonName,
+		SANs:          subjectAltNames(leaf),
+		Fingerprint:   cert.Fingerprint,
+		NotBefore:     cert.NotBefore,
+		NotAfter:      cert.NotAfter,
+		Certificate:   cert.PEM,
+		Chain:         cert.Chain,
+	}
+	if cert.KeyPair != nil && cert.KeyPair.Exportable {
+		bundle.PrivateKey, err = s.vault.Open(models.TableKeyPairs, cert.KeyPair.ID, cert.KeyPair.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unlock private key: %w", err)
+		}
+	}
+	return bundle, nil
+}
+
+// deploymentTenant names the owner of a target, so that directory targets
+// of different users and organizations never share files.
+func deploymentTenant(target *models.DeploymentTarget) string {
+	if target.OrganizationID != nil {
+		return fmt.Sprintf("org-%d", *target.OrganizationID)
+	}
+	return fmt.Sprintf("user-%d", target.UserID)
+}
+
+func (s *deploymentService) certificate(ctx context.Context, scope repository.Scope, id uint) (*models.Certificate, error) {
+	cert, err := s.certRepo.GetByID(ctx, scope, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: certificate %d not found", ErrNoCertificateToDeploy, id)
+	}
+	return cert, err
+}
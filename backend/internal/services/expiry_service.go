@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/notify"
+)
+
+const (
+	// expiredLookback bounds how long after expiry an item is still picked
+	// up, so an expired notice is sent even if the scheduler was down
+	expiredLookback = 7 * 24 * time.Hour
+
+	expiryBatchSize = 200
+)
+
+// expiryService implements ExpiryService interface
+// Single Responsibility: Handles expiry threshold detection and notification
+type expiryService struct {
+	certRepo         repository.CertificateRepository
+	notificationRepo repository.ExpiryNotificationRepository
+	userRepo         repository.UserRepository
+	notifier         *notify.Service
+	thresholds       []int
+	sources          []ExpirySource
+}
+
+// NewExpiryService creates a new expiry service. Thresholds are in days; a
+// notice is sent when an item crosses each of them and once it has expired.
+func NewExpiryService(
+	certRepo repository.CertificateRepository,
+	notificationRepo repository.ExpiryNotificationRepository,
+	userRepo repository.UserRepository,
+	notifier *notify.Service,
+	thresholds []int,
+) ExpiryService {
+	sorted := make([]int, 0, len(thresholds))
+	for _, days := range thresholds {
+		if days > 0 {
+			sorted = append(sorted, days)
+		}
+	}
+	sort.Ints(sorted)
+
+	s := &expiryService{
+		certRepo:         certRepo,
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		notifier:         notifier,
+		thresholds:       sorted,
+	}
+	s.sources = []ExpirySource{s.expiringCertificates}
+	return s
+}
+
+func (s *expiryService) AddSource(source ExpirySource) {
+	s.sources = append(s.sources, source)
+}
+
+// CheckExpiring announces every item that crossed a threshold since the
+// last run. Only the tightest threshold crossed is sent, so an item first
+// seen at five days left gets the 7-day notice, not the 30- and 14-day ones.
+func (s *expiryService) CheckExpiring(ctx context.Context) (*ExpiryCheckResult, error) {
+	result := &ExpiryCheckResult{}
+	if len(s.thresholds) == 0 {
+		return result, nil
+	}
+
+	now := time.Now()
+	from := now.Add(-expiredLookback)
+	to := now.AddDate(0, 0, s.thresholds[len(s.thresholds)-1])
+
+	emails := make(map[uint]string)
+	for _, source := range s.sources {
+		items, err := source(ctx, from, to)
+		if err != nil {
+			return result, err
+		}
+
+		for _, item := range items {
+			result.Checked++
+
+			threshold, ok := s.crossedThreshold(item.NotAfter, now)
+			if !ok {
+				continue
+			}
+
+			sent, err := s.notificationRepo.Exists(ctx, item.SubjectType, item.SubjectID, item.NotAfter, threshold)
+			if err != nil {
+				return result, err
+			}
+			if sent {
+				continue
+			}
+
+			if err := s.announce(ctx, item, threshold, now, emails); err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s %d: %v", item.SubjectType, item.SubjectID, err))
+				continue
+			}
+			result.Sent++
+		}
+	}
+
+	return result, nil
+}
+
+func (s *expiryService) GetNotifications(ctx context.Context, userID uint, offset, limit int) ([]*models.ExpiryNotification, error) {
+	return s.notificationRepo.GetByUserID(ctx, userID, offset, limit)
+}
+
+// Helper functions
+
+func (s *expiryService) expiringCertificates(ctx context.Context, from, to time.Time) ([]ExpiringItem, error) {
+	var items []ExpiringItem
+	var afterID uint
+	for {
+		certs, err := s.certRepo.ListExpiring(ctx, from, to, afterID, expiryBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) == 0 {
+			return items, nil
+		}
+
+		for _, cert := range certs {
+			afterID = cert.ID
+			items = append(items, ExpiringItem{
+				SubjectType: models.ExpirySubjectCertificate,
+				SubjectID:   cert.ID,
+				UserID:      cert.UserID,
+				Name:        cert.Name,
+				NotAfter:    cert.NotAfter,
+				Fields: map[string]string{
+					"Certificate ID": fmt.Sprint(cert.ID),
+					"Common name":    cert.CommonName,
+					"Issuer":         cert.Issuer,
+					"Serial number":  cert.SerialNumber,
+				},
+			})
+		}
+	}
+}
+
+// crossedThreshold returns the smallest threshold the remaining validity is
+// within, or 0 once the item has expired.
+func (s *expiryService) crossedThreshold(notAfter, now time.Time) (int, bool) {
+	remaining := notAfter.Sub(now)
+	if remaining <= 0 {
+		return 0, true
+	}
+	for _, days := range s.thresholds {
+		if remaining <= time.Duration(days)*24*time.Hour {
+			return days, true
+		}
+	}
+	return 0, false
+}
+
+func (s *expiryService) announce(ctx context.Context, item ExpiringItem, threshold int, now time.Time, emails map[uint]string) error {
+	email, ok := emails[item.UserID]
+	if !ok {
+		if user, err := s.userRepo.GetByID(ctx, item.UserID); err == nil {
+			email = user.Email
+		}
+		emails[item.UserID] = email
+	}
+
+	notification := expiryNotification(item, threshold, now)
+	if email != "" {
+		notification.Recipients = []string{email}
+	}
+
+	delivered, err := s.notifier.Send(ctx, notification)
+	if len(delivered) == 0 {
+		// Nothing went out; leave it unrecorded so the next run retries
+		return err
+	}
+
+	record := &models.ExpiryNotification{
+		UserID:        item.UserID,
+		SubjectType:   item.SubjectType,
+		SubjectID:     item.SubjectID,
+		NotAfter:      item.NotAfter,
+		ThresholdDays: threshold,
+		Channels:      strings.Join(delivered, ","),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return s.notificationRepo.Create(ctx, record)
+}
+
+func expiryNotification(item ExpiringItem, threshold int, now time.Time) *notify.Notification {
+	fields := map[string]string{
+		"Expires": item.NotAfter.UTC().Format(time.RFC3339),
+	}
+	for name, value := range item.Fields {
+		if value != "" {
+			fields[name] = value
+		}
+	}
+
+	notification := &notify.Notification{
+		Event:    "certificate.expiring",
+		Severity: notify.SeverityInfo,
+		Fields:   fields,
+		Time:     now.UTC(),
+	}
+
+	days := int(math.Ceil(item.NotAfter.Sub(now).Hours() / 24))
+	switch {
+	case threshold == 0:
+		notification.Event = "certificate.expired"
+		notification.Severity = notify.SeverityCritical
+		notification.Subject = fmt.Sprintf("%s %s has expired", item.SubjectType, item.Name)
+		notification.Text = fmt.Sprintf("The certificate for %s %s expired on %s.", item.SubjectType, item.Name, item.NotAfter.UTC().Format("2006-01-02 15:04 MST"))
+	default:
+		if threshold <= 1 {
+			notification.Severity = notify.SeverityCritical
+		} else if threshold <= 7 {
+			notification.Severity = notify.SeverityWarning
+		}
+		notification.Subject = fmt.Sprintf("%s %s expires in %s", item.SubjectType, item.Name, pluralDays(days))
+		notification.Text = fmt.Sprintf("The certificate for %s %s expires on %s, in %s. Renew it before then to avoid an outage.",
+			item.SubjectType, item.Name, item.NotAfter.UTC().Format("2006-01-02 15:04 MST"), pluralDays(days))
+		notification.Fields["Threshold"] = pluralDays(threshold)
+	}
+
+	return notification
+}
+
+func pluralDays(days int) string {
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/notify"
+)
+
+// expiringCertRepo serves ListExpiring from a fixed set of certificates
+type expiringCertRepo struct {
+	repository.CertificateRepository
+	certs []*models.Certificate
+}
+
+func (r *expiringCertRepo) ListExpiring(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]*models.Certificate, error) {
+	var certs []*models.Certificate
+	for _, cert := range r.certs {
+		if cert.ID > afterID && !cert.NotAfter.Before(from) && cert.NotAfter.Before(to) && len(certs) < limit {
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+type memoryExpiryNotificationRepo struct {
+	mu      sync.Mutex
+	records []*models.ExpiryNotification
+}
+
+func (r *memoryExpiryNotificationRepo) Create(ctx context.Context, notification *models.ExpiryNotification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notification.ID = uint(len(r.records) + 1)
+	r.records = append(r.records, notification)
+	return nil
+}
+
+func (r *memoryExpiryNotificationRepo) Exists(ctx context.Context, subjectType string, subjectID uint, notAfter time.Time, thresholdDays int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, record := range r.records {
+		if record.SubjectType == subjectType && record.SubjectID == subjectID && record.NotAfter.Equal(notAfter) && record.ThresholdDays == thresholdDays {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryExpiryNotificationRepo) GetByUserID(ctx context.Context, userID uint, offset, limit int) ([]*models.ExpiryNotification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records []*models.ExpiryNotification
+	for _, record := range r.records {
+		if record.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func newTestExpiryService(certs ...*models.Certificate) (ExpiryService, *memoryExpiryNotificationRepo, *notify.FakeSender) {
+	notifications := &memoryExpiryNotificationRepo{}
+	users := &memoryUserRepo{users: map[uint]*models.User{1: {ID: 1, Email: "owner@example.com"}}}
+
+	sender := notify.NewFakeSender()
+	notifier := notify.NewService(notify.Config{})
+	notifier.SetSenders(sender)
+
+	s := NewExpiryService(&expiringCertRepo{certs: certs}, notifications, users, notifier, []int{30, 14, 7})
+	return s, notifications, sender
+}
+
+func TestCheckExpiringSendsTightestThresholdOnce(t *testing.T) {
+	notAfter := time.Now().Add(5*24*time.Hour + time.Hour).Truncate(time.Second)
+	s, notifications, sender := newTestExpiryService(&models.Certificate{ID: 3, UserID: 1, Name: "web", CommonName: "www.example.com", NotAfter: notAfter})
+	ctx := context.Background()
+
+	result, err := s.CheckExpiring(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 1 || result.Sent != 1 || result.Failed != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	sent := sender.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(sent))
+	}
+	notification := sent[0]
+	if notification.Event != "certificate.expiring" || notification.Severity != notify.SeverityWarning {
+		t.Errorf("event %s, severity %s", notification.Event, notification.Severity)
+	}
+	if notification.Fields["Threshold"] != "7 days" || notification.Fields["Common name"] != "www.example.com" {
+		t.Errorf("fields = %v", notification.Fields)
+	}
+	if len(notification.Recipients) != 1 || notification.Recipients[0] != "owner@example.com" {
+		t.Errorf("recipients = %v", notification.Recipients)
+	}
+	if len(notifications.records) != 1 || notifications.records[0].ThresholdDays != 7 || notifications.records[0].Channels != "fake" {
+		t.Fatalf("records = %+v", notifications.records)
+	}
+
+	// The same threshold is never announced twice
+	result, err = s.CheckExpiring(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 0 || len(sender.Sent()) != 1 {
+		t.Errorf("second run sent %d notifications", result.Sent)
+	}
+}
+
+func TestCheckExpiringAnnouncesExpiry(t *testing.T) {
+	s, notifications, sender := newTestExpiryService(&models.Certificate{ID: 4, UserID: 2, Name: "old", NotAfter: time.Now().Add(-time.Hour)})
+
+	if _, err := s.CheckExpiring(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := sender.Sent()
+	if len(sent) != 1 || sent[0].Event != "certificate.expired" || sent[0].Severity != notify.SeverityCritical {
+		t.Fatalf("sent = %+v", sent)
+	}
+	// The owner has no address on record, so only the channel recipients get it
+	if len(sent[0].Recipients) != 0 {
+		t.Errorf("recipients = %v", sent[0].Recipients)
+	}
+	if len(notifications.records) != 1 || notifications.records[0].ThresholdDays != 0 {
+		t.Errorf("records = %+v", notifications.records)
+	}
+}
+
+func TestCheckExpiringRetriesUndeliveredNotices(t *testing.T) {
+	s, notifications, sender := newTestExpiryService(&models.Certificate{ID: 5, UserID: 1, Name: "api", NotAfter: time.Now().Add(20 * 24 * time.Hour)})
+	sender.Err = errors.New("connection refused")
+	ctx := context.Background()
+
+	result, err := s.CheckExpiring(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 0 || result.Failed != 1 || len(result.Errors) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(notifications.records) != 0 {
+		t.Fatalf("undelivered notice was recorded: %+v", notifications.records)
+	}
+
+	sender.Err = nil
+	result, err = s.CheckExpiring(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 1 || len(sender.Sent()) != 1 || sender.Sent()[0].Fields["Threshold"] != "30 days" {
+		t.Errorf("retry result = %+v, sent = %+v", result, sender.Sent())
+	}
+}
+
+func TestCheckExpiringRecordsPartialDelivery(t *testing.T) {
+	s, notifications, delivered := newTestExpiryService(&models.Certificate{ID: 6, UserID: 1, Name: "mail", NotAfter: time.Now().Add(10 * 24 * time.Hour)})
+	failing := notify.NewFakeSender()
+	failing.Err = errors.New("webhook returned status 500")
+	s.(*expiryService).notifier.SetSenders(delivered, failing)
+
+	result, err := s.CheckExpiring(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 1 || len(delivered.Sent()) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(notifications.records) != 1 || notifications.records[0].Error == "" {
+		t.Errorf("failure on the second channel was not recorded: %+v", notifications.records)
+	}
+}
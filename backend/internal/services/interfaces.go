@@ -2,7 +2,13 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/openssl"
+	"web-openssl-backend/pkg/webauthn"
 )
 
 // UserService defines business logic operations for users
@@ -13,7 +19,6 @@ type UserService interface {
 	GetProfile(ctx context.Context, userID uint) (*models.User, error)
 	UpdateProfile(ctx context.Context, userID uint, req UpdateProfileRequest) error
 	DeleteAccount(ctx context.Context, userID uint) error
-	GenerateAPIKey(ctx context.Context, userID uint) (string, error)
 }
 
 // OperationService defines business logic for operations
@@ -89,4 +94,565 @@ type CertificateInfo struct {
 	NotAfter   string
 	SerialNum  string
 	PublicKey  string
-}
\ No newline at end of file
+}
+
+// InventoryService defines business logic for the certificate and key inventory
+type InventoryService interface {
+	ResolveScope(ctx context.Context, userID uint, organizationID *uint) (repository.Scope, error)
+	ImportCertificate(ctx context.Context, scope repository.Scope, req ImportCertificateRequest) (*models.Certificate, error)
+	ImportKeyPair(ctx context.Context, scope repository.Scope, req ImportKeyPairRequest) (*models.KeyPair, error)
+	GetCertificate(ctx context.Context, scope repository.Scope, id uint) (*models.Certificate, error)
+	SearchCertificates(ctx context.Context, filter repository.CertificateFilter) ([]*models.Certificate, int64, error)
+	DeleteCertificate(ctx context.Context, scope repository.Scope, id uint) error
+	GetKeyPair(ctx context.Context, scope repository.Scope, id uint) (*models.KeyPair, error)
+	ListKeyPairs(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.KeyPair, int64, error)
+	ExportKeyPair(ctx context.Context, scope repository.Scope, id uint) (string, error)
+	SetKeyPairExportable(ctx context.Context, scope repository.Scope, id uint, exportable bool) (*models.KeyPair, error)
+	DeleteKeyPair(ctx context.Context, scope repository.Scope, id uint) error
+}
+
+// ImportCertificateRequest carries a PEM certificate, optionally followed by
+// its chain, and an optional PEM private key
+type ImportCertificateRequest struct {
+	Name          string
+	Source        models.CertificateSource
+	Certificate   string
+	PrivateKey    string
+	Exportable    bool
+	ACMEAccountID *uint
+}
+
+// ImportKeyPairRequest carries a PEM private key. PublicKey is required when
+// the private key is encrypted
+type ImportKeyPairRequest struct {
+	Name       string
+	Source     models.CertificateSource
+	PrivateKey string
+	PublicKey  string
+	Exportable bool
+}
+
+// KeyVaultService defines business logic for encrypting private keys at rest
+type KeyVaultService interface {
+	// Seal encrypts privateKey for the record id of table; it only opens
+	// for the same record
+	Seal(table string, id uint, privateKey string) (models.SealedKey, error)
+	Open(table string, id uint, sealed models.SealedKey) (string, error)
+	// ReserveID takes the next ID of table, for a record whose key has to be
+	// sealed before the record is created
+	ReserveID(ctx context.Context, table string) (uint, error)
+	Status(ctx context.Context) (*VaultStatus, error)
+	RotateMasterKey(ctx context.Context) (string, error)
+	RewrapAll(ctx context.Context) (*RewrapResult, error)
+}
+
+type VaultStatus struct {
+	CurrentMasterKeyID string           `json:"currentMasterKeyId"`
+	RecordsByMasterKey map[string]int64 `json:"recordsByMasterKey"`
+}
+
+type RewrapResult struct {
+	MasterKeyID string   `json:"masterKeyId"`
+	Rewrapped   int      `json:"rewrapped"`
+	Failed      int      `json:"failed"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// ExpiryService defines business logic for announcing upcoming certificate expiry
+type ExpiryService interface {
+	AddSource(source ExpirySource)
+	CheckExpiring(ctx context.Context) (*ExpiryCheckResult, error)
+	GetNotifications(ctx context.Context, userID uint, offset, limit int) ([]*models.ExpiryNotification, error)
+}
+
+// ExpiringItem is anything carrying a certificate whose expiry is announced
+type ExpiringItem struct {
+	SubjectType string
+	SubjectID   uint
+	UserID      uint
+	Name        string
+	NotAfter    time.Time
+	Fields      map[string]string
+}
+
+// ExpirySource lists the items whose certificates expire in [from, to)
+type ExpirySource func(ctx context.Context, from, to time.Time) ([]ExpiringItem, error)
+
+type ExpiryCheckResult struct {
+	Checked int      `json:"checked"`
+	Sent    int      `json:"sent"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// MonitoringService defines business logic for scheduled TLS endpoint scans
+type MonitoringService interface {
+	CreateEndpoint(ctx context.Context, scope repository.Scope, req CreateEndpointRequest) (*models.MonitoredEndpoint, error)
+	GetEndpoint(ctx context.Context, scope repository.Scope, id uint) (*models.MonitoredEndpoint, error)
+	ListEndpoints(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.MonitoredEndpoint, int64, error)
+	UpdateEndpoint(ctx context.Context, scope repository.Scope, id uint, req UpdateEndpointRequest) (*models.MonitoredEndpoint, error)
+	DeleteEndpoint(ctx context.Context, scope repository.Scope, id uint) error
+	ScanEndpoint(ctx context.Context, scope repository.Scope, id uint) (*models.EndpointScan, error)
+	ScanDue(ctx context.Context) (*ScanDueResult, error)
+	GetScan(ctx context.Context, scope repository.Scope, endpointID, scanID uint) (*models.EndpointScan, error)
+	ListScans(ctx context.Context, scope repository.Scope, endpointID uint, offset, limit int) ([]*models.EndpointScan, int64, error)
+	GetGradeTrend(ctx context.Context, scope repository.Scope, endpointID uint, since time.Time) ([]GradePoint, error)
+	ExpiringEndpoints(ctx context.Context, from, to time.Time) ([]ExpiringItem, error)
+}
+
+type CreateEndpointRequest struct {
+	Name            string
+	Hostname        string
+	Port            int
+	IntervalMinutes int
+}
+
+type UpdateEndpointRequest struct {
+	Name            *string
+	IntervalMinutes *int
+	Paused          *bool
+}
+
+type ScanDueResult struct {
+	Scanned int `json:"scanned"`
+	Failed  int `json:"failed"`
+	Alerts  int `json:"alerts"`
+}
+
+// GradePoint is one scan on the grade trend; Score maps the grade onto a
+// numeric scale where higher is better, for charting.
+type GradePoint struct {
+	Time   time.Time `json:"time"`
+	ScanID uint      `json:"scanId"`
+	Grade  string    `json:"grade"`
+	Score  int       `json:"score"`
+}
+
+// RenewalService defines business logic for automatic certificate renewal
+type RenewalService interface {
+	CreatePolicy(ctx context.Context, scope repository.Scope, req CreateRenewalPolicyRequest) (*models.RenewalPolicy, error)
+	GetPolicy(ctx context.Context, scope repository.Scope, id uint) (*models.RenewalPolicy, error)
+	ListPolicies(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.RenewalPolicy, int64, error)
+	UpdatePolicy(ctx context.Context, scope repository.Scope, id uint, req UpdateRenewalPolicyRequest) (*models.RenewalPolicy, error)
+	DeletePolicy(ctx context.Context, scope repository.Scope, id uint) error
+	RenewNow(ctx context.Context, scope repository.Scope, id uint) (*models.CertificateRenewal, error)
+	RenewDue(ctx context.Context) (*RenewDueResult, error)
+	ListRenewals(ctx context.Context, scope repository.Scope, policyID uint, offset, limit int) ([]*models.CertificateRenewal, int64, error)
+	OnRenewed(hook RenewalHook)
+}
+
+// RenewalHook is called after a certificate has been replaced by its renewal
+type RenewalHook func(ctx context.Context, previous, current *models.Certificate)
+
+type CreateRenewalPolicyRequest struct {
+	CertificateID    uint
+	DaysBeforeExpiry int
+	KeyStrategy      string
+	ChallengeType    string
+	WebhookURL       string
+	WebhookSecret    string
+}
+
+type UpdateRenewalPolicyRequest struct {
+	DaysBeforeExpiry *int
+	KeyStrategy      *string
+	ChallengeType    *string
+	WebhookURL       *string
+	WebhookSecret    *string
+	Paused           *bool
+}
+
+type RenewDueResult struct {
+	Renewed int `json:"renewed"`
+	Failed  int `json:"failed"`
+}
+
+// DeploymentService defines business logic for pushing certificates to deployment targets
+type DeploymentService interface {
+	CreateTarget(ctx context.Context, scope repository.Scope, req CreateDeploymentTargetRequest) (*models.DeploymentTarget, error)
+	GetTarget(ctx context.Context, scope repository.Scope, id uint) (*models.DeploymentTarget, error)
+	ListTargets(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.DeploymentTarget, int64, error)
+	UpdateTarget(ctx context.Context, scope repository.Scope, id uint, req UpdateDeploymentTargetRequest) (*models.DeploymentTarget, error)
+	DeleteTarget(ctx context.Context, scope repository.Scope, id uint) error
+	// Deploy pushes a certificate to a target, by default the one bound to it
+	Deploy(ctx context.Context, scope repository.Scope, targetID uint, certificateID *uint) (*models.Deployment, error)
+	ListDeployments(ctx context.Context, scope repository.Scope, targetID uint, offset, limit int) ([]*models.Deployment, int64, error)
+	TargetTypes() []string
+	CertificateRenewed(ctx context.Context, previous, current *models.Certificate)
+}
+
+type CreateDeploymentTargetRequest struct {
+	Name          string
+	Type          string
+	Config        json.RawMessage
+	Secret        string
+	CertificateID *uint
+	// PlatformAdmin is set for platform administrators, who alone may save
+	// configurations that run commands on the server
+	PlatformAdmin bool
+}
+
+// UpdateDeploymentTargetRequest changes the fields that are set. A
+// CertificateID of 0 unbinds the target from its certificate.
+type UpdateDeploymentTargetRequest struct {
+	Name          *string
+	Config        json.RawMessage
+	Secret        *string
+	CertificateID *uint
+	PlatformAdmin bool
+}
+
+// ProfileService defines certificate profile operations
+type ProfileService interface {
+	ListProfiles(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.CertificateProfile, int64, error)
+	GetProfile(ctx context.Context, userID, id uint) (*models.CertificateProfile, error)
+	CreateProfile(ctx context.Context, actor ProfileActor, req CreateCertificateProfileRequest) (*models.CertificateProfile, error)
+	UpdateProfile(ctx context.Context, actor ProfileActor, id uint, req UpdateCertificateProfileRequest) (*models.CertificateProfile, error)
+	DeleteProfile(ctx context.Context, actor ProfileActor, id uint) error
+	// ApplyProfile fills in the fields a request leaves unset and rejects
+	// the ones that break the profile's constraints
+	ApplyProfile(ctx context.Context, userID, id uint, req *openssl.GenerateCertificateRequest) (*models.CertificateProfile, error)
+	// SeedDefaults creates the built-in global profiles that are missing
+	SeedDefaults(ctx context.Context) error
+}
+
+// ProfileActor is the user managing a profile. Platform administrators
+// manage the global profiles, organization admins their organization's.
+type ProfileActor struct {
+	UserID        uint
+	PlatformAdmin bool
+}
+
+// CreateCertificateProfileRequest creates a global profile when
+// OrganizationID is nil.
+type CreateCertificateProfileRequest struct {
+	OrganizationID *uint
+	Name           string
+	Description    string
+	KeyType        string
+	KeySize        int
+	KeyUsage       []string
+	ExtKeyUsage    []string
+	HashAlgorithm  string
+	ValidDays      int
+	MaxValidDays   int
+	IsCA           bool
+	LockedFields   []string
+}
+
+// UpdateCertificateProfileRequest changes the fields that are set
+type UpdateCertificateProfileRequest struct {
+	Name          *string
+	Description   *string
+	KeyType       *string
+	KeySize       *int
+	KeyUsage      *[]string
+	ExtKeyUsage   *[]string
+	HashAlgorithm *string
+	ValidDays     *int
+	MaxValidDays  *int
+	IsCA          *bool
+	LockedFields  *[]string
+}
+
+// BatchService defines business logic for running many operations at once
+type BatchService interface {
+	// Run executes the operations with bounded concurrency and waits for them.
+	// Every operation is counted against the user's usage up front, failing
+	// with ErrUsageLimitExceeded when they do not all fit; failed operations
+	// are refunded.
+	Run(ctx context.Context, req BatchRequest) ([]*BatchResult, error)
+	// Submit starts the operations as a background job the client polls,
+	// reserving usage like Run. It fails with ErrTooManyBatchJobs while the
+	// user has as many jobs unfinished as allowed.
+	Submit(ctx context.Context, req BatchRequest) (*models.BatchJob, error)
+	GetJob(ctx context.Context, userID, id uint) (*models.BatchJob, error)
+	ListJobs(ctx context.Context, userID uint, offset, limit int) ([]*models.BatchJob, int64, error)
+	// FailInterrupted marks jobs left unfinished by a previous process as failed
+	FailInterrupted(ctx context.Context) error
+}
+
+// BatchRequest carries the operations of one batch together with the
+// details each item's Operation record is made with.
+type BatchRequest struct {
+	UserID     uint
+	IPAddress  string
+	UserAgent  string
+	Operations []BatchOperation
+}
+
+// BatchOperation is one item of a batch. Type and Command are recorded on
+// its Operation, and Message as the output when it succeeds.
+type BatchOperation struct {
+	Type    string
+	Command string
+	Message string
+	Run     func(ctx context.Context) (interface{}, error)
+}
+
+type BatchResult struct {
+	Index       int         `json:"index"`
+	Type        string      `json:"type"`
+	Success     bool        `json:"success"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	OperationID uint        `json:"operationId,omitempty"`
+}
+
+// AccountService defines account recovery operations
+type AccountService interface {
+	// RequestPasswordReset emails a reset link if email belongs to an
+	// active user. It reports success either way, so that callers cannot
+	// find out which addresses have accounts.
+	RequestPasswordReset(ctx context.Context, email, ipAddress string) error
+	// ResetPassword sets a new password with a reset token and signs the
+	// user out everywhere
+	ResetPassword(ctx context.Context, token, password string) error
+	// CheckToken rejects access tokens issued before the user's sessions
+	// were revoked; it is installed as one of the auth service's TokenChecks
+	CheckToken(claims *auth.Claims) error
+	// SendVerification emails the user a signed link that verifies their
+	// current address
+	SendVerification(ctx context.Context, user *models.User) error
+	// ResendVerification sends a new link to an unverified address. Like
+	// RequestPasswordReset it reports success for unknown addresses.
+	ResendVerification(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) (*models.User, error)
+	// Restricts reports whether unverified users are kept from restriction
+	Restricts(restriction string) bool
+	EmailVerified(ctx context.Context, userID uint) (bool, error)
+	// Check reports an invalid unverified user policy
+	Check() error
+}
+
+// TokenPair holds the tokens a login or refresh hands out
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	User         *models.User
+}
+
+// SessionService defines the interface for login sessions. Refresh tokens
+// are opaque and rotated on every use; a session is the family of refresh
+// tokens descending from one login.
+type SessionService interface {
+	// StartSession issues the tokens of a new session for an authenticated
+	// user; methods are the auth.AuthMethod* values the login used
+	StartSession(ctx context.Context, user *models.User, methods []string, ipAddress, userAgent string) (*TokenPair, error)
+	// Refresh swaps a refresh token for new tokens. Presenting a token that
+	// was already rotated revokes its whole session.
+	Refresh(ctx context.Context, refreshToken, ipAddress, userAgent string) (*TokenPair, error)
+	// Logout ends the session of a refresh token
+	Logout(ctx context.Context, refreshToken string) error
+	// LogoutAll ends every session of the user
+	LogoutAll(ctx context.Context, userID uint) error
+	// ListSessions returns the user's active sessions, marking the one whose
+	// ID is currentSessionID, the session_id of the request's access token
+	ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]*models.Session, error)
+	// RevokeSession ends one session of the user
+	RevokeSession(ctx context.Context, userID, id uint) error
+	// CheckToken rejects access tokens of ended sessions; it is installed
+	// as one of the auth service's TokenChecks
+	CheckToken(claims *auth.Claims) error
+}
+
+// APIKeyService defines the interface for API keys. A key is shown in full
+// only when it is created.
+type APIKeyService interface {
+	CreateKey(ctx context.Context, userID uint, req CreateAPIKeyRequest) (*models.APIKey, string, error)
+	ListKeys(ctx context.Context, userID uint) ([]*models.APIKey, error)
+	RevokeKey(ctx context.Context, userID, id uint) error
+	// Authenticate resolves a key sent with a request to its owner, as long
+	// as the key has not expired and the owner's plan includes API access
+	Authenticate(ctx context.Context, key, ipAddress string) (*models.APIKey, *models.User, error)
+	Scopes() []string
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// MFAService defines the interface for two-factor authentication with TOTP
+// authenticator apps. Wherever a code is asked for, an unused recovery code
+// works as well, except to confirm enrolment.
+type MFAService interface {
+	Status(ctx context.Context, userID uint) (*MFAStatus, error)
+	Enabled(ctx context.Context, userID uint) (bool, error)
+	// Required reports whether one of the user's organizations requires
+	// two-factor authentication
+	Required(ctx context.Context, userID uint) (bool, error)
+	// BeginEnrollment creates a new secret that is not used for logins
+	// until ConfirmEnrollment receives a code generated from it
+	BeginEnrollment(ctx context.Context, userID uint) (*TOTPEnrollment, error)
+	// ConfirmEnrollment enables the factor and returns the recovery codes,
+	// which are shown only once
+	ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error)
+	Disable(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error)
+	// Challenge issues the short-lived token a login with a correct
+	// password gets in place of a session, to be sent with the code
+	Challenge(user *models.User) (string, error)
+	VerifyChallenge(ctx context.Context, challenge, code string) (*models.User, error)
+	// ChallengeUser returns the user a login challenge was issued to, for
+	// second factors other than TOTP
+	ChallengeUser(ctx context.Context, challenge string) (*models.User, error)
+	// SetOrganizationPolicy lets organization admins require two-factor
+	// authentication of all members
+	SetOrganizationPolicy(ctx context.Context, userID, organizationID uint, required bool) (*models.Organization, error)
+}
+
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int64      `json:"recoveryCodesLeft"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI to show as a QR code
+	URI string `json:"uri"`
+}
+
+// WebAuthnService defines the interface for passkeys. A passkey signs in on
+// its own, or completes a password login in place of a TOTP code.
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uint) (*webauthn.CreationOptions, error)
+	FinishRegistration(ctx context.Context, userID uint, name string, credential *webauthn.CredentialCreation) (*models.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID uint) ([]*models.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID, id uint) error
+	HasCredentials(ctx context.Context, userID uint) (bool, error)
+	// BeginLogin starts a login in which the user picks any of their
+	// passkeys for the site
+	BeginLogin(ctx context.Context) (*webauthn.RequestOptions, error)
+	FinishLogin(ctx context.Context, assertion *webauthn.CredentialAssertion) (*models.User, error)
+	// BeginSecondFactor starts the second step of a password login with one
+	// of the user's passkeys
+	BeginSecondFactor(ctx context.Context, user *models.User) (*webauthn.RequestOptions, error)
+	FinishSecondFactor(ctx context.Context, user *models.User, assertion *webauthn.CredentialAssertion) error
+	// Check reports relying party configuration that would make every
+	// ceremony fail
+	Check() error
+}
+
+// SSOConnectionRequest configures an organization's identity provider. An
+// empty ClientSecret keeps the stored one. IdPMetadata, when given, supplies
+// the SAML identity provider's entity ID, address and certificates.
+type SSOConnectionRequest struct {
+	Protocol       string
+	Enabled        bool
+	Issuer         string
+	ClientID       string
+	ClientSecret   string
+	Scopes         string
+	IdPMetadata    string
+	IdPEntityID    string
+	IdPSSOURL      string
+	IdPCertificate string
+	EmailClaim     string
+	FirstNameClaim string
+	LastNameClaim  string
+	GroupsClaim    string
+	GroupRoles     map[string]models.MemberRole
+	DefaultRole    models.MemberRole
+}
+
+// SSOLoginResult is what a completed sign-in at an identity provider leads
+// to: a one-time code the web application swaps for tokens, or an account
+// linked to the identity
+type SSOLoginResult struct {
+	LoginCode string
+	Linked    bool
+}
+
+// SSOService defines the interface for enterprise single sign-on. Members
+// of an organization sign in at its identity provider, by OpenID Connect or
+// SAML; first-time users at the organization's verified domains are created
+// on the spot and join the organization with a role mapped from their groups.
+type SSOService interface {
+	GetConnection(ctx context.Context, userID, organizationID uint) (*models.SSOConnection, error)
+	SaveConnection(ctx context.Context, userID, organizationID uint, req SSOConnectionRequest) (*models.SSOConnection, error)
+	DeleteConnection(ctx context.Context, userID, organizationID uint) error
+	ListDomains(ctx context.Context, userID, organizationID uint) ([]*models.SSODomain, error)
+	AddDomain(ctx context.Context, userID, organizationID uint, domain string) (*models.SSODomain, error)
+	// VerifyDomain looks for the domain's verification TXT record
+	VerifyDomain(ctx context.Context, userID, organizationID, id uint) (*models.SSODomain, error)
+	DeleteDomain(ctx context.Context, userID, organizationID, id uint) error
+	// Metadata returns the SAML service provider metadata of a connection
+	Metadata(ctx context.Context, connectionID uint) ([]byte, error)
+	// BeginLogin returns the address that sends the user to the identity
+	// provider, and a binding the same browser has to present on return
+	BeginLogin(ctx context.Context, organizationID uint) (string, string, error)
+	// BeginLink starts a sign-in that links the identity to the account of
+	// a signed-in user
+	BeginLink(ctx context.Context, userID, organizationID uint) (string, error)
+	FinishOIDC(ctx context.Context, state, code, binding string) (*SSOLoginResult, error)
+	FinishSAML(ctx context.Context, connectionID uint, relayState, samlResponse, binding string) (*SSOLoginResult, error)
+	// ExchangeLoginCode returns the user a login code was issued to and the
+	// auth.AuthMethod* values of their sign-in
+	ExchangeLoginCode(ctx context.Context, code string) (*models.User, []string, error)
+}
+
+// LoginAttempt is one try at signing in
+type LoginAttempt struct {
+	// Email is the address given; empty for passkey logins, which name the
+	// user only once they succeed
+	Email     string
+	IPAddress string
+	UserAgent string
+
+	// counted is set once CheckLogin has counted the attempt as a failure
+	counted bool
+	// settled is set once the outcome of the attempt has been reported
+	settled bool
+}
+
+// LoginPolicy sets how failed logins slow down further attempts. After
+// BackoffAfter failures for an address, or IPBackoffAfter from an IP
+// address, each attempt has to wait BackoffBase, doubling with every
+// failure up to BackoffMax. LockoutThreshold failures lock the account for
+// LockoutDuration and email its owner a link to unlock it. Failures are
+// forgotten after FailureWindow without one, and security events after
+// EventRetention.
+type LoginPolicy struct {
+	BackoffAfter     int
+	IPBackoffAfter   int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	FailureWindow    time.Duration
+	EventRetention   time.Duration
+}
+
+// SecurityService defines the interface for brute-force protection of
+// logins and the security event log users see. Every login attempt is
+// counted as a failure up front, so that parallel guesses cannot outrun
+// the backoff, and taken back once it succeeds.
+type SecurityService interface {
+	// CheckLogin counts an attempt before its credentials are checked. It
+	// fails with ErrLoginThrottled or ErrAccountLocked, and how long to wait,
+	// while the address or IP address is backing off or locked.
+	CheckLogin(ctx context.Context, attempt *LoginAttempt) (time.Duration, error)
+	// LoginFailed records why an attempt failed. The owner of an account it
+	// locks is emailed a link to unlock it.
+	LoginFailed(ctx context.Context, attempt *LoginAttempt, reason string) error
+	// LoginPassed takes back the failure counted for an attempt whose
+	// credentials were right and clears the user's failures. methods are the
+	// auth.AuthMethod* values of the session started, or nil while a second
+	// factor is still to come.
+	LoginPassed(ctx context.Context, attempt *LoginAttempt, user *models.User, methods []string) error
+	// LoginAborted settles an attempt neither LoginFailed nor LoginPassed
+	// did, because the server failed before its credentials decided it. Its
+	// failure is taken back, so that errors do not lock anyone out.
+	LoginAborted(ctx context.Context, attempt *LoginAttempt) error
+	// UnlockAccount lifts a lockout with the token of an unlock email
+	UnlockAccount(ctx context.Context, token, ipAddress, userAgent string) error
+	ListEvents(ctx context.Context, userID uint, offset, limit int) ([]*models.SecurityEvent, int64, error)
+	// Purge deletes failure counts and events that are no longer kept
+	Purge(ctx context.Context) (int64, error)
+	// Check reports an invalid login policy
+	Check() error
+}
@@ -0,0 +1,479 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotOrganizationMember = errors.New("not a member of this organization")
+	ErrCertificateExists     = errors.New("certificate is already in the inventory")
+	ErrKeyPairExists         = errors.New("key is already in the inventory")
+	ErrKeyMismatch           = errors.New("private key does not match the certificate")
+	ErrExportNotPermitted    = errors.New("export is not permitted for this key")
+	ErrOrganizationAdminOnly = errors.New("requires an organization admin or owner")
+
+	errPublicKeyMismatch = errors.New("public key does not match the private key")
+)
+
+// inventoryService implements InventoryService interface
+// Single Responsibility: Handles certificate and key inventory business logic
+type inventoryService struct {
+	certRepo repository.CertificateRepository
+	keyRepo  repository.KeyPairRepository
+	orgRepo  repository.OrganizationRepository
+	vault    KeyVaultService
+}
+
+// NewInventoryService creates a new inventory service
+func NewInventoryService(
+	certRepo repository.CertificateRepository,
+	keyRepo repository.KeyPairRepository,
+	orgRepo repository.OrganizationRepository,
+	vault KeyVaultService,
+) InventoryService {
+	return &inventoryService{
+		certRepo: certRepo,
+		keyRepo:  keyRepo,
+		orgRepo:  orgRepo,
+		vault:    vault,
+	}
+}
+
+func (s *inventoryService) ResolveScope(ctx context.Context, userID uint, organizationID *uint) (repository.Scope, error) {
+	scope := repository.Scope{UserID: userID}
+	if organizationID == nil {
+		return scope, nil
+	}
+
+	if _, err := s.orgRepo.GetMember(ctx, *organizationID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return scope, ErrNotOrganizationMember
+		}
+		return scope, err
+	}
+
+	scope.OrganizationID = organizationID
+	return scope, nil
+}
+
+func (s *inventoryService) ImportCertificate(ctx context.Context, scope repository.Scope, req ImportCertificateRequest) (*models.Certificate, error) {
+	leaf, chain, err := parseCertificateBundle(req.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := sha256Hex(leaf.Raw)
+	if existing, err := s.certRepo.GetByFingerprint(ctx, scope, fingerprint); err == nil {
+		return existing, ErrCertificateExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	cert := certificateRecord(leaf, chain)
+	cert.UserID = scope.UserID
+	cert.OrganizationID = scope.OrganizationID
+	cert.Name = req.Name
+	cert.Source = req.Source
+	cert.ACMEAccountID = req.ACMEAccountID
+	if cert.Name == "" {
+		cert.Name = cert.CommonName
+	}
+
+	// Link the key: import it when supplied, otherwise reuse one already held.
+	// The certificate's public key lets encrypted private keys be stored too.
+	if req.PrivateKey != "" {
+		key, err := s.findOrImportKeyPair(ctx, scope, ImportKeyPairRequest{
+			Name:       cert.Name,
+			Source:     req.Source,
+			PrivateKey: req.PrivateKey,
+			Exportable: req.Exportable,
+			PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: leaf.RawSubjectPublicKeyInfo})),
+		})
+		if errors.Is(err, errPublicKeyMismatch) {
+			return nil, ErrKeyMismatch
+		}
+		if err != nil {
+			return nil, err
+		}
+		cert.KeyPairID = &key.ID
+	} else if key, err := s.keyRepo.GetByFingerprint(ctx, scope, cert.KeyFingerprint); err == nil {
+		cert.KeyPairID = &key.ID
+	}
+
+	if issuer := s.findIssuer(ctx, scope, leaf); issuer != nil {
+		cert.IssuerCertificateID = &issuer.ID
+	}
+
+	if err := s.certRepo.Create(ctx, cert); err != nil {
+		return nil, err
+	}
+
+	// A CA certificate may arrive after the certificates it issued
+	if leaf.IsCA {
+		s.adoptIssuedCertificates(ctx, scope, cert, leaf)
+	}
+
+	return cert, nil
+}
+
+func (s *inventoryService) ImportKeyPair(ctx context.Context, scope repository.Scope, req ImportKeyPairRequest) (*models.KeyPair, error) {
+	key, err := keyPairRecord(req.PrivateKey, req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.keyRepo.GetByFingerprint(ctx, scope, key.Fingerprint); err == nil {
+		return existing, ErrKeyPairExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	key.UserID = scope.UserID
+	key.OrganizationID = scope.OrganizationID
+	key.Name = req.Name
+	key.Source = req.Source
+	key.Exportable = req.Exportable
+
+	if key.ID, err = s.vault.ReserveID(ctx, models.TableKeyPairs); err != nil {
+		return nil, err
+	}
+	if key.PrivateKey, err = s.vault.Seal(models.TableKeyPairs, key.ID, req.PrivateKey); err != nil {
+		return nil, err
+	}
+
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	if err := s.certRepo.AttachKeyPair(ctx, scope, key.Fingerprint, key.ID); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *inventoryService) GetCertificate(ctx context.Context, scope repository.Scope, id uint) (*models.Certificate, error) {
+	return s.certRepo.GetByID(ctx, scope, id)
+}
+
+func (s *inventoryService) SearchCertificates(ctx context.Context, filter repository.CertificateFilter) ([]*models.Certificate, int64, error) {
+	if filter.SerialNumber != "" {
+		filter.SerialNumber = normalizeSerial(filter.SerialNumber)
+	}
+	return s.certRepo.Search(ctx, filter)
+}
+
+func (s *inventoryService) DeleteCertificate(ctx context.Context, scope repository.Scope, id uint) error {
+	return s.certRepo.Delete(ctx, scope, id)
+}
+
+func (s *inventoryService) GetKeyPair(ctx context.Context, scope repository.Scope, id uint) (*models.KeyPair, error) {
+	return s.keyRepo.GetByID(ctx, scope, id)
+}
+
+func (s *inventoryService) ListKeyPairs(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.KeyPair, int64, error) {
+	return s.keyRepo.List(ctx, scope, offset, limit)
+}
+
+// ExportKeyPair returns the private key in PEM form. Keys are only released
+// when export was granted on the key and, for organization keys, the caller
+// administers the organization.
+func (s *inventoryService) ExportKeyPair(ctx context.Context, scope repository.Scope, id uint) (string, error) {
+	key, err := s.keyRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return "", err
+	}
+	if !key.Exportable {
+		return "", ErrExportNotPermitted
+	}
+	if err := s.checkKeyAdmin(ctx, scope); err != nil {
+		return "", err
+	}
+
+	return s.vault.Open(models.TableKeyPairs, key.ID, key.PrivateKey)
+}
+
+func (s *inventoryService) SetKeyPairExportable(ctx context.Context, scope repository.Scope, id uint, exportable bool) (*models.KeyPair, error) {
+	if err := s.checkKeyAdmin(ctx, scope); err != nil {
+		return nil, err
+	}
+
+	key, err := s.keyRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Exportable = exportable
+	if err := s.keyRepo.Update(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *inventoryService) DeleteKeyPair(ctx context.Context, scope repository.Scope, id uint) error {
+	return s.keyRepo.Delete(ctx, scope, id)
+}
+
+// Helper functions
+
+// checkKeyAdmin allows personal keys to be managed by their owner and
+// organization keys by organization admins and owners.
+func (s *inventoryService) checkKeyAdmin(ctx context.Context, scope repository.Scope) error {
+	if scope.OrganizationID == nil {
+		return nil
+	}
+
+	member, err := s.orgRepo.GetMember(ctx, *scope.OrganizationID, scope.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotOrganizationMember
+		}
+		return err
+	}
+	if member.Role != models.MemberRoleAdmin && member.Role != models.MemberRoleOwner {
+		return ErrOrganizationAdminOnly
+	}
+	return nil
+}
+
+func (s *inventoryService) findOrImportKeyPair(ctx context.Context, scope repository.Scope, req ImportKeyPairRequest) (*models.KeyPair, error) {
+	key, err := s.ImportKeyPair(ctx, scope, req)
+	if errors.Is(err, ErrKeyPairExists) {
+		return key, nil
+	}
+	return key, err
+}
+
+// findIssuer returns the inventory certificate that signed cert, or nil when
+// the certificate is self-signed or its issuer is not held in scope.
+func (s *inventoryService) findIssuer(ctx context.Context, scope repository.Scope, cert *x509.Certificate) *models.Certificate {
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil {
+		return nil
+	}
+
+	candidates, err := s.certRepo.FindBySubject(ctx, scope, cert.Issuer.String())
+	if err != nil {
+		return nil
+	}
+	for _, candidate := range candidates {
+		parent, _, err := parseCertificateBundle(candidate.PEM)
+		if err != nil {
+			continue
+		}
+		if cert.CheckSignatureFrom(parent) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func (s *inventoryService) adoptIssuedCertificates(ctx context.Context, scope repository.Scope, ca *models.Certificate, caCert *x509.Certificate) {
+	issued, err := s.certRepo.FindUnlinkedByIssuer(ctx, scope, ca.Subject)
+	if err != nil {
+		return
+	}
+	for _, child := range issued {
+		if child.ID == ca.ID {
+			continue
+		}
+		childCert, _, err := parseCertificateBundle(child.PEM)
+		if err != nil || childCert.CheckSignatureFrom(caCert) != nil {
+			continue
+		}
+		child.IssuerCertificateID = &ca.ID
+		s.certRepo.Update(ctx, child)
+	}
+}
+
+// parseCertificateBundle decodes the first certificate in a PEM bundle and
+// returns any following certificates re-encoded as the chain.
+func parseCertificateBundle(bundle string) (*x509.Certificate, string, error) {
+	var leaf *x509.Certificate
+	var chain strings.Builder
+
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if leaf != nil {
+			chain.Write(pem.EncodeToMemory(block))
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, "", fmt.Errorf("certificate parsing error: %w", err)
+		}
+		leaf = cert
+	}
+
+	if leaf == nil {
+		return nil, "", errors.New("no PEM encoded certificate found")
+	}
+	return leaf, chain.String(), nil
+}
+
+func certificateRecord(cert *x509.Certificate, chain string) *models.Certificate {
+	algorithm, size := publicKeyInfo(cert.PublicKey)
+
+	return &models.Certificate{
+		CommonName:         cert.Subject.CommonName,
+		SANs:               strings.Join(subjectAltNames(cert), ","),
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       normalizeSerial(fmt.Sprintf("%X", cert.SerialNumber)),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		IsCA:               cert.IsCA,
+		KeyAlgorithm:       algorithm,
+		KeySize:            size,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		Fingerprint:        sha256Hex(cert.Raw),
+		KeyFingerprint:     sha256Hex(cert.RawSubjectPublicKeyInfo),
+		PEM:                string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		Chain:              chain,
+	}
+}
+
+// keyPairRecord builds a key record, without the sealed private key, from a
+// PEM private key. Encrypted keys cannot be inspected, so their public key
+// must be supplied alongside.
+func keyPairRecord(privateKeyPEM, publicKeyPEM string) (*models.KeyPair, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	var publicKey crypto.PublicKey
+	encrypted := block.Type == "ENCRYPTED PRIVATE KEY" || strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED")
+	if !encrypted {
+		privateKey, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = privateKey.Public()
+	}
+
+	if publicKeyPEM != "" {
+		pubBlock, _ := pem.Decode([]byte(publicKeyPEM))
+		if pubBlock == nil {
+			return nil, errors.New("no PEM encoded public key found")
+		}
+		supplied, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("public key parsing error: %w", err)
+		}
+		if publicKey != nil {
+			derived, err := x509.MarshalPKIXPublicKey(publicKey)
+			if err != nil || !bytes.Equal(derived, pubBlock.Bytes) {
+				return nil, errPublicKeyMismatch
+			}
+		}
+		publicKey = supplied
+	}
+
+	if publicKey == nil {
+		return nil, errors.New("public key is required for encrypted private keys")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key: %w", err)
+	}
+
+	algorithm, size := publicKeyInfo(publicKey)
+	return &models.KeyPair{
+		Algorithm:   algorithm,
+		KeySize:     size,
+		PublicKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		Fingerprint: sha256Hex(der),
+	}, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var key interface{}
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("private key parsing error: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+func publicKeyInfo(key crypto.PublicKey) (string, int) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return "rsa", k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ec", k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "ed25519", 256
+	default:
+		return "unknown", 0
+	}
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// normalizeSerial brings a hex serial number into the stored form: upper case
+// without separators or leading zeros.
+func normalizeSerial(serial string) string {
+	serial = strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(serial))
+	serial = strings.TrimLeft(serial, "0")
+	if serial == "" {
+		return "0"
+	}
+	return serial
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/totp"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMFAAlreadyEnabled         = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled             = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolling           = errors.New("start two-factor enrolment first")
+	ErrInvalidMFACode            = errors.New("invalid authentication code")
+	ErrInvalidMFAChallenge       = errors.New("login challenge is invalid or has expired; log in again")
+	ErrMFALocked                 = errors.New("too many invalid authentication codes; try again later")
+	ErrMFARequiredByOrganization = errors.New("your organization requires two-factor authentication")
+)
+
+const (
+	mfaChallengePurpose = "mfa-challenge"
+	mfaChallengeTTL     = 5 * time.Minute
+
+	recoveryCodeCount = 10
+	// recoveryCodeLength base32 characters carry 50 bits
+	recoveryCodeLength = 10
+
+	// maxMFAFailures consecutive invalid codes lock the factor for mfaLockout
+	maxMFAFailures = 5
+	mfaLockout     = 15 * time.Minute
+)
+
+// mfaService implements MFAService interface
+// Single Responsibility: Handles TOTP enrolment, recovery codes and the
+// second login step
+type mfaService struct {
+	mfaRepo     repository.MFARepository
+	userRepo    repository.UserRepository
+	orgRepo     repository.OrganizationRepository
+	vault       KeyVaultService
+	authService *auth.Service
+	issuer      string
+}
+
+// NewMFAService creates a new MFA service. issuer names the service in
+// authenticator apps.
+func NewMFAService(
+	mfaRepo repository.MFARepository,
+	userRepo repository.UserRepository,
+	orgRepo repository.OrganizationRepository,
+	vault KeyVaultService,
+	authService *auth.Service,
+	issuer string,
+) MFAService {
+	return &mfaService{
+		mfaRepo:     mfaRepo,
+		userRepo:    userRepo,
+		orgRepo:     orgRepo,
+		vault:       vault,
+		authService: authService,
+		issuer:      issuer,
+	}
+}
+
+func (s *mfaService) Status(ctx context.Context, userID uint) (*MFAStatus, error) {
+	status := &MFAStatus{}
+
+	required, err := s.orgRepo.RequiresMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status.Required = required
+
+	factor, err := s.confirmedFactor(ctx, userID)
+	if errors.Is(err, ErrMFANotEnabled) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Enabled = true
+	status.EnabledAt = factor.ConfirmedAt
+
+	if status.RecoveryCodesLeft, err = s.mfaRepo.CountRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *mfaService) Enabled(ctx context.Context, userID uint) (bool, error) {
+	_, err := s.confirmedFactor(ctx, userID)
+	if errors.Is(err, ErrMFANotEnabled) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *mfaService) Required(ctx context.Context, userID uint) (bool, error) {
+	return s.orgRepo.RequiresMFA(ctx, userID)
+}
+
+func (s *mfaService) BeginEnrollment(ctx context.Context, userID uint) (*TOTPEnrollment, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	factor := &models.TOTPFactor{UserID: userID}
+	if factor.ID, err = s.vault.ReserveID(ctx, models.TableTOTPFactors); err != nil {
+		return nil, err
+	}
+	if factor.Secret, err = s.vault.Seal(models.TableTOTPFactors, factor.ID, secret); err != nil {
+		return nil, err
+	}
+
+	// Starting over replaces an enrolment that was never confirmed
+	if err := s.mfaRepo.ReplaceFactor(ctx, factor); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(secret, s.issuer, user.Email),
+	}, nil
+}
+
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	factor, err := s.mfaRepo.GetFactor(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMFANotEnrolling
+	}
+	if err != nil {
+		return nil, err
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	now := time.Now()
+	if err := s.checkLock(factor, now); err != nil {
+		return nil, err
+	}
+	secret, err := s.vault.Open(models.TableTOTPFactors, factor.ID, factor.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, now, factor.LastUsedStep)
+	if !ok {
+		return nil, s.failed(ctx, factor, now)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Confirm(ctx, factor.ID, step, now, hashes); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFACode
+		}
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) Disable(ctx context.Context, userID uint, code string) error {
+	required, err := s.orgRepo.RequiresMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequiredByOrganization
+	}
+
+	factor, err := s.confirmedFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCode(ctx, factor, code); err != nil {
+		return err
+	}
+	return s.mfaRepo.DeleteFactor(ctx, userID)
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	factor, err := s.confirmedFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, factor, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) Challenge(user *models.User) (string, error) {
+	return s.authService.GeneratePurposeToken(mfaChallengePurpose, user, mfaChallengeTTL)
+}
+
+func (s *mfaService) ChallengeUser(ctx context.Context, challenge string) (*models.User, error) {
+	claims, err := s.authService.ValidatePurposeToken(mfaChallengePurpose, strings.TrimSpace(challenge))
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return user, nil
+}
+
+func (s *mfaService) VerifyChallenge(ctx context.Context, challenge, code string) (*models.User, error) {
+	user, err := s.ChallengeUser(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	factor, err := s.confirmedFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, factor, code); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *mfaService) SetOrganizationPolicy(ctx context.Context, userID, organizationID uint, required bool) (*models.Organization, error) {
+	member, err := s.orgRepo.GetMember(ctx, organizationID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrganizationMember
+		}
+		return nil, err
+	}
+	if member.Role != models.MemberRoleAdmin && member.Role != models.MemberRoleOwner {
+		return nil, ErrOrganizationAdminOnly
+	}
+
+	// Keeps admins from locking themselves out of their organization
+	if required {
+		enabled, err := s.Enabled(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, ErrMFANotEnabled
+		}
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	org.RequireMFA = required
+	if err := s.orgRepo.Update(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// Helper functions
+
+func (s *mfaService) confirmedFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error) {
+	factor, err := s.mfaRepo.GetFactor(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if factor.ConfirmedAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	return factor, nil
+}
+
+// verifyCode accepts a code from the authenticator, or failing that an
+// unused recovery code.
+func (s *mfaService) verifyCode(ctx context.Context, factor *models.TOTPFactor, code string) error {
+	now := time.Now()
+	if err := s.checkLock(factor, now); err != nil {
+		return err
+	}
+
+	secret, err := s.vault.Open(models.TableTOTPFactors, factor.ID, factor.Secret)
+	if err != nil {
+		return err
+	}
+	if step, ok := totp.Validate(secret, code, now, factor.LastUsedStep); ok {
+		if err := s.mfaRepo.UseStep(ctx, factor.ID, step); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Another request used the code first
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+		return nil
+	}
+
+	err = s.mfaRepo.UseRecoveryCode(ctx, factor.UserID, hashSecretToken(normalizeRecoveryCode(code)), now)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.failed(ctx, factor, now)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("User %d signed in with a recovery code", factor.UserID)
+	return nil
+}
+
+func (s *mfaService) checkLock(factor *models.TOTPFactor, now time.Time) error {
+	if factor.FailedAttempts >= maxMFAFailures && factor.LastFailedAt != nil && now.Sub(*factor.LastFailedAt) < mfaLockout {
+		return ErrMFALocked
+	}
+	return nil
+}
+
+func (s *mfaService) failed(ctx context.Context, factor *models.TOTPFactor, now time.Time) error {
+	if err := s.mfaRepo.RecordFailure(ctx, factor.ID, now); err != nil {
+		return err
+	}
+	return ErrInvalidMFACode
+}
+
+// newRecoveryCodes returns codes formatted as XXXXX-XXXXX for the user
+// and their hashes for storage.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(random)[:recoveryCodeLength]
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = hashSecretToken(code)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/totp"
+	"web-openssl-backend/pkg/vault"
+
+	"gorm.io/gorm"
+)
+
+// memoryMFARepo keeps the conditional updates of the real repository, which
+// are what make a code usable only once
+type memoryMFARepo struct {
+	repository.MFARepository
+
+	mu            sync.Mutex
+	factors       map[uint]*models.TOTPFactor
+	recoveryCodes map[uint]map[string]bool
+}
+
+func newMemoryMFARepo() *memoryMFARepo {
+	return &memoryMFARepo{
+		factors:       make(map[uint]*models.TOTPFactor),
+		recoveryCodes: make(map[uint]map[string]bool),
+	}
+}
+
+func (r *memoryMFARepo) GetFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	factor, ok := r.factors[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *factor
+	return &copied, nil
+}
+
+func (r *memoryMFARepo) ReplaceFactor(ctx context.Context, factor *models.TOTPFactor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *factor
+	r.factors[factor.UserID] = &copied
+	return nil
+}
+
+func (r *memoryMFARepo) factor(id uint) *models.TOTPFactor {
+	for _, factor := range r.factors {
+		if factor.ID == id {
+			return factor
+		}
+	}
+	return nil
+}
+
+func (r *memoryMFARepo) Confirm(ctx context.Context, id uint, step int64, at time.Time, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	factor := r.factor(id)
+	if factor == nil || factor.ConfirmedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	factor.ConfirmedAt = &at
+	factor.LastUsedStep = step
+	factor.FailedAttempts = 0
+	r.recoveryCodes[factor.UserID] = make(map[string]bool)
+	for _, hash := range codeHashes {
+		r.recoveryCodes[factor.UserID][hash] = true
+	}
+	return nil
+}
+
+func (r *memoryMFARepo) UseStep(ctx context.Context, id uint, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	factor := r.factor(id)
+	if factor == nil || step <= factor.LastUsedStep {
+		return gorm.ErrRecordNotFound
+	}
+	factor.LastUsedStep = step
+	factor.FailedAttempts = 0
+	return nil
+}
+
+func (r *memoryMFARepo) RecordFailure(ctx context.Context, id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if factor := r.factor(id); factor != nil {
+		factor.FailedAttempts++
+		factor.LastFailedAt = &at
+	}
+	return nil
+}
+
+func (r *memoryMFARepo) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.recoveryCodes[userID][codeHash] {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.recoveryCodes[userID], codeHash)
+	return nil
+}
+
+type mfaOrgRepo struct {
+	repository.OrganizationRepository
+}
+
+func (r *mfaOrgRepo) RequiresMFA(ctx context.Context, userID uint) (bool, error) {
+	return false, nil
+}
+
+// sequenceRepo reserves IDs the way the tables' sequences would
+type sequenceRepo struct {
+	repository.SealedKeyRepository
+
+	mu   sync.Mutex
+	last uint
+}
+
+func (r *sequenceRepo) NextID(ctx context.Context, table string) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last++
+	return r.last, nil
+}
+
+func newTestKeyVault(t *testing.T) KeyVaultService {
+	t.Helper()
+	key := make([]byte, vault.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	vaultService := vault.NewService(vault.Config{
+		Provider:    vault.ProviderConfig,
+		MasterKeyID: "test",
+		MasterKey:   base64.StdEncoding.EncodeToString(key),
+	})
+	if err := vaultService.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return NewKeyVaultService(vaultService, &sequenceRepo{})
+}
+
+type mfaTest struct {
+	service   MFAService
+	user      *models.User
+	secret    string
+	challenge string
+}
+
+// newMFATest enrols a user, confirming the factor with the code of the
+// current step
+func newMFATest(t *testing.T) (*mfaTest, []string) {
+	t.Helper()
+	user := &models.User{ID: 1, Email: "user@example.com", IsActive: true}
+	users := &memoryUserRepo{users: map[uint]*models.User{1: user}}
+	authService := auth.NewService(auth.Config{Secret: "test-secret", ExpiresIn: time.Hour})
+	s := NewMFAService(newMemoryMFARepo(), users, &mfaOrgRepo{}, newTestKeyVault(t), authService, "Test")
+	ctx := context.Background()
+
+	enrollment, err := s.BeginEnrollment(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("BeginEnrollment: %v", err)
+	}
+	codes, err := s.ConfirmEnrollment(ctx, user.ID, stepCode(t, enrollment.Secret, 0))
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment: %v", err)
+	}
+	challenge, err := s.Challenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &mfaTest{service: s, user: user, secret: enrollment.Secret, challenge: challenge}, codes
+}
+
+// stepCode returns the code offset steps from the current one
+func stepCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTOTPCodeCannotBeReplayed(t *testing.T) {
+	m, _ := newMFATest(t)
+	ctx := context.Background()
+
+	// The code confirming enrolment is used up
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, stepCode(t, m.secret, 0)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("enrolment code replayed at login: %v", err)
+	}
+
+	next := stepCode(t, m.secret, 1)
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, next); err != nil {
+		t.Fatalf("fresh code rejected: %v", err)
+	}
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, next); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	// Nor is a code older than the last one used accepted
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, stepCode(t, m.secret, -1)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("earlier code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestConcurrentTOTPCodeIsAcceptedOnce(t *testing.T) {
+	m, _ := newMFATest(t)
+	code := stepCode(t, m.secret, 1)
+
+	const attempts = 5
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			_, err := m.service.VerifyChallenge(context.Background(), m.challenge, code)
+			errs <- err
+		}()
+	}
+
+	accepted := 0
+	for i := 0; i < attempts; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, ErrInvalidMFACode):
+			t.Errorf("VerifyChallenge error = %v", err)
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("code accepted %d times, want 1", accepted)
+	}
+}
+
+func TestRecoveryCodeWorksOnce(t *testing.T) {
+	m, codes := newMFATest(t)
+	ctx := context.Background()
+
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, codes[0]); err != nil {
+		t.Fatalf("recovery code rejected: %v", err)
+	}
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("reused recovery code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestInvalidCodesLockFactor(t *testing.T) {
+	m, _ := newMFATest(t)
+	ctx := context.Background()
+
+	for i := 0; i < maxMFAFailures; i++ {
+		if _, err := m.service.VerifyChallenge(ctx, m.challenge, "000000"); !errors.Is(err, ErrInvalidMFACode) && !errors.Is(err, ErrMFALocked) {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	if _, err := m.service.VerifyChallenge(ctx, m.challenge, stepCode(t, m.secret, 1)); !errors.Is(err, ErrMFALocked) {
+		t.Fatalf("locked factor error = %v, want %v", err, ErrMFALocked)
+	}
+}
@@ -0,0 +1,548 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/netguard"
+	"web-openssl-backend/pkg/notify"
+	"web-openssl-backend/pkg/openssl"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEndpointExists       = errors.New("endpoint is already monitored")
+	ErrInvalidEndpoint      = errors.New("invalid hostname or port")
+	ErrScanIntervalTooShort = errors.New("scan interval is shorter than the minimum allowed")
+)
+
+const endpointBatchSize = 50
+
+// monitoringService implements MonitoringService interface
+// Single Responsibility: Handles scheduled endpoint scans and change alerts
+type monitoringService struct {
+	endpointRepo    repository.MonitoredEndpointRepository
+	scanRepo        repository.EndpointScanRepository
+	userRepo        repository.UserRepository
+	scanner         *openssl.Service
+	notifier        *notify.Service
+	defaultInterval time.Duration
+	minInterval     time.Duration
+	concurrency     int
+}
+
+// NewMonitoringService creates a new monitoring service. Endpoints created
+// without an interval are scanned every defaultInterval; at most concurrency
+// scans run at once.
+func NewMonitoringService(
+	endpointRepo repository.MonitoredEndpointRepository,
+	scanRepo repository.EndpointScanRepository,
+	userRepo repository.UserRepository,
+	scanner *openssl.Service,
+	notifier *notify.Service,
+	defaultInterval, minInterval time.Duration,
+	concurrency int,
+) MonitoringService {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &monitoringService{
+		endpointRepo:    endpointRepo,
+		scanRepo:        scanRepo,
+		userRepo:        userRepo,
+		scanner:         scanner,
+		notifier:        notifier,
+		defaultInterval: defaultInterval,
+		minInterval:     minInterval,
+		concurrency:     concurrency,
+	}
+}
+
+func (s *monitoringService) CreateEndpoint(ctx context.Context, scope repository.Scope, req CreateEndpointRequest) (*models.MonitoredEndpoint, error) {
+	hostname, port, err := normalizeEndpoint(req.Hostname, req.Port)
+	if err != nil {
+		return nil, err
+	}
+	if err := netguard.CheckHost(ctx, hostname); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEndpoint, err)
+	}
+	interval, err := s.scanInterval(req.IntervalMinutes)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.endpointRepo.GetByAddress(ctx, scope, hostname, port)
+	if err == nil {
+		return existing, ErrEndpointExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	endpoint := &models.MonitoredEndpoint{
+		UserID:          scope.UserID,
+		OrganizationID:  scope.OrganizationID,
+		Name:            strings.TrimSpace(req.Name),
+		Hostname:        hostname,
+		Port:            port,
+		IntervalMinutes: int(interval / time.Minute),
+		// Due immediately, so the first scan runs on the next scheduler tick
+		NextScanAt: time.Now(),
+	}
+	if endpoint.Name == "" {
+		endpoint.Name = endpoint.Address()
+	}
+
+	if err := s.endpointRepo.Create(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *monitoringService) GetEndpoint(ctx context.Context, scope repository.Scope, id uint) (*models.MonitoredEndpoint, error) {
+	return s.endpointRepo.GetByID(ctx, scope, id)
+}
+
+func (s *monitoringService) ListEndpoints(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.MonitoredEndpoint, int64, error) {
+	return s.endpointRepo.List(ctx, scope, offset, limit)
+}
+
+func (s *monitoringService) UpdateEndpoint(ctx context.Context, scope repository.Scope, id uint, req UpdateEndpointRequest) (*models.MonitoredEndpoint, error) {
+	endpoint, err := s.endpointRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		endpoint.Name = strings.TrimSpace(*req.Name)
+		if endpoint.Name == "" {
+			endpoint.Name = endpoint.Address()
+		}
+	}
+	if req.IntervalMinutes != nil {
+		interval, err := s.scanInterval(*req.IntervalMinutes)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.IntervalMinutes = int(interval / time.Minute)
+		if endpoint.LastScanAt != nil {
+			endpoint.NextScanAt = endpoint.LastScanAt.Add(interval)
+		}
+	}
+	if req.Paused != nil {
+		endpoint.Paused = *req.Paused
+	}
+
+	if err := s.endpointRepo.Update(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *monitoringService) DeleteEndpoint(ctx context.Context, scope repository.Scope, id uint) error {
+	return s.endpointRepo.Delete(ctx, scope, id)
+}
+
+func (s *monitoringService) ScanEndpoint(ctx context.Context, scope repository.Scope, id uint) (*models.EndpointScan, error) {
+	endpoint, err := s.endpointRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.scan(ctx, endpoint)
+}
+
+// ScanDue scans every endpoint whose next scan time has passed. Endpoints
+// are claimed by moving their next scan time forward before scanning, so a
+// slow batch is not picked up again by the next run.
+func (s *monitoringService) ScanDue(ctx context.Context) (*ScanDueResult, error) {
+	result := &ScanDueResult{}
+	var mu sync.Mutex
+
+	for {
+		now := time.Now()
+		endpoints, err := s.endpointRepo.ListDue(ctx, now, endpointBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(endpoints) == 0 {
+			return result, nil
+		}
+
+		for _, endpoint := range endpoints {
+			endpoint.NextScanAt = now.Add(endpointInterval(endpoint))
+			if err := s.endpointRepo.Update(ctx, endpoint); err != nil {
+				return result, err
+			}
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, s.concurrency)
+		for _, endpoint := range endpoints {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(endpoint *models.MonitoredEndpoint) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				scan, err := s.scan(ctx, endpoint)
+
+				mu.Lock()
+				defer mu.Unlock()
+				result.Scanned++
+				if err != nil || !scan.Success {
+					result.Failed++
+				}
+				if err == nil && scan.Alerted {
+					result.Alerts++
+				}
+			}(endpoint)
+		}
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+}
+
+func (s *monitoringService) GetScan(ctx context.Context, scope repository.Scope, endpointID, scanID uint) (*models.EndpointScan, error) {
+	if _, err := s.endpointRepo.GetByID(ctx, scope, endpointID); err != nil {
+		return nil, err
+	}
+	return s.scanRepo.GetByID(ctx, endpointID, scanID)
+}
+
+func (s *monitoringService) ListScans(ctx context.Context, scope repository.Scope, endpointID uint, offset, limit int) ([]*models.EndpointScan, int64, error) {
+	if _, err := s.endpointRepo.GetByID(ctx, scope, endpointID); err != nil {
+		return nil, 0, err
+	}
+	return s.scanRepo.List(ctx, endpointID, offset, limit)
+}
+
+func (s *monitoringService) GetGradeTrend(ctx context.Context, scope repository.Scope, endpointID uint, since time.Time) ([]GradePoint, error) {
+	if _, err := s.endpointRepo.GetByID(ctx, scope, endpointID); err != nil {
+		return nil, err
+	}
+
+	scans, err := s.scanRepo.ListSince(ctx, endpointID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]GradePoint, 0, len(scans))
+	for _, scan := range scans {
+		if !scan.Success {
+			continue
+		}
+		points = append(points, GradePoint{
+			Time:   scan.CreatedAt,
+			ScanID: scan.ID,
+			Grade:  scan.Grade,
+			Score:  openssl.GradeScore(scan.Grade),
+		})
+	}
+	return points, nil
+}
+
+// ExpiringEndpoints is an ExpirySource announcing the certificates served
+// by monitored endpoints, as seen by their latest scan.
+func (s *monitoringService) ExpiringEndpoints(ctx context.Context, from, to time.Time) ([]ExpiringItem, error) {
+	var items []ExpiringItem
+	var afterID uint
+	for {
+		endpoints, err := s.endpointRepo.ListExpiring(ctx, from, to, afterID, endpointBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(endpoints) == 0 {
+			return items, nil
+		}
+
+		for _, endpoint := range endpoints {
+			afterID = endpoint.ID
+			items = append(items, ExpiringItem{
+				SubjectType: models.ExpirySubjectEndpoint,
+				SubjectID:   endpoint.ID,
+				UserID:      endpoint.UserID,
+				Name:        endpoint.Name,
+				NotAfter:    *endpoint.CertificateNotAfter,
+				Fields: map[string]string{
+					"Endpoint":    endpoint.Address(),
+					"Fingerprint": endpoint.CertificateFingerprint,
+				},
+			})
+		}
+	}
+}
+
+// Helper functions
+
+// scan runs the scanner against endpoint, stores the result and alerts the
+// owner when the result differs from the previous successful scan. An
+// unreachable endpoint is a failed scan, not an error.
+func (s *monitoringService) scan(ctx context.Context, endpoint *models.MonitoredEndpoint) (*models.EndpointScan, error) {
+	started := time.Now()
+	result, scanErr := s.scanner.TestSSL(ctx, &openssl.SSLTestRequest{
+		Hostname: endpoint.Hostname,
+		Port:     endpoint.Port,
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	now := time.Now()
+	scan := &models.EndpointScan{
+		EndpointID: endpoint.ID,
+		Success:    scanErr == nil,
+		DurationMs: now.Sub(started).Milliseconds(),
+	}
+
+	if scanErr != nil {
+		scan.Error = scanErr.Error()
+		endpoint.LastError = scan.Error
+	} else {
+		if err := summarizeScan(scan, result); err != nil {
+			return nil, err
+		}
+
+		previous, err := s.scanRepo.GetLatestSuccessful(ctx, endpoint.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if previous != nil {
+			changes := compareScans(previous, scan)
+			scan.Changes = strings.Join(changes, ",")
+			if len(changes) > 0 {
+				scan.Alerted = s.alert(ctx, endpoint, previous, scan, changes)
+			}
+		}
+
+		endpoint.LastError = ""
+		endpoint.LastGrade = scan.Grade
+		endpoint.CertificateFingerprint = scan.CertificateFingerprint
+		endpoint.CertificateNotAfter = scan.NotAfter
+	}
+
+	if err := s.scanRepo.Create(ctx, scan); err != nil {
+		return nil, err
+	}
+
+	endpoint.LastScanAt = &now
+	endpoint.LastScanID = &scan.ID
+	endpoint.NextScanAt = now.Add(endpointInterval(endpoint))
+	if err := s.endpointRepo.Update(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	return scan, nil
+}
+
+func (s *monitoringService) alert(ctx context.Context, endpoint *models.MonitoredEndpoint, previous, current *models.EndpointScan, changes []string) bool {
+	notification := &notify.Notification{
+		Event:    "endpoint.changed",
+		Severity: notify.SeverityInfo,
+		Subject:  fmt.Sprintf("TLS configuration of %s changed", endpoint.Name),
+		Text: fmt.Sprintf("The scan of %s found changes to its %s since the scan of %s.",
+			endpoint.Address(), strings.Join(changes, ", "), previous.CreatedAt.UTC().Format("2006-01-02 15:04 MST")),
+		Fields: map[string]string{
+			"Endpoint": endpoint.Address(),
+			"Grade":    current.Grade,
+		},
+	}
+
+	for _, change := range changes {
+		switch change {
+		case models.EndpointChangeGrade:
+			notification.Fields["Grade"] = previous.Grade + " → " + current.Grade
+			if openssl.GradeRank(current.Grade) > openssl.GradeRank(previous.Grade) {
+				notification.Severity = notify.SeverityCritical
+			}
+		case models.EndpointChangeCertificate:
+			notification.Fields["Certificate"] = shortFingerprint(previous.CertificateFingerprint) + " → " + shortFingerprint(current.CertificateFingerprint)
+			if current.NotAfter != nil {
+				notification.Fields["Certificate expires"] = current.NotAfter.UTC().Format(time.RFC3339)
+			}
+		case models.EndpointChangeChain:
+			notification.Fields["Chain"] = fmt.Sprintf("%d → %d certificates", countList(previous.ChainFingerprints), countList(current.ChainFingerprints))
+		case models.EndpointChangeProtocols:
+			describeSetChange(notification.Fields, "Protocols", previous.Protocols, current.Protocols)
+		case models.EndpointChangeCiphers:
+			describeSetChange(notification.Fields, "Ciphers", previous.Ciphers, current.Ciphers)
+		}
+		if (change == models.EndpointChangeProtocols || change == models.EndpointChangeCiphers) && notification.Severity == notify.SeverityInfo {
+			notification.Severity = notify.SeverityWarning
+		}
+	}
+
+	if user, err := s.userRepo.GetByID(ctx, endpoint.UserID); err == nil && user.Email != "" {
+		notification.Recipients = []string{user.Email}
+	}
+
+	delivered, err := s.notifier.Send(ctx, notification)
+	if err != nil {
+		log.Printf("Failed to send change alert for endpoint %d: %v", endpoint.ID, err)
+	}
+	return len(delivered) > 0
+}
+
+func (s *monitoringService) scanInterval(minutes int) (time.Duration, error) {
+	if minutes == 0 {
+		return s.defaultInterval, nil
+	}
+	interval := time.Duration(minutes) * time.Minute
+	if interval < s.minInterval {
+		return 0, fmt.Errorf("%w (%s)", ErrScanIntervalTooShort, s.minInterval)
+	}
+	return interval, nil
+}
+
+func endpointInterval(endpoint *models.MonitoredEndpoint) time.Duration {
+	if endpoint.IntervalMinutes <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(endpoint.IntervalMinutes) * time.Minute
+}
+
+// normalizeEndpoint validates a host name or IP address and port, defaulting
+// the port to 443.
+func normalizeEndpoint(hostname string, port int) (string, int, error) {
+	hostname = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(hostname), "."))
+	if port == 0 {
+		port = 443
+	}
+	if port < 1 || port > 65535 {
+		return "", 0, ErrInvalidEndpoint
+	}
+
+	if ip := net.ParseIP(strings.Trim(hostname, "[]")); ip != nil {
+		return ip.String(), port, nil
+	}
+
+	if hostname == "" || len(hostname) > 253 {
+		return "", 0, ErrInvalidEndpoint
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", 0, ErrInvalidEndpoint
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return "", 0, ErrInvalidEndpoint
+			}
+		}
+	}
+	return hostname, port, nil
+}
+
+func summarizeScan(scan *models.EndpointScan, result *openssl.SSLTestResponse) error {
+	report, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	scan.Grade = result.Grade
+	scan.Trusted = result.IsValid
+	scan.Protocol = result.Protocol
+	scan.Cipher = result.Cipher
+	scan.Protocols = sortedList(result.Protocols)
+	scan.Ciphers = sortedList(result.Ciphers)
+	scan.Report = report
+
+	if result.Certificate != nil {
+		notAfter := result.Certificate.NotAfter
+		scan.NotAfter = &notAfter
+		scan.CertificateFingerprint = result.Certificate.Fingerprints["sha256"]
+	}
+
+	// Chain order is significant, so it is kept as served
+	chain := make([]string, 0, len(result.Chain))
+	for _, cert := range result.Chain {
+		chain = append(chain, cert.Fingerprints["sha256"])
+	}
+	scan.ChainFingerprints = strings.Join(chain, ",")
+
+	return nil
+}
+
+func compareScans(previous, current *models.EndpointScan) []string {
+	var changes []string
+	if previous.CertificateFingerprint != current.CertificateFingerprint {
+		changes = append(changes, models.EndpointChangeCertificate)
+	}
+	if previous.ChainFingerprints != current.ChainFingerprints {
+		changes = append(changes, models.EndpointChangeChain)
+	}
+	if previous.Protocols != current.Protocols {
+		changes = append(changes, models.EndpointChangeProtocols)
+	}
+	if previous.Ciphers != current.Ciphers {
+		changes = append(changes, models.EndpointChangeCiphers)
+	}
+	if previous.Grade != current.Grade {
+		changes = append(changes, models.EndpointChangeGrade)
+	}
+	return changes
+}
+
+func describeSetChange(fields map[string]string, name, previous, current string) {
+	before := splitList(previous)
+	after := splitList(current)
+
+	if added := difference(after, before); len(added) > 0 {
+		fields[name+" added"] = strings.Join(added, ", ")
+	}
+	if removed := difference(before, after); len(removed) > 0 {
+		fields[name+" removed"] = strings.Join(removed, ", ")
+	}
+}
+
+func sortedList(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func countList(value string) int {
+	return len(splitList(value))
+}
+
+// difference returns the values in a that are not in b
+func difference(a, b []string) []string {
+	present := make(map[string]bool, len(b))
+	for _, value := range b {
+		present[value] = true
+	}
+
+	var result []string
+	for _, value := range a {
+		if !present[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func shortFingerprint(fingerprint string) string {
+	if len(fingerprint) > 16 {
+		return fingerprint[:16] + "…"
+	}
+	return fingerprint
+}
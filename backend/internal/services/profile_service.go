@@ -0,0 +1,437 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/openssl"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCertificateProfile = errors.New("invalid certificate profile")
+	ErrCertificateProfileExists  = errors.New("a certificate profile with this name already exists")
+	ErrProfileConstraint         = errors.New("request does not satisfy the certificate profile")
+	ErrPlatformAdminOnly         = errors.New("requires a platform administrator")
+)
+
+var (
+	profileKeySizes = map[int]bool{2048: true, 3072: true, 4096: true, 8192: true}
+
+	profileHashAlgorithms = map[string]bool{
+		string(openssl.HashSHA256): true,
+		string(openssl.HashSHA384): true,
+		string(openssl.HashSHA512): true,
+	}
+
+	profileKeyUsages = map[string]bool{
+		"digitalSignature": true, "nonRepudiation": true, "keyEncipherment": true,
+		"dataEncipherment": true, "keyAgreement": true, "keyCertSign": true,
+		"cRLSign": true, "encipherOnly": true, "decipherOnly": true,
+	}
+
+	profileExtKeyUsages = map[string]bool{
+		"serverAuth": true, "clientAuth": true, "codeSigning": true,
+		"emailProtection": true, "timeStamping": true, "OCSPSigning": true,
+	}
+
+	profileFields = map[string]bool{
+		models.ProfileFieldKeyType: true, models.ProfileFieldKeySize: true,
+		models.ProfileFieldKeyUsage: true, models.ProfileFieldExtKeyUsage: true,
+		models.ProfileFieldHashAlgorithm: true, models.ProfileFieldValidDays: true,
+		models.ProfileFieldIsCA: true,
+	}
+)
+
+// defaultProfiles are seeded as global profiles on start-up
+var defaultProfiles = []CreateCertificateProfileRequest{
+	{
+		Name:          "TLS server",
+		Description:   "Web and API servers",
+		KeyType:       string(openssl.KeyTypeRSA),
+		KeySize:       2048,
+		KeyUsage:      []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage:   []string{"serverAuth"},
+		HashAlgorithm: string(openssl.HashSHA256),
+		ValidDays:     365,
+		MaxValidDays:  398,
+		LockedFields:  []string{models.ProfileFieldKeyUsage, models.ProfileFieldExtKeyUsage, models.ProfileFieldIsCA},
+	},
+	{
+		Name:          "Client auth",
+		Description:   "Clients authenticating with mutual TLS",
+		KeyType:       string(openssl.KeyTypeRSA),
+		KeySize:       2048,
+		KeyUsage:      []string{"digitalSignature"},
+		ExtKeyUsage:   []string{"clientAuth"},
+		HashAlgorithm: string(openssl.HashSHA256),
+		ValidDays:     365,
+		MaxValidDays:  825,
+		LockedFields:  []string{models.ProfileFieldKeyUsage, models.ProfileFieldExtKeyUsage, models.ProfileFieldIsCA},
+	},
+	{
+		Name:          "Code signing",
+		Description:   "Signing software releases",
+		KeyType:       string(openssl.KeyTypeRSA),
+		KeySize:       3072,
+		KeyUsage:      []string{"digitalSignature"},
+		ExtKeyUsage:   []string{"codeSigning"},
+		HashAlgorithm: string(openssl.HashSHA256),
+		ValidDays:     365,
+		MaxValidDays:  1095,
+		LockedFields:  []string{models.ProfileFieldKeyUsage, models.ProfileFieldExtKeyUsage, models.ProfileFieldIsCA},
+	},
+	{
+		Name:          "Intermediate CA",
+		Description:   "Certificate authorities issuing end-entity certificates",
+		KeyType:       string(openssl.KeyTypeRSA),
+		KeySize:       4096,
+		KeyUsage:      []string{"keyCertSign", "cRLSign"},
+		HashAlgorithm: string(openssl.HashSHA384),
+		ValidDays:     1825,
+		MaxValidDays:  3650,
+		IsCA:          true,
+		LockedFields:  []string{models.ProfileFieldKeyUsage, models.ProfileFieldIsCA},
+	},
+}
+
+// profileService implements ProfileService interface
+// Single Responsibility: Handles certificate profiles and applying them to requests
+type profileService struct {
+	profileRepo repository.CertificateProfileRepository
+	orgRepo     repository.OrganizationRepository
+}
+
+// NewProfileService creates a new certificate profile service
+func NewProfileService(
+	profileRepo repository.CertificateProfileRepository,
+	orgRepo repository.OrganizationRepository,
+) ProfileService {
+	return &profileService{
+		profileRepo: profileRepo,
+		orgRepo:     orgRepo,
+	}
+}
+
+func (s *profileService) ListProfiles(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.CertificateProfile, int64, error) {
+	return s.profileRepo.List(ctx, scope.OrganizationID, offset, limit)
+}
+
+func (s *profileService) GetProfile(ctx context.Context, userID, id uint) (*models.CertificateProfile, error) {
+	profile, err := s.profileRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other organizations' profiles are reported as missing
+	if profile.OrganizationID != nil {
+		if _, err := s.orgRepo.GetMember(ctx, *profile.OrganizationID, userID); err != nil {
+			return nil, err
+		}
+	}
+	return profile, nil
+}
+
+func (s *profileService) CreateProfile(ctx context.Context, actor ProfileActor, req CreateCertificateProfileRequest) (*models.CertificateProfile, error) {
+	if err := s.checkProfileAdmin(ctx, actor, req.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	profile := &models.CertificateProfile{
+		OrganizationID: req.OrganizationID,
+		Name:           strings.TrimSpace(req.Name),
+		Description:    strings.TrimSpace(req.Description),
+		KeyType:        req.KeyType,
+		KeySize:        req.KeySize,
+		KeyUsage:       strings.Join(req.KeyUsage, ","),
+		ExtKeyUsage:    strings.Join(req.ExtKeyUsage, ","),
+		HashAlgorithm:  req.HashAlgorithm,
+		ValidDays:      req.ValidDays,
+		MaxValidDays:   req.MaxValidDays,
+		IsCA:           req.IsCA,
+		LockedFields:   strings.Join(req.LockedFields, ","),
+		CreatedBy:      actor.UserID,
+	}
+	if err := validateProfile(profile); err != nil {
+		return nil, err
+	}
+	if err := s.checkNameFree(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	if err := s.profileRepo.Create(ctx, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *profileService) UpdateProfile(ctx context.Context, actor ProfileActor, id uint, req UpdateCertificateProfileRequest) (*models.CertificateProfile, error) {
+	profile, err := s.manageableProfile(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		profile.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		profile.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.KeyType != nil {
+		profile.KeyType = *req.KeyType
+	}
+	if req.KeySize != nil {
+		profile.KeySize = *req.KeySize
+	}
+	if req.KeyUsage != nil {
+		profile.KeyUsage = strings.Join(*req.KeyUsage, ",")
+	}
+	if req.ExtKeyUsage != nil {
+		profile.ExtKeyUsage = strings.Join(*req.ExtKeyUsage, ",")
+	}
+	if req.HashAlgorithm != nil {
+		profile.HashAlgorithm = *req.HashAlgorithm
+	}
+	if req.ValidDays != nil {
+		profile.ValidDays = *req.ValidDays
+	}
+	if req.MaxValidDays != nil {
+		profile.MaxValidDays = *req.MaxValidDays
+	}
+	if req.IsCA != nil {
+		profile.IsCA = *req.IsCA
+	}
+	if req.LockedFields != nil {
+		profile.LockedFields = strings.Join(*req.LockedFields, ",")
+	}
+
+	if err := validateProfile(profile); err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		if err := s.checkNameFree(ctx, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.profileRepo.Update(ctx, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *profileService) DeleteProfile(ctx context.Context, actor ProfileActor, id uint) error {
+	if _, err := s.manageableProfile(ctx, actor, id); err != nil {
+		return err
+	}
+	return s.profileRepo.Delete(ctx, id)
+}
+
+func (s *profileService) ApplyProfile(ctx context.Context, userID, id uint, req *openssl.GenerateCertificateRequest) (*models.CertificateProfile, error) {
+	profile, err := s.GetProfile(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := make(map[string]bool)
+	for _, field := range splitList(profile.LockedFields) {
+		locked[field] = true
+	}
+	conflict := func(field string, set, differs bool) error {
+		if set && differs && locked[field] {
+			return fmt.Errorf("%w: %s is locked by profile %q", ErrProfileConstraint, field, profile.Name)
+		}
+		return nil
+	}
+
+	if err := conflict(models.ProfileFieldKeyType, req.KeyType != "", string(req.KeyType) != profile.KeyType); err != nil {
+		return nil, err
+	}
+	// A key size only belongs with the key type it was chosen for
+	keyTypeChanged := req.KeyType != "" && string(req.KeyType) != profile.KeyType
+	if err := conflict(models.ProfileFieldKeySize, req.KeySize != 0, req.KeySize != profile.KeySize); err != nil {
+		return nil, err
+	}
+	if err := conflict(models.ProfileFieldKeyUsage, req.KeyUsage != nil, !sameList(req.KeyUsage, splitList(profile.KeyUsage))); err != nil {
+		return nil, err
+	}
+	if err := conflict(models.ProfileFieldExtKeyUsage, req.ExtKeyUsage != nil, !sameList(req.ExtKeyUsage, splitList(profile.ExtKeyUsage))); err != nil {
+		return nil, err
+	}
+	if err := conflict(models.ProfileFieldHashAlgorithm, req.HashAlgorithm != "", string(req.HashAlgorithm) != profile.HashAlgorithm); err != nil {
+		return nil, err
+	}
+	if err := conflict(models.ProfileFieldValidDays, req.ValidDays != 0, req.ValidDays != profile.ValidDays); err != nil {
+		return nil, err
+	}
+	if err := conflict(models.ProfileFieldIsCA, req.IsCA, !profile.IsCA); err != nil {
+		return nil, err
+	}
+
+	if req.KeyType == "" {
+		req.KeyType = openssl.KeyType(profile.KeyType)
+	}
+	if req.KeySize == 0 && !keyTypeChanged {
+		req.KeySize = profile.KeySize
+	}
+	if req.KeyUsage == nil {
+		req.KeyUsage = splitList(profile.KeyUsage)
+	}
+	if req.ExtKeyUsage == nil {
+		req.ExtKeyUsage = splitList(profile.ExtKeyUsage)
+	}
+	if req.HashAlgorithm == "" {
+		req.HashAlgorithm = openssl.HashAlgorithm(profile.HashAlgorithm)
+	}
+	if req.ValidDays == 0 {
+		req.ValidDays = profile.ValidDays
+	}
+	req.IsCA = req.IsCA || profile.IsCA
+
+	if profile.MaxValidDays > 0 && req.ValidDays > profile.MaxValidDays {
+		return nil, fmt.Errorf("%w: profile %q allows at most %d days of validity", ErrProfileConstraint, profile.Name, profile.MaxValidDays)
+	}
+	return profile, nil
+}
+
+func (s *profileService) SeedDefaults(ctx context.Context) error {
+	for _, req := range defaultProfiles {
+		_, err := s.profileRepo.GetByName(ctx, nil, req.Name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if _, err := s.CreateProfile(ctx, ProfileActor{PlatformAdmin: true}, req); err != nil {
+			return fmt.Errorf("failed to create profile %q: %w", req.Name, err)
+		}
+	}
+	return nil
+}
+
+// Helper functions
+
+// manageableProfile loads a profile the actor may change. Profiles of
+// organizations the actor is not in are reported as missing.
+func (s *profileService) manageableProfile(ctx context.Context, actor ProfileActor, id uint) (*models.CertificateProfile, error) {
+	profile, err := s.profileRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkProfileAdmin(ctx, actor, profile.OrganizationID)
+	if errors.Is(err, ErrNotOrganizationMember) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *profileService) checkProfileAdmin(ctx context.Context, actor ProfileActor, organizationID *uint) error {
+	if organizationID == nil {
+		if !actor.PlatformAdmin {
+			return ErrPlatformAdminOnly
+		}
+		return nil
+	}
+
+	member, err := s.orgRepo.GetMember(ctx, *organizationID, actor.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotOrganizationMember
+		}
+		return err
+	}
+	if member.Role != models.MemberRoleAdmin && member.Role != models.MemberRoleOwner {
+		return ErrOrganizationAdminOnly
+	}
+	return nil
+}
+
+func (s *profileService) checkNameFree(ctx context.Context, profile *models.CertificateProfile) error {
+	existing, err := s.profileRepo.GetByName(ctx, profile.OrganizationID, profile.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != profile.ID {
+		return ErrCertificateProfileExists
+	}
+	return nil
+}
+
+func validateProfile(profile *models.CertificateProfile) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidCertificateProfile, fmt.Sprintf(format, args...))
+	}
+
+	if profile.Name == "" {
+		return invalid("name is required")
+	}
+	switch openssl.KeyType(profile.KeyType) {
+	case openssl.KeyTypeRSA:
+		if profile.KeySize != 0 && !profileKeySizes[profile.KeySize] {
+			return invalid("RSA key size must be 2048, 3072, 4096 or 8192")
+		}
+	case openssl.KeyTypeEC, openssl.KeyTypeED25519:
+		if profile.KeySize != 0 {
+			return invalid("keySize only applies to RSA keys")
+		}
+	default:
+		return invalid("keyType must be rsa, ec or ed25519")
+	}
+	if profile.HashAlgorithm != "" && !profileHashAlgorithms[profile.HashAlgorithm] {
+		return invalid("hashAlgorithm must be sha256, sha384 or sha512")
+	}
+	if profile.ValidDays <= 0 {
+		return invalid("validDays must be positive")
+	}
+	if profile.MaxValidDays < 0 || (profile.MaxValidDays > 0 && profile.MaxValidDays < profile.ValidDays) {
+		return invalid("maxValidDays must be 0 or at least validDays")
+	}
+
+	for _, usage := range splitList(profile.KeyUsage) {
+		if !profileKeyUsages[usage] {
+			return invalid("unknown key usage %q", usage)
+		}
+	}
+	for _, usage := range splitList(profile.ExtKeyUsage) {
+		if !profileExtKeyUsages[usage] {
+			return invalid("unknown extended key usage %q", usage)
+		}
+	}
+	for _, field := range splitList(profile.LockedFields) {
+		if !profileFields[field] {
+			return invalid("unknown locked field %q", field)
+		}
+	}
+	return nil
+}
+
+func sameList(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
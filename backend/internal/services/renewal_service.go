@@ -0,0 +1,688 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/acme"
+	"web-openssl-backend/pkg/acmeclient"
+	"web-openssl-backend/pkg/netguard"
+	"web-openssl-backend/pkg/notify"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotRenewable         = errors.New("certificate was not issued by the platform CA or obtained via ACME")
+	ErrRenewalPolicyExists  = errors.New("certificate already has a renewal policy")
+	ErrKeyUnavailable       = errors.New("certificate private key is not available in the inventory")
+	ErrInvalidRenewalPolicy = errors.New("invalid renewal policy")
+
+	errCAKeyRequired = fmt.Errorf("%w: the platform CA only renews certificates whose private key is in the inventory", ErrNotRenewable)
+)
+
+const (
+	renewalBatchSize   = 20
+	renewalTimeout     = 10 * time.Minute
+	maxRenewalDays     = 365
+	maxRenewalBackoff  = 24 * time.Hour
+	renewalWebhookWait = 30 * time.Second
+)
+
+// renewalService implements RenewalService interface
+// Single Responsibility: Handles certificate renewal policies and their execution
+type renewalService struct {
+	certRepo    repository.CertificateRepository
+	policyRepo  repository.RenewalPolicyRepository
+	renewalRepo repository.CertificateRenewalRepository
+	userRepo    repository.UserRepository
+	inventory   InventoryService
+	vault       KeyVaultService
+	ca          *acme.Service
+	acmeClient  *acmeclient.Service
+	notifier    *notify.Service
+	webhook     *http.Client
+	defaultDays int
+	hooks       []RenewalHook
+}
+
+// NewRenewalService creates a new renewal service. Policies created without
+// a renewal window renew defaultDays before expiry.
+func NewRenewalService(
+	certRepo repository.CertificateRepository,
+	policyRepo repository.RenewalPolicyRepository,
+	renewalRepo repository.CertificateRenewalRepository,
+	userRepo repository.UserRepository,
+	inventory InventoryService,
+	vault KeyVaultService,
+	ca *acme.Service,
+	acmeClient *acmeclient.Service,
+	notifier *notify.Service,
+	defaultDays int,
+) RenewalService {
+	return &renewalService{
+		certRepo:    certRepo,
+		policyRepo:  policyRepo,
+		renewalRepo: renewalRepo,
+		userRepo:    userRepo,
+		inventory:   inventory,
+		vault:       vault,
+		ca:          ca,
+		acmeClient:  acmeClient,
+		notifier:    notifier,
+		webhook:     netguard.HTTPClient(renewalWebhookWait),
+		defaultDays: defaultDays,
+	}
+}
+
+func (s *renewalService) CreatePolicy(ctx context.Context, scope repository.Scope, req CreateRenewalPolicyRequest) (*models.RenewalPolicy, error) {
+	cert, err := s.certRepo.GetByID(ctx, scope, req.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &models.RenewalPolicy{
+		UserID:           cert.UserID,
+		OrganizationID:   cert.OrganizationID,
+		CertificateID:    cert.ID,
+		DaysBeforeExpiry: req.DaysBeforeExpiry,
+		KeyStrategy:      req.KeyStrategy,
+		ChallengeType:    req.ChallengeType,
+		WebhookURL:       req.WebhookURL,
+	}
+	if policy.DaysBeforeExpiry == 0 {
+		policy.DaysBeforeExpiry = s.defaultDays
+	}
+	if policy.KeyStrategy == "" {
+		policy.KeyStrategy = models.RenewalKeyReuse
+	}
+	if err := s.checkPolicy(ctx, policy, cert); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.policyRepo.GetByCertificateID(ctx, cert.ID)
+	if err == nil {
+		return existing, ErrRenewalPolicyExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if policy.ID, err = s.vault.ReserveID(ctx, models.TableRenewalPolicies); err != nil {
+		return nil, err
+	}
+	if policy.WebhookSecret, err = s.vault.Seal(models.TableRenewalPolicies, policy.ID, req.WebhookSecret); err != nil {
+		return nil, err
+	}
+
+	// A certificate already inside the window is renewed on the next run
+	policy.RenewAt = renewAt(cert.NotAfter, policy.DaysBeforeExpiry, time.Time{})
+	if err := s.policyRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	policy.Certificate = cert
+	return policy, nil
+}
+
+func (s *renewalService) GetPolicy(ctx context.Context, scope repository.Scope, id uint) (*models.RenewalPolicy, error) {
+	return s.policyRepo.GetByID(ctx, scope, id)
+}
+
+func (s *renewalService) ListPolicies(ctx context.Context, scope repository.Scope, offset, limit int) ([]*models.RenewalPolicy, int64, error) {
+	return s.policyRepo.List(ctx, scope, offset, limit)
+}
+
+func (s *renewalService) UpdatePolicy(ctx context.Context, scope repository.Scope, id uint, req UpdateRenewalPolicyRequest) (*models.RenewalPolicy, error) {
+	policy, err := s.policyRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := s.certRepo.GetByID(ctx, scope, policy.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DaysBeforeExpiry != nil {
+		policy.DaysBeforeExpiry = *req.DaysBeforeExpiry
+	}
+	if req.KeyStrategy != nil {
+		policy.KeyStrategy = *req.KeyStrategy
+	}
+	if req.ChallengeType != nil {
+		policy.ChallengeType = *req.ChallengeType
+	}
+	if req.WebhookURL != nil {
+		policy.WebhookURL = *req.WebhookURL
+	}
+	if req.Paused != nil {
+		policy.Paused = *req.Paused
+		// Resuming retries straight away instead of waiting out the backoff
+		if !policy.Paused {
+			policy.NextAttemptAt = nil
+		}
+	}
+	if err := s.checkPolicy(ctx, policy, cert); err != nil {
+		return nil, err
+	}
+	if req.WebhookSecret != nil {
+		if policy.WebhookSecret, err = s.vault.Seal(models.TableRenewalPolicies, policy.ID, *req.WebhookSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	policy.RenewAt = renewAt(cert.NotAfter, policy.DaysBeforeExpiry, time.Time{})
+	if err := s.policyRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	policy.Certificate = cert
+	return policy, nil
+}
+
+func (s *renewalService) DeletePolicy(ctx context.Context, scope repository.Scope, id uint) error {
+	return s.policyRepo.Delete(ctx, scope, id)
+}
+
+func (s *renewalService) RenewNow(ctx context.Context, scope repository.Scope, id uint) (*models.CertificateRenewal, error) {
+	policy, err := s.policyRepo.GetByID(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.renew(ctx, policy)
+}
+
+// RenewDue renews every certificate whose policy has reached its renewal
+// time. Policies are claimed by moving their next attempt forward before
+// renewing, so a slow ACME order is not started twice by overlapping runs.
+func (s *renewalService) RenewDue(ctx context.Context) (*RenewDueResult, error) {
+	result := &RenewDueResult{}
+
+	for {
+		now := time.Now()
+		policies, err := s.policyRepo.ListDue(ctx, now, renewalBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(policies) == 0 {
+			return result, nil
+		}
+
+		claimed := now.Add(renewalTimeout)
+		for _, policy := range policies {
+			policy.NextAttemptAt = &claimed
+			if err := s.policyRepo.Update(ctx, policy); err != nil {
+				return result, err
+			}
+		}
+
+		for _, policy := range policies {
+			renewal, err := s.renew(ctx, policy)
+			if err != nil {
+				return result, err
+			}
+			if renewal.Success {
+				result.Renewed++
+			} else {
+				result.Failed++
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+}
+
+func (s *renewalService) ListRenewals(ctx context.Context, scope repository.Scope, policyID uint, offset, limit int) ([]*models.CertificateRenewal, int64, error) {
+	if _, err := s.policyRepo.GetByID(ctx, scope, policyID); err != nil {
+		return nil, 0, err
+	}
+	return s.renewalRepo.ListByPolicy(ctx, policyID, offset, limit)
+}
+
+// OnRenewed registers a hook run after every successful renewal, e.g. to
+// deploy the new certificate.
+func (s *renewalService) OnRenewed(hook RenewalHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Helper functions
+
+// renewedCertificate is the outcome of a successful issuance
+type renewedCertificate struct {
+	previous   *models.Certificate
+	current    *models.Certificate
+	privateKey string
+	exportable bool
+}
+
+// renew issues a replacement for the policy's certificate, records the
+// attempt and moves the policy on to the new certificate. A failed renewal
+// is recorded and retried with backoff rather than returned as an error;
+// failures that cannot resolve themselves pause the policy.
+func (s *renewalService) renew(ctx context.Context, policy *models.RenewalPolicy) (*models.CertificateRenewal, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, renewalTimeout)
+	renewed, renewErr := s.issue(attemptCtx, policy)
+	cancel()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	now := time.Now()
+	renewal := &models.CertificateRenewal{
+		PolicyID:         policy.ID,
+		OldCertificateID: policy.CertificateID,
+		Success:          renewErr == nil,
+		KeyRotated:       policy.KeyStrategy == models.RenewalKeyRotate,
+	}
+
+	if renewErr != nil {
+		renewal.Error = renewErr.Error()
+		policy.FailureCount++
+		policy.LastError = renewal.Error
+		next := now.Add(renewalBackoff(policy.FailureCount))
+		policy.NextAttemptAt = &next
+		if errors.Is(renewErr, gorm.ErrRecordNotFound) || errors.Is(renewErr, ErrNotRenewable) || errors.Is(renewErr, ErrKeyUnavailable) {
+			policy.Paused = true
+		}
+	} else {
+		renewal.NewCertificateID = &renewed.current.ID
+		if policy.WebhookURL != "" {
+			if err := s.deploy(ctx, policy, renewed); err != nil {
+				renewal.WebhookStatus = "failed"
+				renewal.WebhookError = err.Error()
+			} else {
+				renewal.WebhookStatus = "delivered"
+			}
+		}
+
+		policy.CertificateID = renewed.current.ID
+		policy.Certificate = renewed.current
+		policy.RenewAt = renewAt(renewed.current.NotAfter, policy.DaysBeforeExpiry, now)
+		policy.NextAttemptAt = nil
+		policy.FailureCount = 0
+		policy.LastError = ""
+		policy.LastRenewedAt = &now
+	}
+
+	if err := s.renewalRepo.Create(ctx, renewal); err != nil {
+		return nil, err
+	}
+	if err := s.policyRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	s.notifyOwner(ctx, policy, renewal, renewed)
+
+	if renewal.Success {
+		for _, hook := range s.hooks {
+			hook(ctx, renewed.previous, renewed.current)
+		}
+	}
+	return renewal, nil
+}
+
+// issue obtains the replacement certificate from whichever CA issued the
+// current one and stores it in the inventory, linked to its predecessor.
+func (s *renewalService) issue(ctx context.Context, policy *models.RenewalPolicy) (*renewedCertificate, error) {
+	scope := repository.Scope{UserID: policy.UserID, OrganizationID: policy.OrganizationID}
+	previous, err := s.certRepo.GetByID(ctx, scope, policy.CertificateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	leaf, _, err := parseCertificateBundle(previous.PEM)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, keyPEM, err := s.certificateKey(previous, policy.KeyStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle string
+	var source models.CertificateSource
+	switch {
+	case previous.ACMEAccountID != nil:
+		if previous.ACMEAccount == nil {
+			return nil, fmt.Errorf("%w: ACME account no longer exists", ErrNotRenewable)
+		}
+		accountKey, err := s.vault.Open(models.TableACMEAccounts, previous.ACMEAccount.ID, previous.ACMEAccount.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unlock ACME account key: %w", err)
+		}
+		obtained, err := s.acmeClient.Obtain(ctx, &acmeclient.Order{
+			DirectoryURL:  previous.ACMEAccount.DirectoryURL,
+			AccountKey:    accountKey,
+			Domains:       leaf.DNSNames,
+			ChallengeType: policy.ChallengeType,
+			PrivateKey:    keyPEM,
+		})
+		if err != nil {
+			return nil, err
+		}
+		bundle = obtained.Certificate + obtained.Chain
+		source = models.SourceACME
+	case s.ca.Issued(leaf):
+		if previous.KeyPairID == nil {
+			return nil, errCAKeyRequired
+		}
+		csrPEM, err := renewalCSR(leaf, signer)
+		if err != nil {
+			return nil, err
+		}
+		signed, err := s.ca.Issue(csrPEM, nil)
+		if err != nil {
+			return nil, err
+		}
+		bundle = signed.Certificate + signed.Chain
+		source = models.SourceSigned
+	default:
+		return nil, ErrNotRenewable
+	}
+
+	renewed := &renewedCertificate{
+		previous:   previous,
+		privateKey: keyPEM,
+		exportable: previous.KeyPair != nil && previous.KeyPair.Exportable,
+	}
+
+	// A reused key is already in the inventory and is linked by fingerprint
+	req := ImportCertificateRequest{
+		Name:          previous.Name,
+		Source:        source,
+		Certificate:   bundle,
+		Exportable:    renewed.exportable,
+		ACMEAccountID: previous.ACMEAccountID,
+	}
+	if policy.KeyStrategy == models.RenewalKeyRotate {
+		req.PrivateKey = keyPEM
+	}
+	current, err := s.inventory.ImportCertificate(ctx, scope, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store renewed certificate: %w", err)
+	}
+
+	current.ReplacesCertificateID = &previous.ID
+	if err := s.certRepo.Update(ctx, current); err != nil {
+		return nil, err
+	}
+
+	renewed.current = current
+	return renewed, nil
+}
+
+// certificateKey returns the key to certify: the current certificate's key
+// from the vault, or a fresh key of the same type and size.
+func (s *renewalService) certificateKey(cert *models.Certificate, strategy string) (crypto.Signer, string, error) {
+	if strategy == models.RenewalKeyRotate {
+		return generateKeyLike(cert.KeyAlgorithm, cert.KeySize)
+	}
+
+	if cert.KeyPair == nil {
+		return nil, "", ErrKeyUnavailable
+	}
+	keyPEM, err := s.vault.Open(models.TableKeyPairs, cert.KeyPair.ID, cert.KeyPair.PrivateKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrKeyUnavailable, err)
+	}
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, "", ErrKeyUnavailable
+	}
+	signer, err := parsePrivateKey(block)
+	if err != nil {
+		// Encrypted keys cannot be used without their passphrase
+		return nil, "", fmt.Errorf("%w: %v", ErrKeyUnavailable, err)
+	}
+	return signer, keyPEM, nil
+}
+
+// deploy posts the new certificate to the policy's webhook. The private key
+// is only included when the key may be exported.
+func (s *renewalService) deploy(ctx context.Context, policy *models.RenewalPolicy, renewed *renewedCertificate) error {
+	leaf, _, err := parseCertificateBundle(renewed.current.PEM)
+	if err != nil {
+		return err
+	}
+
+	payload := renewalWebhookPayload{
+		Event:                 "certificate.renewed",
+		PolicyID:              policy.ID,
+		CertificateID:         renewed.current.ID,
+		ReplacesCertificateID: renewed.previous.ID,
+		Name:                  renewed.current.Name,
+		CommonName:            renewed.current.CommonName,
+		SANs:                  subjectAltNames(leaf),
+		NotBefore:             renewed.current.NotBefore,
+		NotAfter:              renewed.current.NotAfter,
+		Certificate:           renewed.current.PEM,
+		Chain:                 renewed.current.Chain,
+		Time:                  time.Now().UTC(),
+	}
+	if renewed.exportable {
+		payload.PrivateKey = renewed.privateKey
+	}
+	// The key may have been made exportable after the URL was saved
+	if err := netguard.CheckURL(ctx, policy.WebhookURL, payload.PrivateKey != ""); err != nil {
+		return err
+	}
+	secret, err := s.vault.Open(models.TableRenewalPolicies, policy.ID, policy.WebhookSecret)
+	if err != nil {
+		return fmt.Errorf("failed to unlock webhook secret: %w", err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return notify.PostSigned(ctx, s.webhook, policy.WebhookURL, secret, body)
+}
+
+func (s *renewalService) notifyOwner(ctx context.Context, policy *models.RenewalPolicy, renewal *models.CertificateRenewal, renewed *renewedCertificate) {
+	var notification *notify.Notification
+	if renewal.Success {
+		key := "reused"
+		if renewal.KeyRotated {
+			key = "rotated"
+		}
+		notification = &notify.Notification{
+			Event:    "certificate.renewed",
+			Severity: notify.SeverityInfo,
+			Subject:  fmt.Sprintf("Certificate %s renewed", renewed.current.Name),
+			Text: fmt.Sprintf("A new certificate for %s was issued, valid until %s.",
+				renewed.current.CommonName, renewed.current.NotAfter.UTC().Format("2006-01-02")),
+			Fields: map[string]string{
+				"Certificate": strconv.FormatUint(uint64(renewed.current.ID), 10),
+				"Replaces":    strconv.FormatUint(uint64(renewed.previous.ID), 10),
+				"Key":         key,
+			},
+		}
+		if renewal.WebhookStatus != "" {
+			notification.Fields["Deployment webhook"] = renewal.WebhookStatus
+		}
+		if renewal.WebhookError != "" {
+			notification.Severity = notify.SeverityWarning
+			notification.Fields["Webhook error"] = renewal.WebhookError
+		}
+	} else {
+		notification = &notify.Notification{
+			Event:    "certificate.renewal_failed",
+			Severity: notify.SeverityWarning,
+			Subject:  fmt.Sprintf("Renewal of certificate %d failed", policy.CertificateID),
+			Text:     renewal.Error,
+			Fields: map[string]string{
+				"Policy":   strconv.FormatUint(uint64(policy.ID), 10),
+				"Attempts": strconv.Itoa(policy.FailureCount),
+			},
+		}
+		if policy.Paused {
+			notification.Fields["Next attempt"] = "paused until the policy is resumed"
+		} else if policy.NextAttemptAt != nil {
+			notification.Fields["Next attempt"] = policy.NextAttemptAt.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if user, err := s.userRepo.GetByID(ctx, policy.UserID); err == nil && user.Email != "" {
+		notification.Recipients = []string{user.Email}
+	}
+
+	if _, err := s.notifier.Send(ctx, notification); err != nil {
+		log.Printf("Failed to send renewal notification for policy %d: %v", policy.ID, err)
+	}
+}
+
+// checkPolicy validates policy settings against the certificate they apply
+// to. A webhook that is sent the private key must use https.
+func (s *renewalService) checkPolicy(ctx context.Context, policy *models.RenewalPolicy, cert *models.Certificate) error {
+	if policy.DaysBeforeExpiry < 1 || policy.DaysBeforeExpiry > maxRenewalDays {
+		return fmt.Errorf("%w: days before expiry must be between 1 and %d", ErrInvalidRenewalPolicy, maxRenewalDays)
+	}
+	if policy.KeyStrategy != models.RenewalKeyReuse && policy.KeyStrategy != models.RenewalKeyRotate {
+		return fmt.Errorf("%w: key strategy must be %q or %q", ErrInvalidRenewalPolicy, models.RenewalKeyReuse, models.RenewalKeyRotate)
+	}
+	switch policy.ChallengeType {
+	case "", acmeclient.ChallengeHTTP01, acmeclient.ChallengeDNS01:
+	default:
+		return fmt.Errorf("%w: unsupported challenge type %q", ErrInvalidRenewalPolicy, policy.ChallengeType)
+	}
+	if policy.WebhookURL != "" {
+		exportable := cert.KeyPair != nil && cert.KeyPair.Exportable
+		if err := netguard.CheckURL(ctx, policy.WebhookURL, exportable); err != nil {
+			return fmt.Errorf("%w: webhook URL: %v", ErrInvalidRenewalPolicy, err)
+		}
+	}
+
+	if cert.IsCA {
+		return ErrNotRenewable
+	}
+	if cert.ACMEAccountID == nil {
+		leaf, _, err := parseCertificateBundle(cert.PEM)
+		if err != nil {
+			return err
+		}
+		if !s.ca.Issued(leaf) {
+			return ErrNotRenewable
+		}
+		// Anyone can import a certificate the platform CA issued to someone
+		// else; holding its key is what shows it was issued to this scope
+		if cert.KeyPairID == nil {
+			return errCAKeyRequired
+		}
+	}
+	if policy.KeyStrategy == models.RenewalKeyReuse && cert.KeyPairID == nil {
+		return ErrKeyUnavailable
+	}
+	return nil
+}
+
+type renewalWebhookPayload struct {
+	Event                 string    `json:"event"`
+	PolicyID              uint      `json:"policyId"`
+	CertificateID         uint      `json:"certificateId"`
+	ReplacesCertificateID uint      `json:"replacesCertificateId"`
+	Name                  string    `json:"name"`
+	CommonName            string    `json:"commonName"`
+	SANs                  []string  `json:"sans"`
+	NotBefore             time.Time `json:"notBefore"`
+	NotAfter              time.Time `json:"notAfter"`
+	Certificate           string    `json:"certificate"`
+	Chain                 string    `json:"chain,omitempty"`
+	PrivateKey            string    `json:"privateKey,omitempty"`
+	Time                  time.Time `json:"time"`
+}
+
+// renewAt is daysBefore days ahead of notAfter. When a certificate's whole
+// lifetime after now is shorter than that, renewal is held back to halfway
+// through the remaining lifetime so a short-lived CA does not cause a
+// renewal on every run.
+func renewAt(notAfter time.Time, daysBefore int, now time.Time) time.Time {
+	at := notAfter.AddDate(0, 0, -daysBefore)
+	if !now.IsZero() && !at.After(now) {
+		at = now.Add(notAfter.Sub(now) / 2)
+	}
+	return at
+}
+
+// renewalBackoff doubles the wait after each consecutive failure, starting
+// at an hour
+func renewalBackoff(failures int) time.Duration {
+	if failures > 5 {
+		return maxRenewalBackoff
+	}
+	backoff := time.Hour << (failures - 1)
+	if backoff > maxRenewalBackoff {
+		return maxRenewalBackoff
+	}
+	return backoff
+}
+
+// renewalCSR builds a CSR for key carrying the subject and alternative names
+// of the certificate being renewed
+func renewalCSR(leaf *x509.Certificate, key crypto.Signer) (string, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		RawSubject:     leaf.RawSubject,
+		DNSNames:       leaf.DNSNames,
+		IPAddresses:    leaf.IPAddresses,
+		EmailAddresses: leaf.EmailAddresses,
+		URIs:           leaf.URIs,
+	}, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create CSR: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
+
+// generateKeyLike creates a private key of the algorithm and size reported
+// by publicKeyInfo
+func generateKeyLike(algorithm string, size int) (crypto.Signer, string, error) {
+	var key crypto.Signer
+	var err error
+
+	switch algorithm {
+	case "rsa":
+		if size < 2048 {
+			size = 2048
+		}
+		key, err = rsa.GenerateKey(rand.Reader, size)
+	case "ec":
+		switch size {
+		case 384:
+			key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		case 521:
+			key, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		default:
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, "", fmt.Errorf("cannot generate a %s key", algorithm)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
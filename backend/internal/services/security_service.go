@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/mail"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLoginThrottled     = errors.New("too many failed login attempts; try again later")
+	ErrAccountLocked      = errors.New("account is locked after too many failed login attempts; check your email to unlock it")
+	ErrInvalidUnlockToken = errors.New("unlock link is invalid or has expired")
+)
+
+// Reasons a login failed, as recorded in security events
+const (
+	LoginFailureUnknownAccount      = "unknown_account"
+	LoginFailureInvalidPassword     = "invalid_password"
+	LoginFailureAccountDisabled     = "account_disabled"
+	LoginFailureInvalidSecondFactor = "invalid_second_factor"
+	LoginFailureInvalidPasskey      = "invalid_passkey"
+	LoginFailureServerError         = "server_error"
+
+	loginBlockedThrottled = "throttled"
+	loginBlockedLocked    = "locked"
+)
+
+const (
+	defaultBackoffAfter     = 3
+	defaultIPBackoffAfter   = 20
+	defaultBackoffBase      = time.Second
+	defaultBackoffMax       = 15 * time.Minute
+	defaultLockoutThreshold = 10
+	defaultLockoutDuration  = 30 * time.Minute
+	defaultFailureWindow    = 24 * time.Hour
+	defaultEventRetention   = 90 * 24 * time.Hour
+
+	accountUnlockPurpose = "account-unlock"
+	accountUnlockTTL     = 24 * time.Hour
+
+	throttleEmailPrefix = "email:"
+	throttleIPPrefix    = "ip:"
+	// IPv6 clients are usually given a whole /64, so it counts as one address
+	ipv6ThrottleBits = 64
+)
+
+// securityService implements SecurityService interface
+// Single Responsibility: Handles login throttling, account lockout and the
+// security event log
+type securityService struct {
+	securityRepo repository.SecurityRepository
+	userRepo     repository.UserRepository
+	authService  *auth.Service
+	mailer       *mail.Service
+	appURL       string
+	policy       LoginPolicy
+}
+
+// NewSecurityService creates a new security service. Unlock links point to
+// appURL, the address of the web application. Zero values in policy take
+// the defaults.
+func NewSecurityService(
+	securityRepo repository.SecurityRepository,
+	userRepo repository.UserRepository,
+	authService *auth.Service,
+	mailer *mail.Service,
+	appURL string,
+	policy LoginPolicy,
+) SecurityService {
+	if policy.BackoffAfter <= 0 {
+		policy.BackoffAfter = defaultBackoffAfter
+	}
+	if policy.IPBackoffAfter <= 0 {
+		policy.IPBackoffAfter = defaultIPBackoffAfter
+	}
+	if policy.BackoffBase <= 0 {
+		policy.BackoffBase = defaultBackoffBase
+	}
+	if policy.BackoffMax <= 0 {
+		policy.BackoffMax = defaultBackoffMax
+	}
+	if policy.LockoutThreshold <= 0 {
+		policy.LockoutThreshold = defaultLockoutThreshold
+	}
+	if policy.LockoutDuration <= 0 {
+		policy.LockoutDuration = defaultLockoutDuration
+	}
+	if policy.FailureWindow <= 0 {
+		policy.FailureWindow = defaultFailureWindow
+	}
+	if policy.EventRetention <= 0 {
+		policy.EventRetention = defaultEventRetention
+	}
+
+	return &securityService{
+		securityRepo: securityRepo,
+		userRepo:     userRepo,
+		authService:  authService,
+		mailer:       mailer,
+		appURL:       strings.TrimRight(appURL, "/"),
+		policy:       policy,
+	}
+}
+
+func (s *securityService) CheckLogin(ctx context.Context, attempt *LoginAttempt) (time.Duration, error) {
+	// Counters are always locked in the same order, email address first
+	targets := []string{ipTarget(attempt.IPAddress)}
+	if email := normalizeEmail(attempt.Email); email != "" {
+		targets = append([]string{throttleEmailPrefix + email}, targets...)
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	var blocked error
+	err := s.securityRepo.UpdateThrottles(ctx, targets, func(throttles []*models.LoginThrottle) error {
+		for _, throttle := range throttles {
+			if throttle.Failures > 0 && now.Sub(throttle.LastFailureAt) > s.policy.FailureWindow {
+				throttle.Failures = 0
+				throttle.LockedAt = nil
+			}
+			until, err := s.blockedUntil(throttle)
+			if until.After(now) && until.Sub(now) > wait {
+				wait, blocked = until.Sub(now), err
+			}
+		}
+		if blocked != nil {
+			return blocked
+		}
+
+		for _, throttle := range throttles {
+			throttle.Failures++
+			throttle.LastFailureAt = now
+		}
+		return nil
+	})
+	if blocked != nil {
+		reason := loginBlockedThrottled
+		if errors.Is(blocked, ErrAccountLocked) {
+			reason = loginBlockedLocked
+		}
+		s.record(ctx, attempt, s.findUser(ctx, attempt.Email), models.SecurityEventLoginBlocked, reason, nil)
+		// Whole seconds, for the Retry-After header
+		return (wait + time.Second - 1).Truncate(time.Second), blocked
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	attempt.counted = true
+	return 0, nil
+}
+
+func (s *securityService) LoginFailed(ctx context.Context, attempt *LoginAttempt, reason string) error {
+	attempt.settled = true
+	user := s.findUser(ctx, attempt.Email)
+	s.record(ctx, attempt, user, models.SecurityEventLoginFailed, reason, nil)
+	if user == nil || !attempt.counted {
+		return nil
+	}
+
+	target := throttleEmailPrefix + normalizeEmail(attempt.Email)
+	throttle, err := s.securityRepo.GetThrottle(ctx, target)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if throttle.Failures < s.policy.LockoutThreshold {
+		return nil
+	}
+
+	// Of several failures racing past the threshold, one emails the owner
+	now := time.Now()
+	err = s.securityRepo.MarkLocked(ctx, target, now, now.Add(-s.policy.LockoutDuration))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.record(ctx, attempt, user, models.SecurityEventAccountLocked, "", nil)
+	log.Printf("Locked user %d after %d failed logins", user.ID, throttle.Failures)
+
+	token, err := s.authService.GeneratePurposeToken(accountUnlockPurpose, user, accountUnlockTTL)
+	if err != nil {
+		return err
+	}
+	link := s.appURL + "/unlock-account?token=" + url.QueryEscape(token)
+	sendUserMail(s.mailer, user, &mail.Message{
+		Subject: "Your account was locked",
+		Text: fmt.Sprintf("Signing in to your account %s failed %d times, the last time from %s, so it is locked until %s.\n\n"+
+			"If it was you, open this link within %s to unlock it now:\n%s\n\n"+
+			"If it was not you, someone may be guessing your password. Consider changing it and turning on two-factor authentication.",
+			user.Email, throttle.Failures, attempt.IPAddress,
+			now.Add(s.policy.LockoutDuration).UTC().Format("2006-01-02 15:04 MST"),
+			formatTTL(accountUnlockTTL), link),
+	})
+	return nil
+}
+
+func (s *securityService) LoginPassed(ctx context.Context, attempt *LoginAttempt, user *models.User, methods []string) error {
+	attempt.settled = true
+	eventType := models.SecurityEventLoginSucceeded
+	if methods == nil {
+		eventType = models.SecurityEventSecondFactorRequired
+	}
+	s.record(ctx, attempt, user, eventType, "", methods)
+
+	if attempt.counted {
+		if err := s.securityRepo.ForgiveFailure(ctx, ipTarget(attempt.IPAddress)); err != nil {
+			return err
+		}
+		attempt.counted = false
+	}
+	// A correct password alone does not clear the count, or whoever knows it
+	// could keep guessing the second factor
+	if methods == nil {
+		return nil
+	}
+	return s.securityRepo.ResetThrottle(ctx, throttleEmailPrefix+normalizeEmail(user.Email))
+}
+
+func (s *securityService) LoginAborted(ctx context.Context, attempt *LoginAttempt) error {
+	if attempt.settled {
+		return nil
+	}
+	attempt.settled = true
+	s.record(ctx, attempt, s.findUser(ctx, attempt.Email), models.SecurityEventLoginFailed, LoginFailureServerError, nil)
+	if !attempt.counted {
+		return nil
+	}
+
+	attempt.counted = false
+	if err := s.securityRepo.ForgiveFailure(ctx, ipTarget(attempt.IPAddress)); err != nil {
+		return err
+	}
+	if email := normalizeEmail(attempt.Email); email != "" {
+		return s.securityRepo.ForgiveFailure(ctx, throttleEmailPrefix+email)
+	}
+	return nil
+}
+
+func (s *securityService) UnlockAccount(ctx context.Context, token, ipAddress, userAgent string) error {
+	claims, err := s.authService.ValidatePurposeToken(accountUnlockPurpose, strings.TrimSpace(token))
+	if err != nil {
+		return ErrInvalidUnlockToken
+	}
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidUnlockToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.securityRepo.ResetThrottle(ctx, throttleEmailPrefix+normalizeEmail(user.Email)); err != nil {
+		return err
+	}
+	s.record(ctx, &LoginAttempt{Email: user.Email, IPAddress: ipAddress, UserAgent: userAgent}, user, models.SecurityEventAccountUnlocked, "", nil)
+	return nil
+}
+
+func (s *securityService) ListEvents(ctx context.Context, userID uint, offset, limit int) ([]*models.SecurityEvent, int64, error) {
+	return s.securityRepo.ListEvents(ctx, userID, offset, limit)
+}
+
+func (s *securityService) Purge(ctx context.Context) (int64, error) {
+	now := time.Now()
+	return s.securityRepo.Purge(ctx, now.Add(-s.policy.FailureWindow), now.Add(-s.policy.EventRetention))
+}
+
+func (s *securityService) Check() error {
+	if s.policy.BackoffMax < s.policy.BackoffBase {
+		return fmt.Errorf("maximum login backoff %s is shorter than its base %s", s.policy.BackoffMax, s.policy.BackoffBase)
+	}
+	if s.policy.FailureWindow < s.policy.LockoutDuration {
+		return fmt.Errorf("failed logins are forgotten after %s, before the %s lockout ends", s.policy.FailureWindow, s.policy.LockoutDuration)
+	}
+	return nil
+}
+
+// Helper functions
+
+// blockedUntil returns when a counter next lets an attempt through, and
+// the error for attempts before then. Only accounts lock; IP addresses,
+// which many users may share, only back off.
+func (s *securityService) blockedUntil(throttle *models.LoginThrottle) (time.Time, error) {
+	if !strings.HasPrefix(throttle.Target, throttleEmailPrefix) {
+		return throttle.LastFailureAt.Add(s.backoff(throttle.Failures, s.policy.IPBackoffAfter)), ErrLoginThrottled
+	}
+	if throttle.Failures >= s.policy.LockoutThreshold {
+		return throttle.LastFailureAt.Add(s.policy.LockoutDuration), ErrAccountLocked
+	}
+	return throttle.LastFailureAt.Add(s.backoff(throttle.Failures, s.policy.BackoffAfter)), ErrLoginThrottled
+}
+
+// backoff doubles the wait with every failure from the after'th on.
+func (s *securityService) backoff(failures, after int) time.Duration {
+	if failures < after {
+		return 0
+	}
+	wait := s.policy.BackoffBase
+	for i := after; i < failures && wait < s.policy.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > s.policy.BackoffMax {
+		return s.policy.BackoffMax
+	}
+	return wait
+}
+
+// findUser returns the user with the address, or nil.
+func (s *securityService) findUser(ctx context.Context, email string) *models.User {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// record adds to the security event log. A failure to record does not fail
+// the login.
+func (s *securityService) record(ctx context.Context, attempt *LoginAttempt, user *models.User, eventType, reason string, methods []string) {
+	event := &models.SecurityEvent{
+		Type:        eventType,
+		Reason:      reason,
+		AuthMethods: strings.Join(methods, ","),
+		Email:       strings.TrimSpace(attempt.Email),
+		IPAddress:   attempt.IPAddress,
+		UserAgent:   attempt.UserAgent,
+	}
+	if user != nil {
+		event.UserID = user.ID
+		event.Email = user.Email
+	}
+	if err := s.securityRepo.CreateEvent(ctx, event); err != nil {
+		log.Printf("Failed to record %s security event: %v", eventType, err)
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipTarget names the counter of an IP address, or of the /64 an IPv6
+// address is in.
+func ipTarget(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip != nil && ip.To4() == nil {
+		ip = ip.Mask(net.CIDRMask(ipv6ThrottleBits, 128))
+		return fmt.Sprintf("%s%s/%d", throttleIPPrefix, ip, ipv6ThrottleBits)
+	}
+	return throttleIPPrefix + ipAddress
+}
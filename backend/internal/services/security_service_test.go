@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/mail"
+
+	"gorm.io/gorm"
+)
+
+var unlockLinkPattern = regexp.MustCompile(`unlock-account\?token=(\S+)`)
+
+// memorySecurityRepo holds the counters by target and saves what
+// UpdateThrottles changed only when its update succeeds, like the
+// transaction of the real repository
+type memorySecurityRepo struct {
+	repository.SecurityRepository
+
+	mu        sync.Mutex
+	throttles map[string]*models.LoginThrottle
+	events    []*models.SecurityEvent
+}
+
+func (r *memorySecurityRepo) UpdateThrottles(ctx context.Context, targets []string, update func(throttles []*models.LoginThrottle) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	throttles := make([]*models.LoginThrottle, len(targets))
+	for i, target := range targets {
+		throttle := models.LoginThrottle{Target: target}
+		if stored, ok := r.throttles[target]; ok {
+			throttle = *stored
+		}
+		throttles[i] = &throttle
+	}
+	if err := update(throttles); err != nil {
+		return err
+	}
+	for _, throttle := range throttles {
+		r.throttles[throttle.Target] = throttle
+	}
+	return nil
+}
+
+func (r *memorySecurityRepo) GetThrottle(ctx context.Context, target string) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	throttle, ok := r.throttles[target]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *memorySecurityRepo) ForgiveFailure(ctx context.Context, target string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if throttle, ok := r.throttles[target]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+	}
+	return nil
+}
+
+func (r *memorySecurityRepo) ResetThrottle(ctx context.Context, target string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.throttles, target)
+	return nil
+}
+
+func (r *memorySecurityRepo) MarkLocked(ctx context.Context, target string, at, lockedSince time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	throttle, ok := r.throttles[target]
+	if !ok || (throttle.LockedAt != nil && !throttle.LockedAt.Before(lockedSince)) {
+		return gorm.ErrRecordNotFound
+	}
+	throttle.LockedAt = &at
+	return nil
+}
+
+func (r *memorySecurityRepo) CreateEvent(ctx context.Context, event *models.SecurityEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *event
+	r.events = append(r.events, &copied)
+	return nil
+}
+
+// failures returns the count of a target
+func (r *memorySecurityRepo) failures(target string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if throttle, ok := r.throttles[target]; ok {
+		return throttle.Failures
+	}
+	return 0
+}
+
+// age moves the last failure of a target back by d, as if d had passed
+func (r *memorySecurityRepo) age(target string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.throttles[target].LastFailureAt = r.throttles[target].LastFailureAt.Add(-d)
+}
+
+// countEvents returns how many events of a type were recorded
+func (r *memorySecurityRepo) countEvents(eventType, reason string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, event := range r.events {
+		if event.Type == eventType && event.Reason == reason {
+			count++
+		}
+	}
+	return count
+}
+
+type securityTest struct {
+	service SecurityService
+	repo    *memorySecurityRepo
+	mail    *mail.FakeSender
+}
+
+func newSecurityTest(t *testing.T, policy LoginPolicy) *securityTest {
+	t.Helper()
+	users := &memoryUserRepo{users: map[uint]*models.User{
+		1: {ID: 1, Email: "user@example.com", IsActive: true},
+		2: {ID: 2, Email: "other@example.com", IsActive: true},
+	}}
+	authService := auth.NewService(auth.Config{Secret: "test-secret", ExpiresIn: time.Hour})
+	sender := mail.NewFakeSender()
+	mailer := mail.NewService(mail.Config{From: "noreply@example.com"})
+	mailer.SetSender(sender)
+	repo := &memorySecurityRepo{throttles: map[string]*models.LoginThrottle{}}
+
+	return &securityTest{
+		service: NewSecurityService(repo, users, authService, mailer, "https://app.example.com/", policy),
+		repo:    repo,
+		mail:    sender,
+	}
+}
+
+// fail makes a login attempt with a wrong password, which must be let
+// through to be checked
+func (s *securityTest) fail(t *testing.T, email, ipAddress string) {
+	t.Helper()
+	attempt := &LoginAttempt{Email: email, IPAddress: ipAddress}
+	if _, err := s.service.CheckLogin(context.Background(), attempt); err != nil {
+		t.Fatalf("attempt at %s from %s was refused: %v", email, ipAddress, err)
+	}
+	if err := s.service.LoginFailed(context.Background(), attempt, LoginFailureInvalidPassword); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// check returns how long an attempt has to wait and why
+func (s *securityTest) check(email, ipAddress string) (time.Duration, error) {
+	return s.service.CheckLogin(context.Background(), &LoginAttempt{Email: email, IPAddress: ipAddress})
+}
+
+func TestLoginBackoffPerEmail(t *testing.T) {
+	s := newSecurityTest(t, LoginPolicy{BackoffAfter: 3, BackoffBase: time.Minute, BackoffMax: time.Hour})
+
+	// Each guess comes from another address, so only the account counts
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		s.fail(t, "user@example.com", ip)
+	}
+
+	wait, err := s.check("User@Example.com", "192.0.2.4")
+	if !errors.Is(err, ErrLoginThrottled) || wait != time.Minute {
+		t.Fatalf("fourth attempt = %s, %v; want to wait a minute", wait, err)
+	}
+	if _, err := s.check("other@example.com", "192.0.2.4"); err != nil {
+		t.Errorf("another account was throttled: %v", err)
+	}
+
+	// Refused attempts are not counted, so the wait does not grow with them
+	if failures := s.repo.failures("email:user@example.com"); failures != 3 {
+		t.Errorf("failures = %d, want 3", failures)
+	}
+
+	s.repo.age("email:user@example.com", time.Minute)
+	s.fail(t, "user@example.com", "192.0.2.5")
+	if wait, err := s.check("user@example.com", "192.0.2.6"); !errors.Is(err, ErrLoginThrottled) || wait != 2*time.Minute {
+		t.Errorf("fifth attempt = %s, %v; want to wait two minutes", wait, err)
+	}
+}
+
+func TestLoginBackoffPerIPv6Prefix(t *testing.T) {
+	s := newSecurityTest(t, LoginPolicy{IPBackoffAfter: 3, BackoffBase: time.Minute, BackoffMax: time.Hour})
+
+	// Guesses at accounts that do not exist, from one /64
+	for i, ip := range []string{"2001:db8:1:1::1", "2001:db8:1:1::2", "2001:db8:1:1:ffff::3"} {
+		s.fail(t, []string{"a@example.com", "b@example.com", "c@example.com"}[i], ip)
+	}
+
+	if _, err := s.check("d@example.com", "2001:db8:1:1:abcd::9"); !errors.Is(err, ErrLoginThrottled) {
+		t.Errorf("address in the same /64 error = %v, want %v", err, ErrLoginThrottled)
+	}
+	if _, err := s.check("d@example.com", "2001:db8:1:2::1"); err != nil {
+		t.Errorf("address in another /64 was throttled: %v", err)
+	}
+	if failures := s.repo.failures("ip:2001:db8:1:1::/64"); failures != 3 {
+		t.Errorf("failures of the /64 = %d, want 3", failures)
+	}
+	// An IP address only backs off; it never locks accounts
+	if _, err := s.check("user@example.com", "192.0.2.1"); err != nil {
+		t.Errorf("IPv4 address was throttled: %v", err)
+	}
+}
+
+func TestAccountLockoutAndUnlock(t *testing.T) {
+	s := newSecurityTest(t, LoginPolicy{BackoffAfter: 100, LockoutThreshold: 5, LockoutDuration: time.Hour})
+	for i := 0; i < 5; i++ {
+		s.fail(t, "user@example.com", "198.51.100.7")
+	}
+
+	wait, err := s.check("user@example.com", "192.0.2.1")
+	if !errors.Is(err, ErrAccountLocked) || wait != time.Hour {
+		t.Fatalf("attempt at a locked account = %s, %v; want to wait an hour", wait, err)
+	}
+	if count := s.repo.countEvents(models.SecurityEventAccountLocked, ""); count != 1 {
+		t.Errorf("%d lock events, want 1", count)
+	}
+
+	// The owner is sent one link, which unlocks the account at once
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.mail.Sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := s.mail.Sent()
+	if len(sent) != 1 || sent[0].To[0] != "user@example.com" {
+		t.Fatalf("sent %v", sent)
+	}
+	match := unlockLinkPattern.FindStringSubmatch(sent[0].Text)
+	if match == nil {
+		t.Fatalf("no unlock link in %q", sent[0].Text)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.service.UnlockAccount(context.Background(), token+"x", "192.0.2.1", "test"); !errors.Is(err, ErrInvalidUnlockToken) {
+		t.Errorf("altered unlock token error = %v, want %v", err, ErrInvalidUnlockToken)
+	}
+	if err := s.service.UnlockAccount(context.Background(), token, "192.0.2.1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.check("user@example.com", "192.0.2.1"); err != nil {
+		t.Errorf("unlocked account was refused: %v", err)
+	}
+}
+
+func TestAccountLockoutExpires(t *testing.T) {
+	s := newSecurityTest(t, LoginPolicy{BackoffAfter: 100, LockoutThreshold: 3, LockoutDuration: time.Hour})
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		s.fail(t, "user@example.com", ip)
+	}
+	if _, err := s.check("user@example.com", "192.0.2.4"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	s.repo.age("email:user@example.com", time.Hour)
+	if _, err := s.check("user@example.com", "192.0.2.4"); err != nil {
+		t.Errorf("account was still locked after the lockout: %v", err)
+	}
+}
+
+func TestAbortedLoginIsTakenBack(t *testing.T) {
+	s := newSecurityTest(t, LoginPolicy{})
+	ctx := context.Background()
+
+	aborted := &LoginAttempt{Email: "user@example.com", IPAddress: "192.0.2.1"}
+	if _, err := s.service.CheckLogin(ctx, aborted); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.service.LoginAborted(ctx, aborted); err != nil {
+		t.Fatal(err)
+	}
+	if s.repo.failures("email:user@example.com") != 0 || s.repo.failures("ip:192.0.2.1") != 0 {
+		t.Error("failure of an aborted attempt was kept")
+	}
+	if count := s.repo.countEvents(models.SecurityEventLoginFailed, LoginFailureServerError); count != 1 {
+		t.Errorf("%d server error events, want 1", count)
+	}
+
+	// Once settled, an attempt is not taken back
+	failed := &LoginAttempt{Email: "user@example.com", IPAddress: "192.0.2.1"}
+	if _, err := s.service.CheckLogin(ctx, failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.service.LoginFailed(ctx, failed, LoginFailureInvalidPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.service.LoginAborted(ctx, failed); err != nil {
+		t.Fatal(err)
+	}
+	if failures := s.repo.failures("email:user@example.com"); failures != 1 {
+		t.Errorf("failures = %d, want 1", failures)
+	}
+}
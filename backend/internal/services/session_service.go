@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been signed out")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+const defaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+// sessionService implements SessionService interface
+// Single Responsibility: Issues, rotates and revokes the tokens of login sessions
+type sessionService struct {
+	userRepo    repository.UserRepository
+	refreshRepo repository.RefreshTokenRepository
+	sessionRepo repository.SessionRepository
+	authService *auth.Service
+	refreshTTL  time.Duration
+}
+
+// NewSessionService creates a new session service. A refresh token expires
+// after refreshTTL unless it is rotated before.
+func NewSessionService(
+	userRepo repository.UserRepository,
+	refreshRepo repository.RefreshTokenRepository,
+	sessionRepo repository.SessionRepository,
+	authService *auth.Service,
+	refreshTTL time.Duration,
+) SessionService {
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTokenTTL
+	}
+
+	return &sessionService{
+		userRepo:    userRepo,
+		refreshRepo: refreshRepo,
+		sessionRepo: sessionRepo,
+		authService: authService,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+func (s *sessionService) StartSession(ctx context.Context, user *models.User, methods []string, ipAddress, userAgent string) (*TokenPair, error) {
+	familyID, err := newFamilyID()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.issue(ctx, user, familyID, methods, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.sessionRepo.Create(ctx, &models.Session{
+		UserID:      user.ID,
+		FamilyID:    familyID,
+		AuthMethods: strings.Join(methods, ","),
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(s.refreshTTL),
+	}); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *sessionService) Refresh(ctx context.Context, refreshToken, ipAddress, userAgent string) (*TokenPair, error) {
+	token, err := s.refreshRepo.GetByHash(ctx, hashSecretToken(strings.TrimSpace(refreshToken)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		return nil, s.reused(ctx, token, now)
+	}
+	if now.After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	// Deleted and deactivated users, and sessions revoked by a password
+	// reset or a sign out everywhere, cannot refresh
+	if user == nil || !user.IsActive ||
+		(user.SessionsRevokedAt != nil && token.CreatedAt.Before(*user.SessionsRevokedAt)) {
+		if err := s.revokeFamily(ctx, token.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err := s.refreshRepo.Use(ctx, token.ID, now); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Another request rotated it first
+			return nil, s.reused(ctx, token, now)
+		}
+		return nil, err
+	}
+
+	tokens, err := s.issue(ctx, user, token.FamilyID, splitAuthMethods(token.AuthMethods), ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.touch(ctx, token, now, ipAddress, userAgent); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *sessionService) Logout(ctx context.Context, refreshToken string) error {
+	token, err := s.refreshRepo.GetByHash(ctx, hashSecretToken(strings.TrimSpace(refreshToken)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.revokeFamily(ctx, token.FamilyID, time.Now())
+}
+
+func (s *sessionService) LogoutAll(ctx context.Context, userID uint) error {
+	now := time.Now()
+	if err := s.refreshRepo.RevokeUser(ctx, userID, now); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeUser(ctx, userID, now); err != nil {
+		return err
+	}
+	return s.userRepo.RevokeSessions(ctx, userID, now)
+}
+
+func (s *sessionService) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]*models.Session, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions started before a password reset or a sign out everywhere
+	// have ended even if their records were not revoked
+	var startedAfter time.Time
+	if user.SessionsRevokedAt != nil {
+		startedAfter = *user.SessionsRevokedAt
+	}
+	sessions, err := s.sessionRepo.ListActive(ctx, userID, startedAfter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = currentSessionID != "" && session.FamilyID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) RevokeSession(ctx context.Context, userID, id uint) error {
+	session, err := s.sessionRepo.GetByID(ctx, userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.revokeFamily(ctx, session.FamilyID, time.Now())
+}
+
+func (s *sessionService) CheckToken(claims *auth.Claims) error {
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	revoked, err := s.refreshRepo.FamilyRevoked(context.Background(), claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// Helper functions
+
+func (s *sessionService) issue(ctx context.Context, user *models.User, familyID string, methods []string, ipAddress, userAgent string) (*TokenPair, error) {
+	refreshToken, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshRepo.Create(ctx, &models.RefreshToken{
+		UserID:      user.ID,
+		FamilyID:    familyID,
+		TokenHash:   hashSecretToken(refreshToken),
+		ExpiresAt:   time.Now().Add(s.refreshTTL),
+		AuthMethods: strings.Join(methods, ","),
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+	}); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.authService.GenerateToken(user, familyID, methods)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
+
+// reused ends the session of a refresh token presented after its rotation:
+// either the client or someone who stole the token holds a newer one.
+func (s *sessionService) reused(ctx context.Context, token *models.RefreshToken, now time.Time) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking session %s", token.UserID, token.FamilyID)
+	if err := s.revokeFamily(ctx, token.FamilyID, now); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// revokeFamily ends a session: its refresh tokens, and with them its
+// access tokens, stop working.
+func (s *sessionService) revokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	if err := s.refreshRepo.RevokeFamily(ctx, familyID, now); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeFamily(ctx, familyID, now)
+}
+
+// touch records the refresh of a session. Sessions started before they
+// were recorded get their record now.
+func (s *sessionService) touch(ctx context.Context, token *models.RefreshToken, now time.Time, ipAddress, userAgent string) error {
+	err := s.sessionRepo.Touch(ctx, token.FamilyID, now, now.Add(s.refreshTTL), ipAddress, userAgent)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return s.sessionRepo.Create(ctx, &models.Session{
+		UserID:      token.UserID,
+		FamilyID:    token.FamilyID,
+		AuthMethods: token.AuthMethods,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(s.refreshTTL),
+	})
+}
+
+func splitAuthMethods(methods string) []string {
+	if methods == "" {
+		return nil
+	}
+	return strings.Split(methods, ",")
+}
+
+func newFamilyID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/pkg/auth"
+
+	"gorm.io/gorm"
+)
+
+// memoryRefreshRepo keeps the conditional update of Use, which is what
+// detects a token rotated twice
+type memoryRefreshRepo struct {
+	mu     sync.Mutex
+	tokens []*models.RefreshToken
+}
+
+func (r *memoryRefreshRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	token.CreatedAt = time.Now()
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+	return nil
+}
+
+func (r *memoryRefreshRepo) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRefreshRepo) Use(ctx context.Context, id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token := r.tokens[id-1]
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	token.UsedAt = &at
+	return nil
+}
+
+func (r *memoryRefreshRepo) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshRepo) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshRepo) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memorySessionRepo struct {
+	mu       sync.Mutex
+	sessions []*models.Session
+}
+
+func (r *memorySessionRepo) Create(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ID = uint(len(r.sessions) + 1)
+	session.CreatedAt = time.Now()
+	copied := *session
+	r.sessions = append(r.sessions, &copied)
+	return nil
+}
+
+func (r *memorySessionRepo) GetByID(ctx context.Context, userID, id uint) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.ID == id && session.UserID == userID {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memorySessionRepo) Touch(ctx context.Context, familyID string, at, expiresAt time.Time, ipAddress, userAgent string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID {
+			session.LastSeenAt = at
+			session.ExpiresAt = expiresAt
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (r *memorySessionRepo) ListActive(ctx context.Context, userID uint, startedAfter, now time.Time) ([]*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*models.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) && !session.CreatedAt.Before(startedAfter) {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memorySessionRepo) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memorySessionRepo) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+type sessionTest struct {
+	service  SessionService
+	auth     *auth.Service
+	refresh  *memoryRefreshRepo
+	sessions *memorySessionRepo
+	users    map[uint]*models.User
+}
+
+func newSessionTest(t *testing.T) *sessionTest {
+	t.Helper()
+	users := map[uint]*models.User{
+		1: {ID: 1, Email: "user@example.com", Role: models.RoleUser, IsActive: true},
+		2: {ID: 2, Email: "other@example.com", Role: models.RoleUser, IsActive: true},
+	}
+	authService := auth.NewService(auth.Config{Secret: "test-secret", ExpiresIn: time.Hour})
+	refresh := &memoryRefreshRepo{}
+	sessions := &memorySessionRepo{}
+
+	return &sessionTest{
+		service:  NewSessionService(&memoryUserRepo{users: users}, refresh, sessions, authService, 0),
+		auth:     authService,
+		refresh:  refresh,
+		sessions: sessions,
+		users:    users,
+	}
+}
+
+func (s *sessionTest) start(t *testing.T, userID uint) *TokenPair {
+	t.Helper()
+	tokens, err := s.service.StartSession(context.Background(), s.users[userID], []string{"pwd"}, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	return tokens
+}
+
+// checkAccess reports whether an access token is still accepted
+func (s *sessionTest) checkAccess(t *testing.T, accessToken string) error {
+	t.Helper()
+	claims, err := s.auth.ValidateToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	return s.service.CheckToken(claims)
+}
+
+func TestReusedRefreshTokenRevokesFamily(t *testing.T) {
+	s := newSessionTest(t)
+	ctx := context.Background()
+	first := s.start(t, 1)
+
+	second, err := s.service.Refresh(ctx, first.RefreshToken, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	third, err := s.service.Refresh(ctx, second.RefreshToken, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("second Refresh: %v", err)
+	}
+
+	// Presenting a rotated token ends the session, whoever holds the
+	// latest token
+	if _, err := s.service.Refresh(ctx, first.RefreshToken, "198.51.100.7", "stolen"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := s.service.Refresh(ctx, third.RefreshToken, "192.0.2.1", "test"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("latest token of the family error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	for _, token := range s.refresh.tokens {
+		if token.RevokedAt == nil {
+			t.Errorf("refresh token %d of the family was not revoked", token.ID)
+		}
+	}
+	if err := s.checkAccess(t, third.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token of the family error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if s.sessions.sessions[0].RevokedAt == nil {
+		t.Error("session record was not revoked")
+	}
+}
+
+func TestReuseLeavesOtherSessions(t *testing.T) {
+	s := newSessionTest(t)
+	ctx := context.Background()
+	reused := s.start(t, 1)
+	other := s.start(t, 1)
+
+	if _, err := s.service.Refresh(ctx, reused.RefreshToken, "192.0.2.1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.service.Refresh(ctx, reused.RefreshToken, "192.0.2.1", "test"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if _, err := s.service.Refresh(ctx, other.RefreshToken, "192.0.2.1", "test"); err != nil {
+		t.Errorf("other session was signed out: %v", err)
+	}
+}
+
+// sessionOf returns the session record of a token pair
+func (s *sessionTest) sessionOf(t *testing.T, userID uint, tokens *TokenPair) *models.Session {
+	t.Helper()
+	claims, err := s.auth.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := s.service.ListSessions(context.Background(), userID, claims.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, session := range sessions {
+		if session.Current {
+			return session
+		}
+	}
+	t.Fatalf("session %s is not listed", claims.SessionID)
+	return nil
+}
+
+func TestRevokeSessionRevokesFamily(t *testing.T) {
+	s := newSessionTest(t)
+	ctx := context.Background()
+	revoked := s.start(t, 1)
+	kept := s.start(t, 1)
+
+	session := s.sessionOf(t, 1, revoked)
+	if err := s.service.RevokeSession(ctx, 1, session.ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	if _, err := s.service.Refresh(ctx, revoked.RefreshToken, "192.0.2.1", "test"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh token of the revoked session error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if err := s.checkAccess(t, revoked.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token of the revoked session error = %v, want %v", err, ErrSessionRevoked)
+	}
+	for _, token := range s.refresh.tokens {
+		if token.FamilyID == session.FamilyID && token.RevokedAt == nil {
+			t.Errorf("refresh token %d of the revoked session was not revoked", token.ID)
+		}
+	}
+
+	// The user's other devices stay signed in
+	if err := s.checkAccess(t, kept.AccessToken); err != nil {
+		t.Errorf("access token of the other session was rejected: %v", err)
+	}
+	sessions, err := s.service.ListSessions(ctx, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].FamilyID == session.FamilyID {
+		t.Errorf("sessions after the revocation = %+v", sessions)
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	s := newSessionTest(t)
+	ctx := context.Background()
+	victim := s.start(t, 1)
+	session := s.sessionOf(t, 1, victim)
+
+	// Users revoke sessions by ID only among their own
+	if err := s.service.RevokeSession(ctx, 2, session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking another user's session error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if err := s.checkAccess(t, victim.AccessToken); err != nil {
+		t.Errorf("session was revoked by another user: %v", err)
+	}
+	if _, err := s.service.Refresh(ctx, victim.RefreshToken, "192.0.2.1", "test"); err != nil {
+		t.Errorf("refresh after another user's attempt: %v", err)
+	}
+	if sessions, err := s.service.ListSessions(ctx, 2, ""); err != nil || len(sessions) != 0 {
+		t.Errorf("other user's sessions = %v, %v", sessions, err)
+	}
+}
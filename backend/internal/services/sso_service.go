@@ -0,0 +1,915 @@
+package services
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/auth"
+	"web-openssl-backend/pkg/oidc"
+	"web-openssl-backend/pkg/saml"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSSONotInPlan         = errors.New("single sign-on requires the enterprise plan")
+	ErrSSONotConfigured     = errors.New("single sign-on is not set up for this organization")
+	ErrInvalidSSOConnection = errors.New("invalid single sign-on configuration")
+	ErrSSOLoginExpired      = errors.New("sign-in request is invalid or has expired; start again")
+	ErrSSOLoginFailed       = errors.New("single sign-on failed")
+	ErrSSOAccountExists     = errors.New("an account with this email address already exists; sign in and link it to single sign-on")
+	ErrSSOIdentityLinked    = errors.New("this identity is linked to another account")
+	ErrInvalidSSODomain     = errors.New("invalid domain")
+	ErrSSODomainTaken       = errors.New("the domain is verified by another organization")
+	ErrSSODomainNotVerified = errors.New("single sign-on only creates accounts for addresses at the organization's verified domains; ask an admin to invite you")
+)
+
+// Stages of a single sign-on: waiting for the identity provider, then for
+// the web application to swap the login code for tokens
+const (
+	ssoStageAuthorize = "authorize"
+	ssoStageToken     = "token"
+)
+
+const (
+	ssoLoginTTL     = 10 * time.Minute
+	ssoLoginCodeTTL = time.Minute
+
+	ssoCallbackPath = "/api/v1/auth/sso/oidc/callback"
+	samlPathFormat  = "/api/v1/auth/sso/saml/%d/"
+
+	samlNameIDTransient = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+
+	// An organization proves it controls a domain with a TXT record at
+	// ssoDomainRecordPrefix+domain holding ssoDomainValuePrefix+token
+	ssoDomainRecordPrefix = "_openssl-ui-verification."
+	ssoDomainValuePrefix  = "openssl-ui-verification="
+)
+
+// Claims read when a connection names none: OpenID Connect standard claims,
+// and for SAML the names used by the common identity providers
+var (
+	oidcClaims = ssoClaims{
+		email:     []string{"email"},
+		firstName: []string{"given_name"},
+		lastName:  []string{"family_name"},
+		groups:    []string{"groups"},
+	}
+	samlAttributes = ssoClaims{
+		email:     []string{"email", "mail", "urn:oid:0.9.2342.19200300.100.1.3", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"},
+		firstName: []string{"firstName", "givenName", "urn:oid:2.5.4.42", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname"},
+		lastName:  []string{"lastName", "sn", "surname", "urn:oid:2.5.4.4", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname"},
+		groups:    []string{"groups", "memberOf", "http://schemas.microsoft.com/ws/2008/06/identity/claims/groups"},
+	}
+)
+
+// txtResolver looks up the TXT records domains are verified by
+type txtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// ssoService implements SSOService interface
+// Single Responsibility: Handles organization identity providers and the
+// sign-ins and accounts they vouch for
+type ssoService struct {
+	ssoRepo     repository.SSORepository
+	userRepo    repository.UserRepository
+	orgRepo     repository.OrganizationRepository
+	vault       KeyVaultService
+	authService *auth.Service
+	oidc        *oidc.Service
+	resolver    txtResolver
+	baseURL     string
+}
+
+// NewSSOService creates a new SSO service. baseURL is the public address of
+// this API, which identity providers send users back to.
+func NewSSOService(
+	ssoRepo repository.SSORepository,
+	userRepo repository.UserRepository,
+	orgRepo repository.OrganizationRepository,
+	vault KeyVaultService,
+	authService *auth.Service,
+	oidcService *oidc.Service,
+	baseURL string,
+) SSOService {
+	return &ssoService{
+		ssoRepo:     ssoRepo,
+		userRepo:    userRepo,
+		orgRepo:     orgRepo,
+		vault:       vault,
+		authService: authService,
+		oidc:        oidcService,
+		resolver:    net.DefaultResolver,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (s *ssoService) GetConnection(ctx context.Context, userID, organizationID uint) (*models.SSOConnection, error) {
+	if err := s.requireAdmin(ctx, userID, organizationID); err != nil {
+		return nil, err
+	}
+	connection, err := s.ssoRepo.GetConnection(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	s.describe(connection)
+	return connection, nil
+}
+
+func (s *ssoService) SaveConnection(ctx context.Context, userID, organizationID uint, req SSOConnectionRequest) (*models.SSOConnection, error) {
+	if err := s.requireAdmin(ctx, userID, organizationID); err != nil {
+		return nil, err
+	}
+	if _, err := s.organization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+
+	connection, err := s.ssoRepo.GetConnection(ctx, organizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		connection = &models.SSOConnection{OrganizationID: organizationID}
+	} else if err != nil {
+		return nil, err
+	}
+	previousProtocol := connection.Protocol
+
+	connection.Protocol = req.Protocol
+	connection.Enabled = req.Enabled
+	connection.EmailClaim = strings.TrimSpace(req.EmailClaim)
+	connection.FirstNameClaim = strings.TrimSpace(req.FirstNameClaim)
+	connection.LastNameClaim = strings.TrimSpace(req.LastNameClaim)
+	connection.GroupsClaim = strings.TrimSpace(req.GroupsClaim)
+	if err := setRoleMapping(connection, req.GroupRoles, req.DefaultRole); err != nil {
+		return nil, err
+	}
+
+	secret := req.ClientSecret
+	switch req.Protocol {
+	case models.SSOProtocolOIDC:
+		connection.Issuer = strings.TrimRight(strings.TrimSpace(req.Issuer), "/")
+		connection.ClientID = strings.TrimSpace(req.ClientID)
+		connection.Scopes = strings.Join(strings.Fields(req.Scopes), " ")
+		connection.IdPEntityID, connection.IdPSSOURL, connection.IdPCertificate = "", "", ""
+		if connection.ClientID == "" {
+			return nil, fmt.Errorf("%w: client ID is required", ErrInvalidSSOConnection)
+		}
+		if secret == "" && previousProtocol != models.SSOProtocolOIDC {
+			return nil, fmt.Errorf("%w: client secret is required", ErrInvalidSSOConnection)
+		}
+		// Catches a mistyped issuer now rather than at the first sign-in
+		if _, err := s.oidc.Discover(ctx, connection.Issuer); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSSOConnection, err)
+		}
+
+	case models.SSOProtocolSAML:
+		connection.Issuer, connection.ClientID, connection.Scopes = "", "", ""
+		if err := setIdentityProvider(connection, req); err != nil {
+			return nil, err
+		}
+		// Nothing is secret in a SAML connection
+		secret = ""
+
+	default:
+		return nil, fmt.Errorf("%w: protocol must be %s or %s", ErrInvalidSSOConnection, models.SSOProtocolOIDC, models.SSOProtocolSAML)
+	}
+
+	// An empty client secret keeps the stored one
+	if secret != "" || req.Protocol == models.SSOProtocolSAML || len(connection.ClientSecret.Ciphertext) == 0 {
+		var err error
+		if connection.ID == 0 {
+			if connection.ID, err = s.vault.ReserveID(ctx, models.TableSSOConnections); err != nil {
+				return nil, err
+			}
+		}
+		if connection.ClientSecret, err = s.vault.Seal(models.TableSSOConnections, connection.ID, secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.ssoRepo.SaveConnection(ctx, connection); err != nil {
+		return nil, err
+	}
+	s.describe(connection)
+	return connection, nil
+}
+
+func (s *ssoService) DeleteConnection(ctx context.Context, userID, organizationID uint) error {
+	if err := s.requireAdmin(ctx, userID, organizationID); err != nil {
+		return err
+	}
+	return s.ssoRepo.DeleteConnection(ctx, organizationID)
+}
+
+func (s *ssoService) ListDomains(ctx context.Context, userID, organizationID uint) ([]*models.SSODomain, error) {
+	connection, err := s.adminConnection(ctx, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := s.ssoRepo.ListDomains(ctx, connection.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range domains {
+		describeDomain(domain)
+	}
+	return domains, nil
+}
+
+func (s *ssoService) AddDomain(ctx context.Context, userID, organizationID uint, name string) (*models.SSODomain, error) {
+	connection, err := s.adminConnection(ctx, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	name, err = normalizeDomain(name)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := s.ssoRepo.ListDomains(ctx, connection.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range domains {
+		if domain.Domain == name {
+			describeDomain(domain)
+			return domain, nil
+		}
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	domain := &models.SSODomain{ConnectionID: connection.ID, Domain: name, Token: token}
+	if err := s.ssoRepo.CreateDomain(ctx, domain); err != nil {
+		return nil, err
+	}
+	describeDomain(domain)
+	return domain, nil
+}
+
+func (s *ssoService) VerifyDomain(ctx context.Context, userID, organizationID, id uint) (*models.SSODomain, error) {
+	connection, err := s.adminConnection(ctx, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := s.ssoRepo.GetDomain(ctx, connection.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	describeDomain(domain)
+	if domain.VerifiedAt != nil {
+		return domain, nil
+	}
+
+	verified, err := s.ssoRepo.GetVerifiedDomain(ctx, domain.Domain)
+	if err == nil && verified.ConnectionID != connection.ID {
+		return nil, ErrSSODomainTaken
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, domain.RecordName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: looking up TXT records for %s: %v", ErrInvalidSSODomain, domain.RecordName, err)
+	}
+	if !containsString(records, domain.RecordValue) {
+		return nil, fmt.Errorf("%w: no TXT record for %s holds %s", ErrInvalidSSODomain, domain.RecordName, domain.RecordValue)
+	}
+
+	now := time.Now()
+	if err := s.ssoRepo.MarkDomainVerified(ctx, domain.ID, now); err != nil {
+		return nil, err
+	}
+	domain.VerifiedAt = &now
+	return domain, nil
+}
+
+func (s *ssoService) DeleteDomain(ctx context.Context, userID, organizationID, id uint) error {
+	connection, err := s.adminConnection(ctx, userID, organizationID)
+	if err != nil {
+		return err
+	}
+	return s.ssoRepo.DeleteDomain(ctx, connection.ID, id)
+}
+
+func (s *ssoService) Metadata(ctx context.Context, connectionID uint) ([]byte, error) {
+	connection, err := s.ssoRepo.GetConnectionByID(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if connection.Protocol != models.SSOProtocolSAML {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.serviceProvider(connection).Metadata()
+}
+
+func (s *ssoService) BeginLogin(ctx context.Context, organizationID uint) (string, string, error) {
+	binding, err := newSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+	redirectURL, err := s.begin(ctx, organizationID, &models.SSOLoginState{BindingHash: hashSecretToken(binding)})
+	if err != nil {
+		return "", "", err
+	}
+	return redirectURL, binding, nil
+}
+
+func (s *ssoService) BeginLink(ctx context.Context, userID, organizationID uint) (string, error) {
+	return s.begin(ctx, organizationID, &models.SSOLoginState{LinkUserID: userID})
+}
+
+func (s *ssoService) FinishOIDC(ctx context.Context, state, code, binding string) (*SSOLoginResult, error) {
+	login, connection, err := s.useLogin(ctx, state, binding)
+	if err != nil {
+		return nil, err
+	}
+	if connection.Protocol != models.SSOProtocolOIDC {
+		return nil, ErrSSOLoginExpired
+	}
+
+	provider, err := s.oidc.Discover(ctx, connection.Issuer)
+	if err != nil {
+		return nil, s.loginFailed(connection, err)
+	}
+	secret, err := s.vault.Open(models.TableSSOConnections, connection.ID, connection.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.oidc.Exchange(ctx, provider, connection.ClientID, secret, code, s.baseURL+ssoCallbackPath, login.CodeVerifier)
+	if err != nil {
+		return nil, s.loginFailed(connection, err)
+	}
+	claims, err := s.oidc.VerifyIDToken(ctx, provider, connection.ClientID, idToken, login.Nonce)
+	if err != nil {
+		return nil, s.loginFailed(connection, err)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("%w: the identity provider has not verified the email address", ErrSSOLoginFailed)
+	}
+
+	names := oidcClaims.override(connection)
+	profile := &ssoProfile{
+		subject:   claims.String("sub"),
+		email:     firstClaim(claims.String, names.email),
+		firstName: firstClaim(claims.String, names.firstName),
+		lastName:  firstClaim(claims.String, names.lastName),
+		// RFC 8176 "mfa": the provider's sign-in used several factors
+		multiFactor: containsString(claims.Strings("amr"), auth.AuthMethodMultiFactor),
+	}
+	for _, name := range names.groups {
+		profile.groups = append(profile.groups, claims.Strings(name)...)
+	}
+	return s.complete(ctx, connection, login, profile)
+}
+
+func (s *ssoService) FinishSAML(ctx context.Context, connectionID uint, relayState, samlResponse, binding string) (*SSOLoginResult, error) {
+	login, connection, err := s.useLogin(ctx, relayState, binding)
+	if err != nil {
+		return nil, err
+	}
+	if connection.ID != connectionID || connection.Protocol != models.SSOProtocolSAML {
+		return nil, ErrSSOLoginExpired
+	}
+
+	idp, err := identityProvider(connection)
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := s.serviceProvider(connection).ParseResponse(samlResponse, idp, login.RequestID, time.Now())
+	if err != nil {
+		return nil, s.loginFailed(connection, err)
+	}
+	// A transient NameID changes at every sign-in, so it cannot find the
+	// account again
+	if assertion.NameIDFormat == samlNameIDTransient {
+		return nil, fmt.Errorf("%w: the identity provider sends a transient NameID; configure a persistent or email NameID", ErrSSOLoginFailed)
+	}
+
+	names := samlAttributes.override(connection)
+	profile := &ssoProfile{
+		subject:   assertion.NameID,
+		email:     firstClaim(assertion.Attribute, names.email),
+		firstName: firstClaim(assertion.Attribute, names.firstName),
+		lastName:  firstClaim(assertion.Attribute, names.lastName),
+	}
+	if profile.email == "" && strings.Contains(assertion.NameID, "@") {
+		profile.email = assertion.NameID
+	}
+	for _, name := range names.groups {
+		profile.groups = append(profile.groups, assertion.Attributes[name]...)
+	}
+	return s.complete(ctx, connection, login, profile)
+}
+
+func (s *ssoService) ExchangeLoginCode(ctx context.Context, code string) (*models.User, []string, error) {
+	login, err := s.ssoRepo.UseState(ctx, hashSecretToken(strings.TrimSpace(code)), ssoStageToken, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSSOLoginExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, login.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSSOLoginExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, fmt.Errorf("%w: account is disabled", ErrSSOLoginFailed)
+	}
+	return user, splitAuthMethods(login.AuthMethods), nil
+}
+
+// Helper functions
+
+// ssoProfile is what an identity provider vouched for about a user
+type ssoProfile struct {
+	subject     string
+	email       string
+	firstName   string
+	lastName    string
+	groups      []string
+	multiFactor bool
+}
+
+// ssoClaims are the claim or attribute names, in order of preference, the
+// profile is read from
+type ssoClaims struct {
+	email, firstName, lastName, groups []string
+}
+
+// override puts the names a connection configures in place of the defaults.
+func (c ssoClaims) override(connection *models.SSOConnection) ssoClaims {
+	pick := func(configured string, defaults []string) []string {
+		if configured != "" {
+			return []string{configured}
+		}
+		return defaults
+	}
+	return ssoClaims{
+		email:     pick(connection.EmailClaim, c.email),
+		firstName: pick(connection.FirstNameClaim, c.firstName),
+		lastName:  pick(connection.LastNameClaim, c.lastName),
+		groups:    pick(connection.GroupsClaim, c.groups),
+	}
+}
+
+func firstClaim(get func(string) string, names []string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(get(name)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// begin stores a sign-in for the organization's connection and returns the
+// identity provider address that starts it.
+func (s *ssoService) begin(ctx context.Context, organizationID uint, login *models.SSOLoginState) (string, error) {
+	connection, err := s.ssoRepo.GetConnection(ctx, organizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrSSONotConfigured
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := s.usable(ctx, connection); err != nil {
+		return "", err
+	}
+
+	state, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	login.ConnectionID = connection.ID
+	login.Stage = ssoStageAuthorize
+	login.StateHash = hashSecretToken(state)
+	login.ExpiresAt = time.Now().Add(ssoLoginTTL)
+
+	var redirectURL string
+	switch connection.Protocol {
+	case models.SSOProtocolOIDC:
+		provider, err := s.oidc.Discover(ctx, connection.Issuer)
+		if err != nil {
+			return "", s.loginFailed(connection, err)
+		}
+		if login.Nonce, err = oidc.RandomString(); err != nil {
+			return "", err
+		}
+		if login.CodeVerifier, err = oidc.RandomString(); err != nil {
+			return "", err
+		}
+		redirectURL = provider.AuthCodeURL(oidc.AuthRequest{
+			ClientID:     connection.ClientID,
+			RedirectURI:  s.baseURL + ssoCallbackPath,
+			Scopes:       append([]string{"email", "profile"}, strings.Fields(connection.Scopes)...),
+			State:        state,
+			Nonce:        login.Nonce,
+			CodeVerifier: login.CodeVerifier,
+		})
+
+	case models.SSOProtocolSAML:
+		idp, err := identityProvider(connection)
+		if err != nil {
+			return "", err
+		}
+		redirectURL, login.RequestID, err = s.serviceProvider(connection).AuthnRequestURL(idp, state)
+		if err != nil {
+			return "", err
+		}
+
+	default:
+		return "", ErrSSONotConfigured
+	}
+
+	if err := s.ssoRepo.CreateState(ctx, login); err != nil {
+		return "", err
+	}
+	return redirectURL, nil
+}
+
+// useLogin ends the sign-in a returning user belongs to, so that it cannot
+// be completed twice. A login, as opposed to linking an account, must come
+// back to the browser that started it; otherwise someone could sign a
+// victim into the attacker's account.
+func (s *ssoService) useLogin(ctx context.Context, state, binding string) (*models.SSOLoginState, *models.SSOConnection, error) {
+	login, err := s.ssoRepo.UseState(ctx, hashSecretToken(state), ssoStageAuthorize, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSSOLoginExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if login.LinkUserID == 0 && (binding == "" || hashSecretToken(binding) != login.BindingHash) {
+		return nil, nil, ErrSSOLoginExpired
+	}
+
+	connection, err := s.ssoRepo.GetConnectionByID(ctx, login.ConnectionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSSONotConfigured
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.usable(ctx, connection); err != nil {
+		return nil, nil, err
+	}
+	return login, connection, nil
+}
+
+// complete signs in, creates or links the account the identity provider
+// vouched for, and brings its membership in line with the user's groups.
+func (s *ssoService) complete(ctx context.Context, connection *models.SSOConnection, login *models.SSOLoginState, profile *ssoProfile) (*SSOLoginResult, error) {
+	if profile.subject == "" {
+		return nil, fmt.Errorf("%w: the identity provider did not identify the user", ErrSSOLoginFailed)
+	}
+	role := connection.DefaultRole
+	if role == "" {
+		role = models.MemberRoleMember
+	}
+	for _, group := range profile.groups {
+		if mapped := connection.GroupRoles[group]; roleRank(mapped) > roleRank(role) {
+			role = mapped
+		}
+	}
+
+	identity, err := s.ssoRepo.GetIdentity(ctx, connection.ID, profile.subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	found := err == nil
+
+	if login.LinkUserID != 0 {
+		if found && identity.UserID != login.LinkUserID {
+			return nil, ErrSSOIdentityLinked
+		}
+		if !found {
+			if err := s.ssoRepo.CreateIdentity(ctx, &models.SSOIdentity{
+				ConnectionID: connection.ID,
+				Subject:      profile.subject,
+				UserID:       login.LinkUserID,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.syncMembership(ctx, connection, login.LinkUserID, role); err != nil {
+			return nil, err
+		}
+		return &SSOLoginResult{Linked: true}, nil
+	}
+
+	var userID uint
+	if found {
+		userID = identity.UserID
+		if err := s.ssoRepo.MarkIdentityUsed(ctx, identity.ID, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := s.syncMembership(ctx, connection, userID, role); err != nil {
+			return nil, err
+		}
+	} else if userID, err = s.provision(ctx, connection, profile, role); err != nil {
+		return nil, err
+	}
+
+	methods := []string{auth.AuthMethodFederated}
+	if profile.multiFactor {
+		methods = append(methods, auth.AuthMethodMultiFactor)
+	}
+	code, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ssoRepo.CreateState(ctx, &models.SSOLoginState{
+		ConnectionID: connection.ID,
+		Stage:        ssoStageToken,
+		StateHash:    hashSecretToken(code),
+		UserID:       userID,
+		AuthMethods:  strings.Join(methods, ","),
+		ExpiresAt:    time.Now().Add(ssoLoginCodeTTL),
+	}); err != nil {
+		return nil, err
+	}
+	return &SSOLoginResult{LoginCode: code}, nil
+}
+
+// provision creates the account of a user signing in for the first time.
+// The address has to be at a domain the organization verified, so that an
+// identity provider cannot mint accounts for other organizations' users.
+// An existing account with the address is not taken over: its owner links
+// it while signed in.
+func (s *ssoService) provision(ctx context.Context, connection *models.SSOConnection, profile *ssoProfile, role models.MemberRole) (uint, error) {
+	email := strings.ToLower(profile.email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return 0, fmt.Errorf("%w: the identity provider did not send an email address", ErrSSOLoginFailed)
+	}
+	verified, err := s.ssoRepo.GetVerifiedDomain(ctx, email[at+1:])
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && verified.ConnectionID != connection.ID {
+		return 0, ErrSSODomainNotVerified
+	} else if err != nil {
+		return 0, err
+	}
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return 0, ErrSSOAccountExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	// The account has no usable password until its owner resets one
+	password, err := newSecretToken()
+	if err != nil {
+		return 0, err
+	}
+	hash, err := s.authService.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Email:         email,
+		Password:      hash,
+		FirstName:     profile.firstName,
+		LastName:      profile.lastName,
+		Role:          models.RoleUser,
+		Plan:          models.PlanFree,
+		IsActive:      true,
+		EmailVerified: true,
+		UsageResetAt:  now.AddDate(0, 1, 0),
+	}
+	member := &models.OrganizationMember{OrganizationID: connection.OrganizationID, Role: role, JoinedAt: now}
+	identity := &models.SSOIdentity{ConnectionID: connection.ID, Subject: profile.subject, LastLoginAt: &now}
+	if err := s.ssoRepo.ProvisionUser(ctx, user, member, identity); err != nil {
+		return 0, err
+	}
+	log.Printf("Created user %d for %s signing in to organization %d", user.ID, email, connection.OrganizationID)
+	return user.ID, nil
+}
+
+// syncMembership adds the user to the organization, or when the connection
+// maps groups, gives them the role their groups map to. Owners keep their
+// role.
+func (s *ssoService) syncMembership(ctx context.Context, connection *models.SSOConnection, userID uint, role models.MemberRole) error {
+	member, err := s.orgRepo.GetMember(ctx, connection.OrganizationID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.orgRepo.AddMember(ctx, &models.OrganizationMember{
+			UserID:         userID,
+			OrganizationID: connection.OrganizationID,
+			Role:           role,
+			JoinedAt:       time.Now(),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if len(connection.GroupRoles) == 0 || member.Role == models.MemberRoleOwner || member.Role == role {
+		return nil
+	}
+	return s.orgRepo.SetMemberRole(ctx, connection.OrganizationID, userID, role)
+}
+
+// usable requires an enabled connection of an organization whose plan
+// includes single sign-on.
+func (s *ssoService) usable(ctx context.Context, connection *models.SSOConnection) error {
+	if !connection.Enabled {
+		return ErrSSONotConfigured
+	}
+	_, err := s.organization(ctx, connection.OrganizationID)
+	return err
+}
+
+func (s *ssoService) organization(ctx context.Context, organizationID uint) (*models.Organization, error) {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSSONotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sso, _ := models.GetPlanLimits(org.Plan)["sso"].(bool); !sso || !org.IsActive {
+		return nil, ErrSSONotInPlan
+	}
+	return org, nil
+}
+
+// adminConnection returns the organization's connection to an admin.
+func (s *ssoService) adminConnection(ctx context.Context, userID, organizationID uint) (*models.SSOConnection, error) {
+	if err := s.requireAdmin(ctx, userID, organizationID); err != nil {
+		return nil, err
+	}
+	return s.ssoRepo.GetConnection(ctx, organizationID)
+}
+
+func (s *ssoService) requireAdmin(ctx context.Context, userID, organizationID uint) error {
+	member, err := s.orgRepo.GetMember(ctx, organizationID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotOrganizationMember
+		}
+		return err
+	}
+	if member.Role != models.MemberRoleAdmin && member.Role != models.MemberRoleOwner {
+		return ErrOrganizationAdminOnly
+	}
+	return nil
+}
+
+// loginFailed logs why an identity provider sign-in failed; the details are
+// for the organization's admins rather than the user.
+func (s *ssoService) loginFailed(connection *models.SSOConnection, err error) error {
+	log.Printf("Single sign-on for organization %d failed: %v", connection.OrganizationID, err)
+	return ErrSSOLoginFailed
+}
+
+// describeDomain fills in the TXT record that verifies a domain.
+func describeDomain(domain *models.SSODomain) {
+	domain.RecordName = ssoDomainRecordPrefix + domain.Domain
+	domain.RecordValue = ssoDomainValuePrefix + domain.Token
+}
+
+// normalizeDomain validates an email domain.
+func normalizeDomain(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if len(name) > 253 || !strings.Contains(name, ".") || net.ParseIP(name) != nil {
+		return "", fmt.Errorf("%w: %q is not a domain name", ErrInvalidSSODomain, name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", fmt.Errorf("%w: %q is not a domain name", ErrInvalidSSODomain, name)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return "", fmt.Errorf("%w: %q is not a domain name", ErrInvalidSSODomain, name)
+			}
+		}
+	}
+	return name, nil
+}
+
+// describe fills in the addresses an admin enters at the identity provider.
+func (s *ssoService) describe(connection *models.SSOConnection) {
+	switch connection.Protocol {
+	case models.SSOProtocolOIDC:
+		connection.RedirectURI = s.baseURL + ssoCallbackPath
+	case models.SSOProtocolSAML:
+		sp := s.serviceProvider(connection)
+		connection.SPEntityID = sp.EntityID
+		connection.ACSURL = sp.ACSURL
+	}
+}
+
+// serviceProvider identifies this application to a SAML connection's
+// identity provider.
+func (s *ssoService) serviceProvider(connection *models.SSOConnection) *saml.ServiceProvider {
+	path := s.baseURL + fmt.Sprintf(samlPathFormat, connection.ID)
+	return &saml.ServiceProvider{
+		EntityID: path + "metadata",
+		ACSURL:   path + "acs",
+	}
+}
+
+func identityProvider(connection *models.SSOConnection) (*saml.IdentityProvider, error) {
+	certificates, err := saml.ParseCertificates(connection.IdPCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSSOConnection, err)
+	}
+	return &saml.IdentityProvider{
+		EntityID:     connection.IdPEntityID,
+		SSOURL:       connection.IdPSSOURL,
+		Certificates: certificates,
+	}, nil
+}
+
+// setIdentityProvider stores a SAML identity provider's settings, taken
+// from its metadata when given.
+func setIdentityProvider(connection *models.SSOConnection, req SSOConnectionRequest) error {
+	idp := &saml.IdentityProvider{
+		EntityID: strings.TrimSpace(req.IdPEntityID),
+		SSOURL:   strings.TrimSpace(req.IdPSSOURL),
+	}
+	if strings.TrimSpace(req.IdPMetadata) != "" {
+		parsed, err := saml.ParseIdPMetadata([]byte(req.IdPMetadata))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSSOConnection, err)
+		}
+		idp = parsed
+	} else {
+		certificates, err := saml.ParseCertificates(req.IdPCertificate)
+		if err != nil {
+			return fmt.Errorf("%w: identity provider certificate: %v", ErrInvalidSSOConnection, err)
+		}
+		idp.Certificates = certificates
+	}
+
+	if idp.EntityID == "" {
+		return fmt.Errorf("%w: identity provider entity ID is required", ErrInvalidSSOConnection)
+	}
+	if u, err := url.Parse(idp.SSOURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: identity provider sign-in URL must be an http(s) URL", ErrInvalidSSOConnection)
+	}
+
+	var certificates strings.Builder
+	for _, certificate := range idp.Certificates {
+		certificates.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+	}
+	connection.IdPEntityID = idp.EntityID
+	connection.IdPSSOURL = idp.SSOURL
+	connection.IdPCertificate = certificates.String()
+	return nil
+}
+
+// setRoleMapping stores the group to role mapping. Single sign-on never
+// makes anyone an owner.
+func setRoleMapping(connection *models.SSOConnection, groupRoles map[string]models.MemberRole, defaultRole models.MemberRole) error {
+	if defaultRole == "" {
+		defaultRole = models.MemberRoleMember
+	}
+	if roleRank(defaultRole) == 0 {
+		return fmt.Errorf("%w: default role must be member or admin", ErrInvalidSSOConnection)
+	}
+	mapping := make(map[string]models.MemberRole, len(groupRoles))
+	for group, role := range groupRoles {
+		group = strings.TrimSpace(group)
+		if group == "" || roleRank(role) == 0 {
+			return fmt.Errorf("%w: group %q must map to member or admin", ErrInvalidSSOConnection, group)
+		}
+		mapping[group] = role
+	}
+	connection.GroupRoles = mapping
+	connection.DefaultRole = defaultRole
+	return nil
+}
+
+// roleRank orders the roles single sign-on can grant; other values rank 0.
+func roleRank(role models.MemberRole) int {
+	switch role {
+	case models.MemberRoleMember:
+		return 1
+	case models.MemberRoleAdmin:
+		return 2
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
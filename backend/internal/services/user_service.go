@@ -2,8 +2,6 @@ package services
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"errors"
 	"time"
 
@@ -41,12 +39,6 @@ func (s *userService) Register(ctx context.Context, req RegisterRequest) (*model
 		return nil, err
 	}
 
-	// Generate API key
-	apiKey, err := generateAPIKey()
-	if err != nil {
-		return nil, err
-	}
-
 	// Create user
 	user := &models.User{
 		Email:        req.Email,
@@ -56,7 +48,6 @@ func (s *userService) Register(ctx context.Context, req RegisterRequest) (*model
 		Role:         models.RoleUser,
 		Plan:         models.PlanFree,
 		IsActive:     true,
-		APIKey:       apiKey,
 		UsageResetAt: time.Now().AddDate(0, 1, 0),
 	}
 
@@ -125,30 +116,3 @@ func (s *userService) UpdateProfile(ctx context.Context, userID uint, req Update
 func (s *userService) DeleteAccount(ctx context.Context, userID uint) error {
 	return s.userRepo.Delete(ctx, userID)
 }
-
-func (s *userService) GenerateAPIKey(ctx context.Context, userID uint) (string, error) {
-	user, err := s.userRepo.GetByID(ctx, userID)
-	if err != nil {
-		return "", err
-	}
-
-	apiKey, err := generateAPIKey()
-	if err != nil {
-		return "", err
-	}
-
-	user.APIKey = apiKey
-	if err := s.userRepo.Update(ctx, user); err != nil {
-		return "", err
-	}
-
-	return apiKey, nil
-}
-
-func generateAPIKey() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(bytes), nil
-}
\ No newline at end of file
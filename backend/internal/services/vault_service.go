@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/vault"
+
+	"gorm.io/gorm"
+)
+
+// rewrapBatchSize bounds how many sealed keys are loaded per query
+const rewrapBatchSize = 100
+
+// keyVaultService implements KeyVaultService interface
+// Single Responsibility: Handles envelope encryption of stored private keys
+type keyVaultService struct {
+	vault      *vault.Service
+	sealedRepo repository.SealedKeyRepository
+}
+
+// NewKeyVaultService creates a new key vault service
+func NewKeyVaultService(vaultService *vault.Service, sealedRepo repository.SealedKeyRepository) KeyVaultService {
+	return &keyVaultService{
+		vault:      vaultService,
+		sealedRepo: sealedRepo,
+	}
+}
+
+func (s *keyVaultService) Seal(table string, id uint, privateKey string) (models.SealedKey, error) {
+	if id == 0 {
+		return models.SealedKey{}, errors.New("sealed keys need the ID of their record")
+	}
+	sealed, err := s.vault.Seal([]byte(privateKey), sealedKeyContext(table, id))
+	if err != nil {
+		return models.SealedKey{}, err
+	}
+	return models.SealedKey{
+		Ciphertext:  sealed.Ciphertext,
+		WrappedKey:  sealed.WrappedKey,
+		MasterKeyID: sealed.MasterKeyID,
+	}, nil
+}
+
+func (s *keyVaultService) Open(table string, id uint, sealed models.SealedKey) (string, error) {
+	plaintext, err := s.vault.Open(&vault.Sealed{
+		Ciphertext:  sealed.Ciphertext,
+		WrappedKey:  sealed.WrappedKey,
+		MasterKeyID: sealed.MasterKeyID,
+	}, sealedKeyContext(table, id))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (s *keyVaultService) ReserveID(ctx context.Context, table string) (uint, error) {
+	return s.sealedRepo.NextID(ctx, table)
+}
+
+func (s *keyVaultService) Status(ctx context.Context) (*VaultStatus, error) {
+	counts, err := s.sealedRepo.CountByMasterKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &VaultStatus{
+		CurrentMasterKeyID: s.vault.CurrentKeyID(),
+		RecordsByMasterKey: counts,
+	}, nil
+}
+
+func (s *keyVaultService) RotateMasterKey(ctx context.Context) (string, error) {
+	return s.vault.Rotate()
+}
+
+// RewrapAll re-wraps every data key that is not wrapped with the current
+// master key. Records that fail are reported and skipped so one bad record
+// does not block the rest; running it again retries them.
+func (s *keyVaultService) RewrapAll(ctx context.Context) (*RewrapResult, error) {
+	result := &RewrapResult{MasterKeyID: s.vault.CurrentKeyID()}
+
+	for _, table := range s.sealedRepo.Tables() {
+		var afterID uint
+		for {
+			records, err := s.sealedRepo.ListNotWrappedWith(ctx, table, result.MasterKeyID, afterID, rewrapBatchSize)
+			if err != nil {
+				return result, err
+			}
+			if len(records) == 0 {
+				break
+			}
+
+			for _, record := range records {
+				afterID = record.ID
+				if err := s.rewrap(ctx, record); err != nil {
+					result.Failed++
+					result.Errors = append(result.Errors, fmt.Sprintf("%s %d: %v", record.Table, record.ID, err))
+					continue
+				}
+				result.Rewrapped++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Helper functions
+
+// sealedKeyContext is the additional data binding a sealed key to the
+// record holding it
+func sealedKeyContext(table string, id uint) []byte {
+	return []byte(fmt.Sprintf("%s:%d", table, id))
+}
+
+func (s *keyVaultService) rewrap(ctx context.Context, record repository.SealedKeyRecord) error {
+	sealed, changed, err := s.vault.Rewrap(&vault.Sealed{
+		Ciphertext:  record.Key.Ciphertext,
+		WrappedKey:  record.Key.WrappedKey,
+		MasterKeyID: record.Key.MasterKeyID,
+	})
+	if err != nil || !changed {
+		return err
+	}
+
+	previousMasterKeyID := record.Key.MasterKeyID
+	record.Key = models.SealedKey{
+		Ciphertext:  sealed.Ciphertext,
+		WrappedKey:  sealed.WrappedKey,
+		MasterKeyID: sealed.MasterKeyID,
+	}
+	if err := s.sealedRepo.ReplaceKey(ctx, record, previousMasterKeyID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("record changed while re-wrapping")
+		}
+		return err
+	}
+	return nil
+}
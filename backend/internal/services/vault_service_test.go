@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"sync"
+	"testing"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/vault"
+
+	"gorm.io/gorm"
+)
+
+// memorySealedRepo stores sealed keys by table and ID, in place of the
+// tables that hold them
+type memorySealedRepo struct {
+	mu   sync.Mutex
+	keys map[string]map[uint]models.SealedKey
+	last uint
+}
+
+func newMemorySealedRepo() *memorySealedRepo {
+	return &memorySealedRepo{keys: map[string]map[uint]models.SealedKey{
+		models.TableKeyPairs:     {},
+		models.TableACMEAccounts: {},
+	}}
+}
+
+func (r *memorySealedRepo) Tables() []string {
+	return []string{models.TableKeyPairs, models.TableACMEAccounts}
+}
+
+func (r *memorySealedRepo) NextID(ctx context.Context, table string) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last++
+	return r.last, nil
+}
+
+func (r *memorySealedRepo) CountByMasterKey(ctx context.Context) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := map[string]int64{}
+	for _, keys := range r.keys {
+		for _, key := range keys {
+			counts[key.MasterKeyID]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *memorySealedRepo) ListNotWrappedWith(ctx context.Context, table, masterKeyID string, afterID uint, limit int) ([]repository.SealedKeyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records []repository.SealedKeyRecord
+	for id, key := range r.keys[table] {
+		if id > afterID && key.MasterKeyID != masterKeyID {
+			records = append(records, repository.SealedKeyRecord{Table: table, ID: id, Key: key})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func (r *memorySealedRepo) ReplaceKey(ctx context.Context, record repository.SealedKeyRecord, previousMasterKeyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[record.Table][record.ID]
+	if !ok || key.MasterKeyID != previousMasterKeyID {
+		return gorm.ErrRecordNotFound
+	}
+	r.keys[record.Table][record.ID] = record.Key
+	return nil
+}
+
+// store seals a private key for a new record of table
+func (r *memorySealedRepo) store(t *testing.T, s KeyVaultService, table, privateKey string) uint {
+	t.Helper()
+	id, err := s.ReserveID(context.Background(), table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := s.Seal(table, id, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[table][id] = sealed
+	return id
+}
+
+func (r *memorySealedRepo) get(table string, id uint) models.SealedKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.keys[table][id]
+}
+
+func newMasterKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, vault.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newConfigVault(t *testing.T, id, key string, previousKeys ...string) *vault.Service {
+	t.Helper()
+	s := vault.NewService(vault.Config{
+		Provider:           vault.ProviderConfig,
+		MasterKeyID:        id,
+		MasterKey:          key,
+		PreviousMasterKeys: previousKeys,
+	})
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSealedKeyOpensOnlyForItsRecord(t *testing.T) {
+	repo := newMemorySealedRepo()
+	s := NewKeyVaultService(newConfigVault(t, "k1", newMasterKey(t)), repo)
+	id := repo.store(t, s, models.TableKeyPairs, "private key")
+	sealed := repo.get(models.TableKeyPairs, id)
+
+	if privateKey, err := s.Open(models.TableKeyPairs, id, sealed); err != nil || privateKey != "private key" {
+		t.Fatalf("Open = %q, %v", privateKey, err)
+	}
+
+	// A sealed key copied to another record, of the same or another table,
+	// does not open there
+	tests := []struct {
+		name  string
+		table string
+		id    uint
+	}{
+		{"other ID", models.TableKeyPairs, id + 1},
+		{"other table", models.TableACMEAccounts, id},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if privateKey, err := s.Open(tt.table, tt.id, sealed); err == nil {
+				t.Errorf("opened as %s %d: %q", tt.table, tt.id, privateKey)
+			}
+		})
+	}
+
+	if _, err := s.Seal(models.TableKeyPairs, 0, "private key"); err == nil {
+		t.Error("sealed a key for a record without an ID")
+	}
+}
+
+func TestRewrapUnderNewMasterKey(t *testing.T) {
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+	repo := newMemorySealedRepo()
+	before := NewKeyVaultService(newConfigVault(t, "k1", oldKey), repo)
+	type record struct {
+		table, privateKey string
+		id                uint
+	}
+	records := []record{
+		{table: models.TableKeyPairs, privateKey: "first key"},
+		{table: models.TableKeyPairs, privateKey: "second key"},
+		{table: models.TableACMEAccounts, privateKey: "account key"},
+	}
+	for i := range records {
+		records[i].id = repo.store(t, before, records[i].table, records[i].privateKey)
+	}
+
+	// k2 is current; k1 is kept until nothing is wrapped with it
+	s := NewKeyVaultService(newConfigVault(t, "k2", newKey, "k1:"+oldKey), repo)
+	result, err := s.RewrapAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rewrapped != 3 || result.Failed != 0 || result.MasterKeyID != "k2" {
+		t.Fatalf("RewrapAll = %+v", result)
+	}
+	status, err := s.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RecordsByMasterKey["k2"] != 3 || status.RecordsByMasterKey["k1"] != 0 {
+		t.Errorf("records by master key = %v", status.RecordsByMasterKey)
+	}
+
+	// Without k1, every value still opens
+	after := NewKeyVaultService(newConfigVault(t, "k2", newKey), repo)
+	for _, r := range records {
+		opened, err := after.Open(r.table, r.id, repo.get(r.table, r.id))
+		if err != nil || opened != r.privateKey {
+			t.Errorf("%s %d opened as %q, %v; want %q", r.table, r.id, opened, err, r.privateKey)
+		}
+	}
+
+	// A second run has nothing left to do
+	if result, err := s.RewrapAll(context.Background()); err != nil || result.Rewrapped != 0 {
+		t.Errorf("second RewrapAll = %+v, %v", result, err)
+	}
+}
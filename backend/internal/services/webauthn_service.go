@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"web-openssl-backend/internal/models"
+	"web-openssl-backend/internal/repository"
+	"web-openssl-backend/pkg/webauthn"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPasskey         = errors.New("passkey could not be verified")
+	ErrPasskeyCeremonyExpired = errors.New("passkey request is invalid or has expired; start again")
+	ErrTooManyPasskeys        = errors.New("too many passkeys")
+	ErrNoPasskeys             = errors.New("no passkeys are registered")
+)
+
+// WebAuthn ceremonies, kept apart so that a challenge issued for one cannot
+// complete another
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	ceremonySecondFactor = "second-factor"
+)
+
+const (
+	maxPasskeysPerUser = 10
+	userHandleBytes    = 32
+	defaultPasskeyName = "Passkey"
+)
+
+// webAuthnService implements WebAuthnService interface
+// Single Responsibility: Registers passkeys and runs passkey logins
+type webAuthnService struct {
+	webAuthnRepo repository.WebAuthnRepository
+	userRepo     repository.UserRepository
+	webAuthn     *webauthn.Service
+}
+
+// NewWebAuthnService creates a new WebAuthn service
+func NewWebAuthnService(
+	webAuthnRepo repository.WebAuthnRepository,
+	userRepo repository.UserRepository,
+	webAuthn *webauthn.Service,
+) WebAuthnService {
+	return &webAuthnService{
+		webAuthnRepo: webAuthnRepo,
+		userRepo:     userRepo,
+		webAuthn:     webAuthn,
+	}
+}
+
+func (s *webAuthnService) BeginRegistration(ctx context.Context, userID uint) (*webauthn.CreationOptions, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) >= maxPasskeysPerUser {
+		return nil, fmt.Errorf("%w: remove one of your %d passkeys first", ErrTooManyPasskeys, len(credentials))
+	}
+
+	// Every credential of a user carries the same handle, so that an
+	// authenticator replaces its old passkey for the account
+	handle := ""
+	if len(credentials) > 0 {
+		handle = credentials[0].UserHandle
+	} else if handle, err = newUserHandle(); err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.startCeremony(ctx, ceremonyRegistration, userID, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if displayName == "" {
+		displayName = user.Email
+	}
+	return s.webAuthn.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          handle,
+		Name:        user.Email,
+		DisplayName: displayName,
+	}, descriptors(credentials)), nil
+}
+
+func (s *webAuthnService) FinishRegistration(ctx context.Context, userID uint, name string, credential *webauthn.CredentialCreation) (*models.WebAuthnCredential, error) {
+	ceremony, err := s.useCeremony(ctx, ceremonyRegistration, credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID != userID {
+		return nil, ErrPasskeyCeremonyExpired
+	}
+
+	verified, err := s.webAuthn.VerifyRegistration(ceremony.challenge, credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	if _, err := s.webAuthnRepo.GetCredential(ctx, verified.ID); err == nil {
+		return nil, fmt.Errorf("%w: it is already registered", ErrInvalidPasskey)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	stored := &models.WebAuthnCredential{
+		UserID:         userID,
+		Name:           name,
+		CredentialID:   verified.ID,
+		UserHandle:     ceremony.UserHandle,
+		PublicKey:      verified.PublicKey,
+		Algorithm:      verified.Algorithm,
+		SignCount:      verified.SignCount,
+		AAGUID:         verified.AAGUID,
+		Transports:     strings.Join(verified.Transports, ","),
+		BackupEligible: verified.BackupEligible,
+	}
+	if err := s.webAuthnRepo.CreateCredential(ctx, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (s *webAuthnService) ListCredentials(ctx context.Context, userID uint) ([]*models.WebAuthnCredential, error) {
+	return s.webAuthnRepo.ListCredentials(ctx, userID)
+}
+
+func (s *webAuthnService) DeleteCredential(ctx context.Context, userID, id uint) error {
+	return s.webAuthnRepo.DeleteCredential(ctx, userID, id)
+}
+
+func (s *webAuthnService) HasCredentials(ctx context.Context, userID uint) (bool, error) {
+	count, err := s.webAuthnRepo.CountCredentials(ctx, userID)
+	return count > 0, err
+}
+
+func (s *webAuthnService) BeginLogin(ctx context.Context) (*webauthn.RequestOptions, error) {
+	challenge, err := s.startCeremony(ctx, ceremonyLogin, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.webAuthn.RequestOptions(challenge, nil), nil
+}
+
+func (s *webAuthnService) FinishLogin(ctx context.Context, assertion *webauthn.CredentialAssertion) (*models.User, error) {
+	ceremony, err := s.useCeremony(ctx, ceremonyLogin, assertion.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.verifyAssertion(ctx, ceremony.challenge, assertion, 0)
+	if err != nil {
+		return nil, err
+	}
+	// A passkey login names the user only through the handle
+	if assertion.Response.UserHandle == "" || strings.TrimRight(assertion.Response.UserHandle, "=") != credential.UserHandle {
+		return nil, fmt.Errorf("%w: user handle does not match", ErrInvalidPasskey)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, credential.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidPasskey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidPasskey
+	}
+	return user, nil
+}
+
+func (s *webAuthnService) BeginSecondFactor(ctx context.Context, user *models.User) (*webauthn.RequestOptions, error) {
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, ErrNoPasskeys
+	}
+
+	challenge, err := s.startCeremony(ctx, ceremonySecondFactor, user.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.webAuthn.RequestOptions(challenge, descriptors(credentials)), nil
+}
+
+func (s *webAuthnService) FinishSecondFactor(ctx context.Context, user *models.User, assertion *webauthn.CredentialAssertion) error {
+	ceremony, err := s.useCeremony(ctx, ceremonySecondFactor, assertion.Response.ClientDataJSON)
+	if err != nil {
+		return err
+	}
+	if ceremony.UserID != user.ID {
+		return ErrPasskeyCeremonyExpired
+	}
+
+	_, err = s.verifyAssertion(ctx, ceremony.challenge, assertion, user.ID)
+	return err
+}
+
+func (s *webAuthnService) Check() error {
+	return s.webAuthn.Check()
+}
+
+// Helper functions
+
+// openCeremony is a ceremony taken from storage together with the challenge
+// it was found by
+type openCeremony struct {
+	*models.WebAuthnChallenge
+	challenge string
+}
+
+func (s *webAuthnService) startCeremony(ctx context.Context, ceremony string, userID uint, userHandle string) (string, error) {
+	challenge, err := s.webAuthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+	if err := s.webAuthnRepo.CreateChallenge(ctx, &models.WebAuthnChallenge{
+		UserID:        userID,
+		Ceremony:      ceremony,
+		ChallengeHash: hashSecretToken(challenge),
+		UserHandle:    userHandle,
+		ExpiresAt:     time.Now().Add(s.webAuthn.Timeout()),
+	}); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// useCeremony ends the ceremony a response belongs to, so that its challenge
+// cannot be answered twice.
+func (s *webAuthnService) useCeremony(ctx context.Context, ceremony, clientDataJSON string) (*openCeremony, error) {
+	challenge, err := s.webAuthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	stored, err := s.webAuthnRepo.UseChallenge(ctx, hashSecretToken(challenge), ceremony, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPasskeyCeremonyExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &openCeremony{WebAuthnChallenge: stored, challenge: challenge}, nil
+}
+
+// verifyAssertion checks a signature by a stored credential, which must be
+// one of userID's unless that is zero.
+func (s *webAuthnService) verifyAssertion(ctx context.Context, challenge string, assertion *webauthn.CredentialAssertion, userID uint) (*models.WebAuthnCredential, error) {
+	credential, err := s.webAuthnRepo.GetCredential(ctx, assertion.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: unknown passkey", ErrInvalidPasskey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 && credential.UserID != userID {
+		return nil, fmt.Errorf("%w: unknown passkey", ErrInvalidPasskey)
+	}
+
+	signCount, err := s.webAuthn.VerifyAssertion(challenge, assertion, credential.PublicKey, credential.SignCount)
+	if errors.Is(err, webauthn.ErrClonedAuthenticator) {
+		log.Printf("Passkey %d of user %d may have been cloned", credential.ID, credential.UserID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	now := time.Now()
+	if err := s.webAuthnRepo.MarkUsed(ctx, credential.ID, credential.SignCount, signCount, now); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Another login with the same counter value got there first
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	credential.SignCount = signCount
+	credential.LastUsedAt = &now
+	return credential, nil
+}
+
+func descriptors(credentials []*models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	result := make([]webauthn.CredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		result[i] = webauthn.CredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+		if credential.Transports != "" {
+			result[i].Transports = strings.Split(credential.Transports, ",")
+		}
+	}
+	return result
+}
+
+func newUserHandle() (string, error) {
+	handle := make([]byte, userHandleBytes)
+	if _, err := rand.Read(handle); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(handle), nil
+}
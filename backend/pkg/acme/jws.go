@@ -0,0 +1,207 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwsMessage is the flattened JSON serialization required by RFC 8555
+// section 6.2.
+type jwsMessage struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+type jwsHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	JWK   json.RawMessage `json:"jwk"`
+	KID   string          `json:"kid"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseJWK(raw json.RawMessage) (crypto.PublicKey, error) {
+	var jwk jsonWebKey
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return nil, fmt.Errorf("invalid JWK: %w", err)
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeSegment(jwk.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("invalid RSA modulus")
+		}
+		e, err := decodeSegment(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA account keys must be at least 2048 bits")
+		}
+		return key, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeSegment(jwk.X)
+		if err != nil {
+			return nil, errors.New("invalid EC x coordinate")
+		}
+		y, err := decodeSegment(jwk.Y)
+		if err != nil {
+			return nil, errors.New("invalid EC y coordinate")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeSegment(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint, which hashes only the
+// required members in lexicographic order with no whitespace.
+func jwkThumbprint(raw json.RawMessage) (string, error) {
+	var jwk jsonWebKey
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", err
+	}
+
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return encodeSegment(sum[:]), nil
+}
+
+func verifyJWSSignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 requires an RSA key")
+		}
+		digest := sha256.Sum256(signingInput)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an EC key", alg)
+		}
+
+		var digest []byte
+		var curve elliptic.Curve
+		switch alg {
+		case "ES256":
+			sum := sha256.Sum256(signingInput)
+			digest, curve = sum[:], elliptic.P256()
+		case "ES384":
+			sum := sha512.Sum384(signingInput)
+			digest, curve = sum[:], elliptic.P384()
+		default:
+			sum := sha512.Sum512(signingInput)
+			digest, curve = sum[:], elliptic.P521()
+		}
+		if pub.Curve != curve {
+			return fmt.Errorf("%s does not match the key's curve", alg)
+		}
+
+		// JWS ECDSA signatures are the fixed-width concatenation R || S.
+		size := (curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("ECDSA signature verification failed")
+		}
+		return nil
+
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("EdDSA requires an Ed25519 key")
+		}
+		if !ed25519.Verify(pub, signingInput, signature) {
+			return errors.New("EdDSA signature verification failed")
+		}
+		return nil
+
+	default:
+		return badSignatureAlgorithm(alg)
+	}
+}
+
+func supportedAlgorithm(alg string) bool {
+	switch alg {
+	case "RS256", "ES256", "ES384", "ES512", "EdDSA":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const problemNamespace = "urn:ietf:params:acme:error:"
+
+// Problem is an RFC 7807 problem document using the ACME error types from
+// RFC 8555 section 6.7. It is returned as an error by the service so that
+// handlers can render it unchanged.
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+func newProblem(errorType string, status int, format string, args ...interface{}) *Problem {
+	return &Problem{
+		Type:   problemNamespace + errorType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+func malformed(format string, args ...interface{}) *Problem {
+	return newProblem("malformed", http.StatusBadRequest, format, args...)
+}
+
+func notFound(resource string) *Problem {
+	return newProblem("malformed", http.StatusNotFound, "%s not found", resource)
+}
+
+func unauthorized(format string, args ...interface{}) *Problem {
+	return newProblem("unauthorized", http.StatusForbidden, format, args...)
+}
+
+func badNonce() *Problem {
+	return newProblem("badNonce", http.StatusBadRequest, "JWS has an invalid anti-replay nonce")
+}
+
+func badSignatureAlgorithm(alg string) *Problem {
+	return newProblem("badSignatureAlgorithm", http.StatusBadRequest, "unsupported signature algorithm %q", alg)
+}
+
+func accountDoesNotExist() *Problem {
+	return newProblem("accountDoesNotExist", http.StatusBadRequest, "no account exists for the provided key")
+}
+
+func rejectedIdentifier(format string, args ...interface{}) *Problem {
+	return newProblem("rejectedIdentifier", http.StatusBadRequest, format, args...)
+}
+
+func unsupportedIdentifier(format string, args ...interface{}) *Problem {
+	return newProblem("unsupportedIdentifier", http.StatusBadRequest, format, args...)
+}
+
+func orderNotReady(status string) *Problem {
+	return newProblem("orderNotReady", http.StatusForbidden, "order is %s, not ready", status)
+}
+
+func badCSR(format string, args ...interface{}) *Problem {
+	return newProblem("badCSR", http.StatusBadRequest, format, args...)
+}
+
+func serverInternal(format string, args ...interface{}) *Problem {
+	return newProblem("serverInternal", http.StatusInternalServerError, format, args...)
+}
+
+func badPublicKey(format string, args ...interface{}) *Problem {
+	return newProblem("badPublicKey", http.StatusBadRequest, format, args...)
+}
+
+func invalidContact(format string, args ...interface{}) *Problem {
+	return newProblem("invalidContact", http.StatusBadRequest, format, args...)
+}
+
+func unsupportedContact(format string, args ...interface{}) *Problem {
+	return newProblem("unsupportedContact", http.StatusBadRequest, format, args...)
+}
+
+func incorrectResponse(format string, args ...interface{}) *Problem {
+	return newProblem("incorrectResponse", http.StatusForbidden, format, args...)
+}
+
+// AsProblem converts err into a problem document. Errors that are not
+// already problems are reported as internal errors without their detail.
+func AsProblem(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+	return serverInternal("internal server error")
+}
+
+// UnsupportedMediaType is returned for POST bodies that are not sent as
+// application/jose+json.
+func UnsupportedMediaType() *Problem {
+	return newProblem("malformed", http.StatusUnsupportedMediaType, "Content-Type must be application/jose+json")
+}
@@ -0,0 +1,1063 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"web-openssl-backend/pkg/openssl"
+)
+
+const (
+	pathPrefix = "/acme"
+
+	defaultValidityDays   = 90
+	nonceLifetime         = time.Hour
+	orderLifetime         = 7 * 24 * time.Hour
+	authorizationLifetime = 30 * 24 * time.Hour
+	validationTimeout     = 30 * time.Second
+
+	// RFC 5280 ub-common-name
+	maxCommonNameLength = 64
+)
+
+var ErrNotConfigured = errors.New("ACME server is not configured")
+
+type Service struct {
+	config     Config
+	issuer     *openssl.Service
+	store      Store
+	validators map[string]ChallengeValidator
+
+	caCert string
+	caKey  string
+
+	// mu serialises state transitions of orders and authorizations, which
+	// are changed both by requests and by background validation.
+	mu sync.Mutex
+
+	nonceMu sync.Mutex
+	nonces  map[string]time.Time
+}
+
+// NewService creates an ACME server that issues certificates through the
+// platform's OpenSSL service. LoadCA must succeed before requests are served.
+func NewService(config Config, issuer *openssl.Service, store Store) *Service {
+	if config.ValidityDays == 0 {
+		config.ValidityDays = defaultValidityDays
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Service{
+		config: config,
+		issuer: issuer,
+		store:  store,
+		validators: map[string]ChallengeValidator{
+			ChallengeHTTP01: NewHTTP01Validator(),
+			ChallengeDNS01:  NewDNS01Validator(),
+		},
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether a CA has been configured for issuance.
+func (s *Service) Enabled() bool {
+	return s.config.CACertFile != "" && s.config.CAKeyFile != ""
+}
+
+// LoadCA reads and checks the issuing CA so that misconfiguration is caught
+// at startup rather than on the first finalize request.
+func (s *Service) LoadCA() error {
+	if !s.Enabled() {
+		return ErrNotConfigured
+	}
+	if s.config.BaseURL == "" {
+		return errors.New("ACME base URL is required")
+	}
+
+	certPEM, err := os.ReadFile(s.config.CACertFile)
+	if err != nil {
+		return fmt.Errorf("failed to read ACME CA certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(s.config.CAKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read ACME CA key: %w", err)
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("ACME CA certificate and key do not match: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse ACME CA certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return errors.New("ACME CA certificate is not a CA certificate")
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("ACME CA certificate does not permit certificate signing")
+	}
+	if time.Now().After(cert.NotAfter) {
+		return errors.New("ACME CA certificate has expired")
+	}
+
+	s.caCert = string(certPEM)
+	s.caKey = string(keyPEM)
+	return nil
+}
+
+// Issue signs csr with the platform CA outside of the ACME order flow, for
+// certificates the platform renews on a user's behalf. names are the
+// subject alternative names to include; as for orders, the subject holds
+// only the first of them.
+func (s *Service) Issue(csrPEM string, names []string) (*openssl.SignCSRResponse, error) {
+	if s.caCert == "" {
+		return nil, ErrNotConfigured
+	}
+
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return nil, errors.New("failed to parse CSR PEM block")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid CSR signature: %w", err)
+	}
+
+	return s.issuer.SignCSR(&openssl.SignCSRRequest{
+		CSR:           csrPEM,
+		CACertificate: s.caCert,
+		CAPrivateKey:  s.caKey,
+		ValidDays:     s.config.ValidityDays,
+		KeyUsage:      keyUsageFor(csr),
+		ExtKeyUsage:   []string{"serverAuth", "clientAuth"},
+		SANs:          names,
+		Subject:       issuedSubject(names),
+	})
+}
+
+// Issued reports whether cert was signed by the platform CA.
+func (s *Service) Issued(cert *x509.Certificate) bool {
+	if s.caCert == "" {
+		return false
+	}
+	block, _ := pem.Decode([]byte(s.caCert))
+	if block == nil {
+		return false
+	}
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(cert.RawIssuer, ca.RawSubject) && cert.CheckSignatureFrom(ca) == nil
+}
+
+// SetValidator replaces the validator used for a challenge type. Passing a
+// nil validator stops the challenge type from being offered.
+func (s *Service) SetValidator(challengeType string, validator ChallengeValidator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if validator == nil {
+		delete(s.validators, challengeType)
+		return
+	}
+	s.validators[challengeType] = validator
+}
+
+// URL returns the absolute URL for a request path.
+func (s *Service) URL(path string) string {
+	return s.config.BaseURL + path
+}
+
+func (s *Service) DirectoryURL() string {
+	return s.URL(pathPrefix + "/directory")
+}
+
+func (s *Service) AccountURL(id string) string {
+	return s.URL(pathPrefix + "/account/" + id)
+}
+
+func (s *Service) OrderURL(id string) string {
+	return s.URL(pathPrefix + "/order/" + id)
+}
+
+func (s *Service) AuthorizationURL(id string) string {
+	return s.URL(pathPrefix + "/authz/" + id)
+}
+
+func (s *Service) Directory() *Directory {
+	return &Directory{
+		NewNonce:   s.URL(pathPrefix + "/new-nonce"),
+		NewAccount: s.URL(pathPrefix + "/new-account"),
+		NewOrder:   s.URL(pathPrefix + "/new-order"),
+	}
+}
+
+func (s *Service) NewNonce() string {
+	nonce := randomID()
+	now := time.Now()
+
+	s.nonceMu.Lock()
+	defer s.nonceMu.Unlock()
+
+	for n, expires := range s.nonces {
+		if now.After(expires) {
+			delete(s.nonces, n)
+		}
+	}
+	s.nonces[nonce] = now.Add(nonceLifetime)
+
+	return nonce
+}
+
+// ParseRequest verifies a JWS request body (RFC 8555 section 6.2) that was
+// posted to url and resolves the account or key that signed it.
+func (s *Service) ParseRequest(body []byte, url string) (*Request, error) {
+	var msg jwsMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, malformed("request body is not a flattened JWS: %v", err)
+	}
+
+	protected, err := decodeSegment(msg.Protected)
+	if err != nil {
+		return nil, malformed("invalid protected header encoding")
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return nil, malformed("invalid protected header: %v", err)
+	}
+
+	if !supportedAlgorithm(header.Alg) {
+		return nil, badSignatureAlgorithm(header.Alg)
+	}
+	if header.URL != url {
+		return nil, unauthorized("JWS url %q does not match the request URL", header.URL)
+	}
+	if !s.consumeNonce(header.Nonce) {
+		return nil, badNonce()
+	}
+
+	req := &Request{URL: url}
+
+	var key crypto.PublicKey
+	switch {
+	case len(header.JWK) > 0 && header.KID != "":
+		return nil, malformed("JWS must not contain both jwk and kid")
+
+	case len(header.JWK) > 0:
+		key, err = parseJWK(header.JWK)
+		if err != nil {
+			return nil, badPublicKey("%v", err)
+		}
+		req.Key = header.JWK
+		req.Thumbprint, err = jwkThumbprint(header.JWK)
+		if err != nil {
+			return nil, badPublicKey("%v", err)
+		}
+
+	case header.KID != "":
+		accountPrefix := s.AccountURL("")
+		if !strings.HasPrefix(header.KID, accountPrefix) {
+			return nil, accountDoesNotExist()
+		}
+		account, err := s.store.GetAccount(strings.TrimPrefix(header.KID, accountPrefix))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, accountDoesNotExist()
+			}
+			return nil, serverInternal("%v", err)
+		}
+		if account.Status != StatusValid {
+			return nil, unauthorized("account is %s", account.Status)
+		}
+		key, err = parseJWK(account.Key)
+		if err != nil {
+			return nil, serverInternal("stored account key is invalid: %v", err)
+		}
+		req.Account = account
+		req.Thumbprint = account.Thumbprint
+
+	default:
+		return nil, malformed("JWS must contain either jwk or kid")
+	}
+
+	signature, err := decodeSegment(msg.Signature)
+	if err != nil {
+		return nil, malformed("invalid signature encoding")
+	}
+	if err := verifyJWSSignature(header.Alg, key, []byte(msg.Protected+"."+msg.Payload), signature); err != nil {
+		var problem *Problem
+		if errors.As(err, &problem) {
+			return nil, problem
+		}
+		return nil, malformed("JWS signature is invalid: %v", err)
+	}
+
+	req.Payload, err = decodeSegment(msg.Payload)
+	if err != nil {
+		return nil, malformed("invalid payload encoding")
+	}
+
+	return req, nil
+}
+
+func (s *Service) NewAccount(req *Request) (*Account, bool, error) {
+	if req.Account != nil || req.Key == nil {
+		return nil, false, malformed("newAccount requests must be signed with a jwk")
+	}
+
+	var payload newAccountPayload
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return nil, false, err
+	}
+
+	existing, err := s.store.GetAccountByThumbprint(req.Thumbprint)
+	if err == nil {
+		if existing.Status != StatusValid {
+			return nil, false, unauthorized("account is %s", existing.Status)
+		}
+		return s.accountResource(existing), false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, false, serverInternal("%v", err)
+	}
+
+	if payload.OnlyReturnExisting {
+		return nil, false, accountDoesNotExist()
+	}
+	if err := validateContacts(payload.Contact); err != nil {
+		return nil, false, err
+	}
+
+	account := &Account{
+		ID:                   randomID(),
+		Key:                  req.Key,
+		Thumbprint:           req.Thumbprint,
+		CreatedAt:            time.Now(),
+		Status:               StatusValid,
+		Contact:              payload.Contact,
+		TermsOfServiceAgreed: payload.TermsOfServiceAgreed,
+	}
+	if err := s.store.CreateAccount(account); err != nil {
+		return nil, false, serverInternal("%v", err)
+	}
+
+	return s.accountResource(account), true, nil
+}
+
+func (s *Service) UpdateAccount(req *Request, id string) (*Account, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, err
+	}
+	if account.ID != id {
+		return nil, unauthorized("account does not match the signing key")
+	}
+
+	if req.IsPostAsGet() {
+		return s.accountResource(account), nil
+	}
+
+	var payload updateAccountPayload
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Contact != nil {
+		if err := validateContacts(payload.Contact); err != nil {
+			return nil, err
+		}
+		account.Contact = payload.Contact
+	}
+	switch payload.Status {
+	case "":
+	case StatusDeactivated:
+		account.Status = StatusDeactivated
+	default:
+		return nil, malformed("account status can only be changed to deactivated")
+	}
+
+	if err := s.store.UpdateAccount(account); err != nil {
+		return nil, serverInternal("%v", err)
+	}
+
+	return s.accountResource(account), nil
+}
+
+func (s *Service) ListOrders(req *Request, accountID string) (*OrderList, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, err
+	}
+	if account.ID != accountID {
+		return nil, unauthorized("account does not match the signing key")
+	}
+
+	orders, err := s.store.ListOrders(account.ID)
+	if err != nil {
+		return nil, serverInternal("%v", err)
+	}
+
+	list := &OrderList{Orders: []string{}}
+	for _, order := range orders {
+		if order.Status == StatusPending || order.Status == StatusReady || order.Status == StatusProcessing {
+			list.Orders = append(list.Orders, s.OrderURL(order.ID))
+		}
+	}
+	return list, nil
+}
+
+func (s *Service) NewOrder(req *Request) (*Order, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload newOrderPayload
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return nil, err
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return nil, malformed("notBefore and notAfter are not supported")
+	}
+
+	identifiers, err := s.normalizeIdentifiers(payload.Identifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{
+		ID:          randomID(),
+		AccountID:   account.ID,
+		Status:      StatusPending,
+		Expires:     time.Now().Add(orderLifetime).UTC().Truncate(time.Second),
+		Identifiers: identifiers,
+	}
+
+	for _, identifier := range identifiers {
+		authz := s.newAuthorization(account.ID, identifier, order.Expires)
+		if err := s.store.CreateAuthorization(authz); err != nil {
+			return nil, serverInternal("%v", err)
+		}
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	if err := s.store.CreateOrder(order); err != nil {
+		return nil, serverInternal("%v", err)
+	}
+
+	return s.orderResource(order), nil
+}
+
+func (s *Service) GetOrder(req *Request, id string) (*Order, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, err := s.ownedOrder(account, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshOrder(order); err != nil {
+		return nil, err
+	}
+
+	return s.orderResource(order), nil
+}
+
+// Finalize checks the CSR against the order and issues the certificate.
+func (s *Service) Finalize(req *Request, id string) (*Order, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload finalizePayload
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	order, err := s.ownedOrder(account, id)
+	if err == nil {
+		err = s.refreshOrder(order)
+	}
+	if err == nil && order.Status != StatusReady {
+		err = orderNotReady(order.Status)
+	}
+	var csr *x509.CertificateRequest
+	var names []string
+	if err == nil {
+		csr, names, err = s.checkCSR(account, order, payload.CSR)
+	}
+	if err == nil {
+		order.Status = StatusProcessing
+		if updateErr := s.store.UpdateOrder(order); updateErr != nil {
+			err = serverInternal("%v", updateErr)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	issued, issueErr := s.issuer.SignCSR(&openssl.SignCSRRequest{
+		CSR:           string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+		CACertificate: s.caCert,
+		CAPrivateKey:  s.caKey,
+		ValidDays:     s.config.ValidityDays,
+		KeyUsage:      keyUsageFor(csr),
+		ExtKeyUsage:   []string{"serverAuth", "clientAuth"},
+		SANs:          names,
+		Subject:       issuedSubject(names),
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if issueErr == nil {
+		cert := &Certificate{
+			ID:           randomID(),
+			AccountID:    account.ID,
+			OrderID:      order.ID,
+			SerialNumber: issued.SerialNumber,
+			Chain:        issued.Chain,
+			CreatedAt:    time.Now(),
+		}
+		if err := s.store.CreateCertificate(cert); err != nil {
+			issueErr = err
+		} else {
+			order.Status = StatusValid
+			order.CertificateID = cert.ID
+		}
+	}
+	if issueErr != nil {
+		log.Printf("ACME issuance failed for order %s: %v", order.ID, issueErr)
+		order.Status = StatusInvalid
+		order.Error = serverInternal("certificate issuance failed")
+	}
+
+	if err := s.store.UpdateOrder(order); err != nil {
+		return nil, serverInternal("%v", err)
+	}
+
+	return s.orderResource(order), nil
+}
+
+func (s *Service) GetAuthorization(req *Request, id string) (*Authorization, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authz, err := s.store.GetAuthorization(id)
+	if err != nil {
+		return nil, storeError(err, "authorization")
+	}
+	if authz.AccountID != account.ID {
+		return nil, unauthorized("authorization belongs to another account")
+	}
+	if err := s.refreshAuthorization(authz); err != nil {
+		return nil, err
+	}
+
+	if !req.IsPostAsGet() {
+		var payload updateAuthorizationPayload
+		if err := unmarshalPayload(req, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Status != StatusDeactivated {
+			return nil, malformed("authorization status can only be changed to deactivated")
+		}
+		if authz.Status != StatusPending && authz.Status != StatusValid {
+			return nil, malformed("authorization is %s", authz.Status)
+		}
+		authz.Status = StatusDeactivated
+		if err := s.store.UpdateAuthorization(authz); err != nil {
+			return nil, serverInternal("%v", err)
+		}
+	}
+
+	return s.authorizationResource(authz), nil
+}
+
+// RespondChallenge starts validation of a challenge. Validation runs in the
+// background; clients poll the authorization for the result. It returns the
+// challenge and the URL of the authorization it belongs to.
+func (s *Service) RespondChallenge(req *Request, id string) (*Challenge, string, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authz, err := s.store.GetAuthorizationByChallenge(id)
+	if err != nil {
+		return nil, "", storeError(err, "challenge")
+	}
+	if authz.AccountID != account.ID {
+		return nil, "", unauthorized("challenge belongs to another account")
+	}
+	if err := s.refreshAuthorization(authz); err != nil {
+		return nil, "", err
+	}
+
+	challenge := findChallenge(authz, id)
+
+	// A POST-as-GET only reads the challenge; the client signals that it
+	// is ready for validation by posting an empty JSON object.
+	if !req.IsPostAsGet() && authz.Status == StatusPending && challenge.Status == StatusPending {
+		validator, ok := s.validators[challenge.Type]
+		if !ok {
+			return nil, "", malformed("challenge type %s is not supported", challenge.Type)
+		}
+
+		challenge.Status = StatusProcessing
+		if err := s.store.UpdateAuthorization(authz); err != nil {
+			return nil, "", serverInternal("%v", err)
+		}
+
+		keyAuthorization := challenge.Token + "." + account.Thumbprint
+		go s.validate(validator, authz.ID, challenge.ID, authz.Identifier.Value, challenge.Token, keyAuthorization)
+	}
+
+	return s.challengeResource(challenge), s.AuthorizationURL(authz.ID), nil
+}
+
+// GetCertificate returns the PEM certificate chain, leaf first.
+func (s *Service) GetCertificate(req *Request, id string) (string, error) {
+	account, err := s.requireAccount(req)
+	if err != nil {
+		return "", err
+	}
+
+	cert, err := s.store.GetCertificate(id)
+	if err != nil {
+		return "", storeError(err, "certificate")
+	}
+	if cert.AccountID != account.ID {
+		return "", unauthorized("certificate belongs to another account")
+	}
+
+	return cert.Chain, nil
+}
+
+// Helper functions
+
+func (s *Service) consumeNonce(nonce string) bool {
+	s.nonceMu.Lock()
+	defer s.nonceMu.Unlock()
+
+	expires, ok := s.nonces[nonce]
+	if !ok {
+		return false
+	}
+	delete(s.nonces, nonce)
+	return time.Now().Before(expires)
+}
+
+func (s *Service) requireAccount(req *Request) (*Account, error) {
+	if req.Account == nil {
+		return nil, malformed("request must be signed with an account kid")
+	}
+	return req.Account, nil
+}
+
+func (s *Service) ownedOrder(account *Account, id string) (*Order, error) {
+	order, err := s.store.GetOrder(id)
+	if err != nil {
+		return nil, storeError(err, "order")
+	}
+	if order.AccountID != account.ID {
+		return nil, unauthorized("order belongs to another account")
+	}
+	return order, nil
+}
+
+// refreshOrder derives the order status from its authorizations and expiry.
+// The caller must hold s.mu.
+func (s *Service) refreshOrder(order *Order) error {
+	status := order.Status
+
+	switch order.Status {
+	case StatusPending:
+		allValid := true
+		for _, authzID := range order.AuthorizationIDs {
+			authz, err := s.store.GetAuthorization(authzID)
+			if err != nil {
+				return storeError(err, "authorization")
+			}
+			if err := s.refreshAuthorization(authz); err != nil {
+				return err
+			}
+			switch authz.Status {
+			case StatusValid:
+			case StatusPending:
+				allValid = false
+			default:
+				order.Status = StatusInvalid
+				order.Error = unauthorized("authorization for %s is %s", authz.Identifier.Value, authz.Status)
+			}
+		}
+		if order.Status == StatusPending && allValid {
+			order.Status = StatusReady
+		}
+		if order.Status == StatusPending && time.Now().After(order.Expires) {
+			order.Status = StatusInvalid
+		}
+	case StatusReady:
+		if time.Now().After(order.Expires) {
+			order.Status = StatusInvalid
+		}
+	}
+
+	if order.Status != status {
+		if err := s.store.UpdateOrder(order); err != nil {
+			return serverInternal("%v", err)
+		}
+	}
+	return nil
+}
+
+// refreshAuthorization expires authorizations past their lifetime. The
+// caller must hold s.mu.
+func (s *Service) refreshAuthorization(authz *Authorization) error {
+	if (authz.Status == StatusPending || authz.Status == StatusValid) && time.Now().After(authz.Expires) {
+		authz.Status = StatusExpired
+		if err := s.store.UpdateAuthorization(authz); err != nil {
+			return serverInternal("%v", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) validate(validator ChallengeValidator, authzID, challengeID, domain, token, keyAuthorization string) {
+	ctx, cancel := context.WithTimeout(context.Background(), validationTimeout)
+	defer cancel()
+
+	validationErr := validator.Validate(ctx, domain, token, keyAuthorization)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authz, err := s.store.GetAuthorization(authzID)
+	if err != nil {
+		log.Printf("ACME validation of %s: %v", authzID, err)
+		return
+	}
+	challenge := findChallenge(authz, challengeID)
+	if challenge == nil || challenge.Status != StatusProcessing {
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if validationErr == nil {
+		challenge.Status = StatusValid
+		challenge.Validated = &now
+		authz.Status = StatusValid
+		authz.Expires = now.Add(authorizationLifetime)
+	} else {
+		challenge.Status = StatusInvalid
+		challenge.Error = incorrectResponse("%v", validationErr)
+		authz.Status = StatusInvalid
+	}
+
+	if err := s.store.UpdateAuthorization(authz); err != nil {
+		log.Printf("ACME validation of %s: %v", authzID, err)
+	}
+}
+
+func (s *Service) newAuthorization(accountID string, identifier Identifier, expires time.Time) *Authorization {
+	authz := &Authorization{
+		ID:         randomID(),
+		AccountID:  accountID,
+		Status:     StatusPending,
+		Expires:    expires,
+		Identifier: Identifier{Type: IdentifierDNS, Value: strings.TrimPrefix(identifier.Value, "*.")},
+		Wildcard:   strings.HasPrefix(identifier.Value, "*."),
+	}
+
+	// Wildcard names can only be proven through DNS (RFC 8555 section 7.1.3).
+	challengeTypes := []string{ChallengeHTTP01, ChallengeDNS01}
+	if authz.Wildcard {
+		challengeTypes = []string{ChallengeDNS01}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, challengeType := range challengeTypes {
+		if _, ok := s.validators[challengeType]; !ok {
+			continue
+		}
+		authz.Challenges = append(authz.Challenges, &Challenge{
+			ID:     randomID(),
+			Type:   challengeType,
+			Status: StatusPending,
+			Token:  randomToken(),
+		})
+	}
+
+	return authz
+}
+
+func (s *Service) normalizeIdentifiers(identifiers []Identifier) ([]Identifier, error) {
+	if len(identifiers) == 0 {
+		return nil, malformed("order must contain at least one identifier")
+	}
+
+	seen := make(map[string]bool)
+	var normalized []Identifier
+	for _, identifier := range identifiers {
+		if identifier.Type != IdentifierDNS {
+			return nil, unsupportedIdentifier("identifier type %q is not supported", identifier.Type)
+		}
+
+		name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(identifier.Value)), ".")
+		if err := validateDNSName(name); err != nil {
+			return nil, rejectedIdentifier("%s: %v", identifier.Value, err)
+		}
+		if !s.domainAllowed(strings.TrimPrefix(name, "*.")) {
+			return nil, rejectedIdentifier("%s is not permitted by this CA", identifier.Value)
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, Identifier{Type: IdentifierDNS, Value: name})
+		}
+	}
+
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i].Value < normalized[j].Value })
+	return normalized, nil
+}
+
+func (s *Service) domainAllowed(name string) bool {
+	if len(s.config.AllowedDomains) == 0 {
+		return true
+	}
+	for _, domain := range s.config.AllowedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCSR verifies a finalize CSR against the order and returns it together
+// with the names to certify. Only its key and names are used; the issued
+// subject is built from the names by issuedSubject.
+func (s *Service) checkCSR(account *Account, order *Order, encoded string) (*x509.CertificateRequest, []string, error) {
+	der, err := decodeSegment(encoded)
+	if err != nil {
+		return nil, nil, badCSR("csr must be base64url encoded DER")
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, nil, badCSR("failed to parse CSR: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, badCSR("CSR signature is invalid: %v", err)
+	}
+	if len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return nil, nil, badCSR("CSR may only contain DNS names")
+	}
+
+	accountKey, err := parseJWK(account.Key)
+	if err == nil {
+		if pub, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(accountKey) {
+			return nil, nil, badCSR("certificate key must differ from the account key")
+		}
+	}
+
+	requested := make(map[string]bool)
+	if csr.Subject.CommonName != "" {
+		requested[strings.ToLower(csr.Subject.CommonName)] = true
+	}
+	for _, name := range csr.DNSNames {
+		requested[strings.ToLower(name)] = true
+	}
+
+	names := make([]string, 0, len(order.Identifiers))
+	for _, identifier := range order.Identifiers {
+		if !requested[identifier.Value] {
+			return nil, nil, badCSR("CSR does not include %s", identifier.Value)
+		}
+		delete(requested, identifier.Value)
+		names = append(names, identifier.Value)
+	}
+	for name := range requested {
+		return nil, nil, badCSR("CSR includes %s, which is not in the order", name)
+	}
+
+	return csr, names, nil
+}
+
+// issuedSubject is the subject of a certificate from the platform CA: the
+// first name as common name, or nothing when it is too long for one. Other
+// attributes of the CSR, such as O or C, are not validated and never signed.
+func issuedSubject(names []string) *openssl.Subject {
+	subject := &openssl.Subject{}
+	if len(names) > 0 && len(names[0]) <= maxCommonNameLength {
+		subject.CommonName = names[0]
+	}
+	return subject
+}
+
+func (s *Service) accountResource(account *Account) *Account {
+	account.Orders = s.AccountURL(account.ID) + "/orders"
+	return account
+}
+
+func (s *Service) orderResource(order *Order) *Order {
+	order.Authorizations = make([]string, len(order.AuthorizationIDs))
+	for i, id := range order.AuthorizationIDs {
+		order.Authorizations[i] = s.AuthorizationURL(id)
+	}
+	order.Finalize = s.OrderURL(order.ID) + "/finalize"
+	if order.CertificateID != "" {
+		order.Certificate = s.URL(pathPrefix + "/cert/" + order.CertificateID)
+	}
+	return order
+}
+
+func (s *Service) authorizationResource(authz *Authorization) *Authorization {
+	for _, challenge := range authz.Challenges {
+		s.challengeResource(challenge)
+	}
+	return authz
+}
+
+func (s *Service) challengeResource(challenge *Challenge) *Challenge {
+	challenge.URL = s.URL(pathPrefix + "/chall/" + challenge.ID)
+	return challenge
+}
+
+func findChallenge(authz *Authorization, id string) *Challenge {
+	for _, challenge := range authz.Challenges {
+		if challenge.ID == id {
+			return challenge
+		}
+	}
+	return nil
+}
+
+func unmarshalPayload(req *Request, v interface{}) error {
+	if req.IsPostAsGet() {
+		return nil
+	}
+	if err := json.Unmarshal(req.Payload, v); err != nil {
+		return malformed("invalid payload: %v", err)
+	}
+	return nil
+}
+
+func storeError(err error, resource string) error {
+	if errors.Is(err, ErrNotFound) {
+		return notFound(resource)
+	}
+	return serverInternal("%v", err)
+}
+
+func validateContacts(contacts []string) error {
+	for _, contact := range contacts {
+		address, found := strings.CutPrefix(contact, "mailto:")
+		if !found {
+			return unsupportedContact("only mailto: contacts are supported")
+		}
+		if _, err := mail.ParseAddress(address); err != nil {
+			return invalidContact("invalid email address %q", address)
+		}
+	}
+	return nil
+}
+
+func validateDNSName(name string) error {
+	if net.ParseIP(name) != nil {
+		return errors.New("IP address identifiers are not supported")
+	}
+	if len(name) > 253 {
+		return errors.New("name is too long")
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return errors.New("name must be fully qualified")
+	}
+	for i, label := range labels {
+		if label == "*" && i == 0 {
+			continue
+		}
+		if len(label) == 0 || len(label) > 63 {
+			return errors.New("invalid label length")
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return errors.New("labels must not start or end with a hyphen")
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("invalid character %q", r)
+			}
+		}
+	}
+	if labels[0] == "*" && len(labels) < 3 {
+		return errors.New("wildcard must be below a registered domain")
+	}
+	return nil
+}
+
+// keyUsageFor picks key usages matching the CSR key type, since
+// keyEncipherment is only meaningful for RSA keys.
+func keyUsageFor(csr *x509.CertificateRequest) []string {
+	if _, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		return []string{"digitalSignature", "keyEncipherment"}
+	}
+	return []string{"digitalSignature"}
+}
+
+func randomID() string {
+	return randomString(16)
+}
+
+// randomToken returns a challenge token with 256 bits of entropy; RFC 8555
+// requires at least 128.
+func randomToken() string {
+	return randomString(32)
+}
+
+func randomString(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return encodeSegment(b)
+}
@@ -0,0 +1,331 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/pkg/acme/acmetest"
+	"web-openssl-backend/pkg/openssl"
+)
+
+const testBaseURL = "https://acme.test"
+
+// stubValidator stands in for the network checks of a challenge type
+type stubValidator struct {
+	err error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (v *stubValidator) Validate(ctx context.Context, domain, token, keyAuthorization string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.calls = append(v.calls, domain+" "+keyAuthorization)
+	return v.err
+}
+
+// testClient signs requests the way an ACME client does, with ES256
+type testClient struct {
+	t   *testing.T
+	s   *Service
+	key *ecdsa.PrivateKey
+	kid string
+}
+
+func newTestClient(t *testing.T, s *Service) *testClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t: t, s: s, key: key}
+}
+
+func (c *testClient) jwk() json.RawMessage {
+	size := (c.key.Curve.Params().BitSize + 7) / 8
+	jwk, _ := json.Marshal(jsonWebKey{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   encodeSegment(c.key.X.FillBytes(make([]byte, size))),
+		Y:   encodeSegment(c.key.Y.FillBytes(make([]byte, size))),
+	})
+	return jwk
+}
+
+// sign builds the flattened JWS of payload for url; a nil payload makes a
+// POST-as-GET
+func (c *testClient) sign(url, nonce string, payload interface{}) []byte {
+	header := map[string]interface{}{"alg": "ES256", "nonce": nonce, "url": url}
+	if c.kid != "" {
+		header["kid"] = c.kid
+	} else {
+		header["jwk"] = c.jwk()
+	}
+	protected, _ := json.Marshal(header)
+
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+	msg := jwsMessage{Protected: encodeSegment(protected), Payload: encodeSegment(body)}
+
+	digest := sha256.Sum256([]byte(msg.Protected + "." + msg.Payload))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	msg.Signature = encodeSegment(signature)
+
+	encoded, _ := json.Marshal(msg)
+	return encoded
+}
+
+func (c *testClient) post(url string, payload interface{}) *Request {
+	c.t.Helper()
+	req, err := c.s.ParseRequest(c.sign(url, c.s.NewNonce(), payload), url)
+	if err != nil {
+		c.t.Fatalf("request to %s rejected: %v", url, err)
+	}
+	return req
+}
+
+func (c *testClient) register() {
+	c.t.Helper()
+	account, created, err := c.s.NewAccount(c.post(c.s.Directory().NewAccount, map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	}))
+	if err != nil || !created {
+		c.t.Fatalf("NewAccount: created %v, %v", created, err)
+	}
+	c.kid = c.s.AccountURL(account.ID)
+}
+
+func (c *testClient) order(names ...string) *Order {
+	c.t.Helper()
+	identifiers := make([]Identifier, len(names))
+	for i, name := range names {
+		identifiers[i] = Identifier{Type: IdentifierDNS, Value: name}
+	}
+	order, err := c.s.NewOrder(c.post(c.s.Directory().NewOrder, map[string]interface{}{"identifiers": identifiers}))
+	if err != nil {
+		c.t.Fatalf("NewOrder: %v", err)
+	}
+	return order
+}
+
+func (c *testClient) authorization(order *Order, i int) *Authorization {
+	c.t.Helper()
+	authz, err := c.s.GetAuthorization(c.post(order.Authorizations[i], nil), order.AuthorizationIDs[i])
+	if err != nil {
+		c.t.Fatalf("GetAuthorization: %v", err)
+	}
+	return authz
+}
+
+// respond asks for validation of the authorization's challenge of the type
+// and waits until validation has finished
+func (c *testClient) respond(order *Order, challengeType string) *Authorization {
+	c.t.Helper()
+	authz := c.authorization(order, 0)
+	var challenge *Challenge
+	for _, ch := range authz.Challenges {
+		if ch.Type == challengeType {
+			challenge = ch
+		}
+	}
+	if challenge == nil {
+		c.t.Fatalf("no %s challenge offered", challengeType)
+	}
+	if _, _, err := c.s.RespondChallenge(c.post(challenge.URL, map[string]interface{}{}), challenge.ID); err != nil {
+		c.t.Fatalf("RespondChallenge: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		authz = c.authorization(order, 0)
+		if authz.Status != StatusPending {
+			return authz
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.t.Fatal("challenge validation did not finish")
+	return nil
+}
+
+func newTestService(t *testing.T, validator ChallengeValidator) *Service {
+	t.Helper()
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl is not installed")
+	}
+
+	certFile, keyFile := acmetest.WriteCA(t)
+	s := NewService(Config{BaseURL: testBaseURL, CACertFile: certFile, CAKeyFile: keyFile}, openssl.NewService("openssl"), NewMemoryStore())
+	if err := s.LoadCA(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetValidator(ChallengeHTTP01, validator)
+	s.SetValidator(ChallengeDNS01, validator)
+	return s
+}
+
+func testCSR(t *testing.T, subject pkix.Name, names ...string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject, DNSNames: names}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeSegment(der)
+}
+
+func TestChallengeFlowIssuesCertificate(t *testing.T) {
+	validator := &stubValidator{}
+	s := newTestService(t, validator)
+	client := newTestClient(t, s)
+	client.register()
+
+	order := client.order("www.example.com")
+	if order.Status != StatusPending {
+		t.Fatalf("new order is %s, want pending", order.Status)
+	}
+
+	authz := client.respond(order, ChallengeHTTP01)
+	if authz.Status != StatusValid {
+		t.Fatalf("authorization is %s, want valid", authz.Status)
+	}
+	thumbprint, _ := jwkThumbprint(client.jwk())
+	if len(validator.calls) != 1 || validator.calls[0] != "www.example.com "+authz.Challenges[0].Token+"."+thumbprint {
+		t.Fatalf("validator called with %q", validator.calls)
+	}
+
+	order, err := s.GetOrder(client.post(s.OrderURL(order.ID), nil), order.ID)
+	if err != nil || order.Status != StatusReady {
+		t.Fatalf("order is %v, %v; want ready", order, err)
+	}
+
+	// Subject attributes besides the names are never signed
+	csr := testCSR(t, pkix.Name{CommonName: "www.example.com", Organization: []string{"Not Verified Inc"}}, "www.example.com")
+	order, err = s.Finalize(client.post(order.Finalize, map[string]string{"csr": csr}), order.ID)
+	if err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+	if order.Status != StatusValid || order.Certificate == "" {
+		t.Fatalf("finalized order is %s, error %v", order.Status, order.Error)
+	}
+
+	chain, err := s.GetCertificate(client.post(order.Certificate, nil), order.CertificateID)
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	block, _ := pem.Decode([]byte(chain))
+	if block == nil {
+		t.Fatal("certificate chain is not PEM")
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "www.example.com" {
+		t.Errorf("certificate names %v, want www.example.com", leaf.DNSNames)
+	}
+	if leaf.Subject.CommonName != "www.example.com" || len(leaf.Subject.Organization) != 0 {
+		t.Errorf("certificate subject %q, want only the common name", leaf.Subject)
+	}
+	if !s.Issued(leaf) {
+		t.Error("certificate is not issued by the ACME CA")
+	}
+}
+
+func TestFailedChallengeInvalidatesOrder(t *testing.T) {
+	s := newTestService(t, &stubValidator{err: errors.New("connection refused")})
+	client := newTestClient(t, s)
+	client.register()
+
+	order := client.order("www.example.com")
+	authz := client.respond(order, ChallengeDNS01)
+	if authz.Status != StatusInvalid {
+		t.Fatalf("authorization is %s, want invalid", authz.Status)
+	}
+	if authz.Challenges[1].Error == nil || !strings.Contains(authz.Challenges[1].Error.Detail, "connection refused") {
+		t.Errorf("challenge error %+v, want the validation failure", authz.Challenges[1].Error)
+	}
+
+	csr := testCSR(t, pkix.Name{}, "www.example.com")
+	_, err := s.Finalize(client.post(order.Finalize, map[string]string{"csr": csr}), order.ID)
+	if problem := AsProblem(err); problem == nil || !strings.HasSuffix(problem.Type, "orderNotReady") {
+		t.Fatalf("Finalize of an invalid order: %v, want orderNotReady", err)
+	}
+}
+
+func TestWildcardOffersOnlyDNSChallenge(t *testing.T) {
+	s := newTestService(t, &stubValidator{})
+	client := newTestClient(t, s)
+	client.register()
+
+	authz := client.authorization(client.order("*.example.com"), 0)
+	if !authz.Wildcard || len(authz.Challenges) != 1 || authz.Challenges[0].Type != ChallengeDNS01 {
+		t.Fatalf("wildcard authorization offers %+v, want only dns-01", authz.Challenges)
+	}
+}
+
+func TestFinalizeRejectsNamesOutsideOrder(t *testing.T) {
+	s := newTestService(t, &stubValidator{})
+	client := newTestClient(t, s)
+	client.register()
+
+	order := client.order("www.example.com")
+	client.respond(order, ChallengeHTTP01)
+
+	csr := testCSR(t, pkix.Name{}, "www.example.com", "mail.example.com")
+	_, err := s.Finalize(client.post(order.Finalize, map[string]string{"csr": csr}), order.ID)
+	if problem := AsProblem(err); problem == nil || !strings.HasSuffix(problem.Type, "badCSR") {
+		t.Fatalf("Finalize with an extra name: %v, want badCSR", err)
+	}
+}
+
+func TestOtherAccountCannotRespondToChallenge(t *testing.T) {
+	s := newTestService(t, &stubValidator{})
+	owner := newTestClient(t, s)
+	owner.register()
+	other := newTestClient(t, s)
+	other.register()
+
+	authz := owner.authorization(owner.order("www.example.com"), 0)
+	challenge := authz.Challenges[0]
+	_, _, err := s.RespondChallenge(other.post(challenge.URL, map[string]interface{}{}), challenge.ID)
+	if problem := AsProblem(err); problem == nil || !strings.HasSuffix(problem.Type, "unauthorized") {
+		t.Fatalf("RespondChallenge by another account: %v, want unauthorized", err)
+	}
+}
+
+func TestReplayedNonceIsRejected(t *testing.T) {
+	s := newTestService(t, &stubValidator{})
+	client := newTestClient(t, s)
+
+	url := s.Directory().NewAccount
+	body := client.sign(url, s.NewNonce(), map[string]interface{}{"termsOfServiceAgreed": true})
+	if _, err := s.ParseRequest(body, url); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	_, err := s.ParseRequest(body, url)
+	if problem := AsProblem(err); problem == nil || !strings.HasSuffix(problem.Type, "badNonce") {
+		t.Fatalf("replayed request: %v, want badNonce", err)
+	}
+}
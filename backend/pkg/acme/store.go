@@ -0,0 +1,229 @@
+package acme
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Store persists ACME state. Implementations must return copies so that
+// callers can modify a record without affecting the stored one until it is
+// written back with the matching Update method.
+type Store interface {
+	CreateAccount(account *Account) error
+	GetAccount(id string) (*Account, error)
+	GetAccountByThumbprint(thumbprint string) (*Account, error)
+	UpdateAccount(account *Account) error
+
+	CreateOrder(order *Order) error
+	GetOrder(id string) (*Order, error)
+	ListOrders(accountID string) ([]*Order, error)
+	UpdateOrder(order *Order) error
+
+	CreateAuthorization(authz *Authorization) error
+	GetAuthorization(id string) (*Authorization, error)
+	GetAuthorizationByChallenge(challengeID string) (*Authorization, error)
+	UpdateAuthorization(authz *Authorization) error
+
+	CreateCertificate(cert *Certificate) error
+	GetCertificate(id string) (*Certificate, error)
+}
+
+// MemoryStore keeps ACME state in process memory. Accounts do not survive a
+// restart, so it suits tests; the server keeps its state in the database.
+type MemoryStore struct {
+	mu             sync.RWMutex
+	accounts       map[string]*Account
+	orders         map[string]*Order
+	authorizations map[string]*Authorization
+	challenges     map[string]string
+	certificates   map[string]*Certificate
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		accounts:       make(map[string]*Account),
+		orders:         make(map[string]*Order),
+		authorizations: make(map[string]*Authorization),
+		challenges:     make(map[string]string),
+		certificates:   make(map[string]*Certificate),
+	}
+}
+
+func (m *MemoryStore) CreateAccount(account *Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounts[account.ID] = copyAccount(account)
+	return nil
+}
+
+func (m *MemoryStore) GetAccount(id string) (*Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	account, ok := m.accounts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyAccount(account), nil
+}
+
+func (m *MemoryStore) GetAccountByThumbprint(thumbprint string) (*Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, account := range m.accounts {
+		if account.Thumbprint == thumbprint {
+			return copyAccount(account), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) UpdateAccount(account *Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[account.ID]; !ok {
+		return ErrNotFound
+	}
+	m.accounts[account.ID] = copyAccount(account)
+	return nil
+}
+
+func (m *MemoryStore) CreateOrder(order *Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+func (m *MemoryStore) GetOrder(id string) (*Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyOrder(order), nil
+}
+
+func (m *MemoryStore) ListOrders(accountID string) ([]*Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var orders []*Order
+	for _, order := range m.orders {
+		if order.AccountID == accountID {
+			orders = append(orders, copyOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Expires.Before(orders[j].Expires) })
+	return orders, nil
+}
+
+func (m *MemoryStore) UpdateOrder(order *Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.orders[order.ID]; !ok {
+		return ErrNotFound
+	}
+	m.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+func (m *MemoryStore) CreateAuthorization(authz *Authorization) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authorizations[authz.ID] = copyAuthorization(authz)
+	for _, challenge := range authz.Challenges {
+		m.challenges[challenge.ID] = authz.ID
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAuthorization(id string) (*Authorization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	authz, ok := m.authorizations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyAuthorization(authz), nil
+}
+
+func (m *MemoryStore) GetAuthorizationByChallenge(challengeID string) (*Authorization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	authzID, ok := m.challenges[challengeID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyAuthorization(m.authorizations[authzID]), nil
+}
+
+func (m *MemoryStore) UpdateAuthorization(authz *Authorization) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.authorizations[authz.ID]; !ok {
+		return ErrNotFound
+	}
+	m.authorizations[authz.ID] = copyAuthorization(authz)
+	return nil
+}
+
+func (m *MemoryStore) CreateCertificate(cert *Certificate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *cert
+	m.certificates[cert.ID] = &stored
+	return nil
+}
+
+func (m *MemoryStore) GetCertificate(id string) (*Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cert, ok := m.certificates[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *cert
+	return &found, nil
+}
+
+// Helper functions
+
+func copyAccount(account *Account) *Account {
+	c := *account
+	c.Key = append([]byte(nil), account.Key...)
+	c.Contact = append([]string(nil), account.Contact...)
+	return &c
+}
+
+func copyOrder(order *Order) *Order {
+	c := *order
+	c.AuthorizationIDs = append([]string(nil), order.AuthorizationIDs...)
+	c.Identifiers = append([]Identifier(nil), order.Identifiers...)
+	c.Authorizations = append([]string(nil), order.Authorizations...)
+	if order.Error != nil {
+		problem := *order.Error
+		c.Error = &problem
+	}
+	return &c
+}
+
+func copyAuthorization(authz *Authorization) *Authorization {
+	c := *authz
+	c.Challenges = make([]*Challenge, len(authz.Challenges))
+	for i, challenge := range authz.Challenges {
+		cc := *challenge
+		if challenge.Validated != nil {
+			validated := *challenge.Validated
+			cc.Validated = &validated
+		}
+		if challenge.Error != nil {
+			problem := *challenge.Error
+			cc.Error = &problem
+		}
+		c.Challenges[i] = &cc
+	}
+	return &c
+}
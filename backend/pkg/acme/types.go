@@ -0,0 +1,152 @@
+package acme
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Config struct {
+	// BaseURL is the externally visible origin of the server, e.g.
+	// https://pki.example.com. Every resource URL is derived from it and
+	// JWS "url" headers are checked against it.
+	BaseURL        string
+	CACertFile     string
+	CAKeyFile      string
+	ValidityDays   int
+	AllowedDomains []string
+}
+
+const (
+	StatusPending     = "pending"
+	StatusReady       = "ready"
+	StatusProcessing  = "processing"
+	StatusValid       = "valid"
+	StatusInvalid     = "invalid"
+	StatusDeactivated = "deactivated"
+	StatusExpired     = "expired"
+	StatusRevoked     = "revoked"
+
+	ChallengeHTTP01 = "http-01"
+	ChallengeDNS01  = "dns-01"
+
+	IdentifierDNS = "dns"
+)
+
+type Directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Account, Order, Authorization and Challenge double as the stored records
+// and the RFC 8555 resource objects. Fields tagged json:"-" are internal;
+// URL fields are filled in by the service before a resource is returned.
+
+type Account struct {
+	ID         string          `json:"-"`
+	Key        json.RawMessage `json:"-"`
+	Thumbprint string          `json:"-"`
+	CreatedAt  time.Time       `json:"-"`
+
+	Status               string   `json:"status"`
+	Contact              []string `json:"contact,omitempty"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed,omitempty"`
+	Orders               string   `json:"orders"`
+}
+
+type Order struct {
+	ID               string   `json:"-"`
+	AccountID        string   `json:"-"`
+	AuthorizationIDs []string `json:"-"`
+	CertificateID    string   `json:"-"`
+
+	Status         string       `json:"status"`
+	Expires        time.Time    `json:"expires"`
+	Identifiers    []Identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Error          *Problem     `json:"error,omitempty"`
+}
+
+type Authorization struct {
+	ID        string `json:"-"`
+	AccountID string `json:"-"`
+
+	Status     string       `json:"status"`
+	Expires    time.Time    `json:"expires"`
+	Identifier Identifier   `json:"identifier"`
+	Challenges []*Challenge `json:"challenges"`
+	Wildcard   bool         `json:"wildcard,omitempty"`
+}
+
+type Challenge struct {
+	ID string `json:"-"`
+
+	Type      string     `json:"type"`
+	URL       string     `json:"url"`
+	Status    string     `json:"status"`
+	Token     string     `json:"token"`
+	Validated *time.Time `json:"validated,omitempty"`
+	Error     *Problem   `json:"error,omitempty"`
+}
+
+type Certificate struct {
+	ID           string
+	AccountID    string
+	OrderID      string
+	SerialNumber string
+	Chain        string
+	CreatedAt    time.Time
+}
+
+type OrderList struct {
+	Orders []string `json:"orders"`
+}
+
+// Request is an authenticated JWS request body.
+type Request struct {
+	URL     string
+	Payload []byte
+
+	// Exactly one of Account (kid requests) or Key (jwk requests) is set.
+	Account    *Account
+	Key        json.RawMessage
+	Thumbprint string
+}
+
+// IsPostAsGet reports whether the request is a POST-as-GET, which carries
+// an empty payload.
+func (r *Request) IsPostAsGet() bool {
+	return len(r.Payload) == 0
+}
+
+type newAccountPayload struct {
+	Contact              []string `json:"contact"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+	OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+}
+
+type updateAccountPayload struct {
+	Contact []string `json:"contact"`
+	Status  string   `json:"status"`
+}
+
+type newOrderPayload struct {
+	Identifiers []Identifier `json:"identifiers"`
+	NotBefore   string       `json:"notBefore"`
+	NotAfter    string       `json:"notAfter"`
+}
+
+type finalizePayload struct {
+	CSR string `json:"csr"`
+}
+
+type updateAuthorizationPayload struct {
+	Status string `json:"status"`
+}
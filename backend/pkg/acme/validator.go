@@ -0,0 +1,98 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ChallengeValidator checks that the key authorization for a challenge has
+// been provisioned for a domain. Implementations are registered per
+// challenge type with Service.SetValidator, which lets tests substitute
+// local stubs for the network checks.
+type ChallengeValidator interface {
+	Validate(ctx context.Context, domain, token, keyAuthorization string) error
+}
+
+// maxHTTP01ResponseSize bounds the body read from a challenge response; a
+// key authorization is well under 200 bytes.
+const maxHTTP01ResponseSize = 4096
+
+// HTTP01Validator implements RFC 8555 section 8.3.
+type HTTP01Validator struct {
+	Client *http.Client
+}
+
+func NewHTTP01Validator() *HTTP01Validator {
+	return &HTTP01Validator{
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *HTTP01Validator) Validate(ctx context.Context, domain, token, keyAuthorization string) error {
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTP01ResponseSize))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return fmt.Errorf("key authorization served at %s does not match", url)
+	}
+	return nil
+}
+
+// DNS01Validator implements RFC 8555 section 8.4.
+type DNS01Validator struct {
+	Resolver *net.Resolver
+}
+
+func NewDNS01Validator() *DNS01Validator {
+	return &DNS01Validator{
+		Resolver: net.DefaultResolver,
+	}
+}
+
+func (v *DNS01Validator) Validate(ctx context.Context, domain, token, keyAuthorization string) error {
+	name := "_acme-challenge." + domain
+
+	records, err := v.Resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return fmt.Errorf("looking up TXT records for %s: %w", name, err)
+	}
+
+	expected := DNS01RecordValue(keyAuthorization)
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("no TXT record for %s matches the key authorization", name)
+}
+
+// DNS01RecordValue returns the TXT record content for a key authorization.
+func DNS01RecordValue(keyAuthorization string) string {
+	sum := sha256.Sum256([]byte(keyAuthorization))
+	return encodeSegment(sum[:])
+}
@@ -0,0 +1,358 @@
+package acmeclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"web-openssl-backend/pkg/netguard"
+
+	"golang.org/x/crypto/acme"
+)
+
+var ErrDirectoryNotAllowed = errors.New("ACME directory URL is not allowed")
+
+type Service struct {
+	httpClient         *http.Client
+	http01             *HTTP01Solver
+	allowedDirectories []string
+	// checkURL vets directory URLs; tests replace it to reach a local CA
+	checkURL func(ctx context.Context, rawURL string) error
+
+	mu      sync.RWMutex
+	solvers map[string]Solver
+}
+
+func NewService(config Config) *Service {
+	s := &Service{
+		// Directories and the URLs they hand out are chosen by users, so
+		// the server's own networks are off limits
+		httpClient:         netguard.HTTPClient(30 * time.Second),
+		http01:             NewHTTP01Solver(),
+		allowedDirectories: config.AllowedDirectories,
+		checkURL: func(ctx context.Context, rawURL string) error {
+			return netguard.CheckURL(ctx, rawURL, true)
+		},
+		solvers: make(map[string]Solver),
+	}
+
+	s.solvers[ChallengeHTTP01] = s.http01
+	if config.DNSWebhookURL != "" {
+		s.solvers[ChallengeDNS01] = NewDNS01WebhookSolver(config.DNSWebhookURL, config.DNSWebhookSecret, config.DNSPropagationDelay)
+	}
+
+	return s
+}
+
+// SetSolver replaces the solver used for a challenge type.
+func (s *Service) SetSolver(challengeType string, solver Solver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.solvers[challengeType] = solver
+}
+
+// SetHTTPClient sets the client used to talk to ACME directories, e.g. one
+// trusting the private root of a test CA.
+func (s *Service) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+// ChallengeResponse returns the key authorization the built-in http-01
+// solver serves for token.
+func (s *Service) ChallengeResponse(token string) (string, bool) {
+	return s.http01.Response(token)
+}
+
+func (s *Service) Register(ctx context.Context, req *RegisterAccountRequest) (*Account, error) {
+	if err := s.checkDirectory(ctx, req.DirectoryURL); err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("account key generation failed: %w", err)
+	}
+
+	client := s.newClient(req.DirectoryURL, key)
+
+	directory, err := client.Discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ACME directory: %w", err)
+	}
+
+	account := &acme.Account{}
+	if req.Email != "" {
+		account.Contact = []string{"mailto:" + req.Email}
+	}
+
+	registered, err := client.Register(ctx, account, func(string) bool { return req.AcceptTOS })
+	if err != nil {
+		return nil, fmt.Errorf("account registration failed: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Account{
+		DirectoryURL:   req.DirectoryURL,
+		URI:            registered.URI,
+		Status:         registered.Status,
+		Contact:        registered.Contact,
+		TermsOfService: directory.Terms,
+		PrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}, nil
+}
+
+// Obtain runs an order to completion: it authorizes every domain through the
+// configured solver, finalizes with the order's key or a freshly generated
+// one and downloads the certificate chain.
+func (s *Service) Obtain(ctx context.Context, order *Order) (*Certificate, error) {
+	if err := s.checkDirectory(ctx, order.DirectoryURL); err != nil {
+		return nil, err
+	}
+	accountKey, err := parsePrivateKey(order.AccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account key: %w", err)
+	}
+
+	challengeType := order.ChallengeType
+	if challengeType == "" {
+		challengeType = ChallengeHTTP01
+	}
+	solver, err := s.solver(challengeType)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]string, len(order.Domains))
+	for i, domain := range order.Domains {
+		domains[i] = strings.ToLower(strings.TrimSpace(domain))
+	}
+
+	client := s.newClient(order.DirectoryURL, accountKey)
+
+	acmeOrder, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, fmt.Errorf("order creation failed: %w", err)
+	}
+
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+
+	for _, authzURL := range acmeOrder.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch authorization: %w", err)
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+
+		domain := authz.Identifier.Value
+		challenge := findChallenge(authz, challengeType)
+		if challenge == nil {
+			return nil, fmt.Errorf("CA does not offer %s for %s", challengeType, domain)
+		}
+
+		// The key authorization (token.thumbprint) is the same for every
+		// challenge type; solvers derive their own representation of it.
+		keyAuthorization, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := solver.Present(ctx, domain, challenge.Token, keyAuthorization); err != nil {
+			return nil, fmt.Errorf("failed to present %s challenge for %s: %w", challengeType, domain, err)
+		}
+		token := challenge.Token
+		cleanups = append(cleanups, func() {
+			if err := solver.CleanUp(context.Background(), domain, token, keyAuthorization); err != nil {
+				log.Printf("ACME challenge cleanup for %s failed: %v", domain, err)
+			}
+		})
+
+		if _, err := client.Accept(ctx, challenge); err != nil {
+			return nil, fmt.Errorf("failed to accept challenge for %s: %w", domain, err)
+		}
+		if _, err := client.WaitAuthorization(ctx, authzURL); err != nil {
+			return nil, fmt.Errorf("authorization for %s failed: %w", domain, err)
+		}
+	}
+
+	acmeOrder, err = client.WaitOrder(ctx, acmeOrder.URI)
+	if err != nil {
+		return nil, fmt.Errorf("order failed: %w", err)
+	}
+
+	var certKey crypto.Signer
+	keyPEM := order.PrivateKey
+	if keyPEM != "" {
+		certKey, err = parsePrivateKey(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate key: %w", err)
+		}
+	} else {
+		certKey, keyPEM, err = generateCertificateKey(order.KeyType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		return nil, fmt.Errorf("CSR creation failed: %w", err)
+	}
+
+	chain, _, err := client.CreateOrderCert(ctx, acmeOrder.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("order finalization failed: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, fmt.Errorf("CA returned an invalid certificate: %w", err)
+	}
+
+	var intermediates strings.Builder
+	for _, der := range chain[1:] {
+		intermediates.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+
+	return &Certificate{
+		Domains:      domains,
+		Certificate:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: chain[0]})),
+		Chain:        intermediates.String(),
+		PrivateKey:   keyPEM,
+		SerialNumber: leaf.SerialNumber.String(),
+		Issuer:       leaf.Issuer.String(),
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+		OrderURL:     acmeOrder.URI,
+	}, nil
+}
+
+// Helper functions
+
+// checkDirectory refuses directory URLs outside the allowlist and those
+// that are not https or point at a non-public host.
+func (s *Service) checkDirectory(ctx context.Context, directoryURL string) error {
+	if len(s.allowedDirectories) > 0 {
+		allowed := false
+		for _, candidate := range s.allowedDirectories {
+			if strings.TrimRight(candidate, "/") == strings.TrimRight(directoryURL, "/") {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s is not in the list of permitted CAs", ErrDirectoryNotAllowed, directoryURL)
+		}
+	}
+	if err := s.checkURL(ctx, directoryURL); err != nil {
+		return fmt.Errorf("%w: %v", ErrDirectoryNotAllowed, err)
+	}
+	return nil
+}
+
+func (s *Service) newClient(directoryURL string, key crypto.Signer) *acme.Client {
+	return &acme.Client{
+		Key:          key,
+		DirectoryURL: directoryURL,
+		HTTPClient:   s.httpClient,
+		UserAgent:    "web-openssl-backend",
+	}
+}
+
+func (s *Service) solver(challengeType string) (Solver, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	solver, ok := s.solvers[challengeType]
+	if !ok {
+		return nil, fmt.Errorf("no solver configured for %s challenges", challengeType)
+	}
+	return solver, nil
+}
+
+func findChallenge(authz *acme.Authorization, challengeType string) *acme.Challenge {
+	for _, challenge := range authz.Challenges {
+		if challenge.Type == challengeType {
+			return challenge
+		}
+	}
+	return nil
+}
+
+func generateCertificateKey(keyType string) (crypto.Signer, string, error) {
+	var key crypto.Signer
+	var err error
+
+	switch keyType {
+	case "", KeyTypeEC:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return nil, "", fmt.Errorf("unsupported key type: %s", keyType)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("key generation failed: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+func parsePrivateKey(keyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("failed to parse PEM block")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key cannot sign")
+	}
+	return signer, nil
+}
+
+func base64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
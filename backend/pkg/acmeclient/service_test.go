@@ -0,0 +1,369 @@
+package acmeclient
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"web-openssl-backend/pkg/acme"
+	"web-openssl-backend/pkg/acme/acmetest"
+	"web-openssl-backend/pkg/notify"
+	"web-openssl-backend/pkg/openssl"
+)
+
+// validatorFunc adapts a function to acme.ChallengeValidator
+type validatorFunc func(ctx context.Context, domain, token, keyAuthorization string) error
+
+func (f validatorFunc) Validate(ctx context.Context, domain, token, keyAuthorization string) error {
+	return f(ctx, domain, token, keyAuthorization)
+}
+
+// testCA is a Pebble-style stand-in for an external CA: the platform's own
+// ACME server behind a local TLS listener, with challenge checks replaced
+// by the validators a test sets.
+type testCA struct {
+	*httptest.Server
+	acme *acme.Service
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl is not installed")
+	}
+
+	ca := &testCA{}
+	ca.Server = httptest.NewUnstartedServer(ca)
+	ca.StartTLS()
+	t.Cleanup(ca.Close)
+
+	certFile, keyFile := acmetest.WriteCA(t)
+	ca.acme = acme.NewService(acme.Config{BaseURL: ca.URL, CACertFile: certFile, CAKeyFile: keyFile}, openssl.NewService("openssl"), acme.NewMemoryStore())
+	if err := ca.acme.LoadCA(); err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+// client returns an acmeclient service that trusts the CA's listener and
+// may reach it on the loopback address.
+func (ca *testCA) client(config Config) *Service {
+	s := NewService(config)
+	s.checkURL = func(context.Context, string) error { return nil }
+	s.SetHTTPClient(ca.Client())
+	return s
+}
+
+func (ca *testCA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", ca.acme.NewNonce())
+	w.Header().Set("Cache-Control", "no-store")
+
+	path := strings.TrimPrefix(r.URL.Path, "/acme/")
+	switch path {
+	case "directory":
+		writeJSON(w, http.StatusOK, ca.acme.Directory())
+		return
+	case "new-nonce":
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+	req, err := ca.acme.ParseRequest(body, ca.acme.URL(r.URL.Path))
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	resource, id, _ := strings.Cut(path, "/")
+	switch {
+	case resource == "new-account":
+		account, created, err := ca.acme.NewAccount(req)
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		w.Header().Set("Location", ca.acme.AccountURL(account.ID))
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, account)
+	case resource == "new-order":
+		order, err := ca.acme.NewOrder(req)
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		w.Header().Set("Location", ca.acme.OrderURL(order.ID))
+		writeJSON(w, http.StatusCreated, order)
+	case resource == "order" && strings.HasSuffix(id, "/finalize"):
+		order, err := ca.acme.Finalize(req, strings.TrimSuffix(id, "/finalize"))
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		w.Header().Set("Location", ca.acme.OrderURL(order.ID))
+		writeJSON(w, http.StatusOK, order)
+	case resource == "order":
+		order, err := ca.acme.GetOrder(req, id)
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, order)
+	case resource == "authz":
+		authz, err := ca.acme.GetAuthorization(req, id)
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, authz)
+	case resource == "chall":
+		challenge, authzURL, err := ca.acme.RespondChallenge(req, id)
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf("<%s>;rel=\"up\"", authzURL))
+		writeJSON(w, http.StatusOK, challenge)
+	case resource == "cert":
+		chain, err := ca.acme.GetCertificate(req, id)
+		if err != nil {
+			writeProblem(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		io.WriteString(w, chain)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, err error) {
+	problem := acme.AsProblem(err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func register(t *testing.T, ctx context.Context, s *Service, ca *testCA) *Account {
+	t.Helper()
+	account, err := s.Register(ctx, &RegisterAccountRequest{
+		DirectoryURL: ca.acme.DirectoryURL(),
+		Email:        "admin@example.com",
+		AcceptTOS:    true,
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return account
+}
+
+func TestObtainWithHTTP01(t *testing.T) {
+	ca := newTestCA(t)
+	s := ca.client(Config{})
+
+	var mu sync.Mutex
+	var tokens []string
+	// The CA fetches the response the built-in solver would serve under
+	// /.well-known/acme-challenge/
+	ca.acme.SetValidator(acme.ChallengeHTTP01, validatorFunc(func(ctx context.Context, domain, token, keyAuthorization string) error {
+		mu.Lock()
+		tokens = append(tokens, token)
+		mu.Unlock()
+		if response, ok := s.ChallengeResponse(token); !ok || response != keyAuthorization {
+			return errors.New("key authorization not served")
+		}
+		return nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	account := register(t, ctx, s, ca)
+	if account.URI == "" || account.PrivateKey == "" {
+		t.Fatalf("account %+v lacks its URI or key", account)
+	}
+
+	cert, err := s.Obtain(ctx, &Order{
+		DirectoryURL: account.DirectoryURL,
+		AccountKey:   account.PrivateKey,
+		Domains:      []string{"www.example.com", "Example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Obtain: %v", err)
+	}
+
+	leaf, err := parseCertificate(cert.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Issuer.CommonName != "Test ACME CA" {
+		t.Errorf("issuer = %s", leaf.Issuer)
+	}
+	if got := strings.Join(leaf.DNSNames, ","); !strings.Contains(got, "www.example.com") || !strings.Contains(got, "example.com") {
+		t.Errorf("certificate names = %s", got)
+	}
+	if cert.PrivateKey == "" {
+		t.Error("generated certificate key was not returned")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tokens) != 2 {
+		t.Fatalf("validated %d challenges, want 2", len(tokens))
+	}
+	for _, token := range tokens {
+		if _, ok := s.ChallengeResponse(token); ok {
+			t.Errorf("challenge response for %s was not cleaned up", token)
+		}
+	}
+}
+
+func TestObtainWithDNSWebhook(t *testing.T) {
+	ca := newTestCA(t)
+
+	const secret = "webhook-secret"
+	var mu sync.Mutex
+	records := make(map[string]string)
+	var actions []string
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Signature") != notify.SignWebhook(secret, r.Header.Get("X-Timestamp"), body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var payload dnsWebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		actions = append(actions, payload.Action+" "+payload.FQDN)
+		if payload.Action == "present" {
+			records[payload.FQDN] = payload.Value
+		} else {
+			delete(records, payload.FQDN)
+		}
+	}))
+	defer webhook.Close()
+
+	ca.acme.SetValidator(acme.ChallengeDNS01, validatorFunc(func(ctx context.Context, domain, token, keyAuthorization string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if records["_acme-challenge."+domain+"."] != dns01RecordValue(keyAuthorization) {
+			return errors.New("TXT record not found")
+		}
+		return nil
+	}))
+
+	s := ca.client(Config{DNSWebhookURL: webhook.URL, DNSWebhookSecret: secret})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	account := register(t, ctx, s, ca)
+	if _, err := s.Obtain(ctx, &Order{
+		DirectoryURL:  account.DirectoryURL,
+		AccountKey:    account.PrivateKey,
+		Domains:       []string{"*.example.com"},
+		ChallengeType: ChallengeDNS01,
+		KeyType:       KeyTypeRSA,
+	}); err != nil {
+		t.Fatalf("Obtain: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := "present _acme-challenge.example.com.,cleanup _acme-challenge.example.com."
+	if got := strings.Join(actions, ","); got != want {
+		t.Errorf("webhook calls = %s, want %s", got, want)
+	}
+	if len(records) != 0 {
+		t.Errorf("records left behind: %v", records)
+	}
+}
+
+func TestObtainFailsWhenChallengeFails(t *testing.T) {
+	ca := newTestCA(t)
+	s := ca.client(Config{})
+	ca.acme.SetValidator(acme.ChallengeHTTP01, validatorFunc(func(context.Context, string, string, string) error {
+		return errors.New("connection refused")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	account := register(t, ctx, s, ca)
+	_, err := s.Obtain(ctx, &Order{
+		DirectoryURL: account.DirectoryURL,
+		AccountKey:   account.PrivateKey,
+		Domains:      []string{"example.com"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "authorization for example.com failed") {
+		t.Fatalf("Obtain error = %v", err)
+	}
+}
+
+func TestDirectoryIsChecked(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		config    Config
+		directory string
+	}{
+		{"not allowlisted", Config{AllowedDirectories: []string{"https://acme.example.com/directory"}}, "https://acme.example.org/directory"},
+		{"plain http", Config{}, "http://acme.example.com/directory"},
+		{"loopback", Config{}, "https://127.0.0.1/directory"},
+		{"private network", Config{}, "https://10.0.0.1/directory"},
+		{"allowlisted but internal", Config{AllowedDirectories: []string{"https://[::1]/directory"}}, "https://[::1]/directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(tt.config)
+			_, err := s.Register(ctx, &RegisterAccountRequest{DirectoryURL: tt.directory})
+			if !errors.Is(err, ErrDirectoryNotAllowed) {
+				t.Errorf("Register error = %v, want %v", err, ErrDirectoryNotAllowed)
+			}
+			_, err = s.Obtain(ctx, &Order{DirectoryURL: tt.directory, Domains: []string{"example.com"}})
+			if !errors.Is(err, ErrDirectoryNotAllowed) {
+				t.Errorf("Obtain error = %v, want %v", err, ErrDirectoryNotAllowed)
+			}
+		})
+	}
+}
+
+func parseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, errors.New("no PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,144 @@
+package acmeclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"web-openssl-backend/pkg/notify"
+)
+
+// Solver provisions the response to a challenge so the CA can validate it.
+// keyAuthorization is the value defined by RFC 8555 section 8.1; solvers
+// derive the challenge-specific representation from it.
+type Solver interface {
+	Present(ctx context.Context, domain, token, keyAuthorization string) error
+	CleanUp(ctx context.Context, domain, token, keyAuthorization string) error
+}
+
+// HTTP01Solver answers http-01 challenges from memory. The server routes
+// /.well-known/acme-challenge/:token to Response, so the domain must resolve
+// to this server on port 80.
+type HTTP01Solver struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+func NewHTTP01Solver() *HTTP01Solver {
+	return &HTTP01Solver{
+		tokens: make(map[string]string),
+	}
+}
+
+func (s *HTTP01Solver) Present(ctx context.Context, domain, token, keyAuthorization string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = keyAuthorization
+	return nil
+}
+
+func (s *HTTP01Solver) CleanUp(ctx context.Context, domain, token, keyAuthorization string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+	return nil
+}
+
+// Response returns the key authorization to serve for token.
+func (s *HTTP01Solver) Response(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keyAuthorization, ok := s.tokens[token]
+	return keyAuthorization, ok
+}
+
+// DNS01WebhookSolver delegates TXT record management to an external service.
+// Each request carries an X-Signature header with the hex HMAC-SHA256 of
+// "<timestamp>.<body>" and the timestamp in X-Timestamp, so the receiver can
+// authenticate it and reject replays.
+type DNS01WebhookSolver struct {
+	URL              string
+	Secret           string
+	PropagationDelay time.Duration
+	Client           *http.Client
+}
+
+func NewDNS01WebhookSolver(url, secret string, propagationDelay time.Duration) *DNS01WebhookSolver {
+	return &DNS01WebhookSolver{
+		URL:              url,
+		Secret:           secret,
+		PropagationDelay: propagationDelay,
+		Client:           &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *DNS01WebhookSolver) Present(ctx context.Context, domain, token, keyAuthorization string) error {
+	if err := s.send(ctx, "present", domain, keyAuthorization); err != nil {
+		return err
+	}
+
+	if s.PropagationDelay > 0 {
+		select {
+		case <-time.After(s.PropagationDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *DNS01WebhookSolver) CleanUp(ctx context.Context, domain, token, keyAuthorization string) error {
+	return s.send(ctx, "cleanup", domain, keyAuthorization)
+}
+
+func (s *DNS01WebhookSolver) send(ctx context.Context, action, domain, keyAuthorization string) error {
+	if s.URL == "" {
+		return errors.New("DNS webhook URL is not configured")
+	}
+
+	body, err := json.Marshal(dnsWebhookPayload{
+		Action: action,
+		Domain: domain,
+		FQDN:   "_acme-challenge." + domain + ".",
+		Value:  dns01RecordValue(keyAuthorization),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Timestamp", timestamp)
+	if s.Secret != "" {
+		req.Header.Set("X-Signature", notify.SignWebhook(s.Secret, timestamp, body))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("DNS webhook %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("DNS webhook %s returned status %d", action, resp.StatusCode)
+	}
+	return nil
+}
+
+func dns01RecordValue(keyAuthorization string) string {
+	sum := sha256.Sum256([]byte(keyAuthorization))
+	return base64URL(sum[:])
+}
@@ -0,0 +1,82 @@
+package acmeclient
+
+import (
+	"time"
+)
+
+const (
+	ChallengeHTTP01 = "http-01"
+	ChallengeDNS01  = "dns-01"
+
+	KeyTypeEC  = "ec"
+	KeyTypeRSA = "rsa"
+)
+
+type Config struct {
+	// AllowedDirectories, when set, are the only directory URLs accounts
+	// can be registered with. Any directory must be an https URL on a
+	// public host.
+	AllowedDirectories []string
+	DNSWebhookURL      string
+	DNSWebhookSecret   string
+	// DNSPropagationDelay is how long to wait after the webhook has created
+	// a record before asking the CA to validate it.
+	DNSPropagationDelay time.Duration
+}
+
+type RegisterAccountRequest struct {
+	DirectoryURL string `json:"directoryUrl" binding:"required,url"`
+	Email        string `json:"email,omitempty" binding:"omitempty,email"`
+	AcceptTOS    bool   `json:"acceptTos"`
+}
+
+// Account is an account registered with an external ACME CA. PrivateKey is
+// the PEM encoded account key needed for every later request.
+type Account struct {
+	DirectoryURL   string   `json:"directoryUrl"`
+	URI            string   `json:"uri"`
+	Status         string   `json:"status"`
+	Contact        []string `json:"contact"`
+	TermsOfService string   `json:"termsOfService,omitempty"`
+	PrivateKey     string   `json:"-"`
+}
+
+type ObtainCertificateRequest struct {
+	AccountID     uint     `json:"accountId" binding:"required"`
+	Domains       []string `json:"domains" binding:"required,min=1,dive,required"`
+	ChallengeType string   `json:"challengeType,omitempty" binding:"omitempty,oneof=http-01 dns-01"`
+	KeyType       string   `json:"keyType,omitempty" binding:"omitempty,oneof=ec rsa"`
+}
+
+// Order describes a certificate order against an account.
+type Order struct {
+	DirectoryURL  string
+	AccountKey    string
+	Domains       []string
+	ChallengeType string
+	KeyType       string
+	// PrivateKey, when set, is the PEM certificate key to finalize with
+	// instead of a freshly generated one of KeyType.
+	PrivateKey string
+}
+
+type Certificate struct {
+	Domains      []string  `json:"domains"`
+	Certificate  string    `json:"certificate"`
+	Chain        string    `json:"chain"`
+	PrivateKey   string    `json:"privateKey"`
+	SerialNumber string    `json:"serialNumber"`
+	Issuer       string    `json:"issuer"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	OrderURL     string    `json:"orderUrl"`
+}
+
+// dnsWebhookPayload is posted to the DNS webhook to create or remove the
+// _acme-challenge TXT record.
+type dnsWebhookPayload struct {
+	Action string `json:"action"`
+	Domain string `json:"domain"`
+	FQDN   string `json:"fqdn"`
+	Value  string `json:"value"`
+}
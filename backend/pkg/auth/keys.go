@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Access token signing algorithms. HS256 signs with the shared secret; the
+// others with a private key whose public half is published as a JWK set.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const minRSAKeyBits = 2048
+
+// signingKey is a key access tokens are signed or verified with
+type signingKey struct {
+	id        string
+	algorithm string
+	method    jwt.SigningMethod
+	public    crypto.PublicKey
+	// private is nil for keys that are only accepted
+	private crypto.Signer
+}
+
+// JSONWebKey is the public half of a signing key, RFC 7517
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the document other services verify access tokens with
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// LoadKeys reads the signing key and the keys accepted besides it. It must
+// succeed before access tokens can be issued with an asymmetric algorithm;
+// with HS256 there is nothing to load.
+func (s *Service) LoadKeys() error {
+	switch s.config.Algorithm {
+	case "", AlgorithmHS256:
+		if s.config.SigningKeyFile != "" || len(s.config.VerificationKeyFiles) > 0 {
+			return errors.New("signing key files need an asymmetric algorithm: RS256, ES256 or EdDSA")
+		}
+		return nil
+	case AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA:
+	default:
+		return fmt.Errorf("unknown JWT algorithm %q, use HS256, RS256, ES256 or EdDSA", s.config.Algorithm)
+	}
+
+	if s.config.SigningKeyFile == "" {
+		return fmt.Errorf("%s needs a signing key file", s.config.Algorithm)
+	}
+	current, err := loadKey(s.config.SigningKeyFile)
+	if err != nil {
+		return err
+	}
+	if current.private == nil {
+		return fmt.Errorf("%s holds no private key", s.config.SigningKeyFile)
+	}
+	if current.algorithm != s.config.Algorithm {
+		return fmt.Errorf("%s holds a key for %s, not %s", s.config.SigningKeyFile, current.algorithm, s.config.Algorithm)
+	}
+
+	keys := map[string]*signingKey{current.id: current}
+	for _, file := range s.config.VerificationKeyFiles {
+		key, err := loadKey(file)
+		if err != nil {
+			return err
+		}
+		if _, ok := keys[key.id]; ok {
+			return fmt.Errorf("%s holds a key that is already configured", file)
+		}
+		// Only the current key signs
+		key.private = nil
+		keys[key.id] = key
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentKey = current
+	s.keys = keys
+	return nil
+}
+
+// JWKS returns the public keys access tokens are verified with, the
+// current one first. It is empty when tokens are signed with the shared
+// secret.
+func (s *Service) JWKS() JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if s.currentKey == nil {
+		return set
+	}
+	set.Keys = append(set.Keys, s.currentKey.jwk())
+	for id, key := range s.keys {
+		if id != s.currentKey.id {
+			set.Keys = append(set.Keys, key.jwk())
+		}
+	}
+	return set
+}
+
+// Helper functions
+
+// loadKey reads a PEM private or public key. Its algorithm follows from
+// its type.
+func loadKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM key", file)
+	}
+
+	key := &signingKey{}
+	switch block.Type {
+	case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+		var parsed interface{}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			parsed, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s holds an unsupported key type", file)
+		}
+		key.private = signer
+		key.public = signer.Public()
+	case "PUBLIC KEY":
+		if key.public, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s holds a %s, not a key", file, block.Type)
+	}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("%s holds an RSA key shorter than %d bits", file, minRSAKeyBits)
+		}
+		key.algorithm, key.method = AlgorithmRS256, jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if public.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s holds an EC key that is not on P-256", file)
+		}
+		key.algorithm, key.method = AlgorithmES256, jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.algorithm, key.method = AlgorithmEdDSA, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%s holds an unsupported key type", file)
+	}
+
+	key.id = thumbprint(key.jwk())
+	return key, nil
+}
+
+// jwk describes the public key. Its key ID is only set once loadKey has
+// derived it.
+func (k *signingKey) jwk() JSONWebKey {
+	jwk := JSONWebKey{KeyID: k.id, Use: "sig", Algorithm: k.algorithm}
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBase64URL(public.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType = "EC"
+		jwk.Curve = "P-256"
+		jwk.X = encodeBase64URL(public.X.FillBytes(make([]byte, 32)))
+		jwk.Y = encodeBase64URL(public.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encodeBase64URL(public)
+	}
+	return jwk
+}
+
+// thumbprint is the RFC 7638 SHA-256 thumbprint of a key, which serves as
+// its key ID: the same key always gets the same ID.
+func thumbprint(jwk JSONWebKey) string {
+	// The required members only, in lexicographic order
+	var members interface{}
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return encodeBase64URL(sum[:])
+}
+
+func encodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"web-openssl-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testUser = &models.User{ID: 1, Email: "user@example.com", Role: models.RoleUser}
+
+// writeKey writes a private key as PKCS#8 PEM, or only its public half
+func writeKey(t *testing.T, key crypto.Signer, public bool) string {
+	t.Helper()
+	block := &pem.Block{Type: "PRIVATE KEY"}
+	var err error
+	if public {
+		block.Type = "PUBLIC KEY"
+		block.Bytes, err = x509.MarshalPKIXPublicKey(key.Public())
+	} else {
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newKeyService(t *testing.T, signingKeyFile string, verificationKeyFiles ...string) *Service {
+	t.Helper()
+	return newKeyServiceWith(t, AlgorithmES256, signingKeyFile, verificationKeyFiles...)
+}
+
+func newKeyServiceWith(t *testing.T, algorithm, signingKeyFile string, verificationKeyFiles ...string) *Service {
+	t.Helper()
+	s := NewService(Config{
+		Secret:               "test-secret",
+		ExpiresIn:            time.Hour,
+		Algorithm:            algorithm,
+		SigningKeyFile:       signingKeyFile,
+		VerificationKeyFiles: verificationKeyFiles,
+	})
+	if err := s.LoadKeys(); err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	return s
+}
+
+func generateToken(t *testing.T, s *Service) string {
+	t.Helper()
+	token, err := s.GenerateToken(testUser, "session", []string{AuthMethodPassword})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+// forge signs claims for testUser with any method and key, naming kid
+func forge(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, &Claims{
+		UserID: testUser.ID,
+		Email:  testUser.Email,
+		Role:   testUser.Role,
+		Type:   TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	previous := newECKey(t)
+	current := newECKey(t)
+	previousFile := writeKey(t, previous, false)
+
+	old := newKeyService(t, previousFile)
+	token := generateToken(t, old)
+
+	// The previous key is still accepted while its tokens may be in use
+	rotated := newKeyService(t, writeKey(t, current, false), writeKey(t, previous, true))
+	if _, err := rotated.ValidateToken(token); err != nil {
+		t.Fatalf("token of the previous key was rejected: %v", err)
+	}
+	if _, err := rotated.ValidateToken(generateToken(t, rotated)); err != nil {
+		t.Fatalf("token of the current key was rejected: %v", err)
+	}
+
+	// Once removed, it no longer is, although the signature is still valid
+	removed := newKeyService(t, writeKey(t, current, false))
+	if _, err := removed.ValidateToken(token); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Errorf("token of a removed key error = %v", err)
+	}
+}
+
+func TestTokenWithUnknownKeyIDRejected(t *testing.T) {
+	key := newECKey(t)
+	s := newKeyService(t, writeKey(t, key, false))
+
+	for name, kid := range map[string]string{"no kid": "", "unknown kid": "not-a-key"} {
+		t.Run(name, func(t *testing.T) {
+			token := forge(t, jwt.SigningMethodES256, kid, key)
+			if _, err := s.ValidateToken(token); err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+}
+
+func TestTokenAlgorithmPinnedToKey(t *testing.T) {
+	key := newECKey(t)
+	s := newKeyService(t, writeKey(t, key, false))
+	kid := s.currentKey.id
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	otherP384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherEd25519, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// The published public key used as an HMAC secret
+		{"HS256 with the public key PEM", forge(t, jwt.SigningMethodHS256, kid, publicPEM)},
+		{"HS256 with the public key DER", forge(t, jwt.SigningMethodHS256, kid, publicDER)},
+		// The shared secret only signs purpose tokens when keys are in use
+		{"HS256 with the shared secret", forge(t, jwt.SigningMethodHS256, kid, []byte("test-secret"))},
+		{"HS256 with the shared secret and no kid", forge(t, jwt.SigningMethodHS256, "", []byte("test-secret"))},
+		{"ES384 with another key", forge(t, jwt.SigningMethodES384, kid, otherP384)},
+		{"EdDSA with another key", forge(t, jwt.SigningMethodEdDSA, kid, otherEd25519)},
+		{"none", forge(t, jwt.SigningMethodNone, kid, jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.ValidateToken(tt.token); err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+}
+
+// An RSA key verifies every RS and PS algorithm, so only the pin keeps a
+// token from choosing one the key was not published for
+func TestRSATokenAlgorithmPinnedToKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newKeyServiceWith(t, AlgorithmRS256, writeKey(t, key, false))
+	kid := s.currentKey.id
+
+	if _, err := s.ValidateToken(forge(t, jwt.SigningMethodRS256, kid, key)); err != nil {
+		t.Fatalf("RS256 token was rejected: %v", err)
+	}
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodRS512, jwt.SigningMethodPS256} {
+		t.Run(method.Alg(), func(t *testing.T) {
+			if _, err := s.ValidateToken(forge(t, method, kid, key)); err == nil || !strings.Contains(err.Error(), "invalid signing method") {
+				t.Errorf("token error = %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	current := newECKey(t)
+	next := newECKey(t)
+
+	s := newKeyService(t, writeKey(t, current, false), writeKey(t, next, false))
+	set := s.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(set.Keys))
+	}
+
+	// The current key comes first and verifies the tokens it signed
+	token, _, err := jwt.NewParser().ParseUnverified(generateToken(t, s), &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := set.Keys[0]
+	if jwk.KeyID != token.Header["kid"] || jwk.Algorithm != AlgorithmES256 || jwk.Use != "sig" || jwk.KeyType != "EC" || jwk.Curve != "P-256" {
+		t.Errorf("current key = %+v, token header = %v", jwk, token.Header)
+	}
+	x, y := decodeCoordinate(t, jwk.X), decodeCoordinate(t, jwk.Y)
+	if x.Cmp(current.X) != 0 || y.Cmp(current.Y) != 0 {
+		t.Error("JWK does not describe the current public key")
+	}
+	if set.Keys[1].KeyID == jwk.KeyID || set.Keys[1].KeyID != thumbprint(set.Keys[1]) {
+		t.Errorf("next key ID = %q", set.Keys[1].KeyID)
+	}
+
+	// Only public members are published, also for private verification keys
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []string{`"d"`, `"p"`, `"q"`, `"dp"`, `"dq"`, `"qi"`, `"k"`} {
+		if strings.Contains(string(data), member) {
+			t.Errorf("JWKS holds private member %s: %s", member, data)
+		}
+	}
+
+	// A key taken out of the configuration is no longer published
+	s = newKeyService(t, writeKey(t, current, false))
+	if keys := s.JWKS().Keys; len(keys) != 1 || keys[0].KeyID != jwk.KeyID {
+		t.Errorf("JWKS after removing the next key = %+v", keys)
+	}
+
+	// Tokens signed with the shared secret have no keys to publish
+	shared := NewService(Config{Secret: "test-secret", ExpiresIn: time.Hour})
+	if keys := shared.JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("HS256 JWKS = %v, want an empty set", keys)
+	}
+}
+
+func decodeCoordinate(t *testing.T, value string) *big.Int {
+	t.Helper()
+	data, err := jwt.NewParser().DecodeSegment(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return new(big.Int).SetBytes(data)
+}
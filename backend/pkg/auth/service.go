@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
+	"sync"
 	"time"
 
 	"web-openssl-backend/internal/models"
@@ -10,23 +13,70 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Config selects how access tokens are signed. Secret also derives the keys
+// of purpose tokens, so it is needed with every algorithm.
+type Config struct {
+	Secret    string
+	ExpiresIn time.Duration
+	// Algorithm is HS256, RS256, ES256 or EdDSA
+	Algorithm string
+	// SigningKeyFile is the PEM private key asymmetric algorithms sign with
+	SigningKeyFile string
+	// VerificationKeyFiles are PEM keys that are published and accepted but
+	// not signed with: the next key ahead of a rotation, and the previous one
+	// until the tokens it signed have expired
+	VerificationKeyFiles []string
+}
+
 type Service struct {
-	secretKey string
-	expiresIn time.Duration
+	config Config
+
+	mu          sync.RWMutex
+	tokenChecks []TokenCheck
+	// currentKey signs access tokens; keys holds it and every key accepted,
+	// by key ID. Both are unset with HS256.
+	currentKey *signingKey
+	keys       map[string]*signingKey
 }
 
+// TokenCheck decides whether a token with a valid signature is still
+// honoured, e.g. after the user's sessions have been revoked.
+type TokenCheck func(claims *Claims) error
+
+// TokenTypeAccess marks access tokens, so that no other token the service
+// signs can be used to call the API
+const TokenTypeAccess = "access"
+
+// Authentication methods a session's login used, named as in RFC 8176
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	// AuthMethodPasskey is a WebAuthn credential used with user verification
+	AuthMethodPasskey = "hwk"
+	// AuthMethodFederated is a sign-in at an organization's identity
+	// provider; RFC 8176 has no value for it
+	AuthMethodFederated = "fed"
+	// AuthMethodMultiFactor is reported by identity providers whose sign-in
+	// used more than one factor
+	AuthMethodMultiFactor = "mfa"
+)
+
 type Claims struct {
 	UserID uint             `json:"user_id"`
 	Email  string           `json:"email"`
 	Role   models.UserRole  `json:"role"`
+	Type   string           `json:"typ,omitempty"`
+	// SessionID names the session an access token belongs to
+	SessionID string `json:"sid,omitempty"`
+	// AuthMethods are the methods the session's login used
+	AuthMethods []string `json:"amr,omitempty"`
+	// MFA is set when the session's login passed a second factor
+	MFA bool `json:"mfa,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewService(secretKey string, expiresIn time.Duration) *Service {
-	return &Service{
-		secretKey: secretKey,
-		expiresIn: expiresIn,
-	}
+func NewService(config Config) *Service {
+	return &Service{config: config}
 }
 
 func (s *Service) HashPassword(password string) (string, error) {
@@ -39,13 +89,19 @@ func (s *Service) CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func (s *Service) GenerateToken(user *models.User) (string, error) {
+// GenerateToken issues an access token for one of the user's sessions,
+// recording the authentication methods its login used.
+func (s *Service) GenerateToken(user *models.User, sessionID string, methods []string) (string, error) {
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        user.Role,
+		Type:        TokenTypeAccess,
+		SessionID:   sessionID,
+		AuthMethods: methods,
+		MFA:         MultiFactor(methods),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expiresIn)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.ExpiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "openssl-ui",
@@ -53,19 +109,26 @@ func (s *Service) GenerateToken(user *models.User) (string, error) {
 		},
 	}
 
+	if s.asymmetric() {
+		s.mu.RLock()
+		key := s.currentKey
+		s.mu.RUnlock()
+		if key == nil {
+			return "", errors.New("signing key is not loaded")
+		}
+		token := jwt.NewWithClaims(key.method, claims)
+		token.Header["kid"] = key.id
+		return token.SignedString(key.private)
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	return token.SignedString([]byte(s.config.Secret))
 }
 
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(s.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -75,23 +138,115 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	s.mu.RLock()
+	checks := s.tokenChecks
+	s.mu.RUnlock()
+	for _, check := range checks {
+		if err := check(claims); err != nil {
+			return nil, err
+		}
+	}
+
 	return claims, nil
 }
 
-func (s *Service) GenerateRefreshToken(user *models.User) (string, error) {
+// MultiFactor reports whether a login with these methods passed more than
+// one factor. A passkey counts on its own, since it is only accepted with
+// user verification by PIN or biometrics.
+func MultiFactor(methods []string) bool {
+	return len(methods) > 1 || HasAuthMethod(methods, AuthMethodPasskey)
+}
+
+func HasAuthMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTokenCheck installs a check ValidateToken runs on every token.
+func (s *Service) AddTokenCheck(check TokenCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenChecks = append(s.tokenChecks, check)
+}
+
+// GeneratePurposeToken signs a token that proves a single action, such as
+// verifying an email address. It is signed with a key derived for the
+// purpose, so it is never accepted as an access token or for another purpose.
+func (s *Service) GeneratePurposeToken(purpose string, user *models.User, ttl time.Duration) (string, error) {
 	claims := &Claims{
 		UserID: user.ID,
 		Email:  user.Email,
-		Role:   user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "openssl-ui",
 			Subject:   user.Email,
+			Audience:  jwt.ClaimStrings{purpose},
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
-}
\ No newline at end of file
+	return token.SignedString(s.purposeKey(purpose))
+}
+
+// ValidatePurposeToken checks a token made by GeneratePurposeToken for the
+// same purpose.
+func (s *Service) ValidatePurposeToken(purpose, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return s.purposeKey(purpose), nil
+	}, jwt.WithAudience(purpose))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// verificationKey picks the key a token is checked with: the shared secret
+// with HS256, otherwise the key its kid header names. A token is only
+// accepted with the algorithm of its key.
+func (s *Service) verificationKey(token *jwt.Token) (interface{}, error) {
+	if !s.asymmetric() {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return []byte(s.config.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.public, nil
+}
+
+func (s *Service) asymmetric() bool {
+	return s.config.Algorithm != "" && s.config.Algorithm != AlgorithmHS256
+}
+
+func (s *Service) purposeKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.config.Secret))
+	mac.Write([]byte("purpose:" + purpose))
+	return mac.Sum(nil)
+}
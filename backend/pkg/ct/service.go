@@ -0,0 +1,498 @@
+package ct
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+var (
+	// OIDSCTList is the X.509v3 extension carrying embedded SCTs.
+	OIDSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	// OIDPrecertPoison marks a precertificate so it cannot be used for TLS.
+	OIDPrecertPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+)
+
+const (
+	signatureTypeCertificateTimestamp = 0
+	entryTypePrecert                  = 1
+)
+
+type Service struct {
+	logs map[[sha256.Size]byte]*knownLog
+}
+
+type knownLog struct {
+	Log
+	publicKey crypto.PublicKey
+}
+
+type rawSCT struct {
+	version    uint8
+	logID      [sha256.Size]byte
+	timestamp  uint64
+	extensions []byte
+	hashAlg    uint8
+	sigAlg     uint8
+	signature  []byte
+}
+
+func NewService() *Service {
+	return &Service{logs: make(map[[sha256.Size]byte]*knownLog)}
+}
+
+// AddLogs registers logs whose SCTs can be verified. It is meant to be called
+// during startup, before the service handles requests.
+func (s *Service) AddLogs(logs []Log) error {
+	for _, log := range logs {
+		if err := s.addLog(log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadLogList reads a CT log list in the published v3 JSON format.
+func LoadLogList(path string) ([]Log, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CT log list: %w", err)
+	}
+
+	var list struct {
+		Operators []struct {
+			Name string `json:"name"`
+			Logs []Log  `json:"logs"`
+		} `json:"operators"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse CT log list: %w", err)
+	}
+
+	var logs []Log
+	for _, operator := range list.Operators {
+		logs = append(logs, operator.Logs...)
+	}
+	return logs, nil
+}
+
+// LogCount returns the number of logs SCTs can be verified against.
+func (s *Service) LogCount() int {
+	return len(s.logs)
+}
+
+// ParseSCTs decodes the SCT list embedded in a certificate. A certificate
+// without the extension yields no SCTs and no error. SCTs of a version
+// other than v1 are skipped, as RFC 6962 section 3.3 requires.
+func ParseSCTs(cert *x509.Certificate) ([]SCT, error) {
+	var extValue []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(OIDSCTList) {
+			extValue = ext.Value
+			break
+		}
+	}
+	if extValue == nil {
+		return nil, nil
+	}
+
+	var list []byte
+	if _, err := asn1.Unmarshal(extValue, &list); err != nil {
+		return nil, fmt.Errorf("invalid SCT list extension: %w", err)
+	}
+
+	input := cryptobyte.String(list)
+	var entries cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&entries) || !input.Empty() {
+		return nil, errors.New("malformed SCT list")
+	}
+
+	var scts []SCT
+	for !entries.Empty() {
+		var entry cryptobyte.String
+		if !entries.ReadUint16LengthPrefixed(&entry) {
+			return nil, errors.New("malformed SCT list entry")
+		}
+		sct, err := parseSCT(entry)
+		if errors.Is(err, errUnknownSCTVersion) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, *sct)
+	}
+
+	return scts, nil
+}
+
+// IsPrecertificate reports whether the certificate carries the CT poison
+// extension.
+func IsPrecertificate(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(OIDPrecertPoison) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) Check(req *CheckRequest) (*CheckResponse, error) {
+	cert, err := parsePEMCertificate(req.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("certificate: %w", err)
+	}
+	issuer, err := parsePEMCertificate(req.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("issuer: %w", err)
+	}
+
+	logs := s
+	if len(req.LogKeys) > 0 {
+		logs, err = s.withExtraKeys(req.LogKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scts, err := ParseSCTs(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CheckResponse{
+		SCTs:          []SCTResult{},
+		RequiredCount: requiredSCTCount(cert),
+	}
+
+	if IsPrecertificate(cert) {
+		response.Issues = append(response.Issues, "certificate is a precertificate and cannot be deployed")
+	}
+
+	if len(scts) > 0 {
+		signedEntry, err := precertSignedEntry(cert, issuer)
+		if err != nil {
+			return nil, err
+		}
+
+		// Chrome counts logs, not SCTs: two SCTs from one log count once
+		validLogs := make(map[[sha256.Size]byte]bool)
+		for _, sct := range scts {
+			result := SCTResult{SCT: sct}
+			log, ok := logs.logs[sct.raw.logID]
+			switch {
+			case !ok:
+				result.Status = SCTStatusUnknownLog
+			default:
+				result.LogDescription = log.Description
+				if err := verifySCT(log.publicKey, &sct.raw, signedEntry); err != nil {
+					result.Status = SCTStatusInvalid
+					result.Error = err.Error()
+				} else {
+					result.Status = SCTStatusValid
+					response.ValidCount++
+					validLogs[sct.raw.logID] = true
+				}
+			}
+			response.SCTs = append(response.SCTs, result)
+		}
+		response.LogCount = len(validLogs)
+	}
+
+	switch {
+	case len(scts) == 0:
+		response.Issues = append(response.Issues, "certificate has no embedded SCTs")
+	case response.LogCount < response.RequiredCount:
+		response.Issues = append(response.Issues, fmt.Sprintf("certificate has valid SCTs from %d distinct logs, %d required", response.LogCount, response.RequiredCount))
+	}
+
+	response.Compliant = len(response.Issues) == 0
+	return response, nil
+}
+
+// Link checks that a final certificate was issued from the given
+// precertificate: both TBSCertificates must be identical once the poison
+// and SCT list extensions are removed.
+func (s *Service) Link(req *LinkRequest) (*LinkResponse, error) {
+	precert, err := parsePEMCertificate(req.Precertificate)
+	if err != nil {
+		return nil, fmt.Errorf("precertificate: %w", err)
+	}
+	cert, err := parsePEMCertificate(req.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("certificate: %w", err)
+	}
+
+	scts, err := ParseSCTs(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &LinkResponse{
+		SerialNumber: cert.SerialNumber.String(),
+		SCTCount:     len(scts),
+	}
+
+	if !IsPrecertificate(precert) {
+		response.Reason = "first certificate is not a precertificate"
+		return response, nil
+	}
+	if IsPrecertificate(cert) {
+		response.Reason = "second certificate is a precertificate, not a final certificate"
+		return response, nil
+	}
+
+	precertTBS, err := stripExtension(precert.RawTBSCertificate, OIDPrecertPoison)
+	if err != nil {
+		return nil, err
+	}
+	certTBS, err := stripExtension(cert.RawTBSCertificate, OIDSCTList)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(precertTBS, certTBS) {
+		response.Reason = "certificate contents differ from the precertificate"
+		if precert.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			response.Reason = "serial numbers differ"
+		} else if !bytes.Equal(precert.RawIssuer, cert.RawIssuer) {
+			response.Reason = "issuers differ (precertificate signing certificates are not supported)"
+		}
+		return response, nil
+	}
+
+	response.Linked = true
+	return response, nil
+}
+
+// Helper functions
+
+func (s *Service) addLog(log Log) error {
+	der, err := base64.StdEncoding.DecodeString(log.Key)
+	if err != nil {
+		return fmt.Errorf("log %q: invalid key encoding: %w", log.Description, err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("log %q: invalid key: %w", log.Description, err)
+	}
+
+	// The log ID is defined as the SHA-256 hash of the log's public key.
+	logID := sha256.Sum256(der)
+	log.LogID = base64.StdEncoding.EncodeToString(logID[:])
+	s.logs[logID] = &knownLog{Log: log, publicKey: publicKey}
+	return nil
+}
+
+func (s *Service) withExtraKeys(keys []string) (*Service, error) {
+	extended := &Service{logs: make(map[[sha256.Size]byte]*knownLog, len(s.logs)+len(keys))}
+	for id, log := range s.logs {
+		extended.logs[id] = log
+	}
+	for i, key := range keys {
+		if err := extended.addLog(Log{Description: fmt.Sprintf("request log key %d", i+1), Key: key}); err != nil {
+			return nil, err
+		}
+	}
+	return extended, nil
+}
+
+// errUnknownSCTVersion marks an SCT whose layout cannot be known
+var errUnknownSCTVersion = errors.New("unknown SCT version")
+
+func parseSCT(input cryptobyte.String) (*SCT, error) {
+	var raw rawSCT
+	var logID []byte
+	var extensions, signature cryptobyte.String
+
+	// Only the version is common to all versions of the structure
+	if !input.ReadUint8(&raw.version) {
+		return nil, errors.New("malformed SCT")
+	}
+	if raw.version != 0 {
+		return nil, fmt.Errorf("%w %d", errUnknownSCTVersion, raw.version)
+	}
+
+	if !input.ReadBytes(&logID, sha256.Size) ||
+		!input.ReadUint64(&raw.timestamp) ||
+		!input.ReadUint16LengthPrefixed(&extensions) ||
+		!input.ReadUint8(&raw.hashAlg) ||
+		!input.ReadUint8(&raw.sigAlg) ||
+		!input.ReadUint16LengthPrefixed(&signature) ||
+		!input.Empty() {
+		return nil, errors.New("malformed SCT")
+	}
+
+	copy(raw.logID[:], logID)
+	raw.extensions = extensions
+	raw.signature = signature
+
+	return &SCT{
+		Version:            int(raw.version) + 1,
+		LogID:              base64.StdEncoding.EncodeToString(logID),
+		Timestamp:          time.UnixMilli(int64(raw.timestamp)).UTC(),
+		HashAlgorithm:      hashAlgorithmName(raw.hashAlg),
+		SignatureAlgorithm: signatureAlgorithmName(raw.sigAlg),
+		Signature:          base64.StdEncoding.EncodeToString(raw.signature),
+		Extensions:         base64.StdEncoding.EncodeToString(raw.extensions),
+		raw:                raw,
+	}, nil
+}
+
+// precertSignedEntry rebuilds the precert_entry a log signed for an
+// embedded SCT: the issuer key hash followed by the final TBSCertificate
+// with the SCT list extension removed.
+func precertSignedEntry(cert, issuer *x509.Certificate) ([]byte, error) {
+	tbs, err := stripExtension(cert.RawTBSCertificate, OIDSCTList)
+	if err != nil {
+		return nil, err
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+	var b cryptobyte.Builder
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	return b.Bytes()
+}
+
+func verifySCT(publicKey crypto.PublicKey, sct *rawSCT, signedEntry []byte) error {
+	var b cryptobyte.Builder
+	b.AddUint8(sct.version)
+	b.AddUint8(signatureTypeCertificateTimestamp)
+	b.AddUint64(sct.timestamp)
+	b.AddUint16(entryTypePrecert)
+	b.AddBytes(signedEntry)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.extensions)
+	})
+	signed, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if sct.hashAlg != 4 {
+		return fmt.Errorf("unsupported hash algorithm %s", hashAlgorithmName(sct.hashAlg))
+	}
+	digest := sha256.Sum256(signed)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if sct.sigAlg != 3 {
+			return errors.New("signature algorithm does not match log key")
+		}
+		if !ecdsa.VerifyASN1(key, digest[:], sct.signature) {
+			return errors.New("signature verification failed")
+		}
+	case *rsa.PublicKey:
+		if sct.sigAlg != 1 {
+			return errors.New("signature algorithm does not match log key")
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sct.signature); err != nil {
+			return errors.New("signature verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported log key type %T", publicKey)
+	}
+
+	return nil
+}
+
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm asn1.RawValue
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	IssuerUniqueID     asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+// stripExtension re-encodes a TBSCertificate without the given extension.
+func stripExtension(rawTBS []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	var tbs tbsCertificate
+	rest, err := asn1.Unmarshal(rawTBS, &tbs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TBSCertificate: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after TBSCertificate")
+	}
+
+	extensions := tbs.Extensions[:0]
+	for _, ext := range tbs.Extensions {
+		if !ext.Id.Equal(oid) {
+			extensions = append(extensions, ext)
+		}
+	}
+	tbs.Extensions = extensions
+	tbs.Raw = nil
+
+	return asn1.Marshal(tbs)
+}
+
+// requiredSCTCount follows the Chrome CT policy for embedded SCTs.
+func requiredSCTCount(cert *x509.Certificate) int {
+	if cert.NotAfter.Sub(cert.NotBefore) <= 180*24*time.Hour {
+		return 2
+	}
+	return 3
+}
+
+func parsePEMCertificate(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("failed to parse PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func hashAlgorithmName(id uint8) string {
+	switch id {
+	case 2:
+		return "sha1"
+	case 4:
+		return "sha256"
+	case 5:
+		return "sha384"
+	case 6:
+		return "sha512"
+	default:
+		return fmt.Sprintf("unknown(%d)", id)
+	}
+}
+
+func signatureAlgorithmName(id uint8) string {
+	switch id {
+	case 1:
+		return "rsa"
+	case 3:
+		return "ecdsa"
+	default:
+		return fmt.Sprintf("unknown(%d)", id)
+	}
+}
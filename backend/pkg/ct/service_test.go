@@ -0,0 +1,320 @@
+package ct
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// testLog signs SCTs with its own P-256 key
+type testLog struct {
+	key *ecdsa.PrivateKey
+	der []byte
+}
+
+func newTestLog(t *testing.T) *testLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testLog{key: key, der: der}
+}
+
+func (l *testLog) publicKey() string {
+	return base64.StdEncoding.EncodeToString(l.der)
+}
+
+func (l *testLog) id() [sha256.Size]byte {
+	return sha256.Sum256(l.der)
+}
+
+// sct returns a v1 SCT over the precert entry of precert, as the log would
+// have answered its submission
+func (l *testLog) sct(t *testing.T, precert, issuer *x509.Certificate, timestamp time.Time) []byte {
+	t.Helper()
+	tbs, err := stripExtension(precert.RawTBSCertificate, OIDPrecertPoison)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+	var signed cryptobyte.Builder
+	signed.AddUint8(0)
+	signed.AddUint8(signatureTypeCertificateTimestamp)
+	signed.AddUint64(uint64(timestamp.UnixMilli()))
+	signed.AddUint16(entryTypePrecert)
+	signed.AddBytes(issuerKeyHash[:])
+	signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	signed.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {})
+	digest := sha256.Sum256(signed.BytesOrPanic())
+	signature, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := l.id()
+	var sct cryptobyte.Builder
+	sct.AddUint8(0)
+	sct.AddBytes(id[:])
+	sct.AddUint64(uint64(timestamp.UnixMilli()))
+	sct.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {})
+	sct.AddUint8(4) // sha256
+	sct.AddUint8(3) // ecdsa
+	sct.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(signature) })
+	return sct.BytesOrPanic()
+}
+
+// testIssuer is a CA issuing precertificates and their final certificates
+type testIssuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	// leafKey and notBefore are shared by every certificate, so a final
+	// certificate only differs from its precertificate in the extensions
+	leafKey   *ecdsa.PrivateKey
+	notBefore time.Time
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CT Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIssuer{cert: cert, key: key, leafKey: leafKey, notBefore: time.Now().Add(-time.Hour).Truncate(time.Second)}
+}
+
+func (i *testIssuer) issue(t *testing.T, serial int64, extension pkix.Extension) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(serial),
+		Subject:         pkix.Name{CommonName: "www.example.com"},
+		DNSNames:        []string{"www.example.com"},
+		NotBefore:       i.notBefore,
+		NotAfter:        i.notBefore.Add(90 * 24 * time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{extension},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, i.cert, &i.leafKey.PublicKey, i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (i *testIssuer) precertificate(t *testing.T, serial int64) *x509.Certificate {
+	t.Helper()
+	null, _ := asn1.Marshal(asn1.NullRawValue)
+	return i.issue(t, serial, pkix.Extension{Id: OIDPrecertPoison, Critical: true, Value: null})
+}
+
+// final issues the certificate of precert with the given SCTs embedded
+func (i *testIssuer) final(t *testing.T, serial int64, scts ...[]byte) *x509.Certificate {
+	t.Helper()
+	var list cryptobyte.Builder
+	list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct) })
+		}
+	})
+	value, err := asn1.Marshal(list.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i.issue(t, serial, pkix.Extension{Id: OIDSCTList, Value: value})
+}
+
+func encodePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func TestParseSCTs(t *testing.T) {
+	issuer := newTestIssuer(t)
+	log := newTestLog(t)
+	precert := issuer.precertificate(t, 10)
+	timestamp := time.Now().Add(-time.Minute).Truncate(time.Millisecond).UTC()
+
+	// A v2 SCT, whose layout is unknown, is skipped
+	unknown := []byte{1, 0xde, 0xad}
+	cert := issuer.final(t, 10, log.sct(t, precert, issuer.cert, timestamp), unknown)
+
+	scts, err := ParseSCTs(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scts) != 1 {
+		t.Fatalf("parsed %d SCTs, want 1", len(scts))
+	}
+	id := log.id()
+	sct := scts[0]
+	if sct.Version != 1 || sct.LogID != base64.StdEncoding.EncodeToString(id[:]) || !sct.Timestamp.Equal(timestamp) {
+		t.Errorf("SCT = %+v", sct)
+	}
+	if sct.HashAlgorithm != "sha256" || sct.SignatureAlgorithm != "ecdsa" {
+		t.Errorf("algorithms = %s, %s", sct.HashAlgorithm, sct.SignatureAlgorithm)
+	}
+
+	if scts, err := ParseSCTs(precert); err != nil || scts != nil {
+		t.Errorf("certificate without SCTs = %v, %v", scts, err)
+	}
+}
+
+func TestCheckVerifiesAgainstLogKey(t *testing.T) {
+	issuer := newTestIssuer(t)
+	log := newTestLog(t)
+	unknownLog := newTestLog(t)
+	precert := issuer.precertificate(t, 20)
+	cert := issuer.final(t, 20,
+		log.sct(t, precert, issuer.cert, time.Now()),
+		unknownLog.sct(t, precert, issuer.cert, time.Now()),
+	)
+
+	s := NewService()
+	if err := s.AddLogs([]Log{{Description: "Test Log", Key: log.publicKey()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := s.Check(&CheckRequest{Certificate: encodePEM(cert), Issuer: encodePEM(issuer.cert)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.SCTs) != 2 {
+		t.Fatalf("checked %d SCTs, want 2", len(response.SCTs))
+	}
+	if got := response.SCTs[0]; got.Status != SCTStatusValid || got.LogDescription != "Test Log" {
+		t.Errorf("SCT of the known log = %+v", got)
+	}
+	if got := response.SCTs[1]; got.Status != SCTStatusUnknownLog {
+		t.Errorf("SCT of the unknown log status = %s", got.Status)
+	}
+
+	// The SCT was signed for another issuer key
+	other := newTestIssuer(t)
+	response, err = s.Check(&CheckRequest{Certificate: encodePEM(cert), Issuer: encodePEM(other.cert)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := response.SCTs[0]; got.Status != SCTStatusInvalid || got.Error == "" {
+		t.Errorf("SCT checked against the wrong issuer = %+v", got)
+	}
+}
+
+func TestCheckCountsDistinctLogs(t *testing.T) {
+	issuer := newTestIssuer(t)
+	first := newTestLog(t)
+	second := newTestLog(t)
+	precert := issuer.precertificate(t, 30)
+	request := func(scts ...[]byte) *CheckRequest {
+		return &CheckRequest{
+			Certificate: encodePEM(issuer.final(t, 30, scts...)),
+			Issuer:      encodePEM(issuer.cert),
+			LogKeys:     []string{first.publicKey(), second.publicKey()},
+		}
+	}
+	s := NewService()
+
+	sameLog, err := s.Check(request(
+		first.sct(t, precert, issuer.cert, time.Now().Add(-time.Minute)),
+		first.sct(t, precert, issuer.cert, time.Now()),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sameLog.ValidCount != 2 || sameLog.LogCount != 1 || sameLog.RequiredCount != 2 {
+		t.Fatalf("two SCTs from one log = %+v", sameLog)
+	}
+	if sameLog.Compliant || len(sameLog.Issues) != 1 || !strings.Contains(sameLog.Issues[0], "1 distinct logs") {
+		t.Errorf("two SCTs from one log met the policy: %v", sameLog.Issues)
+	}
+
+	twoLogs, err := s.Check(request(
+		first.sct(t, precert, issuer.cert, time.Now()),
+		second.sct(t, precert, issuer.cert, time.Now()),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if twoLogs.LogCount != 2 || !twoLogs.Compliant {
+		t.Errorf("SCTs from two logs = %+v", twoLogs)
+	}
+}
+
+func TestLink(t *testing.T) {
+	issuer := newTestIssuer(t)
+	log := newTestLog(t)
+	precert := issuer.precertificate(t, 40)
+	cert := issuer.final(t, 40, log.sct(t, precert, issuer.cert, time.Now()))
+	s := NewService()
+
+	response, err := s.Link(&LinkRequest{Precertificate: encodePEM(precert), Certificate: encodePEM(cert)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Linked || response.SCTCount != 1 || response.SerialNumber != "40" {
+		t.Errorf("Link = %+v", response)
+	}
+
+	tests := []struct {
+		name    string
+		precert *x509.Certificate
+		cert    *x509.Certificate
+		reason  string
+	}{
+		{"another certificate", precert, issuer.final(t, 41), "serial numbers differ"},
+		{"swapped", cert, precert, "first certificate is not a precertificate"},
+		{"two precertificates", precert, precert, "second certificate is a precertificate, not a final certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := s.Link(&LinkRequest{Precertificate: encodePEM(tt.precert), Certificate: encodePEM(tt.cert)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Linked || response.Reason != tt.reason {
+				t.Errorf("Link = %+v, want reason %q", response, tt.reason)
+			}
+		})
+	}
+}
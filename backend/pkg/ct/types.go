@@ -0,0 +1,70 @@
+package ct
+
+import "time"
+
+// SCT is a decoded RFC 6962 SignedCertificateTimestamp.
+type SCT struct {
+	Version            int       `json:"version"`
+	LogID              string    `json:"logId"`
+	LogDescription     string    `json:"logDescription,omitempty"`
+	Timestamp          time.Time `json:"timestamp"`
+	HashAlgorithm      string    `json:"hashAlgorithm"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm"`
+	Signature          string    `json:"signature"`
+	Extensions         string    `json:"extensions,omitempty"`
+
+	raw rawSCT
+}
+
+// Log is a CT log known to the service. The fields mirror the entries of the
+// published log list (v3) so that file can be used directly.
+type Log struct {
+	Description string `json:"description"`
+	LogID       string `json:"log_id"`
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+}
+
+type CheckRequest struct {
+	Certificate string `json:"certificate" binding:"required"`
+	// Issuer is needed to rebuild the signed precertificate entry.
+	Issuer string `json:"issuer" binding:"required"`
+	// LogKeys optionally supplements the configured logs with base64 DER
+	// encoded log public keys.
+	LogKeys []string `json:"logKeys,omitempty"`
+}
+
+type SCTResult struct {
+	SCT
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	SCTStatusValid      = "valid"
+	SCTStatusInvalid    = "invalid"
+	SCTStatusUnknownLog = "unknown_log"
+)
+
+// CheckResponse counts valid SCTs and, in LogCount, the distinct logs that
+// issued them; RequiredCount applies to the logs.
+type CheckResponse struct {
+	SCTs          []SCTResult `json:"scts"`
+	ValidCount    int         `json:"validCount"`
+	LogCount      int         `json:"logCount"`
+	RequiredCount int         `json:"requiredCount"`
+	Compliant     bool        `json:"compliant"`
+	Issues        []string    `json:"issues,omitempty"`
+}
+
+type LinkRequest struct {
+	Precertificate string `json:"precertificate" binding:"required"`
+	Certificate    string `json:"certificate" binding:"required"`
+}
+
+type LinkResponse struct {
+	Linked       bool   `json:"linked"`
+	Reason       string `json:"reason,omitempty"`
+	SerialNumber string `json:"serialNumber"`
+	SCTCount     int    `json:"sctCount"`
+}
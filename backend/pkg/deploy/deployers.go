@@ -0,0 +1,433 @@
+package deploy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-openssl-backend/pkg/netguard"
+	"web-openssl-backend/pkg/notify"
+)
+
+const maxHookOutput = 4096
+
+// hookPath is the search path of post-hooks, which do not inherit the
+// server's environment and its secrets
+const hookPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// DirectoryDeployer writes PEM files into a directory below Root and
+// optionally runs a post-hook, e.g. to reload a web server.
+type DirectoryDeployer struct {
+	Root           string
+	AllowPostHooks bool
+	HookTimeout    time.Duration
+}
+
+func NewDirectoryDeployer(root string, allowPostHooks bool, hookTimeout time.Duration) *DirectoryDeployer {
+	return &DirectoryDeployer{
+		Root:           root,
+		AllowPostHooks: allowPostHooks,
+		HookTimeout:    hookTimeout,
+	}
+}
+
+func (d *DirectoryDeployer) Type() string {
+	return TypeDirectory
+}
+
+func (d *DirectoryDeployer) Validate(config json.RawMessage) error {
+	if d.Root == "" {
+		return invalidConfig("directory targets are disabled on this server")
+	}
+
+	var cfg DirectoryConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.Path) == "" {
+		return invalidConfig("path is required")
+	}
+	switch cfg.Layout {
+	case "", LayoutSplit, LayoutFullchain:
+	case LayoutCombined:
+		if cfg.KeyFile != "" || cfg.ChainFile != "" {
+			return invalidConfig("the combined layout writes a single file; only certFile can be set")
+		}
+	default:
+		return invalidConfig("unknown layout %q", cfg.Layout)
+	}
+	for _, name := range []string{cfg.CertFile, cfg.KeyFile, cfg.ChainFile} {
+		if name != "" && (name != filepath.Base(name) || name == "." || name == ".." || strings.ContainsAny(name, `/\`)) {
+			return invalidConfig("file name %q must not contain a path", name)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, file := range directoryFiles(cfg, &Bundle{Chain: "chain"}) {
+		if seen[file.name] {
+			return invalidConfig("file name %q is used for more than one file", file.name)
+		}
+		seen[file.name] = true
+	}
+	if cfg.PostHook != "" && !d.AllowPostHooks {
+		return invalidConfig("post-hook commands are disabled on this server")
+	}
+	return nil
+}
+
+// Privileged reports whether the configuration has a post-hook.
+func (d *DirectoryDeployer) Privileged(config json.RawMessage) bool {
+	var cfg DirectoryConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return false
+	}
+	return strings.TrimSpace(cfg.PostHook) != ""
+}
+
+// Deploy writes the files of the configured layout, each replaced
+// atomically. When the post-hook fails the files stay written and the
+// result carries the hook output alongside the error.
+func (d *DirectoryDeployer) Deploy(ctx context.Context, config json.RawMessage, secret string, bundle *Bundle) (*Result, error) {
+	var cfg DirectoryConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	if bundle.PrivateKey == "" {
+		return nil, ErrPrivateKeyRequired
+	}
+
+	dir, err := d.directory(bundle.Tenant, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := directoryFiles(cfg, bundle)
+	var output strings.Builder
+	paths := make(map[string]string, len(files))
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := writeFileAtomic(path, file.content, file.mode); err != nil {
+			return &Result{Output: output.String()}, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		paths[file.role] = path
+		fmt.Fprintf(&output, "wrote %s (%d bytes)\n", path, len(file.content))
+	}
+
+	result := &Result{}
+	if cfg.PostHook != "" {
+		hookOutput, err := d.runHook(ctx, cfg.PostHook, dir, paths, bundle)
+		output.WriteString(hookOutput)
+		if err != nil {
+			result.Output = output.String()
+			return result, fmt.Errorf("post-hook failed: %w", err)
+		}
+	}
+
+	result.Output = output.String()
+	return result, nil
+}
+
+// KubernetesDeployer renders a TLS Secret manifest for the caller to apply;
+// it does not talk to a cluster.
+type KubernetesDeployer struct{}
+
+func NewKubernetesDeployer() *KubernetesDeployer {
+	return &KubernetesDeployer{}
+}
+
+func (d *KubernetesDeployer) Type() string {
+	return TypeKubernetes
+}
+
+var (
+	dnsLabel     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	dnsSubdomain = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+func (d *KubernetesDeployer) Validate(config json.RawMessage) error {
+	var cfg KubernetesConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return err
+	}
+	if len(cfg.SecretName) > 253 || !dnsSubdomain.MatchString(cfg.SecretName) {
+		return invalidConfig("secretName must be a lower case DNS subdomain")
+	}
+	if cfg.Namespace != "" && (len(cfg.Namespace) > 63 || !dnsLabel.MatchString(cfg.Namespace)) {
+		return invalidConfig("namespace must be a lower case DNS label")
+	}
+	for _, metadata := range []map[string]string{cfg.Labels, cfg.Annotations} {
+		for key := range metadata {
+			if key == "" || strings.ContainsAny(key, " \t\r\n") {
+				return invalidConfig("invalid label or annotation key %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *KubernetesDeployer) Deploy(ctx context.Context, config json.RawMessage, secret string, bundle *Bundle) (*Result, error) {
+	var cfg KubernetesConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	if bundle.PrivateKey == "" {
+		return nil, ErrPrivateKeyRequired
+	}
+
+	var manifest strings.Builder
+	manifest.WriteString("apiVersion: v1\nkind: Secret\nmetadata:\n")
+	fmt.Fprintf(&manifest, "  name: %s\n", cfg.SecretName)
+	if cfg.Namespace != "" {
+		fmt.Fprintf(&manifest, "  namespace: %s\n", cfg.Namespace)
+	}
+	writeYAMLMap(&manifest, "labels", cfg.Labels)
+	writeYAMLMap(&manifest, "annotations", cfg.Annotations)
+	manifest.WriteString("type: kubernetes.io/tls\ndata:\n")
+
+	keys := []string{"tls.crt", "tls.key"}
+	fmt.Fprintf(&manifest, "  tls.crt: %s\n", base64.StdEncoding.EncodeToString([]byte(joinPEM(bundle.Certificate, bundle.Chain))))
+	fmt.Fprintf(&manifest, "  tls.key: %s\n", base64.StdEncoding.EncodeToString([]byte(joinPEM(bundle.PrivateKey))))
+	if cfg.IncludeCA && bundle.Chain != "" {
+		keys = append(keys, "ca.crt")
+		fmt.Fprintf(&manifest, "  ca.crt: %s\n", base64.StdEncoding.EncodeToString([]byte(joinPEM(bundle.Chain))))
+	}
+
+	name := cfg.SecretName
+	if cfg.Namespace != "" {
+		name = cfg.Namespace + "/" + name
+	}
+	return &Result{
+		Output:   fmt.Sprintf("rendered Secret %s with %s\n", name, strings.Join(keys, ", ")),
+		Artifact: manifest.String(),
+	}, nil
+}
+
+// WebhookDeployer posts the certificate as JSON, signed with the target
+// secret in the X-Timestamp and X-Signature headers. Only public hosts are
+// posted to, and only over https when the private key is included.
+type WebhookDeployer struct {
+	Client *http.Client
+}
+
+func NewWebhookDeployer() *WebhookDeployer {
+	return &WebhookDeployer{
+		Client: netguard.HTTPClient(30 * time.Second),
+	}
+}
+
+func (d *WebhookDeployer) Type() string {
+	return TypeWebhook
+}
+
+func (d *WebhookDeployer) Validate(config json.RawMessage) error {
+	var cfg WebhookConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return err
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidConfig("url must be an http or https URL")
+	}
+	if cfg.IncludePrivateKey && u.Scheme != "https" {
+		return invalidConfig("url must use https when the private key is included")
+	}
+	if err := netguard.CheckHost(context.Background(), u.Hostname()); err != nil {
+		return invalidConfig("url: %v", err)
+	}
+	return nil
+}
+
+func (d *WebhookDeployer) Deploy(ctx context.Context, config json.RawMessage, secret string, bundle *Bundle) (*Result, error) {
+	var cfg WebhookConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+
+	sent := *bundle
+	if !cfg.IncludePrivateKey {
+		sent.PrivateKey = ""
+	} else if sent.PrivateKey == "" {
+		return nil, ErrPrivateKeyRequired
+	}
+	if err := netguard.CheckURL(ctx, cfg.URL, sent.PrivateKey != ""); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Event:  "certificate.deployed",
+		Time:   time.Now().UTC(),
+		Bundle: &sent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := notify.PostSigned(ctx, d.Client, cfg.URL, secret, body); err != nil {
+		return nil, err
+	}
+
+	return &Result{Output: fmt.Sprintf("delivered %d bytes to %s\n", len(body), cfg.URL)}, nil
+}
+
+// Helper functions
+
+type directoryFile struct {
+	role    string
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+func directoryFiles(cfg DirectoryConfig, bundle *Bundle) []directoryFile {
+	name := func(configured, fallback string) string {
+		if configured != "" {
+			return configured
+		}
+		return fallback
+	}
+
+	var files []directoryFile
+	switch cfg.Layout {
+	case LayoutCombined:
+		files = append(files, directoryFile{"cert", name(cfg.CertFile, "bundle.pem"), joinPEM(bundle.PrivateKey, bundle.Certificate, bundle.Chain), 0600})
+	case LayoutFullchain:
+		files = append(files,
+			directoryFile{"cert", name(cfg.CertFile, "fullchain.pem"), joinPEM(bundle.Certificate, bundle.Chain), 0644},
+			directoryFile{"key", name(cfg.KeyFile, "privkey.pem"), joinPEM(bundle.PrivateKey), 0600},
+		)
+		if bundle.Chain != "" {
+			files = append(files, directoryFile{"chain", name(cfg.ChainFile, "chain.pem"), joinPEM(bundle.Chain), 0644})
+		}
+	default:
+		files = append(files,
+			directoryFile{"cert", name(cfg.CertFile, "cert.pem"), joinPEM(bundle.Certificate), 0644},
+			directoryFile{"key", name(cfg.KeyFile, "key.pem"), joinPEM(bundle.PrivateKey), 0600},
+		)
+		if bundle.Chain != "" {
+			files = append(files, directoryFile{"chain", name(cfg.ChainFile, "chain.pem"), joinPEM(bundle.Chain), 0644})
+		}
+	}
+	return files
+}
+
+// directory resolves a target path inside the tenant's directory below
+// Root and creates it. Symbolic links are resolved so a link inside it
+// cannot lead out of it.
+func (d *DirectoryDeployer) directory(tenant, path string) (string, error) {
+	if tenant == "" || tenant != filepath.Base(tenant) || tenant == "." || tenant == ".." {
+		return "", fmt.Errorf("invalid deployment tenant %q", tenant)
+	}
+	root, err := filepath.Abs(filepath.Join(d.Root, tenant))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, filepath.Clean("/"+path))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if realDir != realRoot && !strings.HasPrefix(realDir, realRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q leaves the deployment root", path)
+	}
+	return realDir, nil
+}
+
+func (d *DirectoryDeployer) runHook(ctx context.Context, hook, dir string, paths map[string]string, bundle *Bundle) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.HookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + hookPath,
+		"HOME=" + dir,
+		"LANG=C",
+		"DEPLOY_DIR=" + dir,
+		"DEPLOY_CERT_FILE=" + paths["cert"],
+		"DEPLOY_KEY_FILE=" + paths["key"],
+		"DEPLOY_CHAIN_FILE=" + paths["chain"],
+		"DEPLOY_CERTIFICATE_ID=" + strconv.FormatUint(uint64(bundle.CertificateID), 10),
+		"DEPLOY_COMMON_NAME=" + bundle.CommonName,
+	}
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > maxHookOutput {
+		output = append(output[:maxHookOutput], "\n[output truncated]\n"...)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", d.HookTimeout)
+	}
+	return string(output), err
+}
+
+// writeFileAtomic replaces path with content through a rename, so readers
+// never see a partially written certificate.
+func writeFileAtomic(path, content string, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".deploy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// joinPEM concatenates PEM blocks, each ending in exactly one newline
+func joinPEM(parts ...string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			b.WriteString(part)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func writeYAMLMap(b *strings.Builder, name string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "  %s:\n", name)
+	for _, key := range keys {
+		// Go quoted strings are valid YAML double-quoted scalars
+		fmt.Fprintf(b, "    %s: %s\n", strconv.Quote(key), strconv.Quote(values[key]))
+	}
+}
@@ -0,0 +1,122 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnsupportedType    = errors.New("unsupported deployment target type")
+	ErrInvalidConfig      = errors.New("invalid deployment target configuration")
+	ErrPrivateKeyRequired = errors.New("target requires the private key, which is not exportable")
+)
+
+const defaultPostHookTimeout = time.Minute
+
+type Service struct {
+	mu        sync.RWMutex
+	deployers map[string]Deployer
+}
+
+// NewService registers the built-in directory, Kubernetes and webhook
+// deployers.
+func NewService(config Config) *Service {
+	if config.PostHookTimeout <= 0 {
+		config.PostHookTimeout = defaultPostHookTimeout
+	}
+
+	s := &Service{deployers: make(map[string]Deployer)}
+	s.SetDeployer(NewDirectoryDeployer(config.DirectoryRoot, config.AllowPostHooks, config.PostHookTimeout))
+	s.SetDeployer(NewKubernetesDeployer())
+	s.SetDeployer(NewWebhookDeployer())
+	return s
+}
+
+// SetDeployer adds a target type or replaces the deployer registered for it.
+func (s *Service) SetDeployer(deployer Deployer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deployers[deployer.Type()] = deployer
+}
+
+// Types lists the registered target types.
+func (s *Service) Types() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	types := make([]string, 0, len(s.deployers))
+	for name := range s.deployers {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Validate checks a target configuration before it is stored.
+func (s *Service) Validate(targetType string, config json.RawMessage) error {
+	deployer, err := s.deployer(targetType)
+	if err != nil {
+		return err
+	}
+	return deployer.Validate(config)
+}
+
+// Privileged reports whether a target configuration runs commands on the
+// server, which only platform administrators may set up.
+func (s *Service) Privileged(targetType string, config json.RawMessage) bool {
+	deployer, err := s.deployer(targetType)
+	if err != nil {
+		return false
+	}
+	privileged, ok := deployer.(PrivilegedDeployer)
+	return ok && privileged.Privileged(config)
+}
+
+// Deploy delivers bundle to a target.
+func (s *Service) Deploy(ctx context.Context, targetType string, config json.RawMessage, secret string, bundle *Bundle) (*Result, error) {
+	deployer, err := s.deployer(targetType)
+	if err != nil {
+		return nil, err
+	}
+	if err := deployer.Validate(config); err != nil {
+		return nil, err
+	}
+	return deployer.Deploy(ctx, config, secret, bundle)
+}
+
+// Helper functions
+
+func (s *Service) deployer(targetType string) (Deployer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deployer, ok := s.deployers[targetType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedType, targetType)
+	}
+	return deployer, nil
+}
+
+// decodeConfig strictly decodes a target configuration, so misspelt
+// settings are reported instead of silently ignored.
+func decodeConfig(config json.RawMessage, v interface{}) error {
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+func invalidConfig(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	TypeDirectory  = "directory"
+	TypeKubernetes = "kubernetes"
+	TypeWebhook    = "webhook"
+)
+
+// Directory layouts
+const (
+	LayoutSplit     = "split"     // cert.pem, chain.pem and key.pem
+	LayoutFullchain = "fullchain" // fullchain.pem and privkey.pem, as certbot writes them
+	LayoutCombined  = "combined"  // one bundle.pem holding key, certificate and chain
+)
+
+type Config struct {
+	// DirectoryRoot confines directory targets; every target path is
+	// resolved inside the directory of the target's tenant below it.
+	// Directory targets are disabled when it is empty.
+	DirectoryRoot string
+	// AllowPostHooks permits directory targets to run a shell command after
+	// the files are written. Only platform administrators may set one.
+	AllowPostHooks  bool
+	PostHookTimeout time.Duration
+}
+
+// Bundle is the certificate material handed to a deployer. PrivateKey is
+// empty when the key may not leave the inventory.
+type Bundle struct {
+	// Tenant names the user or organization the target belongs to, as a
+	// single path element; directory targets write below Root/Tenant
+	Tenant        string    `json:"-"`
+	CertificateID uint      `json:"certificateId"`
+	Name          string    `json:"name"`
+	CommonName    string    `json:"commonName"`
+	SANs          []string  `json:"sans"`
+	Fingerprint   string    `json:"fingerprint"`
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+	Certificate   string    `json:"certificate"`
+	Chain         string    `json:"chain,omitempty"`
+	PrivateKey    string    `json:"privateKey,omitempty"`
+}
+
+// Result describes a completed deployment. Output is a log of what was
+// done and safe to store; Artifact is anything rendered for the caller,
+// such as a manifest, which may contain the private key.
+type Result struct {
+	Output   string
+	Artifact string
+}
+
+// Deployer delivers certificates to one kind of target. Its settings are
+// the target's JSON configuration; secret is stored apart from it.
+type Deployer interface {
+	Type() string
+	Validate(config json.RawMessage) error
+	Deploy(ctx context.Context, config json.RawMessage, secret string, bundle *Bundle) (*Result, error)
+}
+
+// PrivilegedDeployer is implemented by deployers whose configurations can
+// run commands on the server. Only platform administrators may save those.
+type PrivilegedDeployer interface {
+	Privileged(config json.RawMessage) bool
+}
+
+// DirectoryConfig writes the certificate as PEM files below the tenant's
+// directory in Config.DirectoryRoot. File names default to those of the
+// layout.
+type DirectoryConfig struct {
+	Path      string `json:"path"`
+	Layout    string `json:"layout,omitempty"`
+	CertFile  string `json:"certFile,omitempty"`
+	KeyFile   string `json:"keyFile,omitempty"`
+	ChainFile string `json:"chainFile,omitempty"`
+	PostHook  string `json:"postHook,omitempty"`
+}
+
+// KubernetesConfig renders a kubernetes.io/tls Secret manifest. tls.crt
+// holds the certificate followed by its chain.
+type KubernetesConfig struct {
+	Namespace   string            `json:"namespace"`
+	SecretName  string            `json:"secretName"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// IncludeCA adds the chain as ca.crt
+	IncludeCA bool `json:"includeCA,omitempty"`
+}
+
+// WebhookConfig posts the certificate to URL, signed with the target
+// secret like notification webhooks. URL must be on a public host, and use
+// https when IncludePrivateKey is set.
+type WebhookConfig struct {
+	URL               string `json:"url"`
+	IncludePrivateKey bool   `json:"includePrivateKey,omitempty"`
+}
+
+// webhookPayload is the body posted by webhook targets
+type webhookPayload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	*Bundle
+}
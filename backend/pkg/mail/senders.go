@@ -0,0 +1,145 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"web-openssl-backend/pkg/smtpclient"
+)
+
+// SMTPSender delivers messages through a mail server, see
+// smtpclient.Client.
+type SMTPSender struct {
+	smtpclient.Client
+}
+
+func NewSMTPSender(host string, port int, username, password string) *SMTPSender {
+	if port == 0 {
+		port = smtpclient.DefaultPort
+	}
+	return &SMTPSender{smtpclient.Client{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+	}}
+}
+
+func (s *SMTPSender) Name() string {
+	return ProviderSMTP
+}
+
+func (s *SMTPSender) Send(ctx context.Context, from string, message *Message) error {
+	return s.Client.Send(ctx, from, message.To, render(from, message))
+}
+
+// LogSender writes whole messages, links included, to the server log. It
+// is meant for development only.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Name() string {
+	return ProviderLog
+}
+
+func (s *LogSender) Send(ctx context.Context, from string, message *Message) error {
+	log.Printf("[mail] to %s: %s\n%s", strings.Join(message.To, ", "), message.Subject, message.Text)
+	return nil
+}
+
+// FileSender writes each message to its own .eml file in Dir, named so
+// that files sort in the order they were sent.
+type FileSender struct {
+	Dir string
+}
+
+func NewFileSender(dir string) *FileSender {
+	return &FileSender{Dir: dir}
+}
+
+func (s *FileSender) Name() string {
+	return ProviderFile
+}
+
+func (s *FileSender) Send(ctx context.Context, from string, message *Message) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", message.Time.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(s.Dir, name), render(from, message), 0600)
+}
+
+// FakeSender records messages instead of delivering them. Setting Err
+// makes every Send fail, to exercise delivery error handling.
+type FakeSender struct {
+	Err error
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+func (s *FakeSender) Name() string {
+	return "fake"
+}
+
+func (s *FakeSender) Send(ctx context.Context, from string, message *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	s.sent = append(s.sent, *message)
+	return nil
+}
+
+// Sent returns a copy of the messages received so far.
+func (s *FakeSender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.sent...)
+}
+
+func (s *FakeSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = nil
+}
+
+// Helper functions
+
+func render(from string, message *Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + strings.Join(message.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + headerValue(message.Subject) + "\r\n")
+	b.WriteString("Date: " + message.Time.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.TrimRight(message.Text, "\n")+"\n", "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrNoRecipients = errors.New("message has no recipients")
+
+// Service sends account email, such as password reset links, to users.
+// Unlike notifications these always go to the user concerned, over a single
+// sender.
+type Service struct {
+	mu        sync.RWMutex
+	from      string
+	sender    Sender
+	configErr error
+}
+
+// NewService creates the sender for config.Provider, writing messages to
+// the log when no provider is set. An incomplete configuration also falls
+// back to the log and is reported by Check.
+func NewService(config Config) *Service {
+	s := &Service{from: config.From}
+
+	switch config.Provider {
+	case ProviderSMTP:
+		if config.SMTPHost == "" {
+			s.configErr = errors.New("the smtp mail provider requires a host")
+			break
+		}
+		s.sender = NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword)
+	case ProviderFile:
+		if config.Dir == "" {
+			s.configErr = errors.New("the file mail provider requires a directory")
+			break
+		}
+		s.sender = NewFileSender(config.Dir)
+	case ProviderLog, "":
+	default:
+		s.configErr = fmt.Errorf("unknown mail provider %q", config.Provider)
+	}
+	if s.sender == nil {
+		s.sender = NewLogSender()
+	}
+
+	return s
+}
+
+// Check reports a configuration the service could not honour.
+func (s *Service) Check() error {
+	return s.configErr
+}
+
+// SetSender replaces the configured sender, e.g. with a FakeSender.
+func (s *Service) SetSender(sender Sender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sender = sender
+}
+
+// Provider names the sender messages go out through.
+func (s *Service) Provider() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sender.Name()
+}
+
+func (s *Service) Send(ctx context.Context, message *Message) error {
+	if len(message.To) == 0 {
+		return ErrNoRecipients
+	}
+	if message.Time.IsZero() {
+		message.Time = time.Now().UTC()
+	}
+
+	s.mu.RLock()
+	sender := s.sender
+	s.mu.RUnlock()
+
+	if err := sender.Send(ctx, s.from, message); err != nil {
+		return fmt.Errorf("%s: %w", sender.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+	"time"
+)
+
+// Providers a Service can be configured with
+const (
+	ProviderSMTP = "smtp"
+	ProviderLog  = "log"
+	ProviderFile = "file"
+)
+
+type Config struct {
+	Provider string
+	From     string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// Dir receives one .eml file per message with the file provider
+	Dir string
+}
+
+// Message is a plain text email to one or more recipients.
+type Message struct {
+	To      []string  `json:"to"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	Time    time.Time `json:"time"`
+}
+
+// Sender delivers email.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, from string, message *Message) error
+}
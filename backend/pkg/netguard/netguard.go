@@ -0,0 +1,138 @@
+// Package netguard keeps outbound connections that users aim, such as
+// endpoint scans and webhooks, away from the server's own networks:
+// loopback, private and link-local addresses, cloud metadata services and
+// other ranges that are not reachable on the public internet.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrNonPublicAddress = errors.New("address is not on the public internet")
+	ErrInsecureURL      = errors.New("URL must use https")
+)
+
+// nonPublic lists the ranges besides those the net.IP predicates cover
+var nonPublic = mustParseCIDRs(
+	"0.0.0.0/8",       // "this network"
+	"100.64.0.0/10",   // carrier-grade NAT
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"240.0.0.0/4",     // reserved, and broadcast
+	"64:ff9b::/96",    // NAT64, which reaches IPv4 addresses
+	"64:ff9b:1::/48",  // local-use NAT64
+	"2001:db8::/32",   // documentation
+	"2002::/16",       // 6to4, which embeds IPv4 addresses
+)
+
+// Public reports whether ip is a unicast address on the public internet.
+func Public(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublic {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHost resolves host and fails unless every address it has is public.
+// It lets a setting be refused when it is saved; connections still need
+// Dialer, as the name may resolve differently later.
+func CheckHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !Public(ip) {
+			return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+		}
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, address := range addresses {
+		if !Public(address.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrNonPublicAddress, host, address.IP)
+		}
+	}
+	return nil
+}
+
+// CheckURL checks that rawURL is an absolute http or https URL whose host
+// is public. With requireTLS only https is accepted, for URLs that are sent
+// secrets.
+func CheckURL(ctx context.Context, rawURL string, requireTLS bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+	if requireTLS && u.Scheme != "https" {
+		return ErrInsecureURL
+	}
+	return CheckHost(ctx, u.Hostname())
+}
+
+// Dialer returns a dialer that refuses to connect to non-public addresses.
+// The check runs on the address actually dialled, after name resolution, so
+// a name that is rebound to an internal address between a check and the
+// connection is caught too.
+func Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: control,
+	}
+}
+
+// HTTPClient returns a client whose connections go through Dialer. It
+// ignores proxy settings, which would hide the address dialled.
+func HTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = Dialer(30 * time.Second).DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// Helper functions
+
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
@@ -0,0 +1,285 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"web-openssl-backend/pkg/smtpclient"
+)
+
+// SMTPSender emails notifications to To and the notification's own
+// recipients, see smtpclient.Client.
+type SMTPSender struct {
+	smtpclient.Client
+	From string
+	To   []string
+}
+
+func NewSMTPSender(host string, port int, username, password, from string, to []string) *SMTPSender {
+	if port == 0 {
+		port = smtpclient.DefaultPort
+	}
+	return &SMTPSender{
+		Client: smtpclient.Client{
+			Host:     host,
+			Port:     port,
+			Username: username,
+			Password: password,
+		},
+		From: from,
+		To:   to,
+	}
+}
+
+func (s *SMTPSender) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPSender) Send(ctx context.Context, notification *Notification) error {
+	recipients := uniqueAddresses(append(append([]string{}, s.To...), notification.Recipients...))
+	if len(recipients) == 0 {
+		return nil
+	}
+	return s.Client.Send(ctx, s.From, recipients, s.message(notification, recipients))
+}
+
+func (s *SMTPSender) message(notification *Notification, recipients []string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + s.From + "\r\n")
+	b.WriteString("To: " + strings.Join(recipients, ", ") + "\r\n")
+	b.WriteString("Subject: " + headerValue(notification.Subject) + "\r\n")
+	b.WriteString("Date: " + notification.Time.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(plainText(notification), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// WebhookSender posts the notification as JSON. Requests carry the same
+// X-Timestamp and X-Signature headers as the other outgoing webhooks: the
+// hex HMAC-SHA256 of "<timestamp>.<body>" under the shared secret.
+type WebhookSender struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookSender(url, secret string) *WebhookSender {
+	return &WebhookSender{
+		URL:    url,
+		Secret: secret,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *WebhookSender) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSender) Send(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	return PostSigned(ctx, s.Client, s.URL, s.Secret, body)
+}
+
+// SlackSender posts to a Slack incoming webhook, or any service accepting
+// the same {"text": ...} payload.
+type SlackSender struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewSlackSender(url string) *SlackSender {
+	return &SlackSender{
+		URL:    url,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *SlackSender) Name() string {
+	return "slack"
+}
+
+func (s *SlackSender) Send(ctx context.Context, notification *Notification) error {
+	icon := ":information_source:"
+	switch notification.Severity {
+	case SeverityWarning:
+		icon = ":warning:"
+	case SeverityCritical:
+		icon = ":rotating_light:"
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("%s *%s*\n%s", icon, notification.Subject, plainText(notification)),
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.Client, s.URL, body, nil)
+}
+
+// LogSender writes notifications to the server log.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Name() string {
+	return "log"
+}
+
+func (s *LogSender) Send(ctx context.Context, notification *Notification) error {
+	log.Printf("[notify] %s: %s", notification.Severity, notification.Subject)
+	return nil
+}
+
+// FakeSender records notifications instead of delivering them. Setting Err
+// makes every Send fail, to exercise delivery error handling.
+type FakeSender struct {
+	Err error
+
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+func (s *FakeSender) Name() string {
+	return "fake"
+}
+
+func (s *FakeSender) Send(ctx context.Context, notification *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	s.sent = append(s.sent, *notification)
+	return nil
+}
+
+// Sent returns a copy of the notifications received so far.
+func (s *FakeSender) Sent() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification(nil), s.sent...)
+}
+
+func (s *FakeSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = nil
+}
+
+// SignWebhook computes the X-Signature value for a webhook body.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostSigned posts a JSON body to url, signed with secret in the
+// X-Timestamp and X-Signature headers when secret is set.
+func PostSigned(ctx context.Context, client *http.Client, url, secret string, body []byte) error {
+	headers := map[string]string{}
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers["X-Timestamp"] = timestamp
+		headers["X-Signature"] = SignWebhook(secret, timestamp, body)
+	}
+	return postJSON(ctx, client, url, body, headers)
+}
+
+// Helper functions
+
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	if url == "" {
+		return errors.New("webhook URL is not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func plainText(notification *Notification) string {
+	text := notification.Text
+	if fields := fieldLines(notification); fields != "" {
+		text += "\n\n" + fields
+	}
+	return text + "\n"
+}
+
+// fieldLines renders the notification fields sorted by name, one per line.
+func fieldLines(notification *Notification) string {
+	names := make([]string, 0, len(notification.Fields))
+	for name := range notification.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + ": " + notification.Fields[name]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+func uniqueAddresses(addresses []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		key := strings.ToLower(address)
+		if address == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, address)
+	}
+	return unique
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Service struct {
+	mu      sync.RWMutex
+	senders []Sender
+}
+
+// NewService creates a sender for every channel configured. Without any
+// channel, notifications are written to the log so they are not lost.
+func NewService(config Config) *Service {
+	s := &Service{}
+
+	if config.SMTPHost != "" {
+		s.senders = append(s.senders, NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.SMTPFrom, config.SMTPTo))
+	}
+	if config.WebhookURL != "" {
+		s.senders = append(s.senders, NewWebhookSender(config.WebhookURL, config.WebhookSecret))
+	}
+	if config.SlackWebhookURL != "" {
+		s.senders = append(s.senders, NewSlackSender(config.SlackWebhookURL))
+	}
+	if len(s.senders) == 0 {
+		s.senders = append(s.senders, NewLogSender())
+	}
+
+	return s
+}
+
+// SetSenders replaces the configured channels, e.g. with a FakeSender.
+func (s *Service) SetSenders(senders ...Sender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.senders = senders
+}
+
+func (s *Service) Channels() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, len(s.senders))
+	for i, sender := range s.senders {
+		names[i] = sender.Name()
+	}
+	return names
+}
+
+// Send delivers notification on every channel. It returns the channels that
+// accepted it and an error describing each channel that did not.
+func (s *Service) Send(ctx context.Context, notification *Notification) ([]string, error) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now().UTC()
+	}
+	if notification.Severity == "" {
+		notification.Severity = SeverityInfo
+	}
+
+	s.mu.RLock()
+	senders := s.senders
+	s.mu.RUnlock()
+
+	var delivered []string
+	var errs []error
+	for _, sender := range senders {
+		if err := sender.Send(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sender.Name(), err))
+			continue
+		}
+		delivered = append(delivered, sender.Name())
+	}
+
+	return delivered, errors.Join(errs...)
+}
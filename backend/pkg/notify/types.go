@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+type Config struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// SMTPTo receives every notification in addition to its own recipients.
+	SMTPTo []string
+
+	WebhookURL    string
+	WebhookSecret string
+
+	SlackWebhookURL string
+}
+
+// Notification is a channel-independent message. Recipients are email
+// addresses of the people it concerns; channels without a notion of
+// recipients ignore them.
+type Notification struct {
+	Event      string            `json:"event"`
+	Severity   string            `json:"severity"`
+	Subject    string            `json:"subject"`
+	Text       string            `json:"text"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Recipients []string          `json:"-"`
+	Time       time.Time         `json:"time"`
+}
+
+// Sender delivers notifications over one channel.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, notification *Notification) error
+}
@@ -0,0 +1,371 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrDiscovery      = errors.New("OpenID provider discovery failed")
+	ErrTokenExchange  = errors.New("authorization code exchange failed")
+	ErrInvalidIDToken = errors.New("invalid ID token")
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// providerTTL is how long discovery documents and key sets are reused
+	providerTTL = time.Hour
+	// keyRefreshInterval limits refetching a key set for unknown key IDs
+	keyRefreshInterval = time.Minute
+	// clockSkew is tolerated on the times in ID tokens
+	clockSkew = time.Minute
+
+	maxResponseBytes = 1 << 20
+	randomBytes      = 32
+)
+
+// signingMethods are the ID token algorithms accepted; "none" and HMAC with
+// the client secret are not
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Provider is the part of an OpenID provider's discovery document the
+// authorization code flow needs
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// AuthRequest is an authentication request of the authorization code flow
+// with PKCE
+type AuthRequest struct {
+	ClientID     string
+	RedirectURI  string
+	Scopes       []string
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// Claims are the claims of a verified ID token
+type Claims map[string]interface{}
+
+type cachedProvider struct {
+	provider  *Provider
+	fetchedAt time.Time
+}
+
+type keySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// Service signs users in with OpenID Connect providers. Discovery documents
+// and signing keys are cached per issuer.
+type Service struct {
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*cachedProvider
+	keySets   map[string]*keySet
+}
+
+func NewService() *Service {
+	return &Service{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		providers:  make(map[string]*cachedProvider),
+		keySets:    make(map[string]*keySet),
+	}
+}
+
+// SetHTTPClient sets the client used to talk to providers, e.g. one trusting
+// the private root of a test provider.
+func (s *Service) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+// CheckIssuer validates an issuer identifier: an https URL without query or
+// fragment. Plain http is accepted for providers on the local machine.
+func CheckIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%w: issuer must be an absolute URL", ErrDiscovery)
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLocalHost(u.Hostname())) {
+		return fmt.Errorf("%w: issuer must use https", ErrDiscovery)
+	}
+	return nil
+}
+
+// Discover fetches the provider's discovery document.
+func (s *Service) Discover(ctx context.Context, issuer string) (*Provider, error) {
+	issuer = strings.TrimRight(issuer, "/")
+	if err := CheckIssuer(issuer); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.providers[issuer]
+	s.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < providerTTL {
+		return cached.provider, nil
+	}
+
+	var provider Provider
+	if err := s.getJSON(ctx, issuer+discoveryPath, &provider); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	// OpenID Connect Discovery 1.0 section 4.3
+	if strings.TrimRight(provider.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%w: document is for issuer %q", ErrDiscovery, provider.Issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: document lacks required endpoints", ErrDiscovery)
+	}
+
+	s.mu.Lock()
+	s.providers[issuer] = &cachedProvider{provider: &provider, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return &provider, nil
+}
+
+// AuthCodeURL returns the address to send the user to in order to sign in.
+func (p *Provider) AuthCodeURL(req AuthRequest) string {
+	scopes := append([]string{"openid"}, req.Scopes...)
+	challenge := sha256.Sum256([]byte(req.CodeVerifier))
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {req.ClientID},
+		"redirect_uri":          {req.RedirectURI},
+		"scope":                 {strings.Join(dedupe(scopes), " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns
+// the ID token.
+func (s *Service) Exchange(ctx context.Context, provider *Provider, clientID, clientSecret, code, redirectURI, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic, RFC 6749 section 2.3.1
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil {
+		return "", fmt.Errorf("%w: status %d", ErrTokenExchange, resp.StatusCode)
+	}
+	if body.Error != "" {
+		return "", fmt.Errorf("%w: %s %s", ErrTokenExchange, body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status %d", ErrTokenExchange, resp.StatusCode)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: no ID token returned", ErrTokenExchange)
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks an ID token's signature by one of the provider's
+// keys, its issuer, audience, lifetime and nonce, and returns its claims.
+func (s *Service) VerifyIDToken(ctx context.Context, provider *Provider, clientID, rawToken, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.key(ctx, provider, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	// OpenID Connect Core 1.0 section 3.1.3.7
+	if expiry, _ := claims.GetExpirationTime(); expiry == nil {
+		return nil, fmt.Errorf("%w: no expiry", ErrInvalidIDToken)
+	}
+	audience, _ := claims.GetAudience()
+	if azp, ok := claims["azp"].(string); (ok || len(audience) > 1) && azp != clientID {
+		return nil, fmt.Errorf("%w: token was issued to another party", ErrInvalidIDToken)
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	}
+	if subject, _ := claims.GetSubject(); subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return Claims(claims), nil
+}
+
+// RandomString returns an unguessable value for a state, nonce or PKCE code
+// verifier.
+func RandomString() (string, error) {
+	b := make([]byte, randomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// String returns a string claim, or "" if it is missing or not a string.
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Strings returns a claim holding a string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// Helper functions
+
+// key returns the provider's signing key with the ID, refetching the key set
+// when the key is unknown, which is how providers roll their keys.
+func (s *Service) key(ctx context.Context, provider *Provider, kid string) (interface{}, error) {
+	s.mu.Lock()
+	set, ok := s.keySets[provider.JWKSURI]
+	s.mu.Unlock()
+
+	fresh := ok && time.Since(set.fetchedAt) < providerTTL
+	if fresh {
+		if key, found := set.find(kid); found {
+			return key, nil
+		}
+	}
+	if ok && time.Since(set.fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.getJSON(ctx, provider.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %v", err)
+	}
+	set = &keySet{keys: make(map[string]interface{}), fetchedAt: time.Now()}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			set.keys[jwk.Kid] = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keySets[provider.JWKSURI] = set
+	s.mu.Unlock()
+
+	if key, found := set.find(kid); found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// find looks a key up by ID. A token without a key ID may only be verified
+// when the set holds a single key.
+func (k *keySet) find(kid string) (interface{}, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+func (s *Service) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
+
+func isLocalHost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
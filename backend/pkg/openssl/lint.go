@@ -0,0 +1,335 @@
+package openssl
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// Baseline Requirements 6.3.2: certificates issued after 2020-09-01
+	// must not have a validity period greater than 398 days.
+	maxLeafValidityDays = 398
+	minRSAKeySize       = 2048
+	// Baseline Requirements 7.1: serial numbers must contain at least
+	// 64 bits of output from a CSPRNG. Entropy cannot be measured, but such
+	// a serial almost always encodes to at least 8 octets.
+	minSerialOctets = 8
+	// RFC 5280 4.1.2.2: serial numbers must not be longer than 20 octets.
+	maxSerialOctets = 20
+)
+
+var (
+	oidExtKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+func (s *Service) Lint(req *LintRequest) (*LintResponse, error) {
+	var info *CertificateInfo
+	var err error
+	response := &LintResponse{Findings: []LintFinding{}}
+
+	switch {
+	case req.Certificate != "" && req.CSR != "":
+		return nil, errors.New("provide either a certificate or a CSR, not both")
+	case req.Certificate != "":
+		response.Type = "certificate"
+		info, err = s.parseCertificateOutput("", req.Certificate)
+	case req.CSR != "":
+		response.Type = "csr"
+		info, err = s.parseCSR(req.CSR)
+	default:
+		return nil, errors.New("certificate or CSR is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response.Subject = info.Subject
+	response.Findings = append(response.Findings, lintCertificateInfo(info, req.CSR != "")...)
+
+	for _, finding := range response.Findings {
+		switch finding.Severity {
+		case LintSeverityError:
+			response.Errors++
+		case LintSeverityWarning:
+			response.Warnings++
+		case LintSeverityNotice:
+			response.Notices++
+		}
+	}
+	response.Passed = response.Errors == 0
+
+	return response, nil
+}
+
+func lintCertificateInfo(info *CertificateInfo, isCSR bool) []LintFinding {
+	var findings []LintFinding
+	add := func(code string, severity LintSeverity, source, format string, args ...interface{}) {
+		findings = append(findings, LintFinding{
+			Code:     code,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+			Source:   source,
+		})
+	}
+
+	isServerCert := !info.IsCA && (len(info.ExtKeyUsage) == 0 || containsString(info.ExtKeyUsage, "serverAuth"))
+
+	// Validity period
+	if !isCSR {
+		validityDays := int(info.NotAfter.Sub(info.NotBefore).Hours()/24) + 1
+		if isServerCert && validityDays > maxLeafValidityDays {
+			add("validity_too_long", LintSeverityError, "BR 6.3.2",
+				"validity period of %d days exceeds the maximum of %d days", validityDays, maxLeafValidityDays)
+		}
+		if !info.NotAfter.After(info.NotBefore) {
+			add("validity_inverted", LintSeverityError, "RFC 5280 4.1.2.5",
+				"notAfter is not later than notBefore")
+		}
+		if time.Now().After(info.NotAfter) {
+			add("expired", LintSeverityWarning, "RFC 5280 4.1.2.5", "certificate expired on %s", info.NotAfter.Format(time.RFC3339))
+		}
+	}
+
+	// Subject alternative names
+	if isServerCert {
+		if len(info.SANs) == 0 {
+			add("san_missing", LintSeverityError, "BR 7.1.2.3",
+				"subscriber certificates must contain a subjectAltName extension")
+		}
+		if cn := info.Subject.CommonName; cn != "" && len(info.SANs) > 0 && !sanContains(info.SANs, cn) {
+			add("cn_not_in_san", LintSeverityError, "BR 7.1.4.3",
+				"common name %q is not present in the subjectAltName extension", cn)
+		}
+		for _, san := range info.SANs {
+			if strings.HasPrefix(san, "*.") && strings.Count(san, ".") < 2 {
+				add("wildcard_too_broad", LintSeverityError, "BR 3.2.2.6",
+					"wildcard %q covers a whole top-level domain", san)
+			}
+		}
+	}
+
+	// Key strength
+	switch info.PublicKeyAlgorithm {
+	case "RSA":
+		if info.PublicKeySize < minRSAKeySize {
+			add("weak_rsa_key", LintSeverityError, "BR 6.1.5",
+				"RSA key size %d is below the minimum of %d bits", info.PublicKeySize, minRSAKeySize)
+		} else if info.PublicKeySize%8 != 0 {
+			add("rsa_modulus_not_byte_aligned", LintSeverityWarning, "BR 6.1.5",
+				"RSA modulus size %d is not divisible by 8", info.PublicKeySize)
+		}
+	case "ECDSA":
+		if info.PublicKeySize != 256 && info.PublicKeySize != 384 && info.PublicKeySize != 521 {
+			add("unsupported_curve", LintSeverityError, "BR 6.1.5",
+				"EC keys must use P-256, P-384 or P-521 (got %d-bit curve)", info.PublicKeySize)
+		}
+	case "DSA":
+		add("dsa_key", LintSeverityError, "BR 6.1.5", "DSA keys are not permitted")
+	}
+
+	// Signature algorithm
+	signatureAlgorithm := strings.ToUpper(info.SignatureAlgorithm)
+	switch {
+	case strings.Contains(signatureAlgorithm, "SHA1"):
+		add("sha1_signature", LintSeverityError, "BR 7.1.3.2",
+			"SHA-1 signatures are not permitted (%s)", info.SignatureAlgorithm)
+	case strings.Contains(signatureAlgorithm, "MD5") || strings.Contains(signatureAlgorithm, "MD2"):
+		add("md5_signature", LintSeverityError, "BR 7.1.3.2",
+			"MD2/MD5 signatures are not permitted (%s)", info.SignatureAlgorithm)
+	}
+
+	// Key usage combinations
+	keyCertSign := containsString(info.KeyUsage, "keyCertSign")
+	switch {
+	case info.IsCA && !keyCertSign:
+		add("ca_missing_key_cert_sign", LintSeverityError, "RFC 5280 4.2.1.3",
+			"CA certificates must assert keyCertSign")
+	case !info.IsCA && keyCertSign:
+		add("leaf_key_cert_sign", LintSeverityError, "RFC 5280 4.2.1.3",
+			"keyCertSign is asserted but the certificate is not a CA")
+	}
+	if !info.IsCA && containsString(info.ExtKeyUsage, "anyExtendedKeyUsage") {
+		add("leaf_any_eku", LintSeverityError, "BR 7.1.2.3",
+			"subscriber certificates must not contain anyExtendedKeyUsage")
+	}
+	if info.PublicKeyAlgorithm == "ECDSA" && containsString(info.KeyUsage, "keyEncipherment") {
+		add("ec_key_encipherment", LintSeverityWarning, "RFC 5480 3",
+			"keyEncipherment is not valid for EC keys")
+	}
+	if info.PublicKeyAlgorithm == "Ed25519" && (containsString(info.KeyUsage, "keyEncipherment") || containsString(info.KeyUsage, "keyAgreement")) {
+		add("ed25519_encipherment", LintSeverityError, "RFC 8410 5",
+			"Ed25519 keys may only be used for signatures")
+	}
+	if containsString(info.KeyUsage, "encipherOnly") || containsString(info.KeyUsage, "decipherOnly") {
+		if !containsString(info.KeyUsage, "keyAgreement") {
+			add("encipher_only_without_key_agreement", LintSeverityError, "RFC 5280 4.2.1.3",
+				"encipherOnly/decipherOnly require keyAgreement")
+		}
+	}
+	if isServerCert && len(info.KeyUsage) > 0 && !containsString(info.KeyUsage, "digitalSignature") {
+		add("server_missing_digital_signature", LintSeverityWarning, "BR 7.1.2.3",
+			"TLS server certificates should assert digitalSignature")
+	}
+	if isServerCert && len(info.ExtKeyUsage) == 0 {
+		add("eku_missing", LintSeverityNotice, "BR 7.1.2.3",
+			"subscriber certificates should contain an extendedKeyUsage extension with serverAuth")
+	}
+
+	// Serial number
+	if !isCSR {
+		serial, ok := new(big.Int).SetString(info.SerialNumber, 10)
+		switch {
+		case !ok:
+			add("serial_invalid", LintSeverityError, "RFC 5280 4.1.2.2", "serial number could not be parsed")
+		case serial.Sign() <= 0:
+			add("serial_not_positive", LintSeverityError, "RFC 5280 4.1.2.2", "serial number must be positive")
+		case serialOctets(serial) > maxSerialOctets:
+			add("serial_too_long", LintSeverityError, "RFC 5280 4.1.2.2",
+				"serial number is %d octets, the maximum is %d", serialOctets(serial), maxSerialOctets)
+		case serialOctets(serial) < minSerialOctets:
+			add("serial_low_entropy", LintSeverityWarning, "BR 7.1",
+				"serial number is %d octets; serials with 64 bits of CSPRNG output are normally at least %d", serialOctets(serial), minSerialOctets)
+		}
+
+		if info.Version != 3 {
+			add("not_v3", LintSeverityWarning, "RFC 5280 4.1.2.1",
+				"certificate is version %d, extensions require version 3", info.Version)
+		}
+	}
+
+	return findings
+}
+
+// parseCSR reads a PEM encoded CSR into the same shape as a parsed
+// certificate so that the lint rules can be shared.
+func (s *Service) parseCSR(csrPEM string) (*CertificateInfo, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse PEM block")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("CSR signature is invalid: %w", err)
+	}
+
+	info := &CertificateInfo{
+		Subject: Subject{
+			CommonName:         csr.Subject.CommonName,
+			Country:            strings.Join(csr.Subject.Country, ","),
+			State:              strings.Join(csr.Subject.Province, ","),
+			Locality:           strings.Join(csr.Subject.Locality, ","),
+			Organization:       strings.Join(csr.Subject.Organization, ","),
+			OrganizationalUnit: strings.Join(csr.Subject.OrganizationalUnit, ","),
+		},
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm: csr.PublicKeyAlgorithm.String(),
+		PublicKeySize:      publicKeySize(csr.PublicKey),
+		Fingerprints:       make(map[string]string),
+		Extensions:         make(map[string]string),
+	}
+
+	info.SANs = append(info.SANs, csr.DNSNames...)
+	info.SANs = append(info.SANs, csr.EmailAddresses...)
+	for _, ip := range csr.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	// Requested extensions are not decoded by crypto/x509 for CSRs.
+	for _, ext := range csr.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtKeyUsage):
+			var bits asn1.BitString
+			if _, err := asn1.Unmarshal(ext.Value, &bits); err == nil {
+				var usage x509.KeyUsage
+				for i := 0; i < 9; i++ {
+					if bits.At(i) != 0 {
+						usage |= 1 << uint(i)
+					}
+				}
+				info.KeyUsage = keyUsageNames(usage)
+			}
+		case ext.Id.Equal(oidExtExtendedKeyUsage):
+			var oids []asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(ext.Value, &oids); err == nil {
+				info.ExtKeyUsage = extKeyUsageNamesFromOIDs(oids)
+			}
+		case ext.Id.Equal(oidExtBasicConstraints):
+			var constraints struct {
+				IsCA       bool `asn1:"optional"`
+				MaxPathLen int  `asn1:"optional,default:-1"`
+			}
+			if _, err := asn1.Unmarshal(ext.Value, &constraints); err == nil {
+				info.IsCA = constraints.IsCA
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func extKeyUsageNamesFromOIDs(oids []asn1.ObjectIdentifier) []string {
+	known := map[string]x509.ExtKeyUsage{
+		"2.5.29.37.0":       x509.ExtKeyUsageAny,
+		"1.3.6.1.5.5.7.3.1": x509.ExtKeyUsageServerAuth,
+		"1.3.6.1.5.5.7.3.2": x509.ExtKeyUsageClientAuth,
+		"1.3.6.1.5.5.7.3.3": x509.ExtKeyUsageCodeSigning,
+		"1.3.6.1.5.5.7.3.4": x509.ExtKeyUsageEmailProtection,
+		"1.3.6.1.5.5.7.3.8": x509.ExtKeyUsageTimeStamping,
+		"1.3.6.1.5.5.7.3.9": x509.ExtKeyUsageOCSPSigning,
+	}
+
+	var names []string
+	for _, oid := range oids {
+		if usage, ok := known[oid.String()]; ok {
+			names = append(names, extKeyUsageNames([]x509.ExtKeyUsage{usage})...)
+		} else {
+			names = append(names, oid.String())
+		}
+	}
+	return names
+}
+
+func sanContains(sans []string, name string) bool {
+	if ip := net.ParseIP(name); ip != nil {
+		for _, san := range sans {
+			if sanIP := net.ParseIP(san); sanIP != nil && sanIP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, san := range sans {
+		if strings.EqualFold(san, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// serialOctets is the length of a positive serial number's DER encoding,
+// which has a leading zero octet when the high bit is set.
+func serialOctets(serial *big.Int) int {
+	return serial.BitLen()/8 + 1
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,15 +2,24 @@ package openssl
 
 import (
 	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"web-openssl-backend/pkg/ct"
 )
 
 type Service struct {
@@ -114,7 +123,7 @@ func (s *Service) GenerateCertificate(req *GenerateCertificateRequest) (*Generat
 	}
 
 	// Add extensions if specified
-	if len(req.SANs) > 0 || req.IsCA || len(req.KeyUsage) > 0 {
+	if len(req.SANs) > 0 || req.IsCA || len(req.KeyUsage) > 0 || len(req.ExtKeyUsage) > 0 {
 		config := s.buildConfigFile(req)
 		args = append(args, "-config", "-")
 
@@ -156,6 +165,130 @@ func (s *Service) GenerateCertificate(req *GenerateCertificateRequest) (*Generat
 	}, nil
 }
 
+// GenerateCSR creates a key and a certificate signing request for it. SANs
+// are requested in the subjectAltName extension.
+func (s *Service) GenerateCSR(req *GenerateCSRRequest) (*GenerateCSRResponse, error) {
+	keyResp, err := s.GenerateKey(&GenerateKeyRequest{
+		KeyType: req.KeyType,
+		KeySize: req.KeySize,
+		Format:  KeyFormatPEM,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+
+	hashAlgorithm := req.HashAlgorithm
+	if hashAlgorithm == "" {
+		hashAlgorithm = HashSHA256
+	}
+
+	files := map[string]string{
+		"request.key": keyResp.PrivateKey,
+		"request.cnf": s.buildRequestConfig(req.SANs),
+	}
+
+	output, err := s.runInWorkDir(files, "", func(dir string) ([]string, error) {
+		args := []string{
+			"req", "-new",
+			"-key", filepath.Join(dir, "request.key"),
+			"-config", filepath.Join(dir, "request.cnf"),
+			"-subj", s.buildSubjectString(req.Subject),
+			"-outform", "PEM",
+		}
+		// Ed25519 signs without a separate digest
+		if req.KeyType != KeyTypeED25519 {
+			args = append(args, "-"+string(hashAlgorithm))
+		}
+		return args, nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CSR generation error: %w", err)
+	}
+
+	return &GenerateCSRResponse{
+		CSR:        string(output),
+		PrivateKey: keyResp.PrivateKey,
+	}, nil
+}
+
+// SignCSR issues a certificate for a CSR with the given CA. Only the subject
+// and public key are taken from the CSR; extensions are set by the caller so
+// that a CSR cannot request CA rights for itself.
+func (s *Service) SignCSR(req *SignCSRRequest) (*SignCSRResponse, error) {
+	block, _ := pem.Decode([]byte(req.CSR))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse CSR PEM block")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("CSR signature is invalid: %w", err)
+	}
+
+	sans := req.SANs
+	if len(sans) == 0 {
+		sans = append(sans, csr.DNSNames...)
+		for _, ip := range csr.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		sans = append(sans, csr.EmailAddresses...)
+	}
+
+	// RFC 5280 requires a positive serial of at most 20 octets; 16 random
+	// bytes with the top bit cleared satisfy that and the BR entropy rule.
+	serial := make([]byte, 16)
+	if _, err := rand.Read(serial); err != nil {
+		return nil, fmt.Errorf("serial generation failed: %w", err)
+	}
+	serial[0] &= 0x7f
+	serialHex := fmt.Sprintf("%X", serial)
+
+	hashAlgorithm := req.HashAlgorithm
+	if hashAlgorithm == "" {
+		hashAlgorithm = HashSHA256
+	}
+
+	files := map[string]string{
+		"request.csr":    req.CSR,
+		"ca.pem":         req.CACertificate,
+		"ca.key":         req.CAPrivateKey,
+		"extensions.cnf": s.buildSigningExtensions(req, sans),
+	}
+
+	output, err := s.runInWorkDir(files, req.CAPassword, func(dir string) ([]string, error) {
+		args := []string{
+			"x509", "-req",
+			"-in", filepath.Join(dir, "request.csr"),
+			"-CA", filepath.Join(dir, "ca.pem"),
+			"-CAkey", filepath.Join(dir, "ca.key"),
+			"-set_serial", "0x" + serialHex,
+			"-days", strconv.Itoa(req.ValidDays),
+			"-extfile", filepath.Join(dir, "extensions.cnf"),
+			"-extensions", "v3_ext",
+			"-" + string(hashAlgorithm),
+		}
+		if req.CAPassword != "" {
+			args = append(args, "-passin", passinFD)
+		}
+		if req.Subject != nil {
+			args = append(args, "-subj", s.buildSubjectString(*req.Subject))
+		}
+		return args, nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("certificate signing error: %w", err)
+	}
+
+	return &SignCSRResponse{
+		Certificate:  string(output),
+		Chain:        string(output) + req.CACertificate,
+		SerialNumber: new(big.Int).SetBytes(serial).String(),
+		Format:       "pem",
+	}, nil
+}
+
 func (s *Service) ParseCertificate(req *ParseCertificateRequest) (*CertificateInfo, error) {
 	cmd := exec.Command(s.opensslPath, "x509", "-in", "-", "-noout", "-text")
 	cmd.Stdin = strings.NewReader(req.Certificate)
@@ -379,6 +512,71 @@ func (s *Service) buildConfigFile(req *GenerateCertificateRequest) string {
 	return config.String()
 }
 
+func (s *Service) buildRequestConfig(sans []string) string {
+	var config strings.Builder
+
+	config.WriteString("[req]\n")
+	config.WriteString("distinguished_name = req_distinguished_name\n")
+	config.WriteString("req_extensions = v3_req\n")
+	config.WriteString("[req_distinguished_name]\n")
+	config.WriteString("[v3_req]\n")
+
+	if len(sans) > 0 {
+		config.WriteString("subjectAltName = @alt_names\n")
+		config.WriteString("[alt_names]\n")
+		for i, san := range sans {
+			switch {
+			case net.ParseIP(san) != nil:
+				config.WriteString(fmt.Sprintf("IP.%d = %s\n", i+1, san))
+			case strings.Contains(san, "@"):
+				config.WriteString(fmt.Sprintf("email.%d = %s\n", i+1, san))
+			default:
+				config.WriteString(fmt.Sprintf("DNS.%d = %s\n", i+1, san))
+			}
+		}
+	}
+
+	return config.String()
+}
+
+func (s *Service) buildSigningExtensions(req *SignCSRRequest, sans []string) string {
+	var config strings.Builder
+
+	config.WriteString("[v3_ext]\n")
+	if req.IsCA {
+		config.WriteString("basicConstraints = critical,CA:TRUE\n")
+	} else {
+		config.WriteString("basicConstraints = critical,CA:FALSE\n")
+	}
+	config.WriteString("subjectKeyIdentifier = hash\n")
+	config.WriteString("authorityKeyIdentifier = keyid,issuer\n")
+
+	if len(req.KeyUsage) > 0 {
+		config.WriteString("keyUsage = critical," + strings.Join(req.KeyUsage, ",") + "\n")
+	}
+
+	if len(req.ExtKeyUsage) > 0 {
+		config.WriteString("extendedKeyUsage = " + strings.Join(req.ExtKeyUsage, ",") + "\n")
+	}
+
+	if len(sans) > 0 {
+		config.WriteString("subjectAltName = @alt_names\n")
+		config.WriteString("[alt_names]\n")
+		for i, san := range sans {
+			switch {
+			case net.ParseIP(san) != nil:
+				config.WriteString(fmt.Sprintf("IP.%d = %s\n", i+1, san))
+			case strings.Contains(san, "@"):
+				config.WriteString(fmt.Sprintf("email.%d = %s\n", i+1, san))
+			default:
+				config.WriteString(fmt.Sprintf("DNS.%d = %s\n", i+1, san))
+			}
+		}
+	}
+
+	return config.String()
+}
+
 func (s *Service) parseCertificateOutput(output, certPEM string) (*CertificateInfo, error) {
 	info := &CertificateInfo{
 		Fingerprints: make(map[string]string),
@@ -420,6 +618,11 @@ func (s *Service) parseCertificateOutput(output, certPEM string) (*CertificateIn
 	info.NotAfter = cert.NotAfter
 	info.Version = cert.Version
 	info.SignatureAlgorithm = cert.SignatureAlgorithm.String()
+	info.PublicKeyAlgorithm = cert.PublicKeyAlgorithm.String()
+	info.PublicKeySize = publicKeySize(cert.PublicKey)
+	info.IsCA = cert.IsCA
+	info.KeyUsage = keyUsageNames(cert.KeyUsage)
+	info.ExtKeyUsage = extKeyUsageNames(cert.ExtKeyUsage)
 
 	info.IsExpired = time.Now().After(cert.NotAfter)
 	if !info.IsExpired {
@@ -433,6 +636,83 @@ func (s *Service) parseCertificateOutput(output, certPEM string) (*CertificateIn
 	for _, san := range cert.EmailAddresses {
 		info.SANs = append(info.SANs, san)
 	}
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	// Certificate Transparency. A malformed SCT list does not stop the rest
+	// of the certificate from being shown.
+	info.IsPrecertificate = ct.IsPrecertificate(cert)
+	info.SCTs, err = ct.ParseSCTs(cert)
+	if err != nil {
+		info.SCTError = err.Error()
+	}
 
 	return info, nil
-}
\ No newline at end of file
+}
+
+func publicKeySize(publicKey interface{}) int {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case *dsa.PublicKey:
+		return key.P.BitLen()
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
+
+// keyUsageNames returns key usages using the same names accepted in
+// GenerateCertificateRequest.KeyUsage.
+func keyUsageNames(usage x509.KeyUsage) []string {
+	names := []struct {
+		bit  x509.KeyUsage
+		name string
+	}{
+		{x509.KeyUsageDigitalSignature, "digitalSignature"},
+		{x509.KeyUsageContentCommitment, "nonRepudiation"},
+		{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+		{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+		{x509.KeyUsageKeyAgreement, "keyAgreement"},
+		{x509.KeyUsageCertSign, "keyCertSign"},
+		{x509.KeyUsageCRLSign, "cRLSign"},
+		{x509.KeyUsageEncipherOnly, "encipherOnly"},
+		{x509.KeyUsageDecipherOnly, "decipherOnly"},
+	}
+
+	var usages []string
+	for _, n := range names {
+		if usage&n.bit != 0 {
+			usages = append(usages, n.name)
+		}
+	}
+	return usages
+}
+
+// extKeyUsageNames returns extended key usages using the same names accepted
+// in GenerateCertificateRequest.ExtKeyUsage.
+func extKeyUsageNames(usages []x509.ExtKeyUsage) []string {
+	names := map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageAny:             "anyExtendedKeyUsage",
+		x509.ExtKeyUsageServerAuth:      "serverAuth",
+		x509.ExtKeyUsageClientAuth:      "clientAuth",
+		x509.ExtKeyUsageCodeSigning:     "codeSigning",
+		x509.ExtKeyUsageEmailProtection: "emailProtection",
+		x509.ExtKeyUsageTimeStamping:    "timeStamping",
+		x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+	}
+
+	var result []string
+	for _, usage := range usages {
+		if name, ok := names[usage]; ok {
+			result = append(result, name)
+		} else {
+			result = append(result, fmt.Sprintf("unknown(%d)", usage))
+		}
+	}
+	return result
+}
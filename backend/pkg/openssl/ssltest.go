@@ -0,0 +1,361 @@
+package openssl
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-openssl-backend/pkg/netguard"
+)
+
+const (
+	defaultSSLPort    = 443
+	defaultSSLTimeout = 10 * time.Second
+	maxSSLTimeout     = 60 * time.Second
+)
+
+// sslProtocols are the versions probed, newest first. SSLv3 and older are
+// not implemented by crypto/tls and cannot be detected.
+var sslProtocols = []struct {
+	version uint16
+	name    string
+}{
+	{tls.VersionTLS13, "TLSv1.3"},
+	{tls.VersionTLS12, "TLSv1.2"},
+	{tls.VersionTLS11, "TLSv1.1"},
+	{tls.VersionTLS10, "TLSv1"},
+}
+
+// sslGrades orders grades from best to worst
+var sslGrades = []string{"A+", "A", "B", "C", "F", "T"}
+
+// GradeRank returns the position of grade in best-to-worst order, or -1 if
+// it is not a grade TestSSL produces.
+func GradeRank(grade string) int {
+	for i, g := range sslGrades {
+		if g == grade {
+			return i
+		}
+	}
+	return -1
+}
+
+// GradeScore maps grade onto a scale where higher is better, from 0 for T to
+// 5 for A+. Unknown grades score 0.
+func GradeScore(grade string) int {
+	if rank := GradeRank(grade); rank >= 0 {
+		return len(sslGrades) - 1 - rank
+	}
+	return 0
+}
+
+// TestSSL connects to a TLS server, enumerates the protocol versions and
+// cipher suites it accepts, verifies its certificate chain against the
+// system roots and grades the configuration.
+func (s *Service) TestSSL(ctx context.Context, req *SSLTestRequest) (*SSLTestResponse, error) {
+	port := req.Port
+	if port == 0 {
+		port = defaultSSLPort
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+	hostname := strings.TrimSuffix(strings.TrimSpace(req.Hostname), ".")
+	if hostname == "" || strings.ContainsAny(hostname, "/: ") && net.ParseIP(hostname) == nil {
+		return nil, fmt.Errorf("invalid hostname %q", req.Hostname)
+	}
+
+	timeout := time.Duration(req.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultSSLTimeout
+	}
+	if timeout > maxSSLTimeout {
+		timeout = maxSSLTimeout
+	}
+
+	probe := &sslProbe{
+		address:    net.JoinHostPort(hostname, strconv.Itoa(port)),
+		serverName: hostname,
+		timeout:    timeout,
+	}
+
+	protocols := sslProtocols
+	if req.Protocol != "" {
+		protocols = nil
+		for _, p := range sslProtocols {
+			if strings.EqualFold(p.name, req.Protocol) || strings.EqualFold(strings.TrimPrefix(p.name, "TLSv"), req.Protocol) {
+				protocols = append(protocols, p)
+			}
+		}
+		if len(protocols) == 0 {
+			return nil, fmt.Errorf("unsupported protocol %q", req.Protocol)
+		}
+	}
+
+	// The first handshake uses the best version both sides support
+	state, err := probe.handshake(ctx, protocols[len(protocols)-1].version, protocols[0].version, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &SSLTestResponse{
+		Protocol:        protocolName(state.Version),
+		Cipher:          tls.CipherSuiteName(state.CipherSuite),
+		Protocols:       []string{},
+		Ciphers:         []string{},
+		Vulnerabilities: []string{},
+		Details:         map[string]interface{}{},
+	}
+
+	for i, cert := range state.PeerCertificates {
+		info, err := certificateInfo(cert)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			response.Certificate = info
+		} else {
+			response.Chain = append(response.Chain, info)
+		}
+	}
+	if response.Certificate == nil {
+		return nil, errors.New("server did not present a certificate")
+	}
+
+	for _, p := range protocols {
+		if p.version == state.Version {
+			response.Protocols = append(response.Protocols, p.name)
+			response.Ciphers = append(response.Ciphers, probe.ciphers(ctx, p.version, state.CipherSuite)...)
+			continue
+		}
+		accepted, err := probe.handshake(ctx, p.version, p.version, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		response.Protocols = append(response.Protocols, p.name)
+		response.Ciphers = append(response.Ciphers, probe.ciphers(ctx, p.version, accepted.CipherSuite)...)
+	}
+	response.Ciphers = uniqueStrings(response.Ciphers)
+
+	verifyErr := verifyPeerChain(hostname, state.PeerCertificates)
+	response.IsValid = verifyErr == nil
+	response.Details["serverName"] = hostname
+	response.Details["port"] = port
+	response.Details["trusted"] = response.IsValid
+	if verifyErr != nil {
+		response.Details["verifyError"] = verifyErr.Error()
+	}
+
+	response.Grade, response.Vulnerabilities = gradeSSL(response, state.PeerCertificates[0], verifyErr)
+
+	return response, nil
+}
+
+// Helper functions
+
+type sslProbe struct {
+	address    string
+	serverName string
+	timeout    time.Duration
+}
+
+// handshake completes a TLS handshake limited to the given versions and, for
+// TLS 1.2 and older, cipher suites. Certificates are checked separately.
+func (p *sslProbe) handshake(ctx context.Context, minVersion, maxVersion uint16, suites []uint16) (*tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	serverName := p.serverName
+	if net.ParseIP(serverName) != nil {
+		serverName = ""
+	}
+
+	// Hosts are chosen by users, so the server's own networks are off limits
+	dialer := &tls.Dialer{
+		NetDialer: netguard.Dialer(0),
+		Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+			MinVersion:         minVersion,
+			MaxVersion:         maxVersion,
+			CipherSuites:       suites,
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	return &state, nil
+}
+
+// ciphers enumerates the suites accepted for version by offering every suite
+// not yet seen until the server refuses. TLS 1.3 suites cannot be restricted
+// by crypto/tls, so only the negotiated one is reported for TLS 1.3.
+func (p *sslProbe) ciphers(ctx context.Context, version, negotiated uint16) []string {
+	accepted := []string{tls.CipherSuiteName(negotiated)}
+	if version == tls.VersionTLS13 {
+		return accepted
+	}
+
+	remaining := make([]uint16, 0)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.ID != negotiated && supportsVersion(suite.SupportedVersions, version) {
+			remaining = append(remaining, suite.ID)
+		}
+	}
+
+	for len(remaining) > 0 {
+		state, err := p.handshake(ctx, version, version, remaining)
+		if err != nil {
+			break
+		}
+		accepted = append(accepted, tls.CipherSuiteName(state.CipherSuite))
+
+		next := remaining[:0]
+		for _, id := range remaining {
+			if id != state.CipherSuite {
+				next = append(next, id)
+			}
+		}
+		remaining = next
+	}
+
+	return accepted
+}
+
+func protocolName(version uint16) string {
+	for _, p := range sslProtocols {
+		if p.version == version {
+			return p.name
+		}
+	}
+	return tls.VersionName(version)
+}
+
+func supportsVersion(versions []uint16, version uint16) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyPeerChain(hostname string, certs []*x509.Certificate) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       hostname,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// gradeSSL grades a scan from A+ to F, or T when the certificate is not
+// trusted for the host, and lists the weaknesses that capped the grade.
+func gradeSSL(response *SSLTestResponse, leaf *x509.Certificate, verifyErr error) (string, []string) {
+	grade := 0
+	findings := []string{}
+	capAt := func(g string, finding string) {
+		if rank := GradeRank(g); rank > grade {
+			grade = rank
+		}
+		findings = append(findings, finding)
+	}
+
+	if time.Now().After(leaf.NotAfter) {
+		capAt("F", "Certificate expired")
+	}
+	if size := publicKeySize(leaf.PublicKey); leaf.PublicKeyAlgorithm == x509.RSA && size < minRSAKeySize {
+		capAt("F", fmt.Sprintf("Weak RSA key (%d bits)", size))
+	}
+
+	hasTLS13 := false
+	for _, name := range response.Protocols {
+		switch name {
+		case "TLSv1.3":
+			hasTLS13 = true
+		case "TLSv1", "TLSv1.1":
+			capAt("B", name+" enabled")
+		}
+	}
+
+	forwardSecret := false
+	staticRSA := false
+	for _, name := range response.Ciphers {
+		switch {
+		case strings.Contains(name, "RC4"):
+			capAt("C", "RC4 cipher enabled ("+name+")")
+		case strings.Contains(name, "3DES"):
+			capAt("C", "SWEET32: 64-bit block cipher enabled ("+name+")")
+		}
+		if strings.HasPrefix(name, "TLS_RSA_") {
+			staticRSA = true
+		} else {
+			forwardSecret = true
+		}
+	}
+	if !forwardSecret {
+		capAt("B", "No forward secrecy")
+	} else if staticRSA {
+		findings = append(findings, "Static RSA key exchange enabled")
+	}
+
+	if verifyErr != nil {
+		var hostErr x509.HostnameError
+		if errors.As(verifyErr, &hostErr) {
+			capAt("T", "Certificate does not match hostname")
+		} else if !time.Now().After(leaf.NotAfter) {
+			capAt("T", "Untrusted certificate chain")
+		}
+	}
+
+	if grade == 0 && (!hasTLS13 || staticRSA) {
+		grade = GradeRank("A")
+	}
+
+	return sslGrades[grade], findings
+}
+
+func certificateInfo(cert *x509.Certificate) (*CertificateInfo, error) {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	info, err := (&Service{}).parseCertificateOutput("", string(certPEM))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	info.Fingerprints["sha256"] = hex.EncodeToString(sum[:])
+	return info, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
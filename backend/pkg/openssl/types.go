@@ -1,6 +1,10 @@
 package openssl
 
-import "time"
+import (
+	"time"
+
+	"web-openssl-backend/pkg/ct"
+)
 
 type KeyType string
 type CertificateFormat string
@@ -60,10 +64,10 @@ type GenerateKeyResponse struct {
 }
 
 type GenerateCertificateRequest struct {
-	KeyType        KeyType   `json:"keyType" binding:"required"`
+	KeyType        KeyType   `json:"keyType"`
 	KeySize        int       `json:"keySize,omitempty"`
 	Subject        Subject   `json:"subject" binding:"required"`
-	ValidDays      int       `json:"validDays" binding:"required"`
+	ValidDays      int       `json:"validDays"`
 	IsCA           bool      `json:"isCA,omitempty"`
 	KeyUsage       []string  `json:"keyUsage,omitempty"`
 	ExtKeyUsage    []string  `json:"extKeyUsage,omitempty"`
@@ -102,6 +106,30 @@ type GenerateCSRResponse struct {
 	PrivateKey string `json:"privateKey"`
 }
 
+type SignCSRRequest struct {
+	CSR           string        `json:"csr" binding:"required"`
+	CACertificate string        `json:"caCertificate" binding:"required"`
+	CAPrivateKey  string        `json:"caPrivateKey" binding:"required"`
+	CAPassword    string        `json:"caPassword,omitempty"`
+	ValidDays     int           `json:"validDays" binding:"required"`
+	IsCA          bool          `json:"isCA,omitempty"`
+	KeyUsage      []string      `json:"keyUsage,omitempty"`
+	ExtKeyUsage   []string      `json:"extKeyUsage,omitempty"`
+	SANs          []string      `json:"sans,omitempty"`
+	HashAlgorithm HashAlgorithm `json:"hashAlgorithm,omitempty"`
+	// Subject, when set, replaces the subject of the CSR, so that only
+	// attributes the caller vouches for are signed. An empty Subject issues
+	// the certificate with an empty subject.
+	Subject *Subject `json:"-"`
+}
+
+type SignCSRResponse struct {
+	Certificate  string `json:"certificate"`
+	Chain        string `json:"chain"`
+	SerialNumber string `json:"serialNumber"`
+	Format       string `json:"format"`
+}
+
 type ParseCertificateRequest struct {
 	Certificate string            `json:"certificate" binding:"required"`
 	Format      CertificateFormat `json:"format,omitempty"`
@@ -125,6 +153,9 @@ type CertificateInfo struct {
 	Extensions       map[string]string `json:"extensions"`
 	IsExpired        bool              `json:"isExpired"`
 	DaysUntilExpiry  int               `json:"daysUntilExpiry"`
+	IsPrecertificate bool              `json:"isPrecertificate"`
+	SCTs             []ct.SCT          `json:"scts,omitempty"`
+	SCTError         string            `json:"sctError,omitempty"`
 }
 
 type VerifyCertificateRequest struct {
@@ -190,7 +221,123 @@ type SSLTestResponse struct {
 	Chain          []*CertificateInfo     `json:"chain"`
 	Protocol       string                 `json:"protocol"`
 	Cipher         string                 `json:"cipher"`
+	Protocols      []string               `json:"protocols"` // every version accepted
+	Ciphers        []string               `json:"ciphers"`   // every suite accepted
 	Vulnerabilities []string              `json:"vulnerabilities"`
 	Grade          string                 `json:"grade"`
 	Details        map[string]interface{} `json:"details"`
-}
\ No newline at end of file
+}
+// CMS / S/MIME
+
+type CMSFormat string
+
+const (
+	CMSFormatPEM   CMSFormat = "pem"
+	CMSFormatDER   CMSFormat = "der"
+	CMSFormatSMIME CMSFormat = "smime"
+)
+
+type CMSSignRequest struct {
+	Data          string        `json:"data" binding:"required"`
+	Certificate   string        `json:"certificate" binding:"required"`
+	PrivateKey    string        `json:"privateKey" binding:"required"`
+	Password      string        `json:"password,omitempty"`
+	Chain         string        `json:"chain,omitempty"`
+	Detached      bool          `json:"detached,omitempty"`
+	HashAlgorithm HashAlgorithm `json:"hashAlgorithm,omitempty"`
+	Format        CMSFormat     `json:"format,omitempty"`
+}
+
+type CMSSignResponse struct {
+	SignedData string `json:"signedData"`
+	Detached   bool   `json:"detached"`
+	Format     string `json:"format"`
+}
+
+type CMSVerifyRequest struct {
+	SignedData string    `json:"signedData" binding:"required"`
+	Content    string    `json:"content,omitempty"`
+	TrustStore string    `json:"trustStore,omitempty"`
+	Format     CMSFormat `json:"format,omitempty"`
+}
+
+type CMSVerifyResponse struct {
+	IsValid      bool               `json:"isValid"`
+	ErrorMessage string             `json:"errorMessage,omitempty"`
+	Content      string             `json:"content,omitempty"`
+	Signers      []*CertificateInfo `json:"signers,omitempty"`
+}
+
+type CMSEncryptRequest struct {
+	Data       string              `json:"data" binding:"required"`
+	Recipients []string            `json:"recipients" binding:"required,min=1"`
+	Algorithm  EncryptionAlgorithm `json:"algorithm,omitempty"`
+	Format     CMSFormat           `json:"format,omitempty"`
+}
+
+type CMSEncryptResponse struct {
+	EnvelopedData string `json:"envelopedData"`
+	Format        string `json:"format"`
+}
+
+type CMSDecryptRequest struct {
+	EnvelopedData string    `json:"envelopedData" binding:"required"`
+	Certificate   string    `json:"certificate,omitempty"`
+	PrivateKey    string    `json:"privateKey" binding:"required"`
+	Password      string    `json:"password,omitempty"`
+	Format        CMSFormat `json:"format,omitempty"`
+}
+
+type CMSDecryptResponse struct {
+	Data string `json:"data"`
+}
+
+type SMIMEParseRequest struct {
+	Message    string `json:"message" binding:"required"`
+	TrustStore string `json:"trustStore,omitempty"`
+}
+
+type SMIMEParseResponse struct {
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	Subject      string             `json:"subject"`
+	Date         string             `json:"date"`
+	ContentType  string             `json:"contentType"`
+	IsSigned     bool               `json:"isSigned"`
+	IsValid      bool               `json:"isValid"`
+	ErrorMessage string             `json:"errorMessage,omitempty"`
+	Content      string             `json:"content,omitempty"`
+	Signers      []*CertificateInfo `json:"signers,omitempty"`
+}
+
+// Certificate linting
+
+type LintSeverity string
+
+const (
+	LintSeverityError   LintSeverity = "error"
+	LintSeverityWarning LintSeverity = "warning"
+	LintSeverityNotice  LintSeverity = "notice"
+)
+
+type LintRequest struct {
+	Certificate string `json:"certificate,omitempty"`
+	CSR         string `json:"csr,omitempty"`
+}
+
+type LintFinding struct {
+	Code     string       `json:"code"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+	Source   string       `json:"source"`
+}
+
+type LintResponse struct {
+	Type     string        `json:"type"`
+	Subject  Subject       `json:"subject"`
+	Findings []LintFinding `json:"findings"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Notices  int           `json:"notices"`
+	Passed   bool          `json:"passed"`
+}
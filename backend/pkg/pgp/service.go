@@ -0,0 +1,359 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+const (
+	defaultKeySize = 3072
+	minKeySize     = 2048
+	maxKeySize     = 4096
+)
+
+type Service struct {
+	config *packet.Config
+}
+
+func NewService() *Service {
+	return &Service{
+		config: &packet.Config{
+			DefaultHash:   crypto.SHA256,
+			DefaultCipher: packet.CipherAES256,
+		},
+	}
+}
+
+func (s *Service) GenerateKey(req *GenerateKeyRequest) (*GenerateKeyResponse, error) {
+	keySize := req.KeySize
+	if keySize == 0 {
+		keySize = defaultKeySize
+	}
+	if keySize < minKeySize || keySize > maxKeySize {
+		return nil, fmt.Errorf("key size must be between %d and %d bits", minKeySize, maxKeySize)
+	}
+
+	config := *s.config
+	config.RSABits = keySize
+
+	primary := req.UserIDs[0]
+	entity, err := openpgp.NewEntity(primary.Name, primary.Comment, primary.Email, &config)
+	if err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+
+	// NewEntity only creates a single identity, so any additional user IDs
+	// are self-signed by the new primary key here.
+	for _, uid := range req.UserIDs[1:] {
+		if err := s.addIdentity(entity, uid, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	var privBuf bytes.Buffer
+	privWriter, err := armor.Encode(&privBuf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := entity.SerializePrivate(privWriter, &config); err != nil {
+		return nil, fmt.Errorf("private key export failed: %w", err)
+	}
+	privWriter.Close()
+
+	publicKey, err := armorPublicKey(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GenerateKeyResponse{
+		PrivateKey:  privBuf.String(),
+		PublicKey:   publicKey,
+		Fingerprint: fingerprint(entity),
+		KeyID:       entity.PrimaryKey.KeyIdString(),
+		UserIDs:     identityNames(entity),
+	}, nil
+}
+
+func (s *Service) Sign(req *SignRequest) (*SignResponse, error) {
+	signer, err := s.readPrivateEntity(req.PrivateKey, req.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if req.Detached {
+		if err := openpgp.ArmoredDetachSign(&out, signer, strings.NewReader(req.Message), s.config); err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
+	} else {
+		w, err := clearsign.Encode(&out, signer.PrivateKey, s.config)
+		if err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
+		if _, err := io.WriteString(w, req.Message); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
+	}
+
+	return &SignResponse{
+		Signature: out.String(),
+		Detached:  req.Detached,
+		KeyID:     signer.PrimaryKey.KeyIdString(),
+	}, nil
+}
+
+func (s *Service) Verify(req *VerifyRequest) (*VerifyResponse, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(req.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	var signed, signature io.Reader
+	response := &VerifyResponse{}
+
+	if block, _ := clearsign.Decode([]byte(req.Signature)); block != nil {
+		signed = bytes.NewReader(block.Bytes)
+		signature = block.ArmoredSignature.Body
+		response.Message = string(block.Plaintext)
+	} else {
+		if req.Message == "" {
+			return nil, errors.New("message is required to verify a detached signature")
+		}
+		sigBlock, err := armor.Decode(strings.NewReader(req.Signature))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature: %w", err)
+		}
+		if sigBlock.Type != openpgp.SignatureType {
+			return nil, fmt.Errorf("unexpected armor type: %s", sigBlock.Type)
+		}
+		signed = strings.NewReader(req.Message)
+		signature = sigBlock.Body
+	}
+
+	signer, err := openpgp.CheckDetachedSignature(keyring, signed, signature)
+	if err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+
+	response.IsValid = true
+	response.SignerKeyID = signer.PrimaryKey.KeyIdString()
+	response.Fingerprint = fingerprint(signer)
+	response.UserIDs = identityNames(signer)
+
+	return response, nil
+}
+
+func (s *Service) Encrypt(req *EncryptRequest) (*EncryptResponse, error) {
+	var recipients openpgp.EntityList
+	for i, armored := range req.Recipients {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipient key %d: %w", i+1, err)
+		}
+		recipients = append(recipients, entities...)
+	}
+
+	var signer *openpgp.Entity
+	if req.SigningKey != "" {
+		var err error
+		signer, err = s.readPrivateEntity(req.SigningKey, req.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	armorWriter, err := armor.Encode(&out, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := openpgp.Encrypt(armorWriter, recipients, signer, nil, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	if _, err := io.WriteString(plaintext, req.Message); err != nil {
+		return nil, err
+	}
+	if err := plaintext.Close(); err != nil {
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	armorWriter.Close()
+
+	keyIDs := make([]string, 0, len(recipients))
+	for _, e := range recipients {
+		keyIDs = append(keyIDs, e.PrimaryKey.KeyIdString())
+	}
+
+	return &EncryptResponse{
+		EncryptedMessage: out.String(),
+		RecipientKeyIDs:  keyIDs,
+		Signed:           signer != nil,
+	}, nil
+}
+
+func (s *Service) Decrypt(req *DecryptRequest) (*DecryptResponse, error) {
+	entity, err := s.readPrivateEntity(req.PrivateKey, req.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := openpgp.EntityList{entity}
+	if req.VerificationKeys != "" {
+		verifiers, err := openpgp.ReadArmoredKeyRing(strings.NewReader(req.VerificationKeys))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verification keys: %w", err)
+		}
+		keyring = append(keyring, verifiers...)
+	}
+
+	block, err := armor.Decode(strings.NewReader(req.EncryptedMessage))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+
+	// The signature (and integrity check) is only evaluated once the body
+	// has been read through to EOF.
+	body, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil && md.SignatureError == nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+
+	response := &DecryptResponse{
+		Message:  string(body),
+		IsSigned: md.IsSigned,
+	}
+
+	if md.IsSigned {
+		response.SignerKeyID = fmt.Sprintf("%016X", md.SignedByKeyId)
+		switch {
+		case md.SignedBy == nil:
+			response.ErrorMessage = "signer public key not available"
+		case md.SignatureError != nil:
+			response.ErrorMessage = md.SignatureError.Error()
+		default:
+			response.SignatureValid = true
+		}
+	}
+
+	return response, nil
+}
+
+// Helper functions
+
+func (s *Service) readPrivateEntity(armored, passphrase string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("no key found")
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey == nil {
+		return nil, errors.New("key does not contain private key material")
+	}
+
+	if err := decryptPrivateKey(entity.PrivateKey, passphrase); err != nil {
+		return nil, err
+	}
+	for _, subkey := range entity.Subkeys {
+		if err := decryptPrivateKey(subkey.PrivateKey, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	return entity, nil
+}
+
+func (s *Service) addIdentity(entity *openpgp.Entity, uid UserID, config *packet.Config) error {
+	userID := packet.NewUserId(uid.Name, uid.Comment, uid.Email)
+	if userID == nil {
+		return errors.New("user id field contained invalid characters")
+	}
+
+	isPrimaryID := false
+	identity := &openpgp.Identity{
+		Name:   userID.Id,
+		UserId: userID,
+		SelfSignature: &packet.Signature{
+			CreationTime:       config.Now(),
+			SigType:            packet.SigTypePositiveCert,
+			PubKeyAlgo:         entity.PrimaryKey.PubKeyAlgo,
+			Hash:               config.Hash(),
+			IsPrimaryId:        &isPrimaryID,
+			FlagsValid:         true,
+			FlagSign:           true,
+			FlagCertify:        true,
+			IssuerKeyId:        &entity.PrimaryKey.KeyId,
+			PreferredHash:      []uint8{8}, // SHA256
+			PreferredSymmetric: []uint8{uint8(config.Cipher())},
+		},
+	}
+
+	if err := identity.SelfSignature.SignUserId(userID.Id, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+		return fmt.Errorf("failed to sign user id %q: %w", userID.Id, err)
+	}
+
+	entity.Identities[userID.Id] = identity
+	return nil
+}
+
+func decryptPrivateKey(key *packet.PrivateKey, passphrase string) error {
+	if key == nil || !key.Encrypted {
+		return nil
+	}
+	if passphrase == "" {
+		return errors.New("private key is encrypted, passphrase required")
+	}
+	if err := key.Decrypt([]byte(passphrase)); err != nil {
+		return errors.New("invalid passphrase")
+	}
+	return nil
+}
+
+func armorPublicKey(entity *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := entity.Serialize(w); err != nil {
+		return "", fmt.Errorf("public key export failed: %w", err)
+	}
+	w.Close()
+	return buf.String(), nil
+}
+
+func fingerprint(entity *openpgp.Entity) string {
+	return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
+
+func identityNames(entity *openpgp.Entity) []string {
+	names := make([]string, 0, len(entity.Identities))
+	for name := range entity.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,84 @@
+package pgp
+
+// Request/Response types for OpenPGP operations
+
+type UserID struct {
+	Name    string `json:"name" binding:"required"`
+	Comment string `json:"comment,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+type GenerateKeyRequest struct {
+	UserIDs []UserID `json:"userIds" binding:"required,min=1,dive"`
+	KeySize int      `json:"keySize,omitempty"`
+}
+
+type GenerateKeyResponse struct {
+	PrivateKey  string   `json:"privateKey"`
+	PublicKey   string   `json:"publicKey"`
+	Fingerprint string   `json:"fingerprint"`
+	KeyID       string   `json:"keyId"`
+	UserIDs     []string `json:"userIds"`
+}
+
+type SignRequest struct {
+	Message    string `json:"message" binding:"required"`
+	PrivateKey string `json:"privateKey" binding:"required"`
+	Passphrase string `json:"passphrase,omitempty"`
+	Detached   bool   `json:"detached,omitempty"`
+}
+
+type SignResponse struct {
+	// Signature holds the armored detached signature, or the complete
+	// cleartext-signed message when Detached is false.
+	Signature string `json:"signature"`
+	Detached  bool   `json:"detached"`
+	KeyID     string `json:"keyId"`
+}
+
+type VerifyRequest struct {
+	// Message is required for detached signatures and ignored for
+	// cleartext-signed messages, which carry their own text.
+	Message   string `json:"message,omitempty"`
+	Signature string `json:"signature" binding:"required"`
+	PublicKey string `json:"publicKey" binding:"required"`
+}
+
+type VerifyResponse struct {
+	IsValid      bool     `json:"isValid"`
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	SignerKeyID  string   `json:"signerKeyId,omitempty"`
+	Fingerprint  string   `json:"fingerprint,omitempty"`
+	UserIDs      []string `json:"userIds,omitempty"`
+}
+
+type EncryptRequest struct {
+	Message    string   `json:"message" binding:"required"`
+	Recipients []string `json:"recipients" binding:"required,min=1"`
+	SigningKey string   `json:"signingKey,omitempty"`
+	Passphrase string   `json:"passphrase,omitempty"`
+}
+
+type EncryptResponse struct {
+	EncryptedMessage string   `json:"encryptedMessage"`
+	RecipientKeyIDs  []string `json:"recipientKeyIds"`
+	Signed           bool     `json:"signed"`
+}
+
+type DecryptRequest struct {
+	EncryptedMessage string `json:"encryptedMessage" binding:"required"`
+	PrivateKey       string `json:"privateKey" binding:"required"`
+	Passphrase       string `json:"passphrase,omitempty"`
+	// VerificationKeys optionally holds the sender's armored public key(s)
+	// so that a signed message can be checked while decrypting.
+	VerificationKeys string `json:"verificationKeys,omitempty"`
+}
+
+type DecryptResponse struct {
+	Message        string `json:"message"`
+	IsSigned       bool   `json:"isSigned"`
+	SignatureValid bool   `json:"signatureValid"`
+	SignerKeyID    string `json:"signerKeyId,omitempty"`
+	ErrorMessage   string `json:"errorMessage,omitempty"`
+}
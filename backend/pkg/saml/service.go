@@ -0,0 +1,489 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A SAML 2.0 service provider for Web Browser SSO: authentication requests
+// go out by the HTTP-Redirect binding and responses come back to the
+// assertion consumer service by HTTP-POST. Encrypted assertions are not
+// supported.
+
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	metadataNamespace  = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	bindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	bindingPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	statusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	confirmBearer   = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	nameIDEmail     = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+
+	// clockSkew is tolerated on the validity times in assertions
+	clockSkew = 3 * time.Minute
+	// maxResponseBytes bounds the decoded SAMLResponse
+	maxResponseBytes = 256 << 10
+	requestIDBytes   = 20
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid SAML response")
+	ErrInvalidMetadata = errors.New("invalid SAML metadata")
+)
+
+// IdentityProvider is the configuration of a SAML identity provider
+type IdentityProvider struct {
+	EntityID string
+	// SSOURL is where authentication requests are sent, by HTTP-Redirect
+	SSOURL       string
+	Certificates []*x509.Certificate
+}
+
+// ServiceProvider is this application, identified to one identity provider
+// by EntityID and receiving its responses at ACSURL
+type ServiceProvider struct {
+	EntityID string
+	ACSURL   string
+}
+
+// Assertion is what a verified response says about the user
+type Assertion struct {
+	ID           string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	// Attributes are keyed by Name and, when present, FriendlyName
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of an attribute, or "".
+func (a *Assertion) Attribute(name string) string {
+	if values := a.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type entityDescriptor struct {
+	XMLName    xml.Name        `xml:"md:EntityDescriptor"`
+	Namespace  string          `xml:"xmlns:md,attr"`
+	EntityID   string          `xml:"entityID,attr"`
+	Descriptor spSSODescriptor `xml:"md:SPSSODescriptor"`
+}
+
+type spSSODescriptor struct {
+	AuthnRequestsSigned  bool                     `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned bool                     `xml:"WantAssertionsSigned,attr"`
+	Protocols            string                   `xml:"protocolSupportEnumeration,attr"`
+	NameIDFormat         string                   `xml:"md:NameIDFormat"`
+	ACS                  assertionConsumerService `xml:"md:AssertionConsumerService"`
+}
+
+type assertionConsumerService struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+type authnRequest struct {
+	XMLName         xml.Name     `xml:"samlp:AuthnRequest"`
+	ProtocolNS      string       `xml:"xmlns:samlp,attr"`
+	AssertionNS     string       `xml:"xmlns:saml,attr"`
+	ID              string       `xml:"ID,attr"`
+	Version         string       `xml:"Version,attr"`
+	IssueInstant    string       `xml:"IssueInstant,attr"`
+	Destination     string       `xml:"Destination,attr"`
+	ACSURL          string       `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding string       `xml:"ProtocolBinding,attr"`
+	Issuer          string       `xml:"saml:Issuer"`
+	NameIDPolicy    nameIDPolicy `xml:"samlp:NameIDPolicy"`
+}
+
+type nameIDPolicy struct {
+	AllowCreate bool `xml:"AllowCreate,attr"`
+}
+
+// Metadata returns the service provider's metadata, to be loaded into the
+// identity provider.
+func (sp *ServiceProvider) Metadata() ([]byte, error) {
+	out, err := xml.MarshalIndent(entityDescriptor{
+		Namespace: metadataNamespace,
+		EntityID:  sp.EntityID,
+		Descriptor: spSSODescriptor{
+			WantAssertionsSigned: true,
+			Protocols:            protocolNamespace,
+			NameIDFormat:         nameIDEmail,
+			ACS: assertionConsumerService{
+				Binding:   bindingPOST,
+				Location:  sp.ACSURL,
+				IsDefault: true,
+			},
+		},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// AuthnRequestURL returns the address that sends the user to the identity
+// provider to sign in, and the ID of the request, which the response must
+// answer. relayState comes back with the response.
+func (sp *ServiceProvider) AuthnRequestURL(idp *IdentityProvider, relayState string) (string, string, error) {
+	id, err := newRequestID()
+	if err != nil {
+		return "", "", err
+	}
+
+	request, err := xml.Marshal(authnRequest{
+		ProtocolNS:      protocolNamespace,
+		AssertionNS:     assertionNamespace,
+		ID:              id,
+		Version:         "2.0",
+		IssueInstant:    time.Now().UTC().Format(time.RFC3339),
+		Destination:     idp.SSOURL,
+		ACSURL:          sp.ACSURL,
+		ProtocolBinding: bindingPOST,
+		Issuer:          sp.EntityID,
+		NameIDPolicy:    nameIDPolicy{AllowCreate: true},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	// HTTP-Redirect binding: DEFLATE, then base64 (SAML Bindings 3.4.4.1)
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	writer.Write(request)
+	writer.Close()
+
+	query := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(compressed.Bytes())}}
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	separator := "?"
+	if strings.Contains(idp.SSOURL, "?") {
+		separator = "&"
+	}
+	return idp.SSOURL + separator + query.Encode(), id, nil
+}
+
+// ParseResponse verifies a base64 SAMLResponse posted to the assertion
+// consumer service in answer to the request with requestID, and returns its
+// assertion. Either the response or the assertion must be signed by the
+// identity provider; everything returned is read from the signed part.
+func (sp *ServiceProvider) ParseResponse(encoded string, idp *IdentityProvider, requestID string, now time.Time) (*Assertion, error) {
+	if requestID == "" {
+		return nil, fmt.Errorf("%w: no sign-in request to answer", ErrInvalidResponse)
+	}
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not base64", ErrInvalidResponse)
+	}
+	if len(data) > maxResponseBytes {
+		return nil, fmt.Errorf("%w: response is too large", ErrInvalidResponse)
+	}
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if !root.is(protocolNamespace, "Response") {
+		return nil, fmt.Errorf("%w: not a Response", ErrInvalidResponse)
+	}
+
+	// An element with a duplicate ID could stand in for the signed one
+	seen := make(map[string]bool)
+	duplicate := false
+	root.walk(func(e *element) {
+		if id := e.attr("ID"); id != "" {
+			duplicate = duplicate || seen[id]
+			seen[id] = true
+		}
+	})
+	if duplicate {
+		return nil, fmt.Errorf("%w: duplicate IDs", ErrInvalidResponse)
+	}
+
+	if err := sp.checkResponse(root, idp, requestID); err != nil {
+		return nil, err
+	}
+
+	if len(root.childrenNamed(assertionNamespace, "EncryptedAssertion")) > 0 {
+		return nil, fmt.Errorf("%w: encrypted assertions are not supported", ErrInvalidResponse)
+	}
+	assertions := root.childrenNamed(assertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("%w: expected exactly one assertion", ErrInvalidResponse)
+	}
+	assertion := assertions[0]
+
+	signed := false
+	for _, e := range []*element{root, assertion} {
+		sig := signature(e)
+		if sig == nil {
+			continue
+		}
+		if err := verifySignature(e, sig, idp.Certificates); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		signed = true
+	}
+	if !signed {
+		return nil, fmt.Errorf("%w: neither the response nor the assertion is signed", ErrInvalidResponse)
+	}
+
+	return sp.readAssertion(assertion, idp, requestID, now)
+}
+
+// ParseCertificates reads certificates in PEM, or a single one as bare
+// base64 DER as it appears in metadata.
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) > 0 {
+		return certificates, nil
+	}
+
+	der, err := decodeBase64(data)
+	if err != nil {
+		return nil, errors.New("no certificate found")
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{certificate}, nil
+}
+
+// ParseIdPMetadata reads the entity ID, sign-in address and signing
+// certificates from an identity provider's metadata.
+func ParseIdPMetadata(data []byte) (*IdentityProvider, error) {
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	if root.is(metadataNamespace, "EntitiesDescriptor") {
+		root = root.child(metadataNamespace, "EntityDescriptor")
+	}
+	if root == nil || !root.is(metadataNamespace, "EntityDescriptor") {
+		return nil, fmt.Errorf("%w: no EntityDescriptor", ErrInvalidMetadata)
+	}
+	descriptor := root.child(metadataNamespace, "IDPSSODescriptor")
+	if descriptor == nil {
+		return nil, fmt.Errorf("%w: not an identity provider", ErrInvalidMetadata)
+	}
+
+	// Requests are sent by HTTP-Redirect, to the HTTP-POST address of
+	// identity providers that list no other; they take GET there too
+	idp := &IdentityProvider{EntityID: root.attr("entityID")}
+	for _, service := range descriptor.childrenNamed(metadataNamespace, "SingleSignOnService") {
+		switch service.attr("Binding") {
+		case bindingRedirect:
+			idp.SSOURL = service.attr("Location")
+		case bindingPOST:
+			if idp.SSOURL == "" {
+				idp.SSOURL = service.attr("Location")
+			}
+		}
+	}
+	for _, key := range descriptor.childrenNamed(metadataNamespace, "KeyDescriptor") {
+		if use := key.attr("use"); use != "" && use != "signing" {
+			continue
+		}
+		key.walk(func(e *element) {
+			if e.is(dsigNamespace, "X509Certificate") {
+				if certificates, err := ParseCertificates(e.text()); err == nil {
+					idp.Certificates = append(idp.Certificates, certificates...)
+				}
+			}
+		})
+	}
+
+	if idp.EntityID == "" || idp.SSOURL == "" || len(idp.Certificates) == 0 {
+		return nil, fmt.Errorf("%w: entity ID, sign-in service and signing certificate are required", ErrInvalidMetadata)
+	}
+	return idp, nil
+}
+
+// Helper functions
+
+// checkResponse checks the response envelope: status, destination, issuer
+// and the request it answers.
+func (sp *ServiceProvider) checkResponse(root *element, idp *IdentityProvider, requestID string) error {
+	status := root.child(protocolNamespace, "Status")
+	if status == nil {
+		return fmt.Errorf("%w: no status", ErrInvalidResponse)
+	}
+	code := status.child(protocolNamespace, "StatusCode")
+	if code == nil || code.attr("Value") != statusSuccess {
+		value := ""
+		if code != nil {
+			value = code.attr("Value")
+			if sub := code.child(protocolNamespace, "StatusCode"); sub != nil {
+				value = sub.attr("Value")
+			}
+		}
+		return fmt.Errorf("%w: identity provider returned status %s", ErrInvalidResponse, value)
+	}
+
+	if destination := root.attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return fmt.Errorf("%w: response is for %s", ErrInvalidResponse, destination)
+	}
+	if root.attr("InResponseTo") != requestID {
+		return fmt.Errorf("%w: response does not answer this sign-in request", ErrInvalidResponse)
+	}
+	if issuer := root.child(assertionNamespace, "Issuer"); issuer != nil && issuer.text() != idp.EntityID {
+		return fmt.Errorf("%w: response is from %s", ErrInvalidResponse, issuer.text())
+	}
+	return nil
+}
+
+// readAssertion checks a signed assertion's issuer, subject confirmation and
+// conditions, and reads the user's identity from it.
+func (sp *ServiceProvider) readAssertion(assertion *element, idp *IdentityProvider, requestID string, now time.Time) (*Assertion, error) {
+	issuer := assertion.child(assertionNamespace, "Issuer")
+	if issuer == nil || issuer.text() != idp.EntityID {
+		return nil, fmt.Errorf("%w: assertion is not from the identity provider", ErrInvalidResponse)
+	}
+
+	subject := assertion.child(assertionNamespace, "Subject")
+	if subject == nil {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidResponse)
+	}
+	nameID := subject.child(assertionNamespace, "NameID")
+	if nameID == nil || nameID.text() == "" {
+		return nil, fmt.Errorf("%w: no NameID", ErrInvalidResponse)
+	}
+	if err := sp.checkConfirmation(subject, requestID, now); err != nil {
+		return nil, err
+	}
+
+	conditions := assertion.child(assertionNamespace, "Conditions")
+	if conditions == nil {
+		return nil, fmt.Errorf("%w: no conditions", ErrInvalidResponse)
+	}
+	if notBefore, ok := parseTime(conditions.attr("NotBefore")); ok && now.Add(clockSkew).Before(notBefore) {
+		return nil, fmt.Errorf("%w: assertion is not yet valid", ErrInvalidResponse)
+	}
+	notOnOrAfter, ok := parseTime(conditions.attr("NotOnOrAfter"))
+	if !ok || !now.Add(-clockSkew).Before(notOnOrAfter) {
+		return nil, fmt.Errorf("%w: assertion has expired", ErrInvalidResponse)
+	}
+	restrictions := conditions.childrenNamed(assertionNamespace, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return nil, fmt.Errorf("%w: no audience restriction", ErrInvalidResponse)
+	}
+	for _, restriction := range restrictions {
+		found := false
+		for _, audience := range restriction.childrenNamed(assertionNamespace, "Audience") {
+			found = found || audience.text() == sp.EntityID
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: assertion is for another audience", ErrInvalidResponse)
+		}
+	}
+
+	result := &Assertion{
+		ID:           assertion.attr("ID"),
+		NameID:       nameID.text(),
+		NameIDFormat: nameID.attr("Format"),
+		Attributes:   make(map[string][]string),
+	}
+	if statement := assertion.child(assertionNamespace, "AuthnStatement"); statement != nil {
+		result.SessionIndex = statement.attr("SessionIndex")
+	}
+	for _, statement := range assertion.childrenNamed(assertionNamespace, "AttributeStatement") {
+		for _, attribute := range statement.childrenNamed(assertionNamespace, "Attribute") {
+			var values []string
+			for _, value := range attribute.childrenNamed(assertionNamespace, "AttributeValue") {
+				values = append(values, value.text())
+			}
+			for _, name := range []string{attribute.attr("Name"), attribute.attr("FriendlyName")} {
+				if name != "" {
+					result.Attributes[name] = append(result.Attributes[name], values...)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// checkConfirmation requires a bearer subject confirmation for this request
+// at this assertion consumer service that has not expired.
+func (sp *ServiceProvider) checkConfirmation(subject *element, requestID string, now time.Time) error {
+	for _, confirmation := range subject.childrenNamed(assertionNamespace, "SubjectConfirmation") {
+		if confirmation.attr("Method") != confirmBearer {
+			continue
+		}
+		data := confirmation.child(assertionNamespace, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+		notOnOrAfter, ok := parseTime(data.attr("NotOnOrAfter"))
+		if !ok || !now.Add(-clockSkew).Before(notOnOrAfter) {
+			continue
+		}
+		if notBefore, ok := parseTime(data.attr("NotBefore")); ok && now.Add(clockSkew).Before(notBefore) {
+			continue
+		}
+		if data.attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		// Required, as the profile does for responses to a request: it ties
+		// the assertion to the single sign-in it was issued for
+		if data.attr("InResponseTo") != requestID {
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no valid bearer subject confirmation", ErrInvalidResponse)
+}
+
+func parseTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+// newRequestID returns a random ID; XML IDs may not start with a digit.
+func newRequestID() (string, error) {
+	b := make([]byte, requestIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,272 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIdPEntityID = "https://idp.example.com/metadata"
+	testRequestID   = "_request"
+	testAssertionID = "_assertion"
+)
+
+var testSP = &ServiceProvider{
+	EntityID: "https://app.example.com/sso/saml/metadata",
+	ACSURL:   "https://app.example.com/sso/saml/acs",
+}
+
+// mockIdP signs responses the way an identity provider does: an enveloped,
+// exclusively canonicalized RSA-SHA256 signature on the assertion
+type mockIdP struct {
+	t           *testing.T
+	key         *rsa.PrivateKey
+	certificate *x509.Certificate
+	issued      time.Time
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &mockIdP{t: t, key: key, certificate: certificate, issued: time.Now().UTC()}
+}
+
+func (idp *mockIdP) provider() *IdentityProvider {
+	return &IdentityProvider{
+		EntityID:     testIdPEntityID,
+		SSOURL:       "https://idp.example.com/sso",
+		Certificates: []*x509.Certificate{idp.certificate},
+	}
+}
+
+func (idp *mockIdP) timestamp(offset time.Duration) string {
+	return idp.issued.Add(offset).Format(time.RFC3339)
+}
+
+// assertion returns an unsigned assertion for nameID, valid for five
+// minutes from issue
+func (idp *mockIdP) assertion(id, nameID, audience string) string {
+	return fmt.Sprintf(`<saml:Assertion xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID Format="%s">%s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="%s"><saml:SubjectConfirmationData NotOnOrAfter="%s" Recipient="%s" InResponseTo="%s"/></saml:SubjectConfirmation>`+
+		`</saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`<saml:AuthnStatement AuthnInstant="%s" SessionIndex="_session"/>`+
+		`<saml:AttributeStatement><saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="mail"><saml:AttributeValue>%s</saml:AttributeValue></saml:Attribute></saml:AttributeStatement>`+
+		`</saml:Assertion>`,
+		assertionNamespace, id, idp.timestamp(0),
+		testIdPEntityID,
+		nameIDEmail, nameID,
+		confirmBearer, idp.timestamp(5*time.Minute), testSP.ACSURL, testRequestID,
+		idp.timestamp(-time.Minute), idp.timestamp(5*time.Minute), audience,
+		idp.timestamp(0),
+		nameID)
+}
+
+// sign inserts an enveloped signature after the Issuer of the element
+func (idp *mockIdP) sign(unsigned, id string) string {
+	idp.t.Helper()
+	e, err := parseXML([]byte(unsigned))
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	digest := sha256.Sum256(canonicalize(e, nil, nil))
+
+	signedInfo := fmt.Sprintf(`<ds:SignedInfo xmlns:ds="%s">`+
+		`<ds:CanonicalizationMethod Algorithm="%s"/>`+
+		`<ds:SignatureMethod Algorithm="%s"/>`+
+		`<ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s"/></ds:Transforms>`+
+		`<ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference>`+
+		`</ds:SignedInfo>`,
+		dsigNamespace, algExcC14N, algRSASHA256, id, algEnvelopedSig, algExcC14N, algSHA256,
+		base64.StdEncoding.EncodeToString(digest[:]))
+	info, err := parseXML([]byte(signedInfo))
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	infoDigest := sha256.Sum256(canonicalize(info, nil, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, infoDigest[:])
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	signature := fmt.Sprintf(`<ds:Signature xmlns:ds="%s">%s<ds:SignatureValue>%s</ds:SignatureValue></ds:Signature>`,
+		dsigNamespace, strings.Replace(signedInfo, ` xmlns:ds="`+dsigNamespace+`"`, "", 1),
+		base64.StdEncoding.EncodeToString(value))
+	return strings.Replace(unsigned, "</saml:Issuer>", "</saml:Issuer>"+signature, 1)
+}
+
+// response wraps assertions in a successful response to testRequestID
+func (idp *mockIdP) response(assertions ...string) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(
+		`<samlp:Response xmlns:samlp="%s" xmlns:saml="%s" ID="_response" Version="2.0" IssueInstant="%s" Destination="%s" InResponseTo="%s">`+
+			`<saml:Issuer>%s</saml:Issuer>`+
+			`<samlp:Status><samlp:StatusCode Value="%s"/></samlp:Status>`+
+			`%s</samlp:Response>`,
+		protocolNamespace, assertionNamespace, idp.timestamp(0), testSP.ACSURL, testRequestID,
+		testIdPEntityID, statusSuccess, strings.Join(assertions, ""))))
+}
+
+// signedAssertion returns the assertion for user@example.com, signed
+func (idp *mockIdP) signedAssertion() string {
+	return idp.sign(idp.assertion(testAssertionID, "user@example.com", testSP.EntityID), testAssertionID)
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := newMockIdP(t)
+
+	assertion, err := testSP.ParseResponse(idp.response(idp.signedAssertion()), idp.provider(), testRequestID, idp.issued)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if assertion.ID != testAssertionID || assertion.NameID != "user@example.com" || assertion.NameIDFormat != nameIDEmail {
+		t.Errorf("assertion = %+v", assertion)
+	}
+	if assertion.SessionIndex != "_session" {
+		t.Errorf("session index = %q", assertion.SessionIndex)
+	}
+	if assertion.Attribute("mail") != "user@example.com" || assertion.Attribute("urn:oid:0.9.2342.19200300.100.1.3") != "user@example.com" {
+		t.Errorf("attributes = %v", assertion.Attributes)
+	}
+}
+
+func TestParseResponseRejectsWrapping(t *testing.T) {
+	idp := newMockIdP(t)
+	signed := idp.signedAssertion()
+	evil := idp.assertion("_evil", "admin@example.com", testSP.EntityID)
+
+	other := idp.provider()
+	other.Certificates = []*x509.Certificate{newMockIdP(t).certificate}
+
+	tests := []struct {
+		name     string
+		response string
+		provider *IdentityProvider
+	}{
+		{"modified after signing", idp.response(strings.Replace(signed, "user@example.com</saml:NameID>", "admin@example.com</saml:NameID>", 1)), nil},
+		{"unsigned assertion beside the signed one", idp.response(evil, signed), nil},
+		{"signed assertion hidden in extensions", idp.response(
+			`<samlp:Extensions>` + signed + `</samlp:Extensions>` + evil), nil},
+		{"signature moved to another assertion", idp.response(
+			strings.Replace(evil, "</saml:Issuer>", "</saml:Issuer>"+signatureOf(signed), 1)), nil},
+		{"assertion with the signed ID", idp.response(
+			`<samlp:Extensions>` + signed + `</samlp:Extensions>` + strings.Replace(evil, `ID="_evil"`, `ID="`+testAssertionID+`"`, 1)), nil},
+		{"signed by another key", idp.response(signed), other},
+		{"not signed", idp.response(idp.assertion(testAssertionID, "user@example.com", testSP.EntityID)), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := tt.provider
+			if provider == nil {
+				provider = idp.provider()
+			}
+			assertion, err := testSP.ParseResponse(tt.response, provider, testRequestID, idp.issued)
+			if !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("ParseResponse = %+v, %v; want %v", assertion, err, ErrInvalidResponse)
+			}
+		})
+	}
+}
+
+func TestParseResponseRejectsExpiredAssertion(t *testing.T) {
+	idp := newMockIdP(t)
+	response := idp.response(idp.signedAssertion())
+
+	tests := []struct {
+		name string
+		now  time.Time
+	}{
+		// Five minutes of validity and three of clock skew
+		{"expired", idp.issued.Add(9 * time.Minute)},
+		{"not yet valid", idp.issued.Add(-5 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := testSP.ParseResponse(response, idp.provider(), testRequestID, tt.now); !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("ParseResponse error = %v, want %v", err, ErrInvalidResponse)
+			}
+		})
+	}
+
+	// Within the tolerated skew it is still accepted
+	if _, err := testSP.ParseResponse(response, idp.provider(), testRequestID, idp.issued.Add(7*time.Minute)); err != nil {
+		t.Errorf("ParseResponse within clock skew: %v", err)
+	}
+}
+
+func TestParseResponseChecksContext(t *testing.T) {
+	idp := newMockIdP(t)
+
+	t.Run("other request", func(t *testing.T) {
+		_, err := testSP.ParseResponse(idp.response(idp.signedAssertion()), idp.provider(), "_another", idp.issued)
+		if !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("ParseResponse error = %v, want %v", err, ErrInvalidResponse)
+		}
+	})
+	t.Run("other audience", func(t *testing.T) {
+		signed := idp.sign(idp.assertion(testAssertionID, "user@example.com", "https://other.example.com"), testAssertionID)
+		_, err := testSP.ParseResponse(idp.response(signed), idp.provider(), testRequestID, idp.issued)
+		if !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("ParseResponse error = %v, want %v", err, ErrInvalidResponse)
+		}
+	})
+	t.Run("document type declaration", func(t *testing.T) {
+		decoded, _ := base64.StdEncoding.DecodeString(idp.response(idp.signedAssertion()))
+		response := base64.StdEncoding.EncodeToString(append([]byte(`<!DOCTYPE r [<!ENTITY e "x">]>`), decoded...))
+		_, err := testSP.ParseResponse(response, idp.provider(), testRequestID, idp.issued)
+		if !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("ParseResponse error = %v, want %v", err, ErrInvalidResponse)
+		}
+	})
+}
+
+// A comment does not change the canonical form, so it keeps the signature
+// valid; the NameID must still be read in full (CVE-2017-11427)
+func TestParseResponseReadsNameIDAcrossComments(t *testing.T) {
+	idp := newMockIdP(t)
+	signed := idp.sign(idp.assertion(testAssertionID, "user@example.com.evil.example", testSP.EntityID), testAssertionID)
+	commented := strings.Replace(signed, "user@example.com.evil.example</saml:NameID>", "user@example.com<!---->.evil.example</saml:NameID>", 1)
+
+	assertion, err := testSP.ParseResponse(idp.response(commented), idp.provider(), testRequestID, idp.issued)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if assertion.NameID != "user@example.com.evil.example" {
+		t.Errorf("NameID = %q", assertion.NameID)
+	}
+}
+
+func signatureOf(signed string) string {
+	start := strings.Index(signed, "<ds:Signature ")
+	end := strings.Index(signed, "</ds:Signature>") + len("</ds:Signature>")
+	return signed[start:end]
+}
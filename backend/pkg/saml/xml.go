@@ -0,0 +1,343 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Signed XML has to be canonicalized exactly as the signer did, which
+// encoding/xml cannot do: it forgets namespace prefixes and where they were
+// declared. Documents are read into a tree of elements that keeps them
+// instead.
+
+const (
+	xmlNamespace   = "http://www.w3.org/XML/1998/namespace"
+	maxDepth       = 64
+	maxElementRead = 10000
+)
+
+var errMalformedXML = errors.New("malformed XML")
+
+// element is an XML element as written: its prefix, its attributes with
+// their prefixes, and the namespaces it declares
+type element struct {
+	prefix   string
+	local    string
+	attrs    []xml.Attr
+	ns       map[string]string
+	parent   *element
+	children []node
+}
+
+// node is a child element or, when elem is nil, character data
+type node struct {
+	elem *element
+	text string
+}
+
+// parseXML reads a document into a tree. Document type declarations are
+// refused, and comments and processing instructions dropped.
+func parseXML(data []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	var root, current *element
+	count := 0
+	depth := 0
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errMalformedXML, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			count++
+			depth++
+			if count > maxElementRead || depth > maxDepth {
+				return nil, fmt.Errorf("%w: document is too large", errMalformedXML)
+			}
+			if current == nil && root != nil {
+				return nil, fmt.Errorf("%w: more than one root element", errMalformedXML)
+			}
+			e := &element{prefix: t.Name.Space, local: t.Name.Local, ns: make(map[string]string), parent: current}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					e.ns[""] = attr.Value
+				case attr.Name.Space == "xmlns":
+					e.ns[attr.Name.Local] = attr.Value
+				default:
+					e.attrs = append(e.attrs, attr)
+				}
+			}
+			if current == nil {
+				root = e
+			} else {
+				current.children = append(current.children, node{elem: e})
+			}
+			current = e
+
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.prefix || t.Name.Local != current.local {
+				return nil, fmt.Errorf("%w: unexpected end element %s", errMalformedXML, t.Name.Local)
+			}
+			current = current.parent
+			depth--
+
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, node{text: string(t)})
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, fmt.Errorf("%w: text outside the root element", errMalformedXML)
+			}
+
+		case xml.Directive:
+			return nil, fmt.Errorf("%w: document type declarations are not allowed", errMalformedXML)
+		}
+	}
+
+	if root == nil || current != nil {
+		return nil, fmt.Errorf("%w: incomplete document", errMalformedXML)
+	}
+	return root, nil
+}
+
+// lookup resolves a namespace prefix in the element's scope; "" is the
+// default namespace.
+func (e *element) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for el := e; el != nil; el = el.parent {
+		if uri, ok := el.ns[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", prefix == ""
+}
+
+func (e *element) namespace() string {
+	uri, _ := e.lookup(e.prefix)
+	return uri
+}
+
+func (e *element) is(namespace, local string) bool {
+	return e.local == local && e.namespace() == namespace
+}
+
+// attr returns the value of an attribute without namespace.
+func (e *element) attr(local string) string {
+	for _, a := range e.attrs {
+		if a.Name.Space == "" && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the name.
+func (e *element) child(namespace, local string) *element {
+	for _, c := range e.children {
+		if c.elem != nil && c.elem.is(namespace, local) {
+			return c.elem
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns the child elements with the name.
+func (e *element) childrenNamed(namespace, local string) []*element {
+	var result []*element
+	for _, c := range e.children {
+		if c.elem != nil && c.elem.is(namespace, local) {
+			result = append(result, c.elem)
+		}
+	}
+	return result
+}
+
+// text returns all character data in the element, including that of
+// descendants. Reading only the first text node would let a comment split
+// a signed value (CVE-2017-11427).
+func (e *element) text() string {
+	var b strings.Builder
+	var walk func(*element)
+	walk = func(el *element) {
+		for _, c := range el.children {
+			if c.elem != nil {
+				walk(c.elem)
+			} else {
+				b.WriteString(c.text)
+			}
+		}
+	}
+	walk(e)
+	return strings.TrimSpace(b.String())
+}
+
+// walk calls fn for the element and each of its descendants.
+func (e *element) walk(fn func(*element)) {
+	fn(e)
+	for _, c := range e.children {
+		if c.elem != nil {
+			c.elem.walk(fn)
+		}
+	}
+}
+
+// canonicalize serializes the element by Exclusive XML Canonicalization
+// 1.0 without comments. inclusivePrefixes are treated as in inclusive
+// canonicalization ("#default" names the default namespace), and exclude
+// is left out together with its content, as the enveloped signature
+// transform requires.
+func canonicalize(e *element, inclusivePrefixes []string, exclude *element) []byte {
+	inclusive := make(map[string]bool, len(inclusivePrefixes))
+	for _, p := range inclusivePrefixes {
+		if p == "#default" {
+			p = ""
+		}
+		inclusive[p] = true
+	}
+
+	var buf bytes.Buffer
+	writeCanonical(&buf, e, map[string]string{}, inclusive, exclude)
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, e *element, rendered map[string]string, inclusive map[string]bool, exclude *element) {
+	// Namespaces visibly utilized by the element and its attributes
+	used := map[string]bool{e.prefix: true}
+	for _, a := range e.attrs {
+		if a.Name.Space != "" && a.Name.Space != "xml" {
+			used[a.Name.Space] = true
+		}
+	}
+	for p := range inclusive {
+		if _, declared := e.lookup(p); declared {
+			used[p] = true
+		}
+	}
+
+	scope := rendered
+	var prefixes []string
+	for p := range used {
+		uri, _ := e.lookup(p)
+		previous, ok := rendered[p]
+		if p == "" && uri == "" && !ok {
+			continue
+		}
+		if ok && previous == uri {
+			continue
+		}
+		if len(prefixes) == 0 {
+			scope = make(map[string]string, len(rendered)+len(used))
+			for k, v := range rendered {
+				scope[k] = v
+			}
+		}
+		scope[p] = uri
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	type attribute struct {
+		namespace, local, qname, value string
+	}
+	attrs := make([]attribute, 0, len(e.attrs))
+	for _, a := range e.attrs {
+		qname := a.Name.Local
+		namespace := ""
+		if a.Name.Space != "" {
+			qname = a.Name.Space + ":" + a.Name.Local
+			namespace, _ = e.lookup(a.Name.Space)
+		}
+		attrs = append(attrs, attribute{namespace, a.Name.Local, qname, a.Value})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	qname := e.local
+	if e.prefix != "" {
+		qname = e.prefix + ":" + e.local
+	}
+
+	buf.WriteByte('<')
+	buf.WriteString(qname)
+	for _, p := range prefixes {
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(" xmlns:" + p + `="`)
+		}
+		escapeAttr(buf, scope[p])
+		buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + a.qname + `="`)
+		escapeAttr(buf, a.value)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+
+	for _, c := range e.children {
+		switch {
+		case c.elem == nil:
+			escapeText(buf, c.text)
+		case c.elem != exclude:
+			writeCanonical(buf, c.elem, scope, inclusive, exclude)
+		}
+	}
+
+	buf.WriteString("</" + qname + ">")
+}
+
+func escapeText(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package smtpclient delivers prepared messages through a mail server. It
+// is shared by account email and notifications.
+package smtpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// DefaultPort is the submission port used when none is configured
+const DefaultPort = 587
+
+// Client connects to Host for each message. Port 465 uses implicit TLS;
+// any other port upgrades with STARTTLS when the server offers it.
+type Client struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Send delivers message, a complete RFC 5322 message with CRLF line
+// endings, from from to every address in to.
+func (c *Client) Send(ctx context.Context, from string, to []string, message []byte) error {
+	client, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && c.Port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", recipient, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Helper functions
+
+func (c *Client) dial(ctx context.Context) (*smtp.Client, error) {
+	port := c.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	address := net.JoinHostPort(c.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: c.Host}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
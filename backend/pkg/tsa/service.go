@@ -0,0 +1,385 @@
+package tsa
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPolicyOID = "1.3.6.1.4.1.4146.2.3"
+
+var ErrNotConfigured = errors.New("time stamping authority is not configured")
+
+var oidExtExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+type Service struct {
+	opensslPath string
+	config      Config
+
+	// openssl increments the serial file in place, so replies are issued
+	// one at a time.
+	mu sync.Mutex
+}
+
+func NewService(opensslPath string, config Config) *Service {
+	if config.PolicyOID == "" {
+		config.PolicyOID = defaultPolicyOID
+	}
+	return &Service{
+		opensslPath: opensslPath,
+		config:      config,
+	}
+}
+
+// Enabled reports whether signing material has been configured.
+func (s *Service) Enabled() bool {
+	return s.config.CertFile != "" && s.config.KeyFile != ""
+}
+
+// CheckSigner validates that the configured certificate may be used to issue
+// time stamps, so misconfiguration is caught at startup rather than on the
+// first request.
+func (s *Service) CheckSigner() error {
+	if !s.Enabled() {
+		return ErrNotConfigured
+	}
+
+	data, err := os.ReadFile(s.config.CertFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TSA certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("failed to parse TSA certificate PEM block")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse TSA certificate: %w", err)
+	}
+
+	// RFC 3161 section 2.3: the certificate must contain exactly one
+	// extended key usage, timeStamping, marked critical.
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping || len(cert.UnknownExtKeyUsage) != 0 {
+		return errors.New("TSA certificate must carry only the timeStamping extended key usage")
+	}
+	if !extKeyUsageCritical(cert) {
+		return errors.New("TSA certificate must mark the extended key usage extension critical")
+	}
+	if time.Now().After(cert.NotAfter) {
+		return errors.New("TSA certificate has expired")
+	}
+
+	return nil
+}
+
+// Reply answers a DER encoded TimeStampReq with a signed TimeStampResp.
+// Malformed or unacceptable queries produce a rejection response rather than
+// an error, as RFC 3161 requires.
+func (s *Service) Reply(query []byte) ([]byte, error) {
+	if !s.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureSerialFile(); err != nil {
+		return nil, err
+	}
+
+	return s.run(map[string][]byte{"query.tsq": query}, func(dir string) ([]string, error) {
+		configPath := filepath.Join(dir, "tsa.cnf")
+		if err := os.WriteFile(configPath, []byte(s.buildConfigFile()), 0600); err != nil {
+			return nil, err
+		}
+		return []string{
+			"ts", "-reply",
+			"-config", configPath,
+			"-queryfile", filepath.Join(dir, "query.tsq"),
+		}, nil
+	})
+}
+
+func (s *Service) CreateQuery(req *CreateQueryRequest) (*CreateQueryResponse, error) {
+	hashAlgorithm := req.HashAlgorithm
+	if hashAlgorithm == "" {
+		hashAlgorithm = "sha256"
+	}
+
+	files := map[string][]byte{}
+	if req.Digest == "" {
+		if req.Data == "" {
+			return nil, errors.New("either data or digest is required")
+		}
+		files["data.bin"] = []byte(req.Data)
+	}
+
+	query, err := s.run(files, func(dir string) ([]string, error) {
+		args := []string{"ts", "-query", "-" + hashAlgorithm}
+		if req.Digest != "" {
+			args = append(args, "-digest", req.Digest)
+		} else {
+			args = append(args, "-data", filepath.Join(dir, "data.bin"))
+		}
+		if req.PolicyOID != "" {
+			args = append(args, "-tspolicy", req.PolicyOID)
+		}
+		if req.RequestCertificate {
+			args = append(args, "-cert")
+		}
+		return args, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("time stamp query error: %w", err)
+	}
+
+	return &CreateQueryResponse{
+		Query: base64.StdEncoding.EncodeToString(query),
+	}, nil
+}
+
+func (s *Service) Verify(req *VerifyRequest) (*VerifyResponse, error) {
+	if req.Data == "" && req.Digest == "" {
+		return nil, errors.New("either data or digest is required")
+	}
+
+	response, err := base64.StdEncoding.DecodeString(strings.TrimSpace(req.Response))
+	if err != nil {
+		return nil, fmt.Errorf("response must be base64 encoded DER: %w", err)
+	}
+
+	token, err := s.parseResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{"response.tsr": response}
+	if req.Data != "" {
+		files["data.bin"] = []byte(req.Data)
+	}
+	if req.CAChain != "" {
+		files["ca.pem"] = []byte(req.CAChain)
+	}
+	if req.Untrusted != "" {
+		files["untrusted.pem"] = []byte(req.Untrusted)
+	}
+
+	_, err = s.run(files, func(dir string) ([]string, error) {
+		args := []string{"ts", "-verify", "-in", filepath.Join(dir, "response.tsr")}
+		if req.Digest != "" {
+			args = append(args, "-digest", req.Digest)
+		} else {
+			args = append(args, "-data", filepath.Join(dir, "data.bin"))
+		}
+		if req.CAChain != "" {
+			args = append(args, "-CAfile", filepath.Join(dir, "ca.pem"))
+		}
+		if req.Untrusted != "" {
+			args = append(args, "-untrusted", filepath.Join(dir, "untrusted.pem"))
+		}
+		return args, nil
+	})
+	if err != nil {
+		return &VerifyResponse{
+			IsValid:      false,
+			ErrorMessage: err.Error(),
+			Token:        token,
+		}, nil
+	}
+
+	return &VerifyResponse{
+		IsValid: true,
+		Token:   token,
+	}, nil
+}
+
+// Helper functions
+
+func (s *Service) parseResponse(response []byte) (*TokenInfo, error) {
+	output, err := s.run(map[string][]byte{"response.tsr": response}, func(dir string) ([]string, error) {
+		return []string{"ts", "-reply", "-in", filepath.Join(dir, "response.tsr"), "-text"}, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("time stamp response parsing error: %w", err)
+	}
+
+	return parseTokenText(string(output)), nil
+}
+
+func (s *Service) ensureSerialFile() error {
+	if _, err := os.Stat(s.config.SerialFile); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.config.SerialFile), 0700); err != nil {
+		return fmt.Errorf("failed to create serial directory: %w", err)
+	}
+	return os.WriteFile(s.config.SerialFile, []byte("01\n"), 0600)
+}
+
+func (s *Service) buildConfigFile() string {
+	var config strings.Builder
+
+	config.WriteString("[tsa]\n")
+	config.WriteString("default_tsa = tsa_config\n")
+	config.WriteString("[tsa_config]\n")
+	config.WriteString("serial = " + s.config.SerialFile + "\n")
+	config.WriteString("signer_cert = " + s.config.CertFile + "\n")
+	config.WriteString("signer_key = " + s.config.KeyFile + "\n")
+	if s.config.ChainFile != "" {
+		config.WriteString("certs = " + s.config.ChainFile + "\n")
+	}
+	config.WriteString("signer_digest = sha256\n")
+	config.WriteString("default_policy = " + s.config.PolicyOID + "\n")
+	config.WriteString("digests = sha256, sha384, sha512\n")
+	config.WriteString("accuracy = secs:1\n")
+	config.WriteString("ordering = yes\n")
+	config.WriteString("tsa_name = yes\n")
+	config.WriteString("ess_cert_id_alg = sha256\n")
+
+	return config.String()
+}
+
+// run writes the inputs into a temporary directory and executes openssl
+// with the arguments built for it, returning stdout.
+func (s *Service) run(files map[string][]byte, buildArgs func(dir string) ([]string, error)) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "openssl-ts-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	args, err := buildArgs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(s.opensslPath, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func parseTokenText(output string) *TokenInfo {
+	info := &TokenInfo{}
+
+	var imprint []byte
+	inMessageData := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if inMessageData {
+			if hexBytes, ok := parseHexDumpLine(line); ok {
+				imprint = append(imprint, hexBytes...)
+				continue
+			}
+			inMessageData = false
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Status":
+			info.Status = strings.TrimSuffix(value, ".")
+		case "Policy OID":
+			info.PolicyOID = value
+		case "Hash Algorithm":
+			info.HashAlgorithm = value
+		case "Message data":
+			inMessageData = true
+		case "Serial number":
+			info.SerialNumber = value
+		case "Time stamp":
+			info.GenTime = parseGenTime(value)
+		case "Accuracy":
+			info.Accuracy = value
+		case "Ordering":
+			info.Ordering = value == "yes"
+		case "Nonce":
+			if value != "unspecified" {
+				info.Nonce = value
+			}
+		case "TSA":
+			if value != "unspecified" {
+				info.TSAName = value
+			}
+		}
+	}
+
+	info.MessageImprint = hex.EncodeToString(imprint)
+	return info
+}
+
+// parseHexDumpLine decodes a BIO_dump line such as
+// "    0000 - 58 91 b5 b5 22 d5 df 08-6d 0f f0 b1 10 fb d9 d2   X...".
+func parseHexDumpLine(line string) ([]byte, bool) {
+	_, rest, found := strings.Cut(line, " - ")
+	if !found || !strings.HasPrefix(line, "    ") {
+		return nil, false
+	}
+	if i := strings.Index(rest, "   "); i >= 0 {
+		rest = rest[:i]
+	}
+
+	var out []byte
+	for _, field := range strings.Fields(strings.ReplaceAll(rest, "-", " ")) {
+		b, err := hex.DecodeString(field)
+		if err != nil {
+			return nil, false
+		}
+		out = append(out, b...)
+	}
+	return out, true
+}
+
+func parseGenTime(value string) time.Time {
+	for _, layout := range []string{"Jan _2 15:04:05 2006 MST", "Jan _2 15:04:05.999999 2006 MST"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// extKeyUsageCritical reports whether the certificate's extended key usage
+// extension is marked critical; x509.Certificate does not expose it.
+func extKeyUsageCritical(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidExtExtendedKeyUsage) {
+			return ext.Critical
+		}
+	}
+	return false
+}
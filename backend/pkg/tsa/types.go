@@ -0,0 +1,55 @@
+package tsa
+
+import "time"
+
+// Config describes the signing material used when acting as a time stamping
+// authority. The certificate must carry the timeStamping extended key usage.
+type Config struct {
+	CertFile   string
+	KeyFile    string
+	ChainFile  string
+	PolicyOID  string
+	SerialFile string
+}
+
+type CreateQueryRequest struct {
+	Data               string `json:"data,omitempty"`
+	Digest             string `json:"digest,omitempty"`
+	HashAlgorithm      string `json:"hashAlgorithm,omitempty"`
+	PolicyOID          string `json:"policyOid,omitempty"`
+	RequestCertificate bool   `json:"requestCertificate,omitempty"`
+}
+
+type CreateQueryResponse struct {
+	// Query is the DER TimeStampReq, base64 encoded.
+	Query string `json:"query"`
+}
+
+type VerifyRequest struct {
+	// Response is the DER TimeStampResp returned by the TSA, base64 encoded.
+	Response string `json:"response" binding:"required"`
+	// Either the original data or its hex encoded digest must be given.
+	Data      string `json:"data,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	CAChain   string `json:"caChain,omitempty"`
+	Untrusted string `json:"untrusted,omitempty"`
+}
+
+type VerifyResponse struct {
+	IsValid      bool       `json:"isValid"`
+	ErrorMessage string     `json:"errorMessage,omitempty"`
+	Token        *TokenInfo `json:"token,omitempty"`
+}
+
+type TokenInfo struct {
+	Status         string    `json:"status"`
+	PolicyOID      string    `json:"policyOid"`
+	HashAlgorithm  string    `json:"hashAlgorithm"`
+	MessageImprint string    `json:"messageImprint"`
+	SerialNumber   string    `json:"serialNumber"`
+	GenTime        time.Time `json:"genTime"`
+	Accuracy       string    `json:"accuracy,omitempty"`
+	Ordering       bool      `json:"ordering"`
+	Nonce          string    `json:"nonce,omitempty"`
+	TSAName        string    `json:"tsaName,omitempty"`
+}
@@ -0,0 +1,104 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PKCS11Token is the part of a PKCS#11 token the vault relies on. The master
+// key never leaves the token: data keys are encrypted and decrypted inside
+// it with the secret key object carrying the given label, typically using
+// CKM_AES_GCM or CKM_AES_KEY_WRAP_PAD.
+type PKCS11Token interface {
+	Encrypt(label string, plaintext []byte) ([]byte, error)
+	Decrypt(label string, ciphertext []byte) ([]byte, error)
+	GenerateKey(label string) error
+}
+
+// PKCS11Opener opens a session on a token from a module path, slot and PIN.
+type PKCS11Opener func(modulePath string, slot int, pin string) (PKCS11Token, error)
+
+var (
+	pkcs11Mu     sync.RWMutex
+	pkcs11Opener PKCS11Opener
+)
+
+// RegisterPKCS11Opener installs the binding used by the pkcs11 provider.
+// The vault itself has no cgo dependency; a build that links a PKCS#11
+// library registers an opener for it at init time.
+func RegisterPKCS11Opener(opener PKCS11Opener) {
+	pkcs11Mu.Lock()
+	defer pkcs11Mu.Unlock()
+	pkcs11Opener = opener
+}
+
+// PKCS11Provider wraps data keys with a secret key held on a token. The key
+// label doubles as the master key id stored with each record.
+type PKCS11Provider struct {
+	token     PKCS11Token
+	baseLabel string
+
+	mu           sync.RWMutex
+	currentLabel string
+}
+
+func NewPKCS11Provider(modulePath string, slot int, pin, label string) (*PKCS11Provider, error) {
+	if modulePath == "" {
+		return nil, errors.New("PKCS#11 module path is not configured")
+	}
+
+	pkcs11Mu.RLock()
+	opener := pkcs11Opener
+	pkcs11Mu.RUnlock()
+	if opener == nil {
+		return nil, errors.New("no PKCS#11 binding is linked into this build")
+	}
+
+	token, err := opener(modulePath, slot, pin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 token: %w", err)
+	}
+	return NewPKCS11ProviderWithToken(token, label), nil
+}
+
+// NewPKCS11ProviderWithToken uses an already opened token session.
+func NewPKCS11ProviderWithToken(token PKCS11Token, label string) *PKCS11Provider {
+	return &PKCS11Provider{
+		token:        token,
+		baseLabel:    label,
+		currentLabel: label,
+	}
+}
+
+func (p *PKCS11Provider) CurrentKeyID() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currentLabel
+}
+
+func (p *PKCS11Provider) Wrap(dataKey []byte) (string, []byte, error) {
+	label := p.CurrentKeyID()
+	wrapped, err := p.token.Encrypt(label, dataKey)
+	return label, wrapped, err
+}
+
+func (p *PKCS11Provider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	return p.token.Decrypt(keyID, wrapped)
+}
+
+// Rotate generates a new key on the token. The new label only lasts for the
+// lifetime of the process, so VAULT_PKCS11_KEY_LABEL must be updated to it
+// before the next restart.
+func (p *PKCS11Provider) Rotate() (string, error) {
+	label := fmt.Sprintf("%s-%s", p.baseLabel, time.Now().UTC().Format("20060102T150405Z"))
+	if err := p.token.GenerateKey(label); err != nil {
+		return "", fmt.Errorf("failed to generate key on token: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.currentLabel = label
+	return label, nil
+}
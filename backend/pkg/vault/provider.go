@@ -0,0 +1,200 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StaticProvider holds master keys supplied through configuration. To rotate,
+// configure a new current key, move the old one to the previous keys and
+// re-wrap; the old key can be dropped once no record references it.
+type StaticProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+func NewStaticProvider(keyID, masterKey string, previousKeys []string) (*StaticProvider, error) {
+	if masterKey == "" {
+		return nil, errors.New("vault master key is not configured")
+	}
+
+	key, err := decodeKey(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault master key: %w", err)
+	}
+
+	p := &StaticProvider{
+		currentID: keyID,
+		keys:      map[string][]byte{keyID: key},
+	}
+
+	for _, entry := range previousKeys {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("previous master key must be id:base64, got %q", entry)
+		}
+		if _, exists := p.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate master key id: %s", id)
+		}
+		previous, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous master key %s: %w", id, err)
+		}
+		p.keys[id] = previous
+	}
+
+	return p, nil
+}
+
+func (p *StaticProvider) CurrentKeyID() string {
+	return p.currentID
+}
+
+func (p *StaticProvider) Wrap(dataKey []byte) (string, []byte, error) {
+	wrapped, err := encryptGCM(p.keys[p.currentID], dataKey, []byte(p.currentID))
+	return p.currentID, wrapped, err
+}
+
+func (p *StaticProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, keyID)
+	}
+	return decryptGCM(key, wrapped, []byte(keyID))
+}
+
+// FileProvider is a local stand-in for a KMS. Each master key is stored as
+// <id>.key in the key directory and the file named "current" holds the id
+// used for wrapping. The directory is created with a first key when empty.
+type FileProvider struct {
+	dir string
+
+	mu        sync.RWMutex
+	currentID string
+	keys      map[string][]byte
+}
+
+const currentKeyFile = "current"
+
+func NewFileProvider(dir string) (*FileProvider, error) {
+	if dir == "" {
+		return nil, errors.New("vault key directory is not configured")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create vault key directory: %w", err)
+	}
+
+	p := &FileProvider{
+		dir:  dir,
+		keys: make(map[string][]byte),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.key"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeKey(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key file %s: %w", path, err)
+		}
+		p.keys[strings.TrimSuffix(filepath.Base(path), ".key")] = key
+	}
+
+	current, err := os.ReadFile(filepath.Join(dir, currentKeyFile))
+	switch {
+	case err == nil:
+		p.currentID = strings.TrimSpace(string(current))
+		if _, ok := p.keys[p.currentID]; !ok {
+			return nil, fmt.Errorf("current master key %s has no key file", p.currentID)
+		}
+	case errors.Is(err, os.ErrNotExist) && len(p.keys) == 0:
+		if _, err := p.Rotate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to read current master key id: %w", err)
+	}
+
+	return p, nil
+}
+
+func (p *FileProvider) CurrentKeyID() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currentID
+}
+
+func (p *FileProvider) Wrap(dataKey []byte) (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	wrapped, err := encryptGCM(p.keys[p.currentID], dataKey, []byte(p.currentID))
+	return p.currentID, wrapped, err
+}
+
+func (p *FileProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	p.mu.RLock()
+	key, ok := p.keys[keyID]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, keyID)
+	}
+	return decryptGCM(key, wrapped, []byte(keyID))
+}
+
+// Rotate writes a new master key and makes it current. Older key files are
+// kept so existing records remain readable.
+func (p *FileProvider) Rotate() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	keyID := "mk-" + hex.EncodeToString(suffix)
+
+	keyPath := filepath.Join(p.dir, keyID+".key")
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write master key: %w", err)
+	}
+
+	// Replace the pointer atomically so a crash never leaves it truncated
+	tmpPath := filepath.Join(p.dir, currentKeyFile+".tmp")
+	if err := os.WriteFile(tmpPath, []byte(keyID+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write current master key id: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(p.dir, currentKeyFile)); err != nil {
+		return "", fmt.Errorf("failed to write current master key id: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[keyID] = key
+	p.currentID = keyID
+
+	return keyID, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type Service struct {
+	config Config
+
+	mu       sync.RWMutex
+	provider KeyProvider
+}
+
+func NewService(config Config) *Service {
+	return &Service{config: config}
+}
+
+// Load opens the master key provider selected in the configuration. It must
+// succeed before any key can be sealed or opened.
+func (s *Service) Load() error {
+	provider, err := NewProvider(s.config)
+	if err != nil {
+		return err
+	}
+	s.SetProvider(provider)
+	return nil
+}
+
+// SetProvider replaces the master key provider, e.g. with a token session
+// opened by the caller.
+func (s *Service) SetProvider(provider KeyProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.provider = provider
+}
+
+// NewProvider opens the master key provider selected in config.
+func NewProvider(config Config) (KeyProvider, error) {
+	switch config.Provider {
+	case ProviderConfig:
+		return NewStaticProvider(config.MasterKeyID, config.MasterKey, config.PreviousMasterKeys)
+	case "", ProviderFile:
+		return NewFileProvider(config.KeyDir)
+	case ProviderPKCS11:
+		return NewPKCS11Provider(config.PKCS11Module, config.PKCS11Slot, config.PKCS11PIN, config.PKCS11KeyLabel)
+	default:
+		return nil, fmt.Errorf("unknown vault provider: %s", config.Provider)
+	}
+}
+
+func (s *Service) CurrentKeyID() string {
+	provider, err := s.keyProvider()
+	if err != nil {
+		return ""
+	}
+	return provider.CurrentKeyID()
+}
+
+// Seal encrypts plaintext under a fresh data key and wraps that key with the
+// current master key. additionalData is authenticated but not stored; the
+// same value has to be passed to Open.
+func (s *Service) Seal(plaintext, additionalData []byte) (*Sealed, error) {
+	provider, err := s.keyProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := encryptGCM(dataKey, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := provider.Wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("data key wrapping failed: %w", err)
+	}
+
+	return &Sealed{
+		Ciphertext:  ciphertext,
+		WrappedKey:  wrapped,
+		MasterKeyID: keyID,
+	}, nil
+}
+
+// Open decrypts a value sealed with the same additionalData.
+func (s *Service) Open(sealed *Sealed, additionalData []byte) ([]byte, error) {
+	provider, err := s.keyProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := provider.Unwrap(sealed.MasterKeyID, sealed.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("data key unwrapping failed: %w", err)
+	}
+
+	plaintext, err := decryptGCM(dataKey, sealed.Ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap re-wraps the data key of sealed with the current master key. The
+// ciphertext is untouched. It reports false when sealed is already current.
+func (s *Service) Rewrap(sealed *Sealed) (*Sealed, bool, error) {
+	provider, err := s.keyProvider()
+	if err != nil {
+		return nil, false, err
+	}
+	if sealed.MasterKeyID == provider.CurrentKeyID() {
+		return sealed, false, nil
+	}
+
+	dataKey, err := provider.Unwrap(sealed.MasterKeyID, sealed.WrappedKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("data key unwrapping failed: %w", err)
+	}
+
+	keyID, wrapped, err := provider.Wrap(dataKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("data key wrapping failed: %w", err)
+	}
+
+	return &Sealed{
+		Ciphertext:  sealed.Ciphertext,
+		WrappedKey:  wrapped,
+		MasterKeyID: keyID,
+	}, true, nil
+}
+
+// Rotate creates a new current master key. Existing records stay readable
+// with the previous key until they are re-wrapped.
+func (s *Service) Rotate() (string, error) {
+	provider, err := s.keyProvider()
+	if err != nil {
+		return "", err
+	}
+	rotator, ok := provider.(Rotator)
+	if !ok {
+		return "", ErrRotationUnsupported
+	}
+	return rotator.Rotate()
+}
+
+// Helper functions
+
+func (s *Service) keyProvider() (KeyProvider, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.provider == nil {
+		return nil, ErrNotLoaded
+	}
+	return s.provider, nil
+}
+
+// encryptGCM returns nonce || AES-GCM ciphertext.
+func encryptGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decryptGCM(key, data, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size %d, expected %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
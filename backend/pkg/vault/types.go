@@ -0,0 +1,62 @@
+package vault
+
+import (
+	"errors"
+)
+
+const (
+	ProviderConfig = "config"
+	ProviderFile   = "file"
+	ProviderPKCS11 = "pkcs11"
+
+	// KeySize is the size of master and data keys (AES-256).
+	KeySize = 32
+)
+
+var (
+	ErrNotLoaded           = errors.New("key vault is not loaded")
+	ErrUnknownMasterKey    = errors.New("master key is not available to this vault")
+	ErrRotationUnsupported = errors.New("master key provider does not support rotation")
+)
+
+type Config struct {
+	Provider string
+
+	// Config provider
+	MasterKey          string
+	MasterKeyID        string
+	PreviousMasterKeys []string
+
+	// File provider
+	KeyDir string
+
+	// PKCS#11 provider
+	PKCS11Module   string
+	PKCS11Slot     int
+	PKCS11PIN      string
+	PKCS11KeyLabel string
+}
+
+// KeyProvider holds the master keys that wrap per-record data keys. Wrap
+// always uses the current key; Unwrap accepts any key the provider still
+// holds, which is what allows records to be re-wrapped after a rotation.
+type KeyProvider interface {
+	CurrentKeyID() string
+	Wrap(dataKey []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Rotator is implemented by providers that can create a new master key
+// themselves. Providers whose keys are managed externally, such as the
+// config provider, are rotated by the operator instead.
+type Rotator interface {
+	Rotate() (string, error)
+}
+
+// Sealed is a value encrypted under a data key, together with that data key
+// wrapped by the master key identified by MasterKeyID.
+type Sealed struct {
+	Ciphertext  []byte
+	WrappedKey  []byte
+	MasterKeyID string
+}
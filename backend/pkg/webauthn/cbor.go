@@ -0,0 +1,161 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WebAuthn encodes attestation objects and COSE keys in CTAP2 canonical
+// CBOR (RFC 8949), which only uses definite lengths. decodeCBOR handles that
+// subset: integers, byte and text strings, arrays, maps, tags and simple
+// values. Maps decode to map[interface{}]interface{} with int64 or string
+// keys.
+
+const maxCBORDepth = 16
+
+var errCBORTruncated = errors.New("truncated CBOR data")
+
+// decodeCBOR decodes one item and returns the bytes after it.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errors.New("CBOR data is nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == 7 {
+		return decodeCBORSimple(info, data)
+	}
+
+	arg, data, err := cborArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("CBOR integer out of range")
+		}
+		return int64(arg), data, nil
+
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("CBOR integer out of range")
+		}
+		return -1 - int64(arg), data, nil
+
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBORTruncated
+		}
+		value := data[:arg]
+		if major == 3 {
+			return string(value), data[arg:], nil
+		}
+		return append([]byte(nil), value...), data[arg:], nil
+
+	case 4:
+		// Every item takes at least one byte
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBORTruncated
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, errCBORTruncated
+		}
+		entries := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("unsupported CBOR map key type %T", key)
+			}
+			if _, ok := entries[key]; ok {
+				return nil, nil, fmt.Errorf("duplicate CBOR map key %v", key)
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			entries[key] = value
+		}
+		return entries, data, nil
+
+	default:
+		// Tags only annotate the item that follows
+		return decodeCBORItem(data, depth+1)
+	}
+}
+
+func cborArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, errCBORTruncated
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, errors.New("indefinite-length CBOR items are not supported")
+	}
+}
+
+func decodeCBORSimple(info byte, data []byte) (interface{}, []byte, error) {
+	switch info {
+	case 20:
+		return false, data, nil
+	case 21:
+		return true, data, nil
+	case 22, 23:
+		return nil, data, nil
+	case 25, 26, 27:
+		// Floats are not used by WebAuthn; skip them
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, errCBORTruncated
+		}
+		return nil, data[size:], nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported CBOR simple value %d", info)
+	}
+}
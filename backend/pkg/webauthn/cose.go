@@ -0,0 +1,148 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) offered when creating credentials,
+// in order of preference
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+var supportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters
+const (
+	coseKeyType      = 1
+	coseKeyAlgorithm = 3
+	coseKeyCurve     = -1
+	coseKeyX         = -2
+	coseKeyY         = -3
+	coseKeyN         = -1
+	coseKeyE         = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// parseCOSEKey decodes a credential public key and returns it with its
+// algorithm.
+func parseCOSEKey(data []byte) (crypto.PublicKey, int, error) {
+	item, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, 0, errors.New("invalid COSE key: trailing data")
+	}
+	return coseKeyFromMap(item)
+}
+
+func coseKeyFromMap(item interface{}) (crypto.PublicKey, int, error) {
+	key, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+	kty, _ := key[int64(coseKeyType)].(int64)
+	alg, _ := key[int64(coseKeyAlgorithm)].(int64)
+
+	switch kty {
+	case coseKeyTypeEC2:
+		crv, _ := key[int64(coseKeyCurve)].(int64)
+		if alg != AlgES256 || crv != coseCurveP256 {
+			return nil, 0, fmt.Errorf("unsupported EC2 key: algorithm %d, curve %d", alg, crv)
+		}
+		x, _ := key[int64(coseKeyX)].([]byte)
+		y, _ := key[int64(coseKeyY)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid EC2 key coordinates")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errors.New("EC point is not on the curve")
+		}
+		return pub, AlgES256, nil
+
+	case coseKeyTypeOKP:
+		crv, _ := key[int64(coseKeyCurve)].(int64)
+		if alg != AlgEdDSA || crv != coseCurveEd25519 {
+			return nil, 0, fmt.Errorf("unsupported OKP key: algorithm %d, curve %d", alg, crv)
+		}
+		x, _ := key[int64(coseKeyX)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), AlgEdDSA, nil
+
+	case coseKeyTypeRSA:
+		if alg != AlgRS256 {
+			return nil, 0, fmt.Errorf("unsupported RSA key algorithm %d", alg)
+		}
+		n, _ := key[int64(coseKeyN)].([]byte)
+		e, _ := key[int64(coseKeyE)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA public key")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, 0, errors.New("RSA credential keys must be at least 2048 bits")
+		}
+		return pub, AlgRS256, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported COSE key type %d", kty)
+	}
+}
+
+// verifySignature checks a WebAuthn signature, which for ECDSA is ASN.1 DER
+// encoded rather than the fixed-width form JWS uses.
+func verifySignature(key crypto.PublicKey, alg int, signed, signature []byte) error {
+	switch alg {
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("ES256 requires an EC key")
+		}
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(pub, digest[:], signature) {
+			return errors.New("ECDSA signature verification failed")
+		}
+		return nil
+
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("EdDSA requires an Ed25519 key")
+		}
+		if !ed25519.Verify(pub, signed, signature) {
+			return errors.New("EdDSA signature verification failed")
+		}
+		return nil
+
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 requires an RSA key")
+		}
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+
+	default:
+		return fmt.Errorf("unsupported signature algorithm %d", alg)
+	}
+}
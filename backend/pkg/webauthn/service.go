@@ -0,0 +1,414 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrVerification is wrapped by every reason a response is rejected
+	ErrVerification = errors.New("WebAuthn verification failed")
+	// ErrClonedAuthenticator means the signature counter went backwards,
+	// which happens when a credential's private key has been copied
+	ErrClonedAuthenticator = errors.New("authenticator signature counter went backwards; the credential may have been cloned")
+)
+
+const (
+	defaultTimeout = 5 * time.Minute
+	challengeBytes = 32
+
+	flagUserPresent       = 0x01
+	flagUserVerified      = 0x04
+	flagBackupEligible    = 0x08
+	flagAttestedData      = 0x40
+	flagExtensionData     = 0x80
+	authenticatorDataSize = 37
+)
+
+// Config identifies the relying party. RPID is the domain credentials are
+// scoped to and Origins the exact origins (scheme, host and port) of the web
+// application pages that run the ceremonies.
+type Config struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	Timeout time.Duration
+}
+
+type Service struct {
+	config Config
+}
+
+func NewService(config Config) *Service {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Service{config: config}
+}
+
+// Check reports configuration that would make every ceremony fail.
+func (s *Service) Check() error {
+	if s.config.RPID == "" {
+		return errors.New("WebAuthn relying party ID is not set")
+	}
+	if len(s.config.Origins) == 0 {
+		return errors.New("no WebAuthn origins are configured")
+	}
+	for _, origin := range s.config.Origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid WebAuthn origin %q", origin)
+		}
+		host := u.Hostname()
+		if host != s.config.RPID && !strings.HasSuffix(host, "."+s.config.RPID) {
+			return fmt.Errorf("WebAuthn origin %q is not within relying party ID %q", origin, s.config.RPID)
+		}
+	}
+	return nil
+}
+
+// NewChallenge returns a random challenge, base64url encoded. Each one must
+// be used for a single ceremony.
+func (s *Service) NewChallenge() (string, error) {
+	challenge := make([]byte, challengeBytes)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+	return encode(challenge), nil
+}
+
+// CreationOptions returns the options for registering a credential. Only
+// discoverable credentials with user verification are asked for, so that
+// they can be used without a password.
+func (s *Service) CreationOptions(challenge string, user UserEntity, exclude []CredentialDescriptor) *CreationOptions {
+	params := make([]CredentialParameter, len(supportedAlgorithms))
+	for i, alg := range supportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Alg: alg}
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return &CreationOptions{
+		Challenge:          challenge,
+		RP:                 RelyingParty{ID: s.config.RPID, Name: s.config.RPName},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            s.config.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options for signing in with one of the allowed
+// credentials, or any discoverable one when allow is empty.
+func (s *Service) RequestOptions(challenge string, allow []CredentialDescriptor) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             s.config.RPID,
+		Timeout:          s.config.Timeout.Milliseconds(),
+		AllowCredentials: allow,
+		UserVerification: "required",
+	}
+}
+
+// Timeout is how long the client is given to complete a ceremony.
+func (s *Service) Timeout() time.Duration {
+	return s.config.Timeout
+}
+
+// Challenge returns the challenge a response was made for, so that the
+// ceremony it belongs to can be looked up before it is verified.
+func (s *Service) Challenge(clientDataJSON string) (string, error) {
+	_, data, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	return data.Challenge, nil
+}
+
+// VerifyRegistration checks the response to CreationOptions made with
+// challenge and returns the new credential.
+func (s *Service) VerifyRegistration(challenge string, credential *CredentialCreation) (*Credential, error) {
+	if credential.Type != "public-key" {
+		return nil, verificationError("unexpected credential type %q", credential.Type)
+	}
+	clientDataHash, err := s.verifyClientData(credential.Response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := decode(credential.Response.AttestationObject)
+	if err != nil {
+		return nil, verificationError("invalid attestation object encoding")
+	}
+	item, rest, err := decodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, verificationError("invalid attestation object")
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, verificationError("invalid attestation object")
+	}
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := attestation["authData"].([]byte)
+
+	authData, err := s.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return nil, verificationError("attested credential data is missing")
+	}
+	if encode(authData.credentialID) != credential.ID {
+		return nil, verificationError("credential ID does not match the authenticator data")
+	}
+
+	publicKey, alg, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, verificationError("%v", err)
+	}
+
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash...)
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, verificationError("attestation statement must be empty for format none")
+		}
+	case "packed":
+		if err := verifyPackedAttestation(statement, signed, publicKey, alg); err != nil {
+			return nil, err
+		}
+	default:
+		// Attestation is not asked for, so clients send "none" in its place;
+		// other formats are not verified and not accepted
+		return nil, verificationError("unsupported attestation format %q", format)
+	}
+
+	return &Credential{
+		ID:             credential.ID,
+		PublicKey:      authData.publicKey,
+		Algorithm:      alg,
+		SignCount:      authData.signCount,
+		AAGUID:         hex.EncodeToString(authData.aaguid),
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		Transports:     credential.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks the response to RequestOptions made with challenge,
+// signed by the stored credential, and returns the new signature counter.
+func (s *Service) VerifyAssertion(challenge string, assertion *CredentialAssertion, publicKey []byte, signCount uint32) (uint32, error) {
+	if assertion.Type != "public-key" {
+		return 0, verificationError("unexpected credential type %q", assertion.Type)
+	}
+	clientDataHash, err := s.verifyClientData(assertion.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := decode(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return 0, verificationError("invalid authenticator data encoding")
+	}
+	authData, err := s.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, alg, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return 0, verificationError("%v", err)
+	}
+	signature, err := decode(assertion.Response.Signature)
+	if err != nil {
+		return 0, verificationError("invalid signature encoding")
+	}
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash...)
+	if err := verifySignature(key, alg, signed, signature); err != nil {
+		return 0, verificationError("%v", err)
+	}
+
+	// Authenticators that do not count, such as synced passkeys, always
+	// report zero
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return 0, ErrClonedAuthenticator
+	}
+	return authData.signCount, nil
+}
+
+// Helper functions
+
+func (s *Service) verifyClientData(clientDataJSON, ceremony, challenge string) ([]byte, error) {
+	raw, data, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if data.Type != ceremony {
+		return nil, verificationError("unexpected client data type %q", data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return nil, verificationError("challenge does not match")
+	}
+	if !s.allowedOrigin(data.Origin) {
+		return nil, verificationError("origin %q is not allowed", data.Origin)
+	}
+	if data.CrossOrigin {
+		return nil, verificationError("cross-origin ceremonies are not allowed")
+	}
+
+	sum := sha256.Sum256(raw)
+	return sum[:], nil
+}
+
+func (s *Service) allowedOrigin(origin string) bool {
+	for _, allowed := range s.config.Origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) verifyAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	rpIDHash := sha256.Sum256([]byte(s.config.RPID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return nil, verificationError("credential belongs to another relying party")
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return nil, verificationError("user presence was not confirmed")
+	}
+	if authData.flags&flagUserVerified == 0 {
+		return nil, verificationError("user verification is required")
+	}
+	return authData, nil
+}
+
+func parseClientData(clientDataJSON string) ([]byte, *clientData, error) {
+	raw, err := decode(clientDataJSON)
+	if err != nil {
+		return nil, nil, verificationError("invalid client data encoding")
+	}
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, verificationError("invalid client data")
+	}
+	return raw, &data, nil
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < authenticatorDataSize {
+		return nil, verificationError("authenticator data is too short")
+	}
+	authData := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[authenticatorDataSize:]
+
+	if authData.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, verificationError("attested credential data is too short")
+		}
+		authData.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || len(rest) < idLength {
+			return nil, verificationError("invalid credential ID")
+		}
+		authData.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, verificationError("invalid credential public key")
+		}
+		authData.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if authData.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, verificationError("invalid extension data")
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, verificationError("unexpected data after the authenticator data")
+	}
+	return authData, nil
+}
+
+// verifyPackedAttestation checks a packed attestation statement (WebAuthn
+// section 8.2), signed either by the credential itself or by an attestation
+// certificate. The certificate chain is not validated, since attestation is
+// not relied on.
+func verifyPackedAttestation(statement map[interface{}]interface{}, signed []byte, credentialKey crypto.PublicKey, credentialAlg int) error {
+	alg, _ := statement["alg"].(int64)
+	signature, _ := statement["sig"].([]byte)
+	if len(signature) == 0 {
+		return verificationError("packed attestation signature is missing")
+	}
+
+	chain, _ := statement["x5c"].([]interface{})
+	if len(chain) == 0 {
+		if int(alg) != credentialAlg {
+			return verificationError("self attestation algorithm does not match the credential")
+		}
+		if err := verifySignature(credentialKey, credentialAlg, signed, signature); err != nil {
+			return verificationError("self attestation: %v", err)
+		}
+		return nil
+	}
+
+	der, _ := chain[0].([]byte)
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return verificationError("invalid attestation certificate")
+	}
+	if err := verifySignature(certificate.PublicKey, int(alg), signed, signature); err != nil {
+		return verificationError("attestation: %v", err)
+	}
+	return nil
+}
+
+func verificationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrVerification, fmt.Sprintf(format, args...))
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decode accepts base64url with or without padding, as clients differ
+func decode(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
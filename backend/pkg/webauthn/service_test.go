@@ -0,0 +1,333 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// cborPair is a map entry; maps are encoded in the order given
+type cborPair struct {
+	key, value interface{}
+}
+
+// encodeCBOR encodes the subset of CBOR authenticators produce
+func encodeCBOR(item interface{}) []byte {
+	switch v := item.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []cborPair:
+		out := cborHead(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair.key)...)
+			out = append(out, encodeCBOR(pair.value)...)
+		}
+		return out
+	default:
+		panic("unsupported CBOR value")
+	}
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+}
+
+// softAuthenticator is a software passkey holding one ES256 credential
+type softAuthenticator struct {
+	t         *testing.T
+	key       *ecdsa.PrivateKey
+	id        []byte
+	rpID      string
+	flags     byte
+	signCount uint32
+	// attestationKey, when set, signs packed attestations in place of the
+	// credential key
+	attestationKey *ecdsa.PrivateKey
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{t: t, key: key, id: id, rpID: testRPID, flags: flagUserPresent | flagUserVerified}
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	return encodeCBOR([]cborPair{
+		{coseKeyType, coseKeyTypeEC2},
+		{coseKeyAlgorithm, AlgES256},
+		{coseKeyCurve, coseCurveP256},
+		{coseKeyX, a.key.X.FillBytes(make([]byte, 32))},
+		{coseKeyY, a.key.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) sign(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return signature
+}
+
+// create answers CreationOptions with the given attestation format, "none"
+// or self-attested "packed"
+func (a *softAuthenticator) create(challenge, origin, format string) *CredentialCreation {
+	clientDataJSON := clientDataFor("webauthn.create", challenge, origin)
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, a.coseKey()...)
+	authData := a.authenticatorData(a.flags|flagAttestedData, attested)
+
+	statement := []cborPair{}
+	if format == "packed" {
+		key := a.key
+		if a.attestationKey != nil {
+			key = a.attestationKey
+		}
+		statement = []cborPair{{"alg", AlgES256}, {"sig", a.sign(key, authData, clientDataJSON)}}
+	}
+	attestation := encodeCBOR([]cborPair{{"fmt", format}, {"attStmt", statement}, {"authData", authData}})
+
+	return &CredentialCreation{
+		ID:    encode(a.id),
+		RawID: encode(a.id),
+		Type:  "public-key",
+		Response: AttestationResponse{
+			ClientDataJSON:    encode(clientDataJSON),
+			AttestationObject: encode(attestation),
+		},
+	}
+}
+
+// get answers RequestOptions, counting the signature
+func (a *softAuthenticator) get(challenge, origin string) *CredentialAssertion {
+	a.signCount++
+	clientDataJSON := clientDataFor("webauthn.get", challenge, origin)
+	authData := a.authenticatorData(a.flags, nil)
+
+	return &CredentialAssertion{
+		ID:    encode(a.id),
+		RawID: encode(a.id),
+		Type:  "public-key",
+		Response: AssertionResponse{
+			ClientDataJSON:    encode(clientDataJSON),
+			AuthenticatorData: encode(authData),
+			Signature:         encode(a.sign(a.key, authData, clientDataJSON)),
+		},
+	}
+}
+
+func clientDataFor(ceremony, challenge, origin string) []byte {
+	data, _ := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	return data
+}
+
+func newTestService(t *testing.T) *Service {
+	s := NewService(Config{RPID: testRPID, RPName: "Test", Origins: []string{testOrigin}})
+	if err := s.Check(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func challenge(t *testing.T, s *Service) string {
+	challenge, err := s.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
+
+// register enrols the authenticator's credential
+func register(t *testing.T, s *Service, a *softAuthenticator) *Credential {
+	t.Helper()
+	c := challenge(t, s)
+	credential, err := s.VerifyRegistration(c, a.create(c, testOrigin, "none"))
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return credential
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+
+	credential := register(t, s, a)
+	if credential.ID != encode(a.id) || credential.Algorithm != AlgES256 || credential.SignCount != 0 {
+		t.Fatalf("credential = %+v", credential)
+	}
+
+	signCount := credential.SignCount
+	for i := 0; i < 2; i++ {
+		c := challenge(t, s)
+		assertion := a.get(c, testOrigin)
+		if got, err := s.Challenge(assertion.Response.ClientDataJSON); err != nil || got != c {
+			t.Fatalf("Challenge = %q, %v", got, err)
+		}
+
+		count, err := s.VerifyAssertion(c, assertion, credential.PublicKey, signCount)
+		if err != nil {
+			t.Fatalf("VerifyAssertion %d: %v", i+1, err)
+		}
+		if count != a.signCount {
+			t.Errorf("sign count = %d, want %d", count, a.signCount)
+		}
+		signCount = count
+	}
+}
+
+func TestPackedSelfAttestation(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+
+	c := challenge(t, s)
+	if _, err := s.VerifyRegistration(c, a.create(c, testOrigin, "packed")); err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+
+	// A self attestation signed with another key is refused
+	a.attestationKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c = challenge(t, s)
+	if _, err := s.VerifyRegistration(c, a.create(c, testOrigin, "packed")); !errors.Is(err, ErrVerification) {
+		t.Fatalf("forged attestation error = %v, want %v", err, ErrVerification)
+	}
+}
+
+func TestRegistrationIsRejected(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name   string
+		create func(a *softAuthenticator, c string) *CredentialCreation
+	}{
+		{"other challenge", func(a *softAuthenticator, c string) *CredentialCreation {
+			return a.create(challenge(t, s), testOrigin, "none")
+		}},
+		{"other origin", func(a *softAuthenticator, c string) *CredentialCreation {
+			return a.create(c, "https://evil.example.net", "none")
+		}},
+		{"other relying party", func(a *softAuthenticator, c string) *CredentialCreation {
+			a.rpID = "example.net"
+			return a.create(c, testOrigin, "none")
+		}},
+		{"without user verification", func(a *softAuthenticator, c string) *CredentialCreation {
+			a.flags = flagUserPresent
+			return a.create(c, testOrigin, "none")
+		}},
+		{"mismatched credential ID", func(a *softAuthenticator, c string) *CredentialCreation {
+			creation := a.create(c, testOrigin, "none")
+			creation.ID = encode([]byte("another credential"))
+			return creation
+		}},
+		{"unverified attestation format", func(a *softAuthenticator, c string) *CredentialCreation {
+			return a.create(c, testOrigin, "fido-u2f")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := challenge(t, s)
+			if _, err := s.VerifyRegistration(c, tt.create(newSoftAuthenticator(t), c)); !errors.Is(err, ErrVerification) {
+				t.Errorf("VerifyRegistration error = %v, want %v", err, ErrVerification)
+			}
+		})
+	}
+}
+
+func TestAssertionIsRejected(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name string
+		get  func(a *softAuthenticator, c string) *CredentialAssertion
+		want error
+	}{
+		{"other challenge", func(a *softAuthenticator, c string) *CredentialAssertion {
+			return a.get(challenge(t, s), testOrigin)
+		}, ErrVerification},
+		{"other origin", func(a *softAuthenticator, c string) *CredentialAssertion {
+			return a.get(c, "https://app.example.com:8443")
+		}, ErrVerification},
+		{"other relying party", func(a *softAuthenticator, c string) *CredentialAssertion {
+			a.rpID = "app.example.com"
+			return a.get(c, testOrigin)
+		}, ErrVerification},
+		{"without user verification", func(a *softAuthenticator, c string) *CredentialAssertion {
+			a.flags = flagUserPresent
+			return a.get(c, testOrigin)
+		}, ErrVerification},
+		{"registration response", func(a *softAuthenticator, c string) *CredentialAssertion {
+			assertion := a.get(c, testOrigin)
+			assertion.Response.ClientDataJSON = encode(clientDataFor("webauthn.create", c, testOrigin))
+			return assertion
+		}, ErrVerification},
+		{"tampered authenticator data", func(a *softAuthenticator, c string) *CredentialAssertion {
+			assertion := a.get(c, testOrigin)
+			a.signCount += 10
+			assertion.Response.AuthenticatorData = encode(a.authenticatorData(a.flags, nil))
+			return assertion
+		}, ErrVerification},
+		{"signed by another key", func(a *softAuthenticator, c string) *CredentialAssertion {
+			a.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			return a.get(c, testOrigin)
+		}, ErrVerification},
+		{"counter went backwards", func(a *softAuthenticator, c string) *CredentialAssertion {
+			a.signCount = 0
+			return a.get(c, testOrigin)
+		}, ErrClonedAuthenticator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newSoftAuthenticator(t)
+			credential := register(t, s, a)
+			a.signCount = 5
+
+			c := challenge(t, s)
+			_, err := s.VerifyAssertion(c, tt.get(a, c), credential.PublicKey, 5)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyAssertion error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package webauthn
+
+// The option and response types follow the JSON forms of the WebAuthn Level 3
+// API (PublicKeyCredential.parseCreationOptionsFromJSON and toJSON), in which
+// binary values are unpadded base64url strings.
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	// ID is the user handle, which authenticators store with discoverable
+	// credentials. It must not contain personal information.
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are passed to navigator.credentials.create() as publicKey
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are passed to navigator.credentials.get() as publicKey. An
+// empty AllowCredentials lets the user pick any passkey for the site.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CredentialCreation is the JSON form of the credential
+// navigator.credentials.create() returns
+type CredentialCreation struct {
+	ID       string              `json:"id" binding:"required"`
+	RawID    string              `json:"rawId"`
+	Type     string              `json:"type" binding:"required"`
+	Response AttestationResponse `json:"response" binding:"required"`
+}
+
+type AttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+// CredentialAssertion is the JSON form of the credential
+// navigator.credentials.get() returns
+type CredentialAssertion struct {
+	ID       string            `json:"id" binding:"required"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type" binding:"required"`
+	Response AssertionResponse `json:"response" binding:"required"`
+}
+
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// Credential is a registered credential, to be stored with its user
+type Credential struct {
+	// ID is the base64url credential ID
+	ID        string
+	PublicKey []byte
+	Algorithm int
+	SignCount uint32
+	AAGUID    string
+	// BackupEligible is set for passkeys that sync between devices
+	BackupEligible bool
+	Transports     []string
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// Set during registration
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}